@@ -1,44 +1,151 @@
 package cache
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"sync"
 	"time"
 
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/metrics"
 	"github.com/go-redis/redis/v8"
 )
 
+//ErrListEmpty is returned when moving an element out of an empty list
+var ErrListEmpty = errors.New("cache: list is empty")
+
+//IsNotFound tells whether err means the key does not exist
+func IsNotFound(err error) bool {
+	return errors.Is(err, redis.Nil)
+}
+
+//Health gives the health check of c, failing while it is not reachable
+func Health(c Cache) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if !c.Alive(ctx) {
+			return errors.New("cache is not reachable")
+		}
+		return nil
+	}
+}
+
 type Cache interface {
 	Set(ctx context.Context, key string, value interface{}) error
 	Get(ctx context.Context, key string, here interface{}) error
 	Del(ctx context.Context, key string) error
 	Alive(ctx context.Context) bool
+	//CompareAndSet stores value under key only when the value stored is still old, as Get gives it into a
+	//json.RawMessage, telling whether it was stored. A nil old stores value only while key is missing
+	CompareAndSet(ctx context.Context, key string, old json.RawMessage, value interface{}) (bool, error)
+	//Tx applies every operation atomically, either all of them are stored or none
+	Tx(ctx context.Context, ops ...Op) error
+	//ListMove takes the oldest element of src and pushes it into dst atomically, returning it.
+	//ErrListEmpty is returned when src has no elements
+	ListMove(ctx context.Context, src, dst string) (string, error)
+	//ListRemove removes every occurrence of value from list
+	ListRemove(ctx context.Context, list, value string) error
+	//ListRange gives the elements of list between start and stop, newest first. Negative indexes count from the end
+	ListRange(ctx context.Context, list string, start, stop int64) ([]string, error)
+	//IncrBy adds delta to the counter under key atomically, giving the new value.
+	//Missing counters start at zero and counters never expire
+	IncrBy(ctx context.Context, key string, delta int64) (int64, error)
+	//Scan calls fn with every key matching the glob pattern, stopping at the first error fn gives.
+	//Keys changed while scanning may be missed or given more than once
+	Scan(ctx context.Context, match string, fn func(key string) error) error
+}
+
+//scanCount is how many keys are asked for on every step of a scan
+const scanCount = 100
+
+type opKind int
+
+const (
+	opSet opKind = iota
+	opDel
+	opPush
+	opTrim
+)
+
+//Op is a single write performed inside a Tx
+type Op struct {
+	kind  opKind
+	key   string
+	value interface{}
+}
+
+//SetOp stores value under key, encoded the same way Set does
+func SetOp(key string, value interface{}) Op {
+	return Op{kind: opSet, key: key, value: value}
+}
+
+//DelOp removes key
+func DelOp(key string) Op {
+	return Op{kind: opDel, key: key}
+}
+
+//PushOp adds value as the newest element of list
+func PushOp(list, value string) Op {
+	return Op{kind: opPush, key: list, value: value}
+}
+
+//TrimOp keeps only the newest max elements of list
+func TrimOp(list string, max int64) Op {
+	return Op{kind: opTrim, key: list, value: max}
 }
 
 type redisCache struct {
-	client *redis.Client
-	ttl    time.Duration
-	logger logger.Logger
+	client   redis.UniversalClient
+	ttl      time.Duration
+	logger   logger.Logger
+	encoding encoding
+}
+
+//Option configures the Redis cache
+type Option func(*redisCache)
+
+//WithCodec stores values with the codec, JSON by default
+func WithCodec(codec Codec) Option {
+	return func(c *redisCache) {
+		c.encoding.codec = codec
+	}
 }
 
-func NewRedisCache(logger logger.Logger, ttl time.Duration, client *redis.Client) Cache {
-	return &redisCache{
-		client: client,
-		ttl:    ttl,
-		logger: logger,
+//WithCompression compresses the values longer than threshold bytes once encoded, none is by default
+func WithCompression(compression Compression, threshold int) Option {
+	return func(c *redisCache) {
+		c.encoding.compression = compression
+		c.encoding.threshold = threshold
 	}
 }
 
+//NewRedisCache gives a Cache stored in Redis, either a single node, a Sentinel failover or a Cluster client.
+//On a Cluster, Tx and ListMove give ErrCrossSlot for keys in different hash slots rather than changing them apart
+func NewRedisCache(logger logger.Logger, ttl time.Duration, client redis.UniversalClient, opts ...Option) Cache {
+	c := &redisCache{
+		client:   client,
+		ttl:      ttl,
+		logger:   logger,
+		encoding: encoding{codec: JSON},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
 func (c *redisCache) Set(ctx context.Context, key string, value interface{}) error {
 	log := c.logger.WithField("key", key).WithField("value", value)
-	b, err := json.Marshal(value)
+	b, err := c.encoding.encode(value)
 	if err != nil {
 		c.logger.WithError(err).Error(ctx, "cache_error")
 		return err
 	}
 	log.Info(ctx, "Saving Value to Key")
+	start := time.Now()
 	err = c.client.Set(context.Background(), key, string(b), c.ttl).Err()
+	metrics.ObserveCacheOperation("set", start, err)
 	if err != nil {
 		log.WithError(err).Error(ctx, "cache_error")
 		return err
@@ -50,12 +157,14 @@ func (c *redisCache) Get(ctx context.Context, key string, here interface{}) erro
 	log := c.logger.WithField("key", key)
 
 	log.Info(ctx, "Retrieving Key")
+	start := time.Now()
 	val, err := c.client.Get(context.Background(), key).Result()
+	metrics.ObserveCacheOperation("get", start, ignoreNil(err))
 	if err != nil {
 		log.WithError(err).Error(ctx, "cache_error")
 		return err
 	}
-	err = json.Unmarshal([]byte(val), here)
+	err = c.encoding.decode([]byte(val), here)
 	if err != nil {
 		log.WithError(err).Error(ctx, "cache_error")
 		return err
@@ -67,7 +176,9 @@ func (c *redisCache) Del(ctx context.Context, key string) error {
 	log := c.logger.WithField("key", key)
 
 	log.WithField("key", key).Info(ctx, "Deleting Key")
+	start := time.Now()
 	numErased, err := c.client.Del(context.Background(), key).Result()
+	metrics.ObserveCacheOperation("del", start, err)
 	if err != nil {
 		log.WithError(err).Error(ctx, "cache_error")
 		return err
@@ -82,10 +193,223 @@ func (c *redisCache) Del(ctx context.Context, key string) error {
 
 func (c *redisCache) Alive(ctx context.Context) bool {
 	c.logger.Info(ctx, "Pinging Redis")
-	err := c.client.Ping(context.Background()).Err()
+	start := time.Now()
+	//bound by ctx, so a check timing out doesn't leave the ping running
+	err := c.client.Ping(ctx).Err()
+	metrics.ObserveCacheOperation("ping", start, err)
 	if err != nil {
 		c.logger.WithError(err).Error(ctx, "cache not connected")
 		return false
 	}
 	return true
 }
+
+//CompareAndSet watches the key, so the value is not stored when it changes between being compared and stored
+func (c *redisCache) CompareAndSet(ctx context.Context, key string, old json.RawMessage, value interface{}) (bool, error) {
+	log := c.logger.WithField("key", key).WithField("value", value)
+	b, err := c.encoding.encode(value)
+	if err != nil {
+		c.logger.WithError(err).Error(ctx, "cache_error")
+		return false, err
+	}
+	log.Info(ctx, "Saving Value to Key if unchanged")
+	start := time.Now()
+	stored := false
+	err = c.client.Watch(context.Background(), func(tx *redis.Tx) error {
+		val, err := tx.Get(context.Background(), key).Result()
+		switch {
+		case err == redis.Nil && old == nil:
+		case err != nil:
+			return err
+		case old == nil:
+			return nil
+		default:
+			current := json.RawMessage{}
+			if err := c.encoding.decode([]byte(val), &current); err != nil {
+				return err
+			}
+			if !bytes.Equal(current, old) {
+				return nil
+			}
+		}
+		_, err = tx.TxPipelined(context.Background(), func(pipe redis.Pipeliner) error {
+			pipe.Set(context.Background(), key, string(b), c.ttl)
+			return nil
+		})
+		stored = err == nil
+		return err
+	}, key)
+	if err == redis.Nil || err == redis.TxFailedErr {
+		//missing or changed meanwhile
+		err = nil
+	}
+	metrics.ObserveCacheOperation("compare_and_set", start, err)
+	if err != nil {
+		log.WithError(err).Error(ctx, "cache_error")
+		return false, err
+	}
+	return stored, nil
+}
+
+//cluster tells whether the client is of a Redis Cluster, which only changes keys of a hash slot atomically
+func (c *redisCache) cluster() bool {
+	_, ok := c.client.(*redis.ClusterClient)
+	return ok
+}
+
+//ignoreNil hides the missing key error, as it is not a failure of the cache itself
+func ignoreNil(err error) error {
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+func (c *redisCache) Tx(ctx context.Context, ops ...Op) error {
+	log := c.logger.WithField("operations", len(ops))
+
+	if c.cluster() && len(ops) > 0 {
+		keys := make([]string, len(ops))
+		for idx, op := range ops {
+			keys[idx] = op.key
+		}
+		if !sameSlot(keys...) {
+			log.WithError(ErrCrossSlot).Error(ctx, "cache_error")
+			return ErrCrossSlot
+		}
+	}
+
+	encoded := make([]interface{}, len(ops))
+	for idx, op := range ops {
+		if op.kind != opSet {
+			continue
+		}
+		b, err := c.encoding.encode(op.value)
+		if err != nil {
+			log.WithField("key", op.key).WithError(err).Error(ctx, "cache_error")
+			return err
+		}
+		encoded[idx] = string(b)
+	}
+
+	log.Info(ctx, "Applying operations in transaction")
+	start := time.Now()
+	_, err := c.client.TxPipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		for idx, op := range ops {
+			switch op.kind {
+			case opSet:
+				pipe.Set(context.Background(), op.key, encoded[idx], c.ttl)
+			case opDel:
+				pipe.Del(context.Background(), op.key)
+			case opPush:
+				pipe.LPush(context.Background(), op.key, op.value)
+			case opTrim:
+				pipe.LTrim(context.Background(), op.key, 0, op.value.(int64)-1)
+			}
+		}
+		return nil
+	})
+	metrics.ObserveCacheOperation("tx", start, err)
+	if err != nil {
+		log.WithError(err).Error(ctx, "cache_error")
+		return err
+	}
+	return nil
+}
+
+func (c *redisCache) ListMove(ctx context.Context, src, dst string) (string, error) {
+	log := c.logger.WithField("src", src).WithField("dst", dst)
+
+	if c.cluster() && !sameSlot(src, dst) {
+		log.WithError(ErrCrossSlot).Error(ctx, "cache_error")
+		return "", ErrCrossSlot
+	}
+
+	start := time.Now()
+	val, err := c.client.RPopLPush(context.Background(), src, dst).Result()
+	metrics.ObserveCacheOperation("list_move", start, ignoreNil(err))
+	if err == redis.Nil {
+		return "", ErrListEmpty
+	}
+	if err != nil {
+		log.WithError(err).Error(ctx, "cache_error")
+		return "", err
+	}
+	return val, nil
+}
+
+func (c *redisCache) ListRemove(ctx context.Context, list, value string) error {
+	log := c.logger.WithField("list", list).WithField("value", value)
+
+	start := time.Now()
+	err := c.client.LRem(context.Background(), list, 0, value).Err()
+	metrics.ObserveCacheOperation("list_remove", start, err)
+	if err != nil {
+		log.WithError(err).Error(ctx, "cache_error")
+		return err
+	}
+	return nil
+}
+
+func (c *redisCache) ListRange(ctx context.Context, list string, start, stop int64) ([]string, error) {
+	log := c.logger.WithField("list", list)
+
+	begin := time.Now()
+	vals, err := c.client.LRange(context.Background(), list, start, stop).Result()
+	metrics.ObserveCacheOperation("list_range", begin, err)
+	if err != nil {
+		log.WithError(err).Error(ctx, "cache_error")
+		return nil, err
+	}
+	return vals, nil
+}
+
+func (c *redisCache) Scan(ctx context.Context, match string, fn func(key string) error) error {
+	log := c.logger.WithField("match", match)
+
+	log.Info(ctx, "Scanning Keys")
+	start := time.Now()
+	var err error
+	if cluster, ok := c.client.(*redis.ClusterClient); ok {
+		//every master holds some of the keys
+		var mu sync.Mutex
+		err = cluster.ForEachMaster(context.Background(), func(ctx context.Context, node *redis.Client) error {
+			return scanNode(node, match, func(key string) error {
+				mu.Lock()
+				defer mu.Unlock()
+				return fn(key)
+			})
+		})
+	} else {
+		err = scanNode(c.client, match, fn)
+	}
+	metrics.ObserveCacheOperation("scan", start, err)
+	if err != nil {
+		log.WithError(err).Error(ctx, "cache_error")
+		return err
+	}
+	return nil
+}
+
+func scanNode(client redis.Cmdable, match string, fn func(key string) error) error {
+	iter := client.Scan(context.Background(), 0, match, scanCount).Iterator()
+	for iter.Next(context.Background()) {
+		if err := fn(iter.Val()); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+func (c *redisCache) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	log := c.logger.WithField("key", key).WithField("delta", delta)
+
+	start := time.Now()
+	val, err := c.client.IncrBy(context.Background(), key, delta).Result()
+	metrics.ObserveCacheOperation("incr_by", start, err)
+	if err != nil {
+		log.WithError(err).Error(ctx, "cache_error")
+		return 0, err
+	}
+	return val, nil
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/go-redis/redis/v8"
 	"github.com/go-redis/redismock/v8"
 )
 
@@ -123,3 +127,317 @@ func TestPingError(t *testing.T) {
 		t.Fatalf("true was not expected")
 	}
 }
+
+func TestHealthIsBoundByTheContextOfTheCheck(t *testing.T) {
+	//a server accepting connections without ever answering
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	db := redis.NewClient(&redis.Options{Addr: l.Addr().String(), ReadTimeout: time.Minute, MaxRetries: -1})
+	defer db.Close()
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := cache.Health(c)(ctx); err == nil {
+		t.Fatalf("Error was expected")
+	}
+	if took := time.Since(start); took > 5*time.Second {
+		t.Fatalf("The check was expected to stop with its context, it took %v", took)
+	}
+}
+
+func TestTxOK(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	b, _ := json.Marshal("test")
+	mock.ExpectTxPipeline()
+	mock.ExpectSet("testKey", string(b), 0).SetVal("OK")
+	mock.ExpectDel("otherKey").SetVal(1)
+	mock.ExpectLPush("testList", "someID").SetVal(1)
+	mock.ExpectLTrim("testList", 0, 9).SetVal("OK")
+	mock.ExpectTxPipelineExec()
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	err := c.Tx(context.TODO(),
+		cache.SetOp("testKey", "test"),
+		cache.DelOp("otherKey"),
+		cache.PushOp("testList", "someID"),
+		cache.TrimOp("testList", 10),
+	)
+	if err != nil {
+		t.Fatalf("Error was not expected: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Expectations not met: %s", err)
+	}
+}
+
+func TestTxUnmarshallError(t *testing.T) {
+	db, _ := redismock.NewClientMock()
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	if c.Tx(context.TODO(), cache.SetOp("testKey", make(chan int))) == nil {
+		t.Fatalf("Error was expected")
+	}
+}
+
+func TestListMoveOK(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	mock.ExpectRPopLPush("src", "dst").SetVal("someID")
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	val, err := c.ListMove(context.TODO(), "src", "dst")
+	if err != nil || val != "someID" {
+		t.Fatalf("Unexpected result %s, %v", val, err)
+	}
+}
+
+func TestListMoveEmpty(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	mock.ExpectRPopLPush("src", "dst").RedisNil()
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	if _, err := c.ListMove(context.TODO(), "src", "dst"); err != cache.ErrListEmpty {
+		t.Fatalf("ErrListEmpty was expected, got %v", err)
+	}
+}
+
+func TestListRemoveError(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	mock.ExpectLRem("list", 0, "someID").SetErr(fmt.Errorf("cache Error"))
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	if c.ListRemove(context.TODO(), "list", "someID") == nil {
+		t.Fatalf("Error was expected")
+	}
+}
+
+func TestListRangeOK(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	mock.ExpectLRange("list", 0, -1).SetVal([]string{"b", "a"})
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	vals, err := c.ListRange(context.TODO(), "list", 0, -1)
+	if err != nil || len(vals) != 2 {
+		t.Fatalf("Unexpected result %v, %v", vals, err)
+	}
+}
+
+func TestIncrByOK(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	mock.ExpectIncrBy("counter", 3).SetVal(5)
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	val, err := c.IncrBy(context.TODO(), "counter", 3)
+	if err != nil || val != 5 {
+		t.Fatalf("Unexpected result %v, %v", val, err)
+	}
+}
+
+func TestIncrByError(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	mock.ExpectIncrBy("counter", 3).SetErr(fmt.Errorf("cache Error"))
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	if _, err := c.IncrBy(context.TODO(), "counter", 3); err == nil {
+		t.Fatalf("Error was expected")
+	}
+}
+
+func TestScanOK(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	mock.ExpectScan(0, "cart:*", 100).SetVal([]string{"cart:1", "cart:2"}, 0)
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	keys := []string{}
+	err := c.Scan(context.TODO(), "cart:*", func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("Unexpected result %v, %v", keys, err)
+	}
+}
+
+func TestScanError(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	mock.ExpectScan(0, "cart:*", 100).SetErr(fmt.Errorf("cache Error"))
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	if err := c.Scan(context.TODO(), "cart:*", func(key string) error { return nil }); err == nil {
+		t.Fatalf("Error was expected")
+	}
+}
+
+func TestCompareAndSetOK(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	old, _ := json.Marshal("test")
+	b, _ := json.Marshal("new")
+	mock.ExpectWatch("testKey")
+	mock.ExpectGet("testKey").SetVal(string(old))
+	mock.ExpectTxPipeline()
+	mock.ExpectSet("testKey", string(b), 0).SetVal("OK")
+	mock.ExpectTxPipelineExec()
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	if stored, err := c.CompareAndSet(context.TODO(), "testKey", old, "new"); err != nil || !stored {
+		t.Fatalf("The value was expected to be stored, got %v, %v", stored, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Expectations not met: %s", err)
+	}
+}
+
+func TestCompareAndSetChanged(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	old, _ := json.Marshal("test")
+	mock.ExpectWatch("testKey")
+	mock.ExpectGet("testKey").SetVal(`"changed"`)
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	if stored, err := c.CompareAndSet(context.TODO(), "testKey", old, "new"); err != nil || stored {
+		t.Fatalf("The value was not expected to be stored, got %v, %v", stored, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Expectations not met: %s", err)
+	}
+}
+
+func TestCompareAndSetMissing(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	mock.ExpectWatch("testKey")
+	mock.ExpectGet("testKey").RedisNil()
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	if stored, err := c.CompareAndSet(context.TODO(), "testKey", json.RawMessage(`"test"`), "new"); err != nil || stored {
+		t.Fatalf("The value was not expected to be stored, got %v, %v", stored, err)
+	}
+}
+
+func TestCompareAndSetMissingWithoutOld(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	b, _ := json.Marshal("new")
+	mock.ExpectWatch("testKey")
+	mock.ExpectGet("testKey").RedisNil()
+	mock.ExpectTxPipeline()
+	mock.ExpectSet("testKey", string(b), 0).SetVal("OK")
+	mock.ExpectTxPipelineExec()
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	if stored, err := c.CompareAndSet(context.TODO(), "testKey", nil, "new"); err != nil || !stored {
+		t.Fatalf("The value was expected to be stored, got %v, %v", stored, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Expectations not met: %s", err)
+	}
+}
+
+func TestCompareAndSetExistingWithoutOld(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	mock.ExpectWatch("testKey")
+	mock.ExpectGet("testKey").SetVal(`"test"`)
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	if stored, err := c.CompareAndSet(context.TODO(), "testKey", nil, "new"); err != nil || stored {
+		t.Fatalf("The value was not expected to be stored, got %v, %v", stored, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Expectations not met: %s", err)
+	}
+}
+
+func TestListMoveOnCluster(t *testing.T) {
+	db, mock := redismock.NewClusterMock()
+	mock.ExpectRPopLPush("{tenant:acme:}outbox", "{tenant:acme:}outbox:processing").SetVal("1")
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	val, err := c.ListMove(context.TODO(), "{tenant:acme:}outbox", "{tenant:acme:}outbox:processing")
+	if err != nil || val != "1" {
+		t.Fatalf("Unexpected result %q, %v", val, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unexpected commands: %v", err)
+	}
+}
+
+func TestListMoveOnClusterAcrossSlots(t *testing.T) {
+	db, mock := redismock.NewClusterMock()
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	if _, err := c.ListMove(context.TODO(), "outbox", "outbox:processing"); err != cache.ErrCrossSlot {
+		t.Fatalf("Cross slot error was expected, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("No command was expected: %v", err)
+	}
+}
+
+func TestTxOnCluster(t *testing.T) {
+	db, _ := redismock.NewClusterMock()
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	//the Cluster mock can't expect transactions, so reaching it with MULTI is what's checked
+	err := c.Tx(context.TODO(),
+		cache.SetOp("tenant:acme:cart:{3}:1", "test"),
+		cache.PushOp("tenant:acme:outbox:{3}", "someID"),
+	)
+	if err == nil || !strings.Contains(err.Error(), "[multi]") {
+		t.Fatalf("The operations were expected in a transaction, got %v", err)
+	}
+}
+
+func TestTxOnClusterAcrossSlots(t *testing.T) {
+	db, mock := redismock.NewClusterMock()
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	err := c.Tx(context.TODO(),
+		cache.SetOp("cart:1", "test"),
+		cache.PushOp("outbox", "someID"),
+	)
+	if err != cache.ErrCrossSlot {
+		t.Fatalf("Cross slot error was expected, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("No command was expected: %v", err)
+	}
+}
+
+func TestPartitionsSpreadOverSlots(t *testing.T) {
+	db, _ := redismock.NewClusterMock()
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	//the keys of a partition are changed together, those of different partitions are in different slots
+	tag := cache.PartitionTag(cache.Partition("cart-1"))
+	err := c.Tx(context.TODO(), cache.SetOp("cart:"+tag+":cart-1", "test"), cache.PushOp("outbox:"+tag, "someID"))
+	if err == nil || !strings.Contains(err.Error(), "[multi]") {
+		t.Fatalf("The operations were expected in a transaction, got %v", err)
+	}
+	if err := c.Tx(context.TODO(), cache.SetOp("outbox:"+cache.PartitionTag(0), "a"), cache.SetOp("outbox:"+cache.PartitionTag(1), "b")); err != cache.ErrCrossSlot {
+		t.Fatalf("Cross slot error was expected, got %v", err)
+	}
+	if p := cache.Partition("cart-1"); p < 0 || p >= cache.Partitions || p != cache.Partition("cart-1") {
+		t.Fatalf("Wrong partition %d", p)
+	}
+}
+
+func TestTxOnClusterEmptyHashTag(t *testing.T) {
+	db, _ := redismock.NewClusterMock()
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	//an empty hash tag hashes the whole key
+	if err := c.Tx(context.TODO(), cache.SetOp("{}cart:1", "test"), cache.PushOp("{}outbox", "someID")); err != cache.ErrCrossSlot {
+		t.Fatalf("Cross slot error was expected, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"crypto/tls"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//RedisOptions tell how to connect to Redis, whichever its topology
+type RedisOptions struct {
+	//Addrs are the Redis server, the Sentinel nodes when MasterName is set, or the seed nodes of the Cluster
+	Addrs []string
+	//MasterName is the master monitored by the Sentinel nodes, connecting through them when set
+	MasterName string
+	//Cluster connects to a Redis Cluster
+	Cluster bool
+	//Username and Password authenticate with Redis, Username being the ACL user
+	Username string
+	Password string
+	//SentinelPassword authenticates with the Sentinel nodes
+	SentinelPassword string
+	//DB is the database selected after connecting, Redis Cluster only has 0
+	DB int
+	//TLS connects over TLS, TLSServerName overriding the name the certificate is checked against
+	TLS           bool
+	TLSServerName string
+	//PoolSize is how many connections are kept to every node, the go-redis default when zero
+	PoolSize     int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+//NewRedisClient gives the client of the topology told by the options: a Sentinel failover client when
+//MasterName is set, a Cluster client when Cluster is, and a single node client otherwise, on localhost:6379
+//when no address is given
+func NewRedisClient(opts RedisOptions) (redis.UniversalClient, error) {
+	if (opts.Cluster || opts.MasterName != "") && len(opts.Addrs) == 0 {
+		return nil, errors.New("redis: sentinel and cluster need at least an address")
+	}
+	if opts.Cluster && opts.MasterName != "" {
+		return nil, errors.New("redis: sentinel and cluster can't be used together")
+	}
+	if opts.Cluster && opts.DB != 0 {
+		return nil, errors.New("redis: cluster only has database 0")
+	}
+	if !opts.Cluster && opts.MasterName == "" && len(opts.Addrs) > 1 {
+		return nil, errors.New("redis: several addresses need either sentinel or cluster")
+	}
+
+	universal := &redis.UniversalOptions{
+		Addrs:            opts.Addrs,
+		MasterName:       opts.MasterName,
+		Username:         opts.Username,
+		Password:         opts.Password,
+		SentinelPassword: opts.SentinelPassword,
+		DB:               opts.DB,
+		PoolSize:         opts.PoolSize,
+		DialTimeout:      opts.DialTimeout,
+		ReadTimeout:      opts.ReadTimeout,
+		WriteTimeout:     opts.WriteTimeout,
+	}
+	if opts.TLS {
+		universal.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ServerName: opts.TLSServerName,
+		}
+	}
+
+	switch {
+	case opts.MasterName != "":
+		return redis.NewFailoverClient(universal.Failover()), nil
+	case opts.Cluster:
+		return redis.NewClusterClient(universal.Cluster()), nil
+	default:
+		return redis.NewClient(universal.Simple()), nil
+	}
+}
@@ -0,0 +1,45 @@
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestNewRedisClientTopologies(t *testing.T) {
+	c, err := cache.NewRedisClient(cache.RedisOptions{Addrs: []string{"redis:6379"}, DB: 2, TLS: true})
+	if _, ok := c.(*redis.Client); err != nil || !ok {
+		t.Fatalf("A single node client was expected, got %T, %v", c, err)
+	}
+	if opts := c.(*redis.Client).Options(); opts.Addr != "redis:6379" || opts.DB != 2 || opts.TLSConfig == nil {
+		t.Fatalf("Unexpected options %+v", opts)
+	}
+
+	c, err = cache.NewRedisClient(cache.RedisOptions{Addrs: []string{"sentinel-1:26379", "sentinel-2:26379"}, MasterName: "carts"})
+	if _, ok := c.(*redis.Client); err != nil || !ok {
+		t.Fatalf("A failover client was expected, got %T, %v", c, err)
+	}
+
+	c, err = cache.NewRedisClient(cache.RedisOptions{Addrs: []string{"node-1:6379"}, Cluster: true, PoolSize: 5})
+	if _, ok := c.(*redis.ClusterClient); err != nil || !ok {
+		t.Fatalf("A cluster client was expected, got %T, %v", c, err)
+	}
+	if opts := c.(*redis.ClusterClient).Options(); opts.PoolSize != 5 {
+		t.Fatalf("Unexpected pool size %d", opts.PoolSize)
+	}
+}
+
+func TestNewRedisClientInvalidOptions(t *testing.T) {
+	for name, opts := range map[string]cache.RedisOptions{
+		"sentinel and cluster":   {Addrs: []string{"node-1:6379"}, MasterName: "carts", Cluster: true},
+		"database on cluster":    {Addrs: []string{"node-1:6379"}, Cluster: true, DB: 1},
+		"cluster without nodes":  {Cluster: true},
+		"several single nodes":   {Addrs: []string{"redis-1:6379", "redis-2:6379"}},
+		"sentinel without nodes": {MasterName: "carts"},
+	} {
+		if _, err := cache.NewRedisClient(opts); err == nil {
+			t.Fatalf("Error was expected for %s", name)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/tinylib/msgp/msgp"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+//Codec turns the values stored into bytes and back
+type Codec interface {
+	//Format identifies the codec within the marker of the values it encoded
+	Format() byte
+	Marshal(value interface{}) ([]byte, error)
+	Unmarshal(b []byte, here interface{}) error
+}
+
+//Compression shrinks the encoded values
+type Compression interface {
+	//Format identifies the compression within the marker of the values it compressed
+	Format() byte
+	Compress(b []byte) ([]byte, error)
+	Decompress(b []byte) ([]byte, error)
+}
+
+var (
+	//JSON stores values as JSON, the way they were stored before codecs existed
+	JSON Codec = jsonCodec{}
+	//JSONMessagePack stores values as JSON turned into MessagePack, rather than encoding the Go values themselves,
+	//so their json tags and json.RawMessage fields keep working. Values are encoded as JSON first, so it is no faster
+	//than JSON, only smaller
+	JSONMessagePack Codec = jsonMsgpackCodec{}
+	//JSONProtobufValue stores values as JSON turned into a google.protobuf.Value, not as messages of their own, so
+	//every field is stored along with its name and type. Numbers are doubles within it, so values holding integers
+	//beyond 2^53 are refused rather than stored changed
+	JSONProtobufValue Codec = jsonProtobufValueCodec{}
+
+	//Gzip compresses values with gzip
+	Gzip Compression = gzipCompression{}
+	//Zstd compresses values with Zstandard
+	Zstd Compression = zstdCompression{}
+)
+
+//MaxDecompressedSize bounds the size of values once decompressed, so a value compressed to a few bytes can't
+//take the memory of the service when read
+const MaxDecompressedSize = 64 << 20
+
+//ErrTooLarge is given when a value decompresses to more than MaxDecompressedSize bytes
+var ErrTooLarge = errors.New("cache: decompressed value is too large")
+
+//marker starts the values stored by a codec other than JSON or compressed, followed by the format of the codec
+//and that of the compression. JSON can't start with it, so values stored bare are told apart
+const marker = 0x00
+
+const (
+	noCompression byte = iota
+	gzipFormat
+	zstdFormat
+)
+
+const (
+	jsonFormat byte = iota + 1
+	jsonMsgpackFormat
+	jsonProtobufValueFormat
+)
+
+var (
+	codecs       = map[byte]Codec{jsonFormat: JSON, jsonMsgpackFormat: JSONMessagePack, jsonProtobufValueFormat: JSONProtobufValue}
+	compressions = map[byte]Compression{gzipFormat: Gzip, zstdFormat: Zstd}
+)
+
+//encoding is how values are stored, compressing the ones longer than threshold when it has a compression.
+//Values stored in every known format are read, whichever is the one written, so formats can be changed
+//while instances using the previous one are still running
+type encoding struct {
+	codec       Codec
+	compression Compression
+	threshold   int
+}
+
+func (e encoding) encode(value interface{}) ([]byte, error) {
+	b, err := e.codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	compression := noCompression
+	if e.compression != nil && len(b) > e.threshold {
+		if b, err = e.compression.Compress(b); err != nil {
+			return nil, err
+		}
+		compression = e.compression.Format()
+	}
+	if e.codec.Format() == jsonFormat && compression == noCompression {
+		//stored bare, as instances without codecs can read it
+		return b, nil
+	}
+	return append([]byte{marker, e.codec.Format(), compression}, b...), nil
+}
+
+func (e encoding) decode(b []byte, here interface{}) error {
+	if len(b) == 0 || b[0] != marker {
+		return JSON.Unmarshal(b, here)
+	}
+	if len(b) < 3 {
+		return fmt.Errorf("cache: value of %d bytes is too short for its marker", len(b))
+	}
+	codec, ok := codecs[b[1]]
+	if !ok && e.codec.Format() == b[1] {
+		codec, ok = e.codec, true
+	}
+	if !ok {
+		return fmt.Errorf("cache: unknown codec format %d", b[1])
+	}
+	payload := b[3:]
+	if b[2] != noCompression {
+		compression, ok := compressions[b[2]]
+		if !ok && e.compression != nil && e.compression.Format() == b[2] {
+			compression, ok = e.compression, true
+		}
+		if !ok {
+			return fmt.Errorf("cache: unknown compression format %d", b[2])
+		}
+		var err error
+		if payload, err = compression.Decompress(payload); err != nil {
+			return err
+		}
+	}
+	return codec.Unmarshal(payload, here)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Format() byte {
+	return jsonFormat
+}
+
+func (jsonCodec) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (jsonCodec) Unmarshal(b []byte, here interface{}) error {
+	return json.Unmarshal(b, here)
+}
+
+type jsonMsgpackCodec struct{}
+
+func (jsonMsgpackCodec) Format() byte {
+	return jsonMsgpackFormat
+}
+
+func (jsonMsgpackCodec) Marshal(value interface{}) ([]byte, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var data interface{}
+	if err := d.Decode(&data); err != nil {
+		return nil, err
+	}
+	return appendMsgpack(nil, data)
+}
+
+func (jsonMsgpackCodec) Unmarshal(b []byte, here interface{}) error {
+	buf := bytes.Buffer{}
+	if _, err := msgp.UnmarshalAsJSON(&buf, b); err != nil {
+		return err
+	}
+	return json.Unmarshal(buf.Bytes(), here)
+}
+
+//appendMsgpack appends the JSON data as MessagePack, with the keys of objects sorted so the same value is
+//always stored the same, and whole numbers as integers, as MessagePack stores them shorter
+func appendMsgpack(b []byte, data interface{}) ([]byte, error) {
+	switch v := data.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return msgp.AppendInt64(b, i), nil
+		}
+		if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return msgp.AppendUint64(b, u), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return msgp.AppendFloat64(b, f), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = msgp.AppendMapHeader(b, uint32(len(keys)))
+		var err error
+		for _, k := range keys {
+			b = msgp.AppendString(b, k)
+			if b, err = appendMsgpack(b, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case []interface{}:
+		b = msgp.AppendArrayHeader(b, uint32(len(v)))
+		var err error
+		for _, e := range v {
+			if b, err = appendMsgpack(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	default:
+		//strings, booleans and null
+		return msgp.AppendIntf(b, v)
+	}
+}
+
+type jsonProtobufValueCodec struct{}
+
+func (jsonProtobufValueCodec) Format() byte {
+	return jsonProtobufValueFormat
+}
+
+func (jsonProtobufValueCodec) Marshal(value interface{}) ([]byte, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var data interface{}
+	if err := d.Decode(&data); err != nil {
+		return nil, err
+	}
+	if data, err = asDoubles(data); err != nil {
+		return nil, err
+	}
+	v, err := structpb.NewValue(data)
+	if err != nil {
+		return nil, err
+	}
+	//deterministic, so the same value is always stored the same
+	return proto.MarshalOptions{Deterministic: true}.Marshal(v)
+}
+
+//maxExactInteger bounds the integers a double holds exactly
+const maxExactInteger = 1 << 53
+
+//asDoubles turns the numbers of the JSON data into doubles, failing for the integers a double can't hold exactly
+func asDoubles(data interface{}) (interface{}, error) {
+	switch v := data.(type) {
+	case json.Number:
+		if !strings.ContainsAny(v.String(), ".eE") {
+			if i, err := v.Int64(); err != nil || i > maxExactInteger || i < -maxExactInteger {
+				return nil, fmt.Errorf("cache: integer %s is beyond the precision of a protobuf Value", v)
+			}
+		}
+		return v.Float64()
+	case map[string]interface{}:
+		for k, e := range v {
+			converted, err := asDoubles(e)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = converted
+		}
+		return v, nil
+	case []interface{}:
+		for idx, e := range v {
+			converted, err := asDoubles(e)
+			if err != nil {
+				return nil, err
+			}
+			v[idx] = converted
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+func (jsonProtobufValueCodec) Unmarshal(b []byte, here interface{}) error {
+	v := &structpb.Value{}
+	if err := proto.Unmarshal(b, v); err != nil {
+		return err
+	}
+	j, err := json.Marshal(v.AsInterface())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(j, here)
+}
+
+type gzipCompression struct{}
+
+func (gzipCompression) Format() byte {
+	return gzipFormat
+}
+
+func (gzipCompression) Compress(b []byte) ([]byte, error) {
+	buf := bytes.Buffer{}
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompression) Decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	decompressed, err := ioutil.ReadAll(io.LimitReader(r, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(decompressed) > MaxDecompressedSize {
+		return nil, ErrTooLarge
+	}
+	return decompressed, nil
+}
+
+//zstdEncoder and zstdDecoder are shared, as they are costly to create and safe to use concurrently
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(MaxDecompressedSize))
+)
+
+type zstdCompression struct{}
+
+func (zstdCompression) Format() byte {
+	return zstdFormat
+}
+
+func (zstdCompression) Compress(b []byte) ([]byte, error) {
+	return zstdEncoder.EncodeAll(b, nil), nil
+}
+
+func (zstdCompression) Decompress(b []byte) ([]byte, error) {
+	decompressed, err := zstdDecoder.DecodeAll(b, nil)
+	if err == zstd.ErrDecoderSizeExceeded {
+		return nil, ErrTooLarge
+	}
+	return decompressed, err
+}
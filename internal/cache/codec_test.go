@@ -0,0 +1,265 @@
+package cache_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	"github.com/go-redis/redismock/v8"
+)
+
+var (
+	testCodecs = map[string]cache.Codec{
+		"json":                cache.JSON,
+		"json_msgpack":        cache.JSONMessagePack,
+		"json_protobuf_value": cache.JSONProtobufValue,
+	}
+	testCompressions = map[string]cache.Compression{
+		"none": nil,
+		"gzip": cache.Gzip,
+		"zstd": cache.Zstd,
+	}
+)
+
+//largeCart gives a cart with many lines, the values where the codecs make a difference
+func largeCart(lines int) cart.Cart {
+	stock := 12
+	c := cart.Cart{
+		ID:              "c7d1f7a4-5b0e-4b59-9f53-7d2a3c1b9e10",
+		ShippingAddress: &cart.Address{Country: "ES", Region: "MD", PostalCode: "28001"},
+	}
+	for i := 0; i < lines; i++ {
+		c.Items = append(c.Items, item.Item{
+			ID:         fmt.Sprintf("item-%d", i%20),
+			LineID:     fmt.Sprintf("line-%d", i),
+			Name:       fmt.Sprintf("Item number %d", i%20),
+			Quantity:   i%5 + 1,
+			Price:      19.99,
+			AddedPrice: 18.5,
+			Category:   "books",
+			Stock:      &stock,
+			Weight:     350,
+			Options:    map[string]string{"size": "M", "color": "blue"},
+			Note:       "gift wrap, please",
+		})
+	}
+	return c
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	type envelope struct {
+		Version int             `json:"version"`
+		Data    json.RawMessage `json:"data"`
+	}
+	want := largeCart(10)
+	data, _ := json.Marshal(want)
+	values := []interface{}{want, envelope{Version: 2, Data: data}, "text", 42, []string{"a", "b"}}
+
+	for name, codec := range testCodecs {
+		for _, value := range values {
+			b, err := codec.Marshal(value)
+			if err != nil {
+				t.Fatalf("%s: Error was not expected: %v", name, err)
+			}
+			got := reflect.New(reflect.TypeOf(value))
+			if err := codec.Unmarshal(b, got.Interface()); err != nil {
+				t.Fatalf("%s: Error was not expected: %v", name, err)
+			}
+			if e, ok := got.Interface().(*envelope); ok {
+				c := cart.Cart{}
+				if err := json.Unmarshal(e.Data, &c); err != nil || !reflect.DeepEqual(c, want) {
+					t.Fatalf("%s: Unexpected raw value %s, %v", name, e.Data, err)
+				}
+				continue
+			}
+			if !reflect.DeepEqual(got.Elem().Interface(), value) {
+				t.Fatalf("%s: Unexpected value %+v, expected %+v", name, got.Elem().Interface(), value)
+			}
+		}
+	}
+}
+
+func TestCompressionsRoundTrip(t *testing.T) {
+	b, _ := json.Marshal(largeCart(50))
+	for name, compression := range testCompressions {
+		if compression == nil {
+			continue
+		}
+		compressed, err := compression.Compress(b)
+		if err != nil || len(compressed) >= len(b) {
+			t.Fatalf("%s: Unexpected result of %d bytes, %v", name, len(compressed), err)
+		}
+		got, err := compression.Decompress(compressed)
+		if err != nil || string(got) != string(b) {
+			t.Fatalf("%s: Unexpected result %v", name, err)
+		}
+	}
+}
+
+func TestCodecsKeepIntegers(t *testing.T) {
+	value := map[string]interface{}{"max_int": int64(math.MaxInt64), "max_uint": uint64(math.MaxUint64), "exact": int64(1 << 53)}
+	b, err := cache.JSONMessagePack.Marshal(value)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	got := struct {
+		MaxInt  int64  `json:"max_int"`
+		MaxUint uint64 `json:"max_uint"`
+		Exact   int64  `json:"exact"`
+	}{}
+	if err := cache.JSONMessagePack.Unmarshal(b, &got); err != nil || got.MaxInt != math.MaxInt64 || got.MaxUint != math.MaxUint64 {
+		t.Fatalf("Unexpected result %+v, %v", got, err)
+	}
+
+	//a protobuf Value holds doubles, so integers it can't hold exactly are refused
+	if _, err := cache.JSONProtobufValue.Marshal(value); err == nil {
+		t.Fatalf("Error was expected")
+	}
+	if _, err := cache.JSONProtobufValue.Marshal(map[string]int64{"n": -(1<<53 + 1)}); err == nil {
+		t.Fatalf("Error was expected")
+	}
+	b, err = cache.JSONProtobufValue.Marshal(map[string]interface{}{"exact": int64(1 << 53), "price": 19.99})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if err := cache.JSONProtobufValue.Unmarshal(b, &got); err != nil || got.Exact != 1<<53 {
+		t.Fatalf("Unexpected result %+v, %v", got, err)
+	}
+}
+
+func TestDecompressionIsBounded(t *testing.T) {
+	b := make([]byte, cache.MaxDecompressedSize+1)
+	for name, compression := range testCompressions {
+		if compression == nil {
+			continue
+		}
+		compressed, err := compression.Compress(b)
+		if err != nil {
+			t.Fatalf("%s: Unexpected error %v", name, err)
+		}
+		if _, err := compression.Decompress(compressed); err != cache.ErrTooLarge {
+			t.Fatalf("%s: Too large error was expected, got %v", name, err)
+		}
+		compressed, _ = compression.Compress(b[:cache.MaxDecompressedSize])
+		if got, err := compression.Decompress(compressed); err != nil || len(got) != cache.MaxDecompressedSize {
+			t.Fatalf("%s: Unexpected result of %d bytes, %v", name, len(got), err)
+		}
+	}
+}
+
+func TestSetWithCodec(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	b, _ := cache.JSONMessagePack.Marshal("test")
+	mock.ExpectSet("testKey", string(append([]byte{0, 2, 0}, b...)), 0).SetVal("OK")
+	c := cache.NewRedisCache(testLogger, 0, db, cache.WithCodec(cache.JSONMessagePack))
+
+	if err := c.Set(context.TODO(), "testKey", "test"); err != nil {
+		t.Fatalf("Error was not expected: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unexpected commands: %v", err)
+	}
+}
+
+func TestSetCompressesAboveThreshold(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	short, _ := json.Marshal("short")
+	long, _ := json.Marshal("long enough to be compressed")
+	compressed, _ := cache.Gzip.Compress(long)
+	//short values are stored bare, as they were before codecs existed
+	mock.ExpectSet("short", string(short), 0).SetVal("OK")
+	mock.ExpectSet("long", string(append([]byte{0, 1, 1}, compressed...)), 0).SetVal("OK")
+	c := cache.NewRedisCache(testLogger, 0, db, cache.WithCompression(cache.Gzip, 10))
+
+	if err := c.Set(context.TODO(), "short", "short"); err != nil {
+		t.Fatalf("Error was not expected: %v", err)
+	}
+	if err := c.Set(context.TODO(), "long", "long enough to be compressed"); err != nil {
+		t.Fatalf("Error was not expected: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unexpected commands: %v", err)
+	}
+}
+
+func TestGetReadsEveryFormat(t *testing.T) {
+	want := largeCart(3)
+	bare, _ := json.Marshal(want)
+	stored := map[string][]byte{"bare": bare}
+	for codecName, codec := range testCodecs {
+		for compressionName, compression := range testCompressions {
+			b, _ := codec.Marshal(want)
+			format := byte(0)
+			if compression != nil {
+				b, _ = compression.Compress(b)
+				format = map[string]byte{"gzip": 1, "zstd": 2}[compressionName]
+			}
+			marker := []byte{0, map[string]byte{"json": 1, "json_msgpack": 2, "json_protobuf_value": 3}[codecName], format}
+			stored[codecName+"+"+compressionName] = append(marker, b...)
+		}
+	}
+
+	for name, b := range stored {
+		db, mock := redismock.NewClientMock()
+		mock.ExpectGet("testKey").SetVal(string(b))
+		//whichever format is written, every one is read
+		c := cache.NewRedisCache(testLogger, 0, db, cache.WithCodec(cache.JSONProtobufValue), cache.WithCompression(cache.Zstd, 0))
+
+		got := cart.Cart{}
+		if err := c.Get(context.TODO(), "testKey", &got); err != nil || !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: Unexpected result %+v, %v", name, got, err)
+		}
+	}
+}
+
+func TestGetUnknownFormat(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	mock.ExpectGet("testKey").SetVal(string([]byte{0, 9, 0, 1}))
+	c := cache.NewRedisCache(testLogger, 0, db)
+
+	str := ""
+	if c.Get(context.TODO(), "testKey", &str) == nil {
+		t.Fatalf("Error was expected")
+	}
+}
+
+//BenchmarkCodecs compares how long storing and reading a large cart takes and how many bytes it is stored in,
+//reported as stored_bytes
+func BenchmarkCodecs(b *testing.B) {
+	value := largeCart(200)
+	for _, codecName := range []string{"json", "json_msgpack", "json_protobuf_value"} {
+		for _, compressionName := range []string{"none", "gzip", "zstd"} {
+			codec, compression := testCodecs[codecName], testCompressions[compressionName]
+			b.Run(codecName+"/"+compressionName, func(b *testing.B) {
+				size := 0
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					encoded, err := codec.Marshal(value)
+					if err == nil && compression != nil {
+						encoded, err = compression.Compress(encoded)
+					}
+					if err != nil {
+						b.Fatal(err)
+					}
+					size = len(encoded)
+					if compression != nil {
+						if encoded, err = compression.Decompress(encoded); err != nil {
+							b.Fatal(err)
+						}
+					}
+					got := cart.Cart{}
+					if err := codec.Unmarshal(encoded, &got); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(size), "stored_bytes")
+			})
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+//ErrUndecryptable is returned when a stored value can't be decrypted, either because it was changed
+//or because its key is not in the keyring
+var ErrUndecryptable = errors.New("cache: value can't be decrypted")
+
+//sealedPrefix starts the encrypted values, followed by the ID of their key and the nonce and ciphertext in base64
+const sealedPrefix = "enc:"
+
+//Key is an AES-256 key of a keyring, Secret being 32 bytes, base64 encoded in JSON
+type Key struct {
+	ID     string `json:"id"`
+	Secret []byte `json:"key"`
+}
+
+//Keyring holds the keys values are encrypted with. The first key encrypts, the others only decrypt
+//the values encrypted before it was rotated in
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+type keyringFile struct {
+	Keys []Key `json:"keys"`
+}
+
+//NewKeyring gives a keyring of the keys, encrypting with the first one
+func NewKeyring(keys ...Key) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("cache: a keyring needs at least a key")
+	}
+	k := &Keyring{primary: keys[0].ID, aeads: map[string]cipher.AEAD{}}
+	for _, key := range keys {
+		if key.ID == "" || strings.Contains(key.ID, ":") {
+			return nil, fmt.Errorf("cache: key id %q must be set and have no colons", key.ID)
+		}
+		if _, ok := k.aeads[key.ID]; ok {
+			return nil, fmt.Errorf("cache: key %s is repeated", key.ID)
+		}
+		if len(key.Secret) != 32 {
+			return nil, fmt.Errorf("cache: key %s must be 32 bytes, it is %d", key.ID, len(key.Secret))
+		}
+		block, err := aes.NewCipher(key.Secret)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.aeads[key.ID] = aead
+	}
+	return k, nil
+}
+
+//LoadKeyring reads the keyring from a JSON file listing the keys, as {"keys": [{"id": "...", "key": "<base64>"}]}
+func LoadKeyring(path string) (*Keyring, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := keyringFile{}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("unable to parse keys %s: %w", path, err)
+	}
+	return NewKeyring(f.Keys...)
+}
+
+//ParseKeyring reads the keyring from a comma separated list of id:key, keys being base64 encoded
+func ParseKeyring(s string) (*Keyring, error) {
+	keys := []Key{}
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("cache: key %q must be id:key", parts[0])
+		}
+		secret, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("cache: key %s is not base64: %w", parts[0], err)
+		}
+		keys = append(keys, Key{ID: parts[0], Secret: secret})
+	}
+	return NewKeyring(keys...)
+}
+
+//seal encrypts plaintext with the primary key, authenticating aad along with it
+func (k *Keyring) seal(plaintext []byte, aad string) (string, error) {
+	aead := k.aeads[k.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(aad))
+	return sealedPrefix + k.primary + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+//open decrypts a sealed value, giving the ID of the key it was encrypted with. ok is false when the value is not sealed
+func (k *Keyring) open(value, aad string) (plaintext []byte, keyID string, ok bool, err error) {
+	if !strings.HasPrefix(value, sealedPrefix) {
+		return nil, "", false, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, sealedPrefix), ":", 2)
+	if len(parts) != 2 {
+		return nil, "", true, ErrUndecryptable
+	}
+	keyID = parts[0]
+	aead, found := k.aeads[keyID]
+	if !found {
+		return nil, keyID, true, fmt.Errorf("%w: unknown key %s", ErrUndecryptable, keyID)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, keyID, true, ErrUndecryptable
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	if plaintext, err = aead.Open(nil, nonce, ciphertext, []byte(aad)); err != nil {
+		return nil, keyID, true, ErrUndecryptable
+	}
+	return plaintext, keyID, true, nil
+}
+
+type encryptedCache struct {
+	next      Cache
+	keys      *Keyring
+	namespace func(ctx context.Context) string
+	//rejectPlaintext gives ErrUndecryptable for values and elements stored in plain
+	rejectPlaintext bool
+}
+
+//EncryptionOption changes how an encrypted Cache encrypts
+type EncryptionOption func(*encryptedCache)
+
+//WithNamespace binds list elements to the namespace of the context they are pushed in, the one the keys of the
+//next Cache are prefixed with, so elements copied into the lists of another namespace can't be read
+func WithNamespace(namespace func(ctx context.Context) string) EncryptionOption {
+	return func(e *encryptedCache) {
+		e.namespace = namespace
+	}
+}
+
+//RejectingPlaintext gives ErrUndecryptable for values and list elements stored in plain rather than reading them,
+//meant for once every one stored before encryption was enabled was encrypted, so plain ones were written by others
+func RejectingPlaintext() EncryptionOption {
+	return func(e *encryptedCache) {
+		e.rejectPlaintext = true
+	}
+}
+
+//NewEncryptedCache decorates a Cache encrypting values and list elements with AES-GCM. Values are bound to their key,
+//so they can't be read once copied under another one, and it must be under the namespacing of keys for them to be
+//bound to their namespace too. List elements can be moved between lists, being bound only to a namespace WithNamespace.
+//Values stored in plain or with a key other than the primary one are encrypted again with it when read, unless
+//changed meanwhile. List elements keep the key they were encrypted with, so retired keys must stay in the keyring
+//while lists may hold elements encrypted with them. Keys and counters are not encrypted.
+//Values that can't be decrypted give ErrUndecryptable
+func NewEncryptedCache(next Cache, keys *Keyring, opts ...EncryptionOption) Cache {
+	e := &encryptedCache{
+		next: next,
+		keys: keys,
+		namespace: func(ctx context.Context) string {
+			return ""
+		},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+func (e *encryptedCache) sealValue(key string, value interface{}) (string, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return e.keys.seal(b, key)
+}
+
+func (e *encryptedCache) Set(ctx context.Context, key string, value interface{}) error {
+	sealed, err := e.sealValue(key, value)
+	if err != nil {
+		return err
+	}
+	return e.next.Set(ctx, key, sealed)
+}
+
+func (e *encryptedCache) Get(ctx context.Context, key string, here interface{}) error {
+	raw := json.RawMessage{}
+	if err := e.next.Get(ctx, key, &raw); err != nil {
+		return err
+	}
+	plaintext, keyID, err := e.openValue(key, raw)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(plaintext, here); err != nil {
+		return err
+	}
+	if keyID != e.keys.primary {
+		e.reseal(ctx, key, raw, plaintext)
+	}
+	return nil
+}
+
+//openValue decrypts the value stored under key, giving the ID of the key it was encrypted with, which is empty
+//for values stored in plain before encryption was enabled. Non string values are always in plain
+func (e *encryptedCache) openValue(key string, raw json.RawMessage) ([]byte, string, error) {
+	value := ""
+	json.Unmarshal(raw, &value)
+	plaintext, keyID, ok, err := e.keys.open(value, key)
+	if err != nil {
+		return nil, "", err
+	}
+	if !ok {
+		if e.rejectPlaintext {
+			return nil, "", fmt.Errorf("%w: stored in plain", ErrUndecryptable)
+		}
+		return raw, "", nil
+	}
+	return plaintext, keyID, nil
+}
+
+//reseal stores the value read encrypted with the primary key, only when it is still the one read, so changes saved
+//since are kept. Failing is not an error of the read, the value is encrypted again on the next one
+func (e *encryptedCache) reseal(ctx context.Context, key string, raw json.RawMessage, plaintext []byte) {
+	if sealed, err := e.keys.seal(plaintext, key); err == nil {
+		e.next.CompareAndSet(ctx, key, raw, sealed)
+	}
+}
+
+//CompareAndSet compares old with the value decrypted, as values encrypted again differ from the stored one
+func (e *encryptedCache) CompareAndSet(ctx context.Context, key string, old json.RawMessage, value interface{}) (bool, error) {
+	raw := json.RawMessage{}
+	if err := e.next.Get(ctx, key, &raw); err != nil {
+		if !IsNotFound(err) {
+			return false, err
+		}
+		if old != nil {
+			return false, nil
+		}
+		sealed, err := e.sealValue(key, value)
+		if err != nil {
+			return false, err
+		}
+		return e.next.CompareAndSet(ctx, key, nil, sealed)
+	}
+	if old == nil {
+		return false, nil
+	}
+	plaintext, _, err := e.openValue(key, raw)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(plaintext, old) {
+		return false, nil
+	}
+	sealed, err := e.sealValue(key, value)
+	if err != nil {
+		return false, err
+	}
+	return e.next.CompareAndSet(ctx, key, raw, sealed)
+}
+
+func (e *encryptedCache) Del(ctx context.Context, key string) error {
+	return e.next.Del(ctx, key)
+}
+
+func (e *encryptedCache) Alive(ctx context.Context) bool {
+	return e.next.Alive(ctx)
+}
+
+func (e *encryptedCache) Tx(ctx context.Context, ops ...Op) error {
+	sealed := make([]Op, len(ops))
+	for idx, op := range ops {
+		switch op.kind {
+		case opSet:
+			value, err := e.sealValue(op.key, op.value)
+			if err != nil {
+				return err
+			}
+			op.value = value
+		case opPush:
+			value, err := e.keys.seal([]byte(op.value.(string)), e.namespace(ctx))
+			if err != nil {
+				return err
+			}
+			op.value = value
+		}
+		sealed[idx] = op
+	}
+	return e.next.Tx(ctx, sealed...)
+}
+
+//openElement decrypts a list element, giving it as it is when it was stored in plain and that is allowed
+func (e *encryptedCache) openElement(ctx context.Context, element string) (string, error) {
+	plaintext, _, ok, err := e.keys.open(element, e.namespace(ctx))
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		if e.rejectPlaintext {
+			return "", fmt.Errorf("%w: stored in plain", ErrUndecryptable)
+		}
+		return element, nil
+	}
+	return string(plaintext), nil
+}
+
+func (e *encryptedCache) ListMove(ctx context.Context, src, dst string) (string, error) {
+	element, err := e.next.ListMove(ctx, src, dst)
+	if err != nil {
+		return "", err
+	}
+	return e.openElement(ctx, element)
+}
+
+//ListRemove goes through the list, as every element encrypted has a ciphertext of its own
+func (e *encryptedCache) ListRemove(ctx context.Context, list, value string) error {
+	elements, err := e.next.ListRange(ctx, list, 0, -1)
+	if err != nil {
+		return err
+	}
+	removed := map[string]bool{}
+	for _, element := range elements {
+		if removed[element] {
+			continue
+		}
+		plain, err := e.openElement(ctx, element)
+		if err != nil || plain != value {
+			continue
+		}
+		if err := e.next.ListRemove(ctx, list, element); err != nil {
+			return err
+		}
+		removed[element] = true
+	}
+	return nil
+}
+
+func (e *encryptedCache) ListRange(ctx context.Context, list string, start, stop int64) ([]string, error) {
+	elements, err := e.next.ListRange(ctx, list, start, stop)
+	if err != nil {
+		return nil, err
+	}
+	for idx, element := range elements {
+		if elements[idx], err = e.openElement(ctx, element); err != nil {
+			return nil, err
+		}
+	}
+	return elements, nil
+}
+
+func (e *encryptedCache) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	return e.next.IncrBy(ctx, key, delta)
+}
+
+func (e *encryptedCache) Scan(ctx context.Context, match string, fn func(key string) error) error {
+	return e.next.Scan(ctx, match, fn)
+}
@@ -0,0 +1,306 @@
+package cache_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+)
+
+func testKey(id string, b byte) cache.Key {
+	return cache.Key{ID: id, Secret: bytes.Repeat([]byte{b}, 32)}
+}
+
+func testKeyring(t *testing.T, keys ...cache.Key) *cache.Keyring {
+	k, err := cache.NewKeyring(keys...)
+	if err != nil {
+		t.Fatalf("Error was not expected: %v", err)
+	}
+	return k
+}
+
+type customer struct {
+	Email string
+}
+
+func TestEncryptedCache_RoundTrip(t *testing.T) {
+	inner := cache.NewMemoryCache()
+	c := cache.NewEncryptedCache(inner, testKeyring(t, testKey("k1", 1)))
+
+	c.Set(context.TODO(), "customer", customer{Email: "jane@example.com"})
+	c.Tx(context.TODO(), cache.SetOp("other", customer{Email: "john@example.com"}))
+
+	got := customer{}
+	if err := c.Get(context.TODO(), "customer", &got); err != nil || got.Email != "jane@example.com" {
+		t.Fatalf("Unexpected result %+v, %v", got, err)
+	}
+	if err := c.Get(context.TODO(), "other", &got); err != nil || got.Email != "john@example.com" {
+		t.Fatalf("Unexpected result %+v, %v", got, err)
+	}
+	raw := ""
+	if err := inner.Get(context.TODO(), "customer", &raw); err != nil || !strings.HasPrefix(raw, "enc:k1:") || strings.Contains(raw, "jane") {
+		t.Fatalf("The value was expected to be stored encrypted, got %q, %v", raw, err)
+	}
+}
+
+func TestEncryptedCache_TamperedValuesAreRejected(t *testing.T) {
+	inner := cache.NewMemoryCache()
+	c := cache.NewEncryptedCache(inner, testKeyring(t, testKey("k1", 1)))
+	c.Set(context.TODO(), "customer", customer{Email: "jane@example.com"})
+
+	raw := ""
+	inner.Get(context.TODO(), "customer", &raw)
+	sealed, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(raw, "enc:k1:"))
+	sealed[len(sealed)-1] ^= 1
+	inner.Set(context.TODO(), "customer", "enc:k1:"+base64.StdEncoding.EncodeToString(sealed))
+
+	got := customer{}
+	if err := c.Get(context.TODO(), "customer", &got); !errors.Is(err, cache.ErrUndecryptable) {
+		t.Fatalf("ErrUndecryptable was expected, got %v", err)
+	}
+
+	//values are bound to their key, so they can't be copied under another
+	c.Set(context.TODO(), "customer", customer{Email: "jane@example.com"})
+	inner.Get(context.TODO(), "customer", &raw)
+	inner.Set(context.TODO(), "tenant:globex:customer", raw)
+	if err := c.Get(context.TODO(), "tenant:globex:customer", &got); !errors.Is(err, cache.ErrUndecryptable) {
+		t.Fatalf("ErrUndecryptable was expected, got %v", err)
+	}
+
+	for _, value := range []string{"enc:k1", "enc:k1:not base64", "enc:k1:AAAA", "enc:k9:" + strings.TrimPrefix(raw, "enc:k1:")} {
+		inner.Set(context.TODO(), "customer", value)
+		if err := c.Get(context.TODO(), "customer", &got); !errors.Is(err, cache.ErrUndecryptable) {
+			t.Fatalf("ErrUndecryptable was expected for %q, got %v", value, err)
+		}
+	}
+}
+
+func TestEncryptedCache_KeyRotation(t *testing.T) {
+	inner := cache.NewMemoryCache()
+	old := cache.NewEncryptedCache(inner, testKeyring(t, testKey("k1", 1)))
+	old.Set(context.TODO(), "customer", customer{Email: "jane@example.com"})
+
+	rotated := cache.NewEncryptedCache(inner, testKeyring(t, testKey("k2", 2), testKey("k1", 1)))
+	got := customer{}
+	if err := rotated.Get(context.TODO(), "customer", &got); err != nil || got.Email != "jane@example.com" {
+		t.Fatalf("Unexpected result %+v, %v", got, err)
+	}
+	raw := ""
+	if inner.Get(context.TODO(), "customer", &raw); !strings.HasPrefix(raw, "enc:k2:") {
+		t.Fatalf("The value was expected to be encrypted again with the new key, got %q", raw)
+	}
+
+	//once read, the old key is no longer needed
+	retired := cache.NewEncryptedCache(inner, testKeyring(t, testKey("k2", 2)))
+	if err := retired.Get(context.TODO(), "customer", &got); err != nil || got.Email != "jane@example.com" {
+		t.Fatalf("Unexpected result %+v, %v", got, err)
+	}
+}
+
+func TestEncryptedCache_PlainValuesAreEncryptedWhenRead(t *testing.T) {
+	inner := cache.NewMemoryCache()
+	inner.Set(context.TODO(), "customer", customer{Email: "jane@example.com"})
+	inner.Set(context.TODO(), "name", "jane")
+	c := cache.NewEncryptedCache(inner, testKeyring(t, testKey("k1", 1)))
+
+	got, name := customer{}, ""
+	if err := c.Get(context.TODO(), "customer", &got); err != nil || got.Email != "jane@example.com" {
+		t.Fatalf("Unexpected result %+v, %v", got, err)
+	}
+	if err := c.Get(context.TODO(), "name", &name); err != nil || name != "jane" {
+		t.Fatalf("Unexpected result %q, %v", name, err)
+	}
+	for _, key := range []string{"customer", "name"} {
+		raw := json.RawMessage{}
+		if inner.Get(context.TODO(), key, &raw); !strings.HasPrefix(string(raw), `"enc:k1:`) {
+			t.Fatalf("The value was expected to be encrypted, got %s", raw)
+		}
+	}
+}
+
+func TestEncryptedCache_PlaintextIsRejectedOnceMigrated(t *testing.T) {
+	inner := cache.NewMemoryCache()
+	inner.Set(context.TODO(), "customer", customer{Email: "jane@example.com"})
+	inner.Set(context.TODO(), "name", "jane")
+	inner.Tx(context.TODO(), cache.PushOp("list", "plain"))
+	c := cache.NewEncryptedCache(inner, testKeyring(t, testKey("k1", 1)), cache.RejectingPlaintext())
+	c.Set(context.TODO(), "encrypted", "john")
+
+	got, name := customer{}, ""
+	if err := c.Get(context.TODO(), "customer", &got); !errors.Is(err, cache.ErrUndecryptable) {
+		t.Fatalf("ErrUndecryptable was expected, got %v", err)
+	}
+	if err := c.Get(context.TODO(), "name", &name); !errors.Is(err, cache.ErrUndecryptable) {
+		t.Fatalf("ErrUndecryptable was expected, got %v", err)
+	}
+	if _, err := c.ListRange(context.TODO(), "list", 0, -1); !errors.Is(err, cache.ErrUndecryptable) {
+		t.Fatalf("ErrUndecryptable was expected, got %v", err)
+	}
+	if _, err := c.ListMove(context.TODO(), "list", "moved"); !errors.Is(err, cache.ErrUndecryptable) {
+		t.Fatalf("ErrUndecryptable was expected, got %v", err)
+	}
+	if err := c.Get(context.TODO(), "encrypted", &name); err != nil || name != "john" {
+		t.Fatalf("Unexpected result %q, %v", name, err)
+	}
+
+	//and they are not encrypted in their place
+	raw := json.RawMessage{}
+	if inner.Get(context.TODO(), "name", &raw); string(raw) != `"jane"` {
+		t.Fatalf("The value was expected to be left as it is, got %s", raw)
+	}
+}
+
+//racingCache changes a value right after it is read, as another instance would
+type racingCache struct {
+	cache.Cache
+}
+
+func (r racingCache) Get(ctx context.Context, key string, here interface{}) error {
+	err := r.Cache.Get(ctx, key, here)
+	r.Cache.Set(ctx, key, "changed meanwhile")
+	return err
+}
+
+func TestEncryptedCache_ChangesAreKeptWhenEncryptingAgain(t *testing.T) {
+	inner := cache.NewMemoryCache()
+	inner.Set(context.TODO(), "name", "jane")
+	c := cache.NewEncryptedCache(racingCache{inner}, testKeyring(t, testKey("k1", 1)))
+
+	name := ""
+	if err := c.Get(context.TODO(), "name", &name); err != nil || name != "jane" {
+		t.Fatalf("Unexpected result %q, %v", name, err)
+	}
+	if inner.Get(context.TODO(), "name", &name); name != "changed meanwhile" {
+		t.Fatalf("The change was expected to be kept, got %q", name)
+	}
+}
+
+func TestEncryptedCache_CompareAndSet(t *testing.T) {
+	inner := cache.NewMemoryCache()
+	c := cache.NewEncryptedCache(inner, testKeyring(t, testKey("k1", 1)))
+	c.Set(context.TODO(), "name", "jane")
+	old := json.RawMessage{}
+	c.Get(context.TODO(), "name", &old)
+
+	if stored, err := c.CompareAndSet(context.TODO(), "name", old, "john"); err != nil || !stored {
+		t.Fatalf("The value was expected to be stored, got %v, %v", stored, err)
+	}
+	if stored, err := c.CompareAndSet(context.TODO(), "name", old, "jim"); err != nil || stored {
+		t.Fatalf("The value was not expected to be stored once changed, got %v, %v", stored, err)
+	}
+	if stored, err := c.CompareAndSet(context.TODO(), "name", nil, "jim"); err != nil || stored {
+		t.Fatalf("The value was not expected to be stored without old under an existing key, got %v, %v", stored, err)
+	}
+	if stored, err := c.CompareAndSet(context.TODO(), "other", nil, "jim"); err != nil || !stored {
+		t.Fatalf("The value was expected to be stored without old under a missing key, got %v, %v", stored, err)
+	}
+	if raw := (json.RawMessage{}); inner.Get(context.TODO(), "other", &raw) != nil || bytes.Contains(raw, []byte("jim")) {
+		t.Fatalf("The value stored under a missing key was expected to be encrypted, got %s", raw)
+	}
+	name := ""
+	if err := c.Get(context.TODO(), "name", &name); err != nil || name != "john" {
+		t.Fatalf("Unexpected result %q, %v", name, err)
+	}
+	raw := ""
+	if inner.Get(context.TODO(), "name", &raw); !strings.HasPrefix(raw, "enc:k1:") {
+		t.Fatalf("The value was expected to be stored encrypted, got %q", raw)
+	}
+}
+
+func TestEncryptedCache_Lists(t *testing.T) {
+	inner := cache.NewMemoryCache()
+	inner.Tx(context.TODO(), cache.PushOp("list", "plain"))
+	c := cache.NewEncryptedCache(inner, testKeyring(t, testKey("k1", 1)))
+	c.Tx(context.TODO(), cache.PushOp("list", "1"), cache.PushOp("list", "2"), cache.PushOp("list", "2"))
+
+	raw, _ := inner.ListRange(context.TODO(), "list", 0, 0)
+	if len(raw) != 1 || !strings.HasPrefix(raw[0], "enc:k1:") {
+		t.Fatalf("The element was expected to be stored encrypted, got %v", raw)
+	}
+	if got, err := c.ListRange(context.TODO(), "list", 0, -1); err != nil || strings.Join(got, ",") != "2,2,1,plain" {
+		t.Fatalf("Unexpected result %v, %v", got, err)
+	}
+	if moved, err := c.ListMove(context.TODO(), "list", "moved"); err != nil || moved != "plain" {
+		t.Fatalf("Unexpected result %q, %v", moved, err)
+	}
+	if moved, err := c.ListMove(context.TODO(), "list", "moved"); err != nil || moved != "1" {
+		t.Fatalf("Unexpected result %q, %v", moved, err)
+	}
+	if err := c.ListRemove(context.TODO(), "list", "2"); err != nil {
+		t.Fatalf("Error was not expected: %v", err)
+	}
+	if got, _ := c.ListRange(context.TODO(), "list", 0, -1); len(got) != 0 {
+		t.Fatalf("Every element was expected to be removed, got %v", got)
+	}
+	if err := c.ListRemove(context.TODO(), "moved", "plain"); err != nil {
+		t.Fatalf("Error was not expected: %v", err)
+	}
+	if got, _ := c.ListRange(context.TODO(), "moved", 0, -1); strings.Join(got, ",") != "1" {
+		t.Fatalf("Unexpected result %v", got)
+	}
+}
+
+func TestEncryptedCache_CopiesAcrossNamespacesAreRejected(t *testing.T) {
+	inner := cache.NewMemoryCache()
+	encrypted := cache.NewEncryptedCache(inner, testKeyring(t, testKey("k1", 1)), cache.WithNamespace(namespaceOf))
+	c := cache.NewNamespacedCache(encrypted, namespaceOf)
+	a, b := inNamespace("tenant:a:"), inNamespace("tenant:b:")
+	c.Tx(a, cache.SetOp("customer", customer{Email: "jane@example.com"}), cache.PushOp("outbox", "1"))
+
+	raw := ""
+	inner.Get(context.TODO(), "tenant:a:customer", &raw)
+	inner.Set(context.TODO(), "tenant:b:customer", raw)
+	elements, _ := inner.ListRange(context.TODO(), "tenant:a:outbox", 0, -1)
+	inner.Tx(context.TODO(), cache.PushOp("tenant:b:outbox", elements[0]))
+
+	got := customer{}
+	if err := c.Get(b, "customer", &got); !errors.Is(err, cache.ErrUndecryptable) {
+		t.Fatalf("ErrUndecryptable was expected, got %v", err)
+	}
+	if _, err := c.ListRange(b, "outbox", 0, -1); !errors.Is(err, cache.ErrUndecryptable) {
+		t.Fatalf("ErrUndecryptable was expected, got %v", err)
+	}
+	if _, err := c.ListMove(b, "outbox", "outbox:processing"); !errors.Is(err, cache.ErrUndecryptable) {
+		t.Fatalf("ErrUndecryptable was expected, got %v", err)
+	}
+
+	//within the namespace they were stored in, they are read
+	if err := c.Get(a, "customer", &got); err != nil || got.Email != "jane@example.com" {
+		t.Fatalf("Unexpected result %+v, %v", got, err)
+	}
+	if moved, err := c.ListMove(a, "outbox", "outbox:processing"); err != nil || moved != "1" {
+		t.Fatalf("Unexpected result %q, %v", moved, err)
+	}
+}
+
+func TestKeyrings(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	if _, err := cache.ParseKeyring("k2:" + secret + ", k1:" + secret); err != nil {
+		t.Fatalf("Error was not expected: %v", err)
+	}
+	for _, keys := range []string{"", "k1", "k1:not base64", "k1:" + base64.StdEncoding.EncodeToString([]byte("short")), "k1:" + secret + ",k1:" + secret} {
+		if _, err := cache.ParseKeyring(keys); err == nil {
+			t.Fatalf("Error was expected for %q", keys)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	ioutil.WriteFile(path, []byte(`{"keys": [{"id": "k1", "key": "`+secret+`"}]}`), 0600)
+	k, err := cache.LoadKeyring(path)
+	if err != nil {
+		t.Fatalf("Error was not expected: %v", err)
+	}
+	c := cache.NewEncryptedCache(cache.NewMemoryCache(), k)
+	c.Set(context.TODO(), "key", "value")
+	got := ""
+	if err := c.Get(context.TODO(), "key", &got); err != nil || got != "value" {
+		t.Fatalf("Unexpected result %q, %v", got, err)
+	}
+}
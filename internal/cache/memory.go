@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type memoryCache struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	lists  map[string][]string
+}
+
+//NewMemoryCache gives a Cache kept in process memory, behaving like the Redis one.
+//Meant for tests and local runs, nothing is shared between instances
+func NewMemoryCache() Cache {
+	return &memoryCache{
+		values: map[string][]byte{},
+		lists:  map[string][]string{},
+	}
+}
+
+func (m *memoryCache) Set(ctx context.Context, key string, value interface{}) error {
+	return m.Tx(ctx, SetOp(key, value))
+}
+
+func (m *memoryCache) Get(ctx context.Context, key string, here interface{}) error {
+	m.mu.Lock()
+	b, ok := m.values[key]
+	m.mu.Unlock()
+	if !ok {
+		return redis.Nil
+	}
+	return json.Unmarshal(b, here)
+}
+
+func (m *memoryCache) CompareAndSet(ctx context.Context, key string, old json.RawMessage, value interface{}) (bool, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, ok := m.values[key]
+	if ok != (old != nil) || !bytes.Equal(current, old) {
+		return false, nil
+	}
+	m.values[key] = b
+	return true, nil
+}
+
+func (m *memoryCache) Del(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, isValue := m.values[key]
+	_, isList := m.lists[key]
+	if !isValue && !isList {
+		return redis.Nil
+	}
+	delete(m.values, key)
+	delete(m.lists, key)
+	return nil
+}
+
+func (m *memoryCache) Alive(ctx context.Context) bool {
+	return true
+}
+
+func (m *memoryCache) Tx(ctx context.Context, ops ...Op) error {
+	encoded := make([][]byte, len(ops))
+	for idx, op := range ops {
+		if op.kind != opSet {
+			continue
+		}
+		b, err := json.Marshal(op.value)
+		if err != nil {
+			return err
+		}
+		encoded[idx] = b
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for idx, op := range ops {
+		switch op.kind {
+		case opSet:
+			m.values[op.key] = encoded[idx]
+		case opDel:
+			delete(m.values, op.key)
+			delete(m.lists, op.key)
+		case opPush:
+			m.lists[op.key] = append([]string{op.value.(string)}, m.lists[op.key]...)
+		case opTrim:
+			if max := op.value.(int64); int64(len(m.lists[op.key])) > max {
+				m.lists[op.key] = m.lists[op.key][:max]
+			}
+		}
+	}
+	return nil
+}
+
+func (m *memoryCache) ListMove(ctx context.Context, src, dst string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l := m.lists[src]
+	if len(l) == 0 {
+		return "", ErrListEmpty
+	}
+	val := l[len(l)-1]
+	m.lists[src] = l[:len(l)-1]
+	m.lists[dst] = append([]string{val}, m.lists[dst]...)
+	return val, nil
+}
+
+func (m *memoryCache) ListRemove(ctx context.Context, list, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := []string{}
+	for _, v := range m.lists[list] {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	m.lists[list] = kept
+	return nil
+}
+
+func (m *memoryCache) ListRange(ctx context.Context, list string, start, stop int64) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l := m.lists[list]
+	n := int64(len(l))
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop {
+		return []string{}, nil
+	}
+	res := make([]string, stop-start+1)
+	copy(res, l[start:stop+1])
+	return res, nil
+}
+
+func (m *memoryCache) Scan(ctx context.Context, match string, fn func(key string) error) error {
+	m.mu.Lock()
+	keys := []string{}
+	for k := range m.values {
+		keys = append(keys, k)
+	}
+	for k := range m.lists {
+		keys = append(keys, k)
+	}
+	m.mu.Unlock()
+	sort.Strings(keys)
+	for _, k := range keys {
+		if ok, _ := path.Match(match, k); !ok {
+			continue
+		}
+		if err := fn(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryCache) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	val := int64(0)
+	if b, ok := m.values[key]; ok {
+		if err := json.Unmarshal(b, &val); err != nil {
+			return 0, err
+		}
+	}
+	val += delta
+	m.values[key] = []byte(strconv.FormatInt(val, 10))
+	return val, nil
+}
@@ -0,0 +1,140 @@
+package cache_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+)
+
+func TestMemoryCache_SetGetDel(t *testing.T) {
+	c := cache.NewMemoryCache()
+
+	if err := c.Set(context.TODO(), "testKey", "test"); err != nil {
+		t.Fatalf("Error was not expected")
+	}
+	str := ""
+	if err := c.Get(context.TODO(), "testKey", &str); err != nil || str != "test" {
+		t.Fatalf("Wrong Value fetched")
+	}
+	if err := c.Del(context.TODO(), "testKey"); err != nil {
+		t.Fatalf("Error was not expected")
+	}
+	if err := c.Get(context.TODO(), "testKey", &str); !cache.IsNotFound(err) {
+		t.Fatalf("Not found was expected, got %v", err)
+	}
+	if err := c.Del(context.TODO(), "testKey"); !cache.IsNotFound(err) {
+		t.Fatalf("Not found was expected, got %v", err)
+	}
+}
+
+func TestMemoryCache_CompareAndSet(t *testing.T) {
+	c := cache.NewMemoryCache()
+	c.Set(context.TODO(), "testKey", "test")
+	old := json.RawMessage{}
+	c.Get(context.TODO(), "testKey", &old)
+
+	if stored, err := c.CompareAndSet(context.TODO(), "testKey", old, "new"); err != nil || !stored {
+		t.Fatalf("The value was expected to be stored, got %v, %v", stored, err)
+	}
+	if stored, err := c.CompareAndSet(context.TODO(), "testKey", old, "newer"); err != nil || stored {
+		t.Fatalf("The value was not expected to be stored once changed, got %v, %v", stored, err)
+	}
+	if stored, err := c.CompareAndSet(context.TODO(), "missing", old, "new"); err != nil || stored {
+		t.Fatalf("Nothing was expected to be stored under a missing key, got %v, %v", stored, err)
+	}
+	if stored, err := c.CompareAndSet(context.TODO(), "testKey", nil, "newer"); err != nil || stored {
+		t.Fatalf("Nothing was expected to be stored without old under an existing key, got %v, %v", stored, err)
+	}
+	if stored, err := c.CompareAndSet(context.TODO(), "missing", nil, "new"); err != nil || !stored {
+		t.Fatalf("The value was expected to be stored without old under a missing key, got %v, %v", stored, err)
+	}
+	str := ""
+	if err := c.Get(context.TODO(), "testKey", &str); err != nil || str != "new" {
+		t.Fatalf("Wrong Value fetched %q", str)
+	}
+}
+
+func TestMemoryCache_ListsAreFIFO(t *testing.T) {
+	c := cache.NewMemoryCache()
+
+	err := c.Tx(context.TODO(),
+		cache.PushOp("queue", "first"),
+		cache.PushOp("queue", "second"),
+	)
+	if err != nil {
+		t.Fatalf("Error was not expected")
+	}
+
+	val, err := c.ListMove(context.TODO(), "queue", "processing")
+	if err != nil || val != "first" {
+		t.Fatalf("Oldest element was expected, got %s", val)
+	}
+	if err := c.ListRemove(context.TODO(), "processing", "first"); err != nil {
+		t.Fatalf("Error was not expected")
+	}
+	vals, _ := c.ListRange(context.TODO(), "queue", 0, -1)
+	if len(vals) != 1 || vals[0] != "second" {
+		t.Fatalf("Unexpected list content %v", vals)
+	}
+	if _, err := c.ListMove(context.TODO(), "processing", "queue"); err != cache.ErrListEmpty {
+		t.Fatalf("ErrListEmpty was expected")
+	}
+}
+
+func TestMemoryCache_TrimKeepsNewest(t *testing.T) {
+	c := cache.NewMemoryCache()
+
+	err := c.Tx(context.TODO(),
+		cache.PushOp("log", "first"),
+		cache.PushOp("log", "second"),
+		cache.PushOp("log", "third"),
+		cache.TrimOp("log", 2),
+	)
+	if err != nil {
+		t.Fatalf("Error was not expected")
+	}
+	vals, _ := c.ListRange(context.TODO(), "log", 0, -1)
+	if len(vals) != 2 || vals[0] != "third" || vals[1] != "second" {
+		t.Fatalf("Unexpected list content %v", vals)
+	}
+}
+
+func TestMemoryCache_IncrBy(t *testing.T) {
+	c := cache.NewMemoryCache()
+
+	if val, err := c.IncrBy(context.TODO(), "counter", 3); err != nil || val != 3 {
+		t.Fatalf("Unexpected result %v, %v", val, err)
+	}
+	if val, err := c.IncrBy(context.TODO(), "counter", -1); err != nil || val != 2 {
+		t.Fatalf("Unexpected result %v, %v", val, err)
+	}
+	got := 0
+	if err := c.Get(context.TODO(), "counter", &got); err != nil || got != 2 {
+		t.Fatalf("Counter was expected to be readable, got %v, %v", got, err)
+	}
+}
+
+func TestMemoryCache_Scan(t *testing.T) {
+	c := cache.NewMemoryCache()
+	c.Set(context.TODO(), "cart:2", "b")
+	c.Set(context.TODO(), "cart:1", "a")
+	c.Set(context.TODO(), "list:1", "c")
+	c.Tx(context.TODO(), cache.PushOp("cart:log", "d"))
+
+	keys := []string{}
+	err := c.Scan(context.TODO(), "cart:*", func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil || len(keys) != 3 || keys[0] != "cart:1" || keys[1] != "cart:2" || keys[2] != "cart:log" {
+		t.Fatalf("Unexpected result %v, %v", keys, err)
+	}
+
+	stop := fmt.Errorf("stop")
+	if err := c.Scan(context.TODO(), "*", func(key string) error { return stop }); err != stop {
+		t.Fatalf("The error of fn was expected, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+type namespacedCache struct {
+	next      Cache
+	namespace func(ctx context.Context) string
+}
+
+//NewNamespacedCache decorates a Cache prefixing every key with the namespace given for the context of the call,
+//so callers in different namespaces never see the keys of each other. Keys are left as they are when the
+//namespace is empty, and Scan gives them without the prefix
+func NewNamespacedCache(next Cache, namespace func(ctx context.Context) string) Cache {
+	return &namespacedCache{
+		next:      next,
+		namespace: namespace,
+	}
+}
+
+func (n *namespacedCache) Set(ctx context.Context, key string, value interface{}) error {
+	return n.next.Set(ctx, n.namespace(ctx)+key, value)
+}
+
+func (n *namespacedCache) Get(ctx context.Context, key string, here interface{}) error {
+	return n.next.Get(ctx, n.namespace(ctx)+key, here)
+}
+
+func (n *namespacedCache) CompareAndSet(ctx context.Context, key string, old json.RawMessage, value interface{}) (bool, error) {
+	return n.next.CompareAndSet(ctx, n.namespace(ctx)+key, old, value)
+}
+
+func (n *namespacedCache) Del(ctx context.Context, key string) error {
+	return n.next.Del(ctx, n.namespace(ctx)+key)
+}
+
+func (n *namespacedCache) Alive(ctx context.Context) bool {
+	return n.next.Alive(ctx)
+}
+
+func (n *namespacedCache) Tx(ctx context.Context, ops ...Op) error {
+	ns := n.namespace(ctx)
+	prefixed := make([]Op, len(ops))
+	for idx, op := range ops {
+		op.key = ns + op.key
+		prefixed[idx] = op
+	}
+	return n.next.Tx(ctx, prefixed...)
+}
+
+func (n *namespacedCache) ListMove(ctx context.Context, src, dst string) (string, error) {
+	ns := n.namespace(ctx)
+	return n.next.ListMove(ctx, ns+src, ns+dst)
+}
+
+func (n *namespacedCache) ListRemove(ctx context.Context, list, value string) error {
+	return n.next.ListRemove(ctx, n.namespace(ctx)+list, value)
+}
+
+func (n *namespacedCache) ListRange(ctx context.Context, list string, start, stop int64) ([]string, error) {
+	return n.next.ListRange(ctx, n.namespace(ctx)+list, start, stop)
+}
+
+func (n *namespacedCache) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	return n.next.IncrBy(ctx, n.namespace(ctx)+key, delta)
+}
+
+func (n *namespacedCache) Scan(ctx context.Context, match string, fn func(key string) error) error {
+	ns := n.namespace(ctx)
+	return n.next.Scan(ctx, ns+match, func(key string) error {
+		return fn(strings.TrimPrefix(key, ns))
+	})
+}
@@ -0,0 +1,84 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+)
+
+type namespaceKey struct{}
+
+func inNamespace(ns string) context.Context {
+	return context.WithValue(context.Background(), namespaceKey{}, ns)
+}
+
+func namespaceOf(ctx context.Context) string {
+	ns, _ := ctx.Value(namespaceKey{}).(string)
+	return ns
+}
+
+func TestNamespacedCache_KeysAreApart(t *testing.T) {
+	inner := cache.NewMemoryCache()
+	c := cache.NewNamespacedCache(inner, namespaceOf)
+	a, b := inNamespace("a:"), inNamespace("b:")
+
+	c.Set(a, "key", "of a")
+	c.Tx(b, cache.SetOp("key", "of b"), cache.PushOp("list", "1"))
+
+	val := ""
+	if err := c.Get(a, "key", &val); err != nil || val != "of a" {
+		t.Fatalf("Wrong Value fetched %q, %v", val, err)
+	}
+	if err := c.Get(b, "key", &val); err != nil || val != "of b" {
+		t.Fatalf("Wrong Value fetched %q, %v", val, err)
+	}
+	if err := c.Get(context.TODO(), "key", &val); !cache.IsNotFound(err) {
+		t.Fatalf("Not found was expected, got %v", err)
+	}
+	if err := inner.Get(context.TODO(), "a:key", &val); err != nil || val != "of a" {
+		t.Fatalf("The key was expected to be stored with its namespace, got %q, %v", val, err)
+	}
+
+	if _, err := c.ListMove(a, "list", "moved"); err != cache.ErrListEmpty {
+		t.Fatalf("Empty list was expected, got %v", err)
+	}
+	if moved, err := c.ListMove(b, "list", "moved"); err != nil || moved != "1" {
+		t.Fatalf("Unexpected result %q, %v", moved, err)
+	}
+	if n, _ := c.IncrBy(a, "counter", 2); n != 2 {
+		t.Fatalf("2 was expected, got %d", n)
+	}
+	if n, _ := c.IncrBy(b, "counter", 1); n != 1 {
+		t.Fatalf("1 was expected, got %d", n)
+	}
+	if err := c.Del(a, "moved"); !cache.IsNotFound(err) {
+		t.Fatalf("Not found was expected, got %v", err)
+	}
+}
+
+func TestNamespacedCache_Scan(t *testing.T) {
+	c := cache.NewNamespacedCache(cache.NewMemoryCache(), namespaceOf)
+	c.Set(inNamespace("a:"), "cart:1", "a")
+	c.Set(inNamespace("a:"), "cart:2", "b")
+	c.Set(inNamespace("b:"), "cart:3", "c")
+	c.Set(context.TODO(), "cart:4", "d")
+
+	keys := []string{}
+	err := c.Scan(inNamespace("a:"), "cart:*", func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil || len(keys) != 2 || keys[0] != "cart:1" || keys[1] != "cart:2" {
+		t.Fatalf("Unexpected result %v, %v", keys, err)
+	}
+
+	keys = []string{}
+	err = c.Scan(context.TODO(), "cart:*", func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil || len(keys) != 1 || keys[0] != "cart:4" {
+		t.Fatalf("Unexpected result %v, %v", keys, err)
+	}
+}
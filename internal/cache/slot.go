@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+//ErrCrossSlot is returned on a Cluster when keys changed together are in different hash slots, as Redis can't
+//change them atomically
+var ErrCrossSlot = errors.New("cache: keys are in different hash slots of the cluster")
+
+//slotCount is how many hash slots a Redis Cluster has
+const slotCount = 16384
+
+//slot gives the hash slot of the key on a Redis Cluster, hashing only its hash tag, the part within the first
+//braces, when it has a non empty one
+func slot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % slotCount
+}
+
+//sameSlot tells whether all the keys are in the same hash slot
+func sameSlot(keys ...string) bool {
+	for _, key := range keys[1:] {
+		if slot(key) != slot(keys[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+//crc16 is the CRC16-CCITT (XMODEM) Redis Cluster hashes keys with
+func crc16(s string) uint16 {
+	crc := uint16(0)
+	for idx := 0; idx < len(s); idx++ {
+		crc ^= uint16(s[idx]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+//Partitions is how many partitions the keys changed together are spread over. The keys of a partition share its
+//hash tag, so on a Cluster they are in one hash slot and can be changed together, while the partitions may be on
+//different nodes
+const Partitions = 16
+
+//Partition gives the partition of an aggregate, so its keys and the queues it is written to along with them are
+//in the same hash slot
+func Partition(id string) int {
+	return int(crc16(id)) % Partitions
+}
+
+//PartitionTag gives the hash tag of the keys of the partition
+func PartitionTag(partition int) string {
+	return "{" + strconv.Itoa(partition) + "}"
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracedCache struct {
+	next Cache
+}
+
+//NewTracedCache decorates a Cache creating a span for every call
+func NewTracedCache(next Cache) Cache {
+	return &tracedCache{
+		next: next,
+	}
+}
+
+func (t *tracedCache) Set(ctx context.Context, key string, value interface{}) error {
+	ctx, span := startCacheSpan(ctx, "set", key)
+	err := t.next.Set(ctx, key, value)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+func (t *tracedCache) Get(ctx context.Context, key string, here interface{}) error {
+	ctx, span := startCacheSpan(ctx, "get", key)
+	err := t.next.Get(ctx, key, here)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+func (t *tracedCache) CompareAndSet(ctx context.Context, key string, old json.RawMessage, value interface{}) (bool, error) {
+	ctx, span := startCacheSpan(ctx, "compare_and_set", key)
+	stored, err := t.next.CompareAndSet(ctx, key, old, value)
+	span.SetAttributes(attribute.Bool("cache.stored", stored))
+	tracing.EndSpan(span, err)
+	return stored, err
+}
+
+func (t *tracedCache) Del(ctx context.Context, key string) error {
+	ctx, span := startCacheSpan(ctx, "del", key)
+	err := t.next.Del(ctx, key)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+func (t *tracedCache) Alive(ctx context.Context) bool {
+	ctx, span := startCacheSpan(ctx, "ping", "")
+	alive := t.next.Alive(ctx)
+	span.SetAttributes(attribute.Bool("cache.alive", alive))
+	span.End()
+	return alive
+}
+
+func (t *tracedCache) Tx(ctx context.Context, ops ...Op) error {
+	ctx, span := startCacheSpan(ctx, "tx", "")
+	span.SetAttributes(attribute.Int("cache.operations", len(ops)))
+	err := t.next.Tx(ctx, ops...)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+func (t *tracedCache) ListMove(ctx context.Context, src, dst string) (string, error) {
+	ctx, span := startCacheSpan(ctx, "list_move", src)
+	val, err := t.next.ListMove(ctx, src, dst)
+	if err == ErrListEmpty {
+		span.End()
+		return val, err
+	}
+	tracing.EndSpan(span, err)
+	return val, err
+}
+
+func (t *tracedCache) ListRemove(ctx context.Context, list, value string) error {
+	ctx, span := startCacheSpan(ctx, "list_remove", list)
+	err := t.next.ListRemove(ctx, list, value)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+func (t *tracedCache) ListRange(ctx context.Context, list string, start, stop int64) ([]string, error) {
+	ctx, span := startCacheSpan(ctx, "list_range", list)
+	vals, err := t.next.ListRange(ctx, list, start, stop)
+	tracing.EndSpan(span, err)
+	return vals, err
+}
+
+func (t *tracedCache) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	ctx, span := startCacheSpan(ctx, "incr_by", key)
+	val, err := t.next.IncrBy(ctx, key, delta)
+	tracing.EndSpan(span, err)
+	return val, err
+}
+
+func (t *tracedCache) Scan(ctx context.Context, match string, fn func(key string) error) error {
+	ctx, span := startCacheSpan(ctx, "scan", match)
+	err := t.next.Scan(ctx, match, fn)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+func startCacheSpan(ctx context.Context, operation, key string) (context.Context, trace.Span) {
+	return tracing.StartSpan(ctx, "cache."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemRedis,
+			semconv.DBOperationKey.String(operation),
+			tracing.Attr("cache.key", key),
+		),
+	)
+}
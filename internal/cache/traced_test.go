@@ -0,0 +1,38 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/go-redis/redismock/v8"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedCacheSpans(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+
+	db, mock := redismock.NewClientMock()
+	mock.ExpectGet("testKey").RedisNil()
+	mock.ExpectPing().SetVal("ok")
+	c := cache.NewTracedCache(cache.NewRedisCache(testLogger, 0, db))
+
+	str := ""
+	if c.Get(context.TODO(), "testKey", &str) == nil {
+		t.Fatalf("Error was expected")
+	}
+	if !c.Alive(context.TODO()) {
+		t.Fatalf("true was expected")
+	}
+
+	spans := sr.Ended()
+	if len(spans) != 2 || spans[0].Name() != "cache.get" || spans[1].Name() != "cache.ping" {
+		t.Fatalf("Unexpected spans %v", spans)
+	}
+	if len(spans[0].Events()) != 1 {
+		t.Fatalf("Error was expected to be recorded in the span")
+	}
+}
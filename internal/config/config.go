@@ -3,30 +3,239 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 var serviceVersion = "local"
 
 const (
-	redisServerKey    = "REDIS_SERVER"
-	redisPasswordKey  = "REDIS_PASSWORD"
-	port              = "HTTP_PORT"
-	tracingEnabledKey = "TRACING_ENABLED"
+	redisServerKey     = "REDIS_SERVER"
+	redisPasswordKey   = "REDIS_PASSWORD"
+	redisUsernameKey   = "REDIS_USERNAME"
+	redisDBKey         = "REDIS_DB"
+	redisMasterKey     = "REDIS_SENTINEL_MASTER"
+	redisSentinelPwKey = "REDIS_SENTINEL_PASSWORD"
+	redisClusterKey    = "REDIS_CLUSTER"
+	redisTLSKey        = "REDIS_TLS"
+	redisTLSServerKey  = "REDIS_TLS_SERVER_NAME"
+	redisPoolSizeKey   = "REDIS_POOL_SIZE"
+	redisDialKey       = "REDIS_DIAL_TIMEOUT"
+	redisReadKey       = "REDIS_READ_TIMEOUT"
+	redisWriteKey      = "REDIS_WRITE_TIMEOUT"
+	cacheCodecKey      = "CACHE_CODEC"
+	cacheCompressKey   = "CACHE_COMPRESSION"
+	cacheThresholdKey  = "CACHE_COMPRESSION_THRESHOLD"
+	encryptionKeysKey  = "CACHE_ENCRYPTION_KEYS"
+	encryptionFileKey  = "CACHE_ENCRYPTION_KEYS_FILE"
+	plaintextKey       = "CACHE_REJECT_PLAINTEXT"
+	port               = "HTTP_PORT"
+	grpcPortKey        = "GRPC_PORT"
+	tracingEnabledKey  = "TRACING_ENABLED"
+	tracingProviderKey = "TRACING_PROVIDER"
+	otelExporterKey    = "OTEL_EXPORTER"
+	eventsSinksKey     = "EVENTS_SINKS"
+	eventsStreamKey    = "EVENTS_STREAM"
+	eventsIntervalKey  = "EVENTS_RELAY_INTERVAL"
+	eventsLeaseKey     = "EVENTS_LEASE_TIMEOUT"
+	webhookAttemptsKey = "WEBHOOK_MAX_ATTEMPTS"
+	webhookBackoffKey  = "WEBHOOK_BACKOFF"
+	webhookTimeoutKey  = "WEBHOOK_TIMEOUT"
+	webhookLeaseKey    = "WEBHOOK_LEASE_TIMEOUT"
+	webhookPrivateKey  = "WEBHOOK_ALLOW_PRIVATE_TARGETS"
+	streamHeartbeatKey = "STREAM_HEARTBEAT"
+	streamDurationKey  = "STREAM_MAX_DURATION"
+	sessionSecretKey   = "SESSION_SECRET"
+	sessionTokenTTLKey = "SESSION_TOKEN_TTL"
+	shareSecretKey     = "SHARE_SECRET"
+	shareTokenTTLKey   = "SHARE_TOKEN_TTL"
+	priceLockTTLKey    = "PRICE_LOCK_TTL"
+	reservationKey     = "RESERVATION_WINDOW"
+	reaperIntervalKey  = "RESERVATION_REAPER_INTERVAL"
+	taxRulesFileKey    = "TAX_RULES_FILE"
+	shippingRatesKey   = "SHIPPING_RATES_FILE"
+	exchangeRatesKey   = "EXCHANGE_RATES_FILE"
+	ratesRefreshKey    = "EXCHANGE_RATES_REFRESH_INTERVAL"
+	tenantsFileKey     = "TENANTS_FILE"
+)
+
+const (
+	TracingProviderDatadog       = "datadog"
+	TracingProviderOpenTelemetry = "otel"
+
+	OTelExporterStdout = "stdout"
+	OTelExporterOTLP   = "otlp"
+
+	EventsSinkRedisStream = "redis_stream"
+	EventsSinkStdout      = "stdout"
+
+	CacheCodecJSON              = "json"
+	CacheCodecJSONMessagePack   = "json_msgpack"
+	CacheCodecJSONProtobufValue = "json_protobuf_value"
+
+	CacheCompressionNone = "none"
+	CacheCompressionGzip = "gzip"
+	CacheCompressionZstd = "zstd"
 )
 
 type Config struct {
-	RedisServer    string
-	RedisPassword  string
-	Port           string
+	//RedisServer is a comma separated list of addresses: the Redis server,
+	//the Sentinel nodes when RedisSentinelMaster is set, or the seed nodes of the Cluster
+	RedisServer   string
+	RedisPassword string
+	//RedisUsername is the ACL user, the default user when empty
+	RedisUsername string
+	//RedisDB is the database selected after connecting, it must be 0 on a Cluster
+	RedisDB int
+	//RedisSentinelMaster is the master monitored by the Sentinel nodes, connecting through them when set
+	RedisSentinelMaster   string
+	RedisSentinelPassword string
+	//RedisCluster connects to a Redis Cluster
+	RedisCluster bool
+	//RedisTLS connects over TLS, RedisTLSServerName overriding the name the certificate is checked against
+	RedisTLS           bool
+	RedisTLSServerName string
+	//RedisPoolSize is how many connections are kept to every node, 10 per CPU when 0
+	RedisPoolSize     int
+	RedisDialTimeout  time.Duration
+	RedisReadTimeout  time.Duration
+	RedisWriteTimeout time.Duration
+	//CacheCodec is how values are stored in Redis, any of json, json_msgpack and json_protobuf_value.
+	//Values stored with every codec are read whichever is set
+	CacheCodec string
+	//CacheCompression compresses the values longer than CacheCompressionThreshold bytes, any of none, gzip and zstd
+	CacheCompression          string
+	CacheCompressionThreshold int
+	//CacheEncryptionKeys encrypt the values stored, as a comma separated list of id:key with base64 encoded
+	//32 byte keys, the first one encrypting. Values are stored in plain without them
+	CacheEncryptionKeys string
+	//CacheEncryptionKeysFile is a JSON file with the keys, used instead of CacheEncryptionKeys
+	CacheEncryptionKeysFile string
+	//CacheRejectPlaintext answers values and list elements stored in plain as undecryptable rather than reading them,
+	//once every one of them was encrypted
+	CacheRejectPlaintext bool
+	Port                 string
+	//GRPCPort is where the gRPC transport listens, apart from the HTTP one
+	GRPCPort       string
 	TracingEnabled bool
+	//TracingProvider selects the tracing backend when TracingEnabled, either datadog or otel
+	TracingProvider string
+	//OTelExporter is where OpenTelemetry spans are sent, either stdout or otlp.
+	//The OTLP endpoint is read from the standard OTEL_EXPORTER_OTLP_ENDPOINT variable
+	OTelExporter string
+	//EventsSinks are where the outbox relay publishes cart events, any of redis_stream and stdout
+	EventsSinks []string
+	//EventsStream is the Redis Stream used by the redis_stream sink
+	EventsStream string
+	//EventsRelayInterval is how often the outbox is polled for new events
+	EventsRelayInterval time.Duration
+	//EventsLeaseTimeout is how long an event is kept by the instance publishing it, being taken by any
+	//instance once passed
+	EventsLeaseTimeout time.Duration
+	//WebhookMaxAttempts is how many times a webhook delivery is tried before being dead lettered
+	WebhookMaxAttempts int
+	//WebhookBackoff is the wait before retrying a webhook delivery, doubled on every retry
+	WebhookBackoff time.Duration
+	//WebhookTimeout bounds every request made to a webhook receiver
+	WebhookTimeout time.Duration
+	//WebhookLeaseTimeout is how long a webhook delivery is kept by the instance attempting it, being taken
+	//by any instance once passed. It must be longer than WebhookTimeout
+	WebhookLeaseTimeout time.Duration
+	//WebhookAllowPrivateTargets lets webhooks be delivered to loopback, private and link-local addresses,
+	//only meant for local development
+	WebhookAllowPrivateTargets bool
+	//StreamHeartbeat is how often idle cart event streams get a heartbeat comment
+	StreamHeartbeat time.Duration
+	//StreamMaxDuration ends cart event streams, it must stay under the 15s write timeout of the server
+	StreamMaxDuration time.Duration
+	//SessionSecret signs the tokens of the collaborative cart sessions, it must be shared by every instance
+	SessionSecret string
+	//SessionTokenTTL is how long a session token may be used to join
+	SessionTokenTTL time.Duration
+	//ShareSecret signs the links sharing carts, it must be shared by every instance
+	ShareSecret string
+	//ShareTokenTTL is how long a link sharing a cart works
+	ShareTokenTTL time.Duration
+	//PriceLockTTL is how long the prices of a cart stay locked for checkout
+	PriceLockTTL time.Duration
+	//ReservationWindow is how long a cart holds the stock of its items since its last change
+	ReservationWindow time.Duration
+	//ReservationReaperInterval is how often expired reservations are looked for
+	ReservationReaperInterval time.Duration
+	//TaxRulesFile is the JSON file with the tax rules of every region, carts have no taxes without it
+	TaxRulesFile string
+	//ShippingRatesFile is the JSON file with the shipping rates of every zone, carts have no shipping options without it
+	ShippingRatesFile string
+	//ExchangeRatesFile is the JSON file with the exchange rates from the currency of the catalog,
+	//read again every ExchangeRatesRefreshInterval. Prices are only given in the catalog currency without it
+	ExchangeRatesFile            string
+	ExchangeRatesRefreshInterval time.Duration
+	//TenantsFile is the JSON file with the tenants sharing the service. Without it requests belong to no tenant
+	TenantsFile string
 }
 
 func New() Config {
 	return Config{
-		RedisServer:    GetEnvString(redisServerKey, ""),
-		RedisPassword:  GetEnvString(redisPasswordKey, ""),
-		Port:           GetEnvString(port, "8080"),
-		TracingEnabled: GetEnvBool(tracingEnabledKey, false),
+		RedisServer:   GetEnvString(redisServerKey, ""),
+		RedisPassword: GetEnvString(redisPasswordKey, ""),
+
+		RedisUsername:         GetEnvString(redisUsernameKey, ""),
+		RedisDB:               GetEnvInt(redisDBKey, 0),
+		RedisSentinelMaster:   GetEnvString(redisMasterKey, ""),
+		RedisSentinelPassword: GetEnvString(redisSentinelPwKey, ""),
+		RedisCluster:          GetEnvBool(redisClusterKey, false),
+		RedisTLS:              GetEnvBool(redisTLSKey, false),
+		RedisTLSServerName:    GetEnvString(redisTLSServerKey, ""),
+		RedisPoolSize:         GetEnvInt(redisPoolSizeKey, 0),
+		RedisDialTimeout:      GetEnvDuration(redisDialKey, 5*time.Second),
+		RedisReadTimeout:      GetEnvDuration(redisReadKey, 3*time.Second),
+		RedisWriteTimeout:     GetEnvDuration(redisWriteKey, 3*time.Second),
+
+		CacheCodec:                GetEnvString(cacheCodecKey, CacheCodecJSON),
+		CacheCompression:          GetEnvString(cacheCompressKey, CacheCompressionNone),
+		CacheCompressionThreshold: GetEnvInt(cacheThresholdKey, 1024),
+		CacheEncryptionKeys:       GetEnvString(encryptionKeysKey, ""),
+		CacheEncryptionKeysFile:   GetEnvString(encryptionFileKey, ""),
+		CacheRejectPlaintext:      GetEnvBool(plaintextKey, false),
+
+		Port:            GetEnvString(port, "8080"),
+		GRPCPort:        GetEnvString(grpcPortKey, "9090"),
+		TracingEnabled:  GetEnvBool(tracingEnabledKey, false),
+		TracingProvider: GetEnvString(tracingProviderKey, TracingProviderDatadog),
+		OTelExporter:    GetEnvString(otelExporterKey, OTelExporterStdout),
+
+		EventsSinks:         GetEnvList(eventsSinksKey, []string{EventsSinkRedisStream}),
+		EventsStream:        GetEnvString(eventsStreamKey, "cart-events"),
+		EventsRelayInterval: GetEnvDuration(eventsIntervalKey, time.Second),
+		EventsLeaseTimeout:  GetEnvDuration(eventsLeaseKey, time.Minute),
+
+		WebhookMaxAttempts:         GetEnvInt(webhookAttemptsKey, 6),
+		WebhookBackoff:             GetEnvDuration(webhookBackoffKey, 2*time.Second),
+		WebhookTimeout:             GetEnvDuration(webhookTimeoutKey, 5*time.Second),
+		WebhookLeaseTimeout:        GetEnvDuration(webhookLeaseKey, time.Minute),
+		WebhookAllowPrivateTargets: GetEnvBool(webhookPrivateKey, false),
+
+		StreamHeartbeat:   GetEnvDuration(streamHeartbeatKey, 5*time.Second),
+		StreamMaxDuration: GetEnvDuration(streamDurationKey, 12*time.Second),
+
+		SessionSecret:   GetEnvString(sessionSecretKey, ""),
+		SessionTokenTTL: GetEnvDuration(sessionTokenTTLKey, time.Hour),
+
+		ShareSecret:   GetEnvString(shareSecretKey, ""),
+		ShareTokenTTL: GetEnvDuration(shareTokenTTLKey, 7*24*time.Hour),
+
+		PriceLockTTL: GetEnvDuration(priceLockTTLKey, 15*time.Minute),
+
+		ReservationWindow:         GetEnvDuration(reservationKey, 30*time.Minute),
+		ReservationReaperInterval: GetEnvDuration(reaperIntervalKey, time.Minute),
+
+		TaxRulesFile:      GetEnvString(taxRulesFileKey, ""),
+		ShippingRatesFile: GetEnvString(shippingRatesKey, ""),
+
+		ExchangeRatesFile:            GetEnvString(exchangeRatesKey, ""),
+		ExchangeRatesRefreshInterval: GetEnvDuration(ratesRefreshKey, time.Hour),
+
+		TenantsFile: GetEnvString(tenantsFileKey, ""),
 	}
 }
 
@@ -53,3 +262,42 @@ func GetEnvBool(key string, defaultValue bool) bool {
 
 	return defaultValue
 }
+
+func GetEnvInt(key string, defaultValue int) int {
+	if val := os.Getenv(key); val != "" {
+		iVal, err := strconv.Atoi(val)
+		if err != nil {
+			return defaultValue
+		}
+		return iVal
+	}
+
+	return defaultValue
+}
+
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if val := os.Getenv(key); val != "" {
+		dVal, err := time.ParseDuration(val)
+		if err != nil {
+			return defaultValue
+		}
+		return dVal
+	}
+
+	return defaultValue
+}
+
+//GetEnvList reads a comma separated list, blank elements are skipped
+func GetEnvList(key string, defaultValue []string) []string {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultValue
+	}
+	res := []string{}
+	for _, v := range strings.Split(val, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,30 @@
+package correlation
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const (
+	//Header carries the correlation ID in HTTP requests and gRPC metadata
+	Header = "X-Correlation-Id"
+	//ContextKey is where the correlation ID is kept in the context, the logger reads it from there
+	ContextKey = "correlation_id"
+)
+
+//WithID keeps id in the context, a new one is generated when id is empty.
+//It gives the context along with the ID kept
+func WithID(ctx context.Context, id string) (context.Context, string) {
+	if id == "" {
+		// generate new version 4 uuid
+		id = uuid.New().String()
+	}
+	return context.WithValue(ctx, ContextKey, id), id
+}
+
+//FromContext gives the correlation ID of the context, if any
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ContextKey).(string)
+	return id
+}
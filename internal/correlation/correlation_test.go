@@ -0,0 +1,24 @@
+package correlation_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/correlation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithID_Given(t *testing.T) {
+	ctx, id := correlation.WithID(context.Background(), "someID")
+
+	assert.Equal(t, "someID", id)
+	assert.Equal(t, "someID", correlation.FromContext(ctx))
+}
+
+func TestWithID_Generated(t *testing.T) {
+	ctx, id := correlation.WithID(context.Background(), "")
+
+	assert.NotEmpty(t, id)
+	assert.Equal(t, id, correlation.FromContext(ctx))
+	assert.Empty(t, correlation.FromContext(context.Background()))
+}
@@ -7,12 +7,99 @@ const (
 	ItemAlreadyInCartCode      = "err_item_already_in_cart"
 	ExternalApiErrorCode       = "err_external_api_error"
 	CacheErrorCode             = "err_cache"
+	ValidationErrorCode        = "err_validation"
+	WebhookNotFoundCode        = "err_webhook_not_found"
+	UnauthorizedCode           = "err_unauthorized"
+	ItemOperationsFailedCode   = "err_item_operations_failed"
+	ListNotFoundCode           = "err_list_not_found"
+	ListItemNotFoundCode       = "err_list_item_not_found"
+	PriceChangedCode           = "err_price_changed"
+	InsufficientStockCode      = "err_insufficient_stock"
+	TenantNotFoundCode         = "err_tenant_not_found"
 )
 
+//Codes lists every code a ServiceError can carry
+var Codes = []string{
+	CartNotFoundCode,
+	ItemNotFoundCode,
+	ItemNotFoundOnProviderCode,
+	ItemAlreadyInCartCode,
+	ExternalApiErrorCode,
+	CacheErrorCode,
+	ValidationErrorCode,
+	WebhookNotFoundCode,
+	UnauthorizedCode,
+	ItemOperationsFailedCode,
+	ListNotFoundCode,
+	ListItemNotFoundCode,
+	PriceChangedCode,
+	InsufficientStockCode,
+	TenantNotFoundCode,
+}
+
+//FieldViolation describes a single field of the input that failed validation
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+//ServiceError is the error returned by the services. Code identifies the kind of error,
+//Details and Fields carry context for the client and Cause keeps the underlying error
+//so it can be inspected with errors.Is / errors.As
 type ServiceError struct {
-	Code string
+	Code    string
+	Details map[string]interface{}
+	Fields  []FieldViolation
+	Cause   error
 }
 
 func (s ServiceError) Error() string {
+	if s.Cause != nil {
+		return s.Code + ": " + s.Cause.Error()
+	}
 	return s.Code
 }
+
+//Unwrap gives access to the underlying cause of the error
+func (s ServiceError) Unwrap() error {
+	return s.Cause
+}
+
+//Is reports whether target is a ServiceError with the same Code
+func (s ServiceError) Is(target error) bool {
+	switch t := target.(type) {
+	case ServiceError:
+		return t.Code == s.Code
+	case *ServiceError:
+		return t != nil && t.Code == s.Code
+	}
+	return false
+}
+
+//WithCause returns a copy of the error wrapping the given cause
+func (s ServiceError) WithCause(err error) ServiceError {
+	s.Cause = err
+	return s
+}
+
+//WithDetail returns a copy of the error with the key-value added to its details
+func (s ServiceError) WithDetail(key string, value interface{}) ServiceError {
+	details := make(map[string]interface{}, len(s.Details)+1)
+	for k, v := range s.Details {
+		details[k] = v
+	}
+	details[key] = value
+	s.Details = details
+	return s
+}
+
+//WithFieldViolation returns a copy of the error with a new field violation appended
+func (s ServiceError) WithFieldViolation(field, description string) ServiceError {
+	fields := make([]FieldViolation, 0, len(s.Fields)+1)
+	fields = append(fields, s.Fields...)
+	s.Fields = append(fields, FieldViolation{
+		Field:       field,
+		Description: description,
+	})
+	return s
+}
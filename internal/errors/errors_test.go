@@ -1,6 +1,8 @@
 package errors_test
 
 import (
+	stdErrors "errors"
+	"fmt"
 	"testing"
 
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
@@ -15,3 +17,50 @@ func TestErrorCode(t *testing.T) {
 		t.Fatalf("Error code unexpected")
 	}
 }
+
+func TestErrorCodeWithCause(t *testing.T) {
+	cause := stdErrors.New("connection refused")
+	err := errors.ServiceError{
+		Code: "TestingError",
+	}.WithCause(cause)
+
+	if err.Error() != "TestingError: connection refused" {
+		t.Fatalf("Error message unexpected: %s", err.Error())
+	}
+	if !stdErrors.Is(err, cause) {
+		t.Fatalf("Cause was expected to be reachable with errors.Is")
+	}
+}
+
+func TestErrorIsSameCode(t *testing.T) {
+	var err error = fmt.Errorf("wrapped: %w", errors.ServiceError{Code: errors.CartNotFoundCode}.WithDetail("cart_id", "someCart"))
+
+	if !stdErrors.Is(err, errors.ServiceError{Code: errors.CartNotFoundCode}) {
+		t.Fatalf("Error was expected to match the code")
+	}
+	if stdErrors.Is(err, errors.ServiceError{Code: errors.CacheErrorCode}) {
+		t.Fatalf("Error was not expected to match a different code")
+	}
+
+	sErr := errors.ServiceError{}
+	if !stdErrors.As(err, &sErr) {
+		t.Fatalf("Error was expected to be a ServiceError")
+	}
+	if sErr.Details["cart_id"] != "someCart" {
+		t.Fatalf("Detail was expected to be kept")
+	}
+}
+
+func TestErrorWithFieldViolationDoesNotShareState(t *testing.T) {
+	base := errors.ServiceError{Code: errors.ValidationErrorCode}.WithFieldViolation("id", "must not be empty")
+
+	a := base.WithFieldViolation("quantity", "must be greater than zero")
+	b := base.WithFieldViolation("name", "must not be empty")
+
+	if len(base.Fields) != 1 || len(a.Fields) != 2 || len(b.Fields) != 2 {
+		t.Fatalf("Unexpected amount of field violations")
+	}
+	if a.Fields[1].Field != "quantity" || b.Fields[1].Field != "name" {
+		t.Fatalf("Field violations were expected to be independent")
+	}
+}
@@ -0,0 +1,41 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+//Event is the envelope every domain event travels in
+type Event struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID string          `json:"aggregate_id"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Payload     json.RawMessage `json:"payload"`
+	//Tenant is the one the aggregate belongs to, set when the event is published
+	Tenant string `json:"tenant,omitempty"`
+}
+
+//New wraps payload in an Event of the given type, identified with a new UUID
+func New(eventType, aggregateID string, payload interface{}) (Event, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:          uuid.New().String(),
+		Type:        eventType,
+		AggregateID: aggregateID,
+		OccurredAt:  time.Now().UTC(),
+		Payload:     b,
+	}, nil
+}
+
+//Sink is where the relay publishes the events taken out of the outbox.
+//Publishing must be idempotent on the consumer side, as an event may be delivered more than once
+type Sink interface {
+	Publish(ctx context.Context, e Event) error
+}
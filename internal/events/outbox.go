@@ -0,0 +1,215 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+)
+
+const (
+	outboxKeyPrefix = "outbox:"
+	eventKeyPrefix  = "event:"
+
+	//DefaultLeaseTimeout is how long a relay keeps the event it publishes when no lease timeout is given
+	DefaultLeaseTimeout = time.Minute
+)
+
+//OutboxKey is the list of the IDs of the events of the partition waiting to be published. Events are in the
+//partition of their aggregate, so they are enqueued along with the change producing them
+func OutboxKey(partition int) string {
+	return outboxKeyPrefix + cache.PartitionTag(partition)
+}
+
+//ProcessingKey is the list of the IDs of the events of the partition taken by a relay and not yet acknowledged
+func ProcessingKey(partition int) string {
+	return OutboxKey(partition) + ":processing"
+}
+
+//EventKey is the key an event of the partition is stored under while it sits in the outbox
+func EventKey(partition int, id string) string {
+	return eventKeyPrefix + cache.PartitionTag(partition) + ":" + id
+}
+
+//outboxEntry is an event as it sits in the outbox, along with the lease of the relay publishing it
+type outboxEntry struct {
+	Event
+	//LeasedUntil is when the relay publishing the event gives it up, any relay may take it once passed
+	LeasedUntil time.Time `json:"leased_until"`
+}
+
+//OutboxOps gives the cache operations enqueueing the events, to be applied in the same
+//cache.Tx as the change producing them
+func OutboxOps(evs ...Event) []cache.Op {
+	ops := make([]cache.Op, 0, len(evs)*2)
+	for _, e := range evs {
+		partition := cache.Partition(e.AggregateID)
+		ops = append(ops,
+			cache.SetOp(EventKey(partition, e.ID), e),
+			cache.PushOp(OutboxKey(partition), e.ID),
+		)
+	}
+	return ops
+}
+
+//Relay moves events from the outbox to the sinks with at-least-once delivery.
+//An event is only removed once every sink accepted it, so a crash or a failing sink makes it be published again.
+//Relays on several instances share the outbox: the event taken by one of them is leased to it, and only taken by
+//another once the lease expires
+type Relay struct {
+	cache        cache.Cache
+	sinks        []Sink
+	logger       logger.Logger
+	interval     time.Duration
+	leaseTimeout time.Duration
+}
+
+//NewRelay gives a Relay polling the outbox every interval, leasing the event it publishes for leaseTimeout,
+//DefaultLeaseTimeout when zero. It must be longer than publishing to every sink takes
+func NewRelay(logger logger.Logger, c cache.Cache, interval, leaseTimeout time.Duration, sinks ...Sink) *Relay {
+	if leaseTimeout <= 0 {
+		leaseTimeout = DefaultLeaseTimeout
+	}
+	return &Relay{
+		cache:        c,
+		sinks:        sinks,
+		logger:       logger,
+		interval:     interval,
+		leaseTimeout: leaseTimeout,
+	}
+}
+
+//Run drains the outbox every interval until ctx is done
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.Drain(ctx); err != nil {
+			r.logger.WithError(err).Warn(ctx, "Outbox drain interrupted, retrying later")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//Drain publishes events until the outbox of every partition is empty, returning how many were published.
+//Events taken but never acknowledged, by a failure or a crash, are retried first, oldest to newest, once their
+//lease expired, the ones leased by other relays being left to them.
+//It stops at the first failure, keeping the failed event as the next one to be retried
+func (r *Relay) Drain(ctx context.Context) (int, error) {
+	published := 0
+	for partition := 0; partition < cache.Partitions; partition++ {
+		n, err := r.drain(ctx, partition)
+		published += n
+		if err != nil {
+			return published, err
+		}
+	}
+	return published, nil
+}
+
+//drain publishes the events of the partition until its outbox is empty
+func (r *Relay) drain(ctx context.Context, partition int) (int, error) {
+	published := 0
+
+	pending, err := r.cache.ListRange(ctx, ProcessingKey(partition), 0, -1)
+	if err != nil {
+		return published, err
+	}
+	for idx := len(pending) - 1; idx >= 0; idx-- {
+		ok, err := r.publish(ctx, partition, pending[idx])
+		if err != nil {
+			return published, err
+		}
+		if ok {
+			published++
+		}
+	}
+
+	for ctx.Err() == nil {
+		id, err := r.cache.ListMove(ctx, OutboxKey(partition), ProcessingKey(partition))
+		if err == cache.ErrListEmpty {
+			return published, nil
+		}
+		if err != nil {
+			return published, err
+		}
+		ok, err := r.publish(ctx, partition, id)
+		if err != nil {
+			return published, err
+		}
+		if ok {
+			published++
+		}
+	}
+	return published, ctx.Err()
+}
+
+//lease takes the event when its lease expired, telling whether it was taken and giving it as stored once leased.
+//The lease is only stored when the event is unchanged since read, so a single relay takes it
+func (r *Relay) lease(ctx context.Context, partition int, id string) (outboxEntry, json.RawMessage, bool, error) {
+	key := EventKey(partition, id)
+	entry := outboxEntry{}
+	raw := json.RawMessage{}
+	if err := r.cache.Get(ctx, key, &raw); err != nil {
+		return entry, nil, false, err
+	}
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return entry, nil, false, err
+	}
+	if time.Now().Before(entry.LeasedUntil) {
+		return entry, nil, false, nil
+	}
+	entry.LeasedUntil = time.Now().Add(r.leaseTimeout).UTC()
+	leased, err := r.cache.CompareAndSet(ctx, key, raw, entry)
+	if err != nil || !leased {
+		return entry, nil, false, err
+	}
+	//read as stored, for the lease to be compared with when given up
+	if err := r.cache.Get(ctx, key, &raw); err != nil {
+		return entry, nil, false, err
+	}
+	return entry, raw, true, nil
+}
+
+//publish publishes the event when it is not leased by another relay, telling whether it was published
+func (r *Relay) publish(ctx context.Context, partition int, id string) (bool, error) {
+	log := r.logger.WithField("event_id", id)
+
+	entry, leased, ok, err := r.lease(ctx, partition, id)
+	if cache.IsNotFound(err) {
+		//without its body the event can never be published, drop the reference
+		log.WithError(err).Error(ctx, "Event body not found, discarding it")
+		return false, r.cache.ListRemove(ctx, ProcessingKey(partition), id)
+	}
+	if err != nil || !ok {
+		return false, err
+	}
+	e := entry.Event
+	//the outbox is kept per tenant, but sinks may be shared by all of them
+	if t, ok := tenant.FromContext(ctx); ok {
+		e.Tenant = t.ID
+	}
+
+	for _, sink := range r.sinks {
+		if err := sink.Publish(ctx, e); err != nil {
+			log.WithField("event_type", e.Type).WithError(err).Error(ctx, "Unable to publish event")
+			//given up, so the next drain retries it rather than waiting for the lease to expire
+			entry.LeasedUntil = time.Time{}
+			r.cache.CompareAndSet(ctx, EventKey(partition, id), leased, entry)
+			return false, err
+		}
+	}
+
+	log.WithField("event_type", e.Type).Info(ctx, "Event published")
+	if err := r.cache.ListRemove(ctx, ProcessingKey(partition), id); err != nil {
+		return true, err
+	}
+	return true, r.cache.Del(ctx, EventKey(partition, id))
+}
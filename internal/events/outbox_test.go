@@ -0,0 +1,253 @@
+package events_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/events"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"github.com/go-redis/redis/v8"
+	"github.com/go-redis/redismock/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+var testLogger = logger.NewLogger("events unit test", false)
+
+//partition is the one of the events enqueued by the tests
+var partition = cache.Partition("someCart")
+
+func enqueue(t *testing.T, c cache.Cache, types ...string) []events.Event {
+	evs := []events.Event{}
+	for _, eventType := range types {
+		e, err := events.New(eventType, "someCart", map[string]string{"cart_id": "someCart"})
+		assert.Nil(t, err)
+		evs = append(evs, e)
+	}
+	assert.Nil(t, c.Tx(context.TODO(), events.OutboxOps(evs...)...))
+	return evs
+}
+
+func TestRelay_PublishesInOrderAndAcknowledges(t *testing.T) {
+	c := cache.NewMemoryCache()
+	sink := events.NewMemorySink()
+	relay := events.NewRelay(testLogger, c, 0, 0, sink)
+
+	evs := enqueue(t, c, "first", "second", "third")
+
+	n, err := relay.Drain(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+
+	published := sink.Events()
+	assert.Len(t, published, 3)
+	for idx := range evs {
+		assert.Equal(t, evs[idx].ID, published[idx].ID)
+	}
+
+	pending, _ := c.ListRange(context.TODO(), events.ProcessingKey(partition), 0, -1)
+	assert.Empty(t, pending)
+	e := events.Event{}
+	assert.True(t, cache.IsNotFound(c.Get(context.TODO(), events.EventKey(partition, evs[0].ID), &e)))
+}
+
+func TestRelay_DrainsEveryPartition(t *testing.T) {
+	c := cache.NewMemoryCache()
+	sink := events.NewMemorySink()
+	relay := events.NewRelay(testLogger, c, 0, 0, sink)
+
+	ids := map[int]string{}
+	for idx := 0; len(ids) < 3; idx++ {
+		aggregateID := fmt.Sprintf("cart-%d", idx)
+		if _, ok := ids[cache.Partition(aggregateID)]; ok {
+			continue
+		}
+		e, err := events.New("first", aggregateID, nil)
+		assert.Nil(t, err)
+		assert.Nil(t, c.Tx(context.TODO(), events.OutboxOps(e)...))
+		ids[cache.Partition(aggregateID)] = e.ID
+	}
+
+	n, err := relay.Drain(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	for p, id := range ids {
+		queued, _ := c.ListRange(context.TODO(), events.OutboxKey(p), 0, -1)
+		assert.Empty(t, queued)
+		e := events.Event{}
+		assert.True(t, cache.IsNotFound(c.Get(context.TODO(), events.EventKey(p, id), &e)))
+	}
+}
+
+func TestRelay_DrainsTheOutboxOfTheTenant(t *testing.T) {
+	c := cache.NewNamespacedCache(cache.NewMemoryCache(), tenant.Namespace)
+	sink := events.NewMemorySink()
+	relay := events.NewRelay(testLogger, c, 0, 0, sink)
+	ctx := tenant.NewContext(context.TODO(), tenant.Tenant{ID: "acme"})
+
+	e, err := events.New("first", "someCart", map[string]string{"cart_id": "someCart"})
+	assert.Nil(t, err)
+	assert.Nil(t, c.Tx(ctx, events.OutboxOps(e)...))
+
+	n, err := relay.Drain(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+
+	n, err = relay.Drain(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "acme", sink.Events()[0].Tenant)
+}
+
+func TestRelay_RetriesFailedEventFirst(t *testing.T) {
+	c := cache.NewMemoryCache()
+	sink := &flakySink{failures: 1, MemorySink: events.NewMemorySink()}
+	relay := events.NewRelay(testLogger, c, 0, 0, sink)
+
+	evs := enqueue(t, c, "first", "second")
+
+	n, err := relay.Drain(context.TODO())
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, n)
+
+	n, err = relay.Drain(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+
+	published := sink.Events()
+	assert.Len(t, published, 2)
+	assert.Equal(t, evs[0].ID, published[0].ID)
+	assert.Equal(t, evs[1].ID, published[1].ID)
+}
+
+func TestRelay_DeliversAgainAfterCrash(t *testing.T) {
+	c := cache.NewMemoryCache()
+	evs := enqueue(t, c, "first")
+
+	//a relay took the event and died before acknowledging it
+	_, err := c.ListMove(context.TODO(), events.OutboxKey(partition), events.ProcessingKey(partition))
+	assert.Nil(t, err)
+
+	sink := events.NewMemorySink()
+	n, err := events.NewRelay(testLogger, c, 0, 0, sink).Drain(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, evs[0].ID, sink.Events()[0].ID)
+}
+
+func TestRelay_LeavesEventsLeasedByOthers(t *testing.T) {
+	c := cache.NewMemoryCache()
+	evs := enqueue(t, c, "first")
+	stuck := &stuckSink{started: make(chan struct{}), release: make(chan struct{}), MemorySink: events.NewMemorySink()}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		events.NewRelay(testLogger, c, 0, time.Hour, stuck).Drain(context.TODO())
+	}()
+	<-stuck.started
+
+	//another instance drains while the event is being published
+	sink := events.NewMemorySink()
+	n, err := events.NewRelay(testLogger, c, 0, time.Hour, sink).Drain(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.Empty(t, sink.Events())
+
+	close(stuck.release)
+	<-done
+	assert.Equal(t, evs[0].ID, stuck.Events()[0].ID)
+	pending, _ := c.ListRange(context.TODO(), events.ProcessingKey(partition), 0, -1)
+	assert.Empty(t, pending)
+}
+
+func TestRelay_TakesEventsOnceTheirLeaseExpires(t *testing.T) {
+	c := cache.NewMemoryCache()
+	evs := enqueue(t, c, "first")
+	stuck := &stuckSink{started: make(chan struct{}), release: make(chan struct{}), MemorySink: events.NewMemorySink()}
+	go events.NewRelay(testLogger, c, 0, 10*time.Millisecond, stuck).Drain(context.TODO())
+	defer close(stuck.release)
+	<-stuck.started
+
+	//the relay publishing the event hangs past its lease
+	time.Sleep(20 * time.Millisecond)
+	sink := events.NewMemorySink()
+	n, err := events.NewRelay(testLogger, c, 0, 0, sink).Drain(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, evs[0].ID, sink.Events()[0].ID)
+}
+
+func TestWriterSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	e, err := events.New("cart.created", "someCart", map[string]string{"cart_id": "someCart"})
+	assert.Nil(t, err)
+
+	assert.Nil(t, events.NewWriterSink(buf).Publish(context.TODO(), e))
+	assert.True(t, strings.HasSuffix(buf.String(), "\n"))
+	assert.Contains(t, buf.String(), `"type":"cart.created"`)
+}
+
+func TestRedisStreamSink(t *testing.T) {
+	e := events.Event{
+		ID:          "someEvent",
+		Type:        "cart.created",
+		AggregateID: "someCart",
+		Tenant:      "acme",
+		OccurredAt:  time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+		Payload:     []byte(`{"cart_id":"someCart"}`),
+	}
+	args := &redis.XAddArgs{
+		Stream: "cart-events",
+		Values: []interface{}{
+			"id", "someEvent",
+			"type", "cart.created",
+			"aggregate_id", "someCart",
+			"tenant", "acme",
+			"occurred_at", "2022-01-02T03:04:05Z",
+			"payload", `{"cart_id":"someCart"}`,
+		},
+	}
+
+	db, mock := redismock.NewClientMock()
+	mock.ExpectXAdd(args).SetVal("1-0")
+	mock.ExpectXAdd(args).SetErr(fmt.Errorf("stream down"))
+	sink := events.NewRedisStreamSink(db, "cart-events")
+
+	assert.Nil(t, sink.Publish(context.TODO(), e))
+	assert.NotNil(t, sink.Publish(context.TODO(), e))
+}
+
+type flakySink struct {
+	*events.MemorySink
+	failures int
+}
+
+func (f *flakySink) Publish(ctx context.Context, e events.Event) error {
+	if f.failures > 0 {
+		f.failures--
+		return fmt.Errorf("sink unavailable")
+	}
+	return f.MemorySink.Publish(ctx, e)
+}
+
+//stuckSink blocks on the first event until released
+type stuckSink struct {
+	*events.MemorySink
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (s *stuckSink) Publish(ctx context.Context, e events.Event) error {
+	s.once.Do(func() {
+		close(s.started)
+		<-s.release
+	})
+	return s.MemorySink.Publish(ctx, e)
+}
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//MemorySink keeps every published event in memory, meant for tests
+type MemorySink struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (m *MemorySink) Publish(ctx context.Context, e Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, e)
+	return nil
+}
+
+//Events gives a copy of the events published so far
+func (m *MemorySink) Events() []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]Event, len(m.events))
+	copy(res, m.events)
+	return res
+}
+
+type writerSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+//NewWriterSink gives a Sink writing every event as a JSON line, os.Stdout being the usual writer
+func NewWriterSink(w io.Writer) Sink {
+	return &writerSink{
+		w: w,
+	}
+}
+
+func (s *writerSink) Publish(ctx context.Context, e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(b, '\n'))
+	return err
+}
+
+type redisStreamSink struct {
+	client redis.Cmdable
+	stream string
+}
+
+//NewRedisStreamSink gives a Sink adding every event to a Redis Stream, consumers read it with XREAD/XREADGROUP
+func NewRedisStreamSink(client redis.Cmdable, stream string) Sink {
+	return &redisStreamSink{
+		client: client,
+		stream: stream,
+	}
+}
+
+func (s *redisStreamSink) Publish(ctx context.Context, e Event) error {
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.stream,
+		Values: []interface{}{
+			"id", e.ID,
+			"type", e.Type,
+			"aggregate_id", e.AggregateID,
+			"tenant", e.Tenant,
+			"occurred_at", e.OccurredAt.Format(time.RFC3339Nano),
+			"payload", string(e.Payload),
+		},
+	}).Err()
+}
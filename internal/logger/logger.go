@@ -5,6 +5,8 @@ import (
 	"runtime/debug"
 
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/config"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tracing"
 	"go.uber.org/zap"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 )
@@ -86,7 +88,8 @@ func (l *logger) Warn(ctx context.Context, message string) {
 // WithField allows for the inclusion of a key-value into the log
 func (l *logger) WithField(key string, value interface{}) Logger {
 	return &logger{
-		internal: l.internal.With(key, value),
+		internal:       l.internal.With(key, value),
+		tracingEnabled: l.tracingEnabled,
 	}
 }
 
@@ -101,11 +104,12 @@ func (l *logger) WithError(err error) Logger {
 	}
 
 	return &logger{
-		internal: newLogger,
+		internal:       newLogger,
+		tracingEnabled: l.tracingEnabled,
 	}
 }
 
-// injectTracing enters correlation ID, if any, and DDog tracing information into the log.
+// injectTracing enters correlation ID and tenant, if any, and DDog or OpenTelemetry tracing information into the log.
 func (l *logger) injectTracing(ctx context.Context) *zap.SugaredLogger {
 	//add our correlation id if present
 	cid := ctx.Value("correlation_id")
@@ -113,6 +117,9 @@ func (l *logger) injectTracing(ctx context.Context) *zap.SugaredLogger {
 	if cid != nil {
 		entry = entry.With("correlation_id", ctx.Value("correlation_id"))
 	}
+	if t, ok := tenant.FromContext(ctx); ok {
+		entry = entry.With("tenant_id", t.ID)
+	}
 
 	//add datadog information if available
 	if span, ok := tracer.SpanFromContext(ctx); ok {
@@ -120,5 +127,11 @@ func (l *logger) injectTracing(ctx context.Context) *zap.SugaredLogger {
 			With("dd.span_id", span.Context().SpanID())
 	}
 
+	//add OpenTelemetry information if available
+	if traceID, spanID, ok := tracing.IDs(ctx); ok {
+		entry = entry.With("trace_id", traceID).
+			With("span_id", spanID)
+	}
+
 	return entry
 }
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "cart_api"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests handled, by route template, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency, by route template, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	cacheDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "operation_duration_seconds",
+		Help:      "Cache operation latency, by operation.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation"})
+
+	cacheErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "operation_errors_total",
+		Help:      "Cache operations that failed, by operation.",
+	}, []string{"operation"})
+
+	providerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "provider",
+		Name:      "request_duration_seconds",
+		Help:      "Item provider request latency, by operation and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "status"})
+
+	providerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "provider",
+		Name:      "request_errors_total",
+		Help:      "Item provider requests that failed, by operation.",
+	}, []string{"operation"})
+
+	cartsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "business",
+		Name:      "carts_created_total",
+		Help:      "Carts created.",
+	})
+
+	itemsAdded = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "business",
+		Name:      "items_added_total",
+		Help:      "Items added to carts.",
+	})
+
+	cartsDeleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "business",
+		Name:      "carts_deleted_total",
+		Help:      "Carts deleted.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		cacheDuration,
+		cacheErrors,
+		providerDuration,
+		providerErrors,
+		cartsCreated,
+		itemsAdded,
+		cartsDeleted,
+	)
+}
+
+//Handler exposes the registered metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+//Registry gives access to the registry holding every metric of the service
+func Registry() *prometheus.Registry {
+	return registry
+}
+
+//ObserveHTTPRequest records a served HTTP request
+func ObserveHTTPRequest(route, method string, status int, since time.Time) {
+	s := strconv.Itoa(status)
+	httpRequests.WithLabelValues(route, method, s).Inc()
+	httpDuration.WithLabelValues(route, method, s).Observe(time.Since(since).Seconds())
+}
+
+//ObserveCacheOperation records a cache operation, counting it as an error when err is not nil
+func ObserveCacheOperation(operation string, since time.Time, err error) {
+	cacheDuration.WithLabelValues(operation).Observe(time.Since(since).Seconds())
+	if err != nil {
+		cacheErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+//ObserveProviderRequest records a call to the item provider. status is 0 when no response was received
+func ObserveProviderRequest(operation string, status int, since time.Time, err error) {
+	providerDuration.WithLabelValues(operation, strconv.Itoa(status)).Observe(time.Since(since).Seconds())
+	if err != nil {
+		providerErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+//CartCreated increments the created carts counter
+func CartCreated() {
+	cartsCreated.Inc()
+}
+
+//ItemAdded increments the added items counter
+func ItemAdded() {
+	itemsAdded.Inc()
+}
+
+//CartDeleted increments the deleted carts counter
+func CartDeleted() {
+	cartsDeleted.Inc()
+}
@@ -0,0 +1,101 @@
+package metrics_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/metrics"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware_LabelsByRouteTemplate(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/cart/{cart_id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods(http.MethodGet)
+	h := metrics.Middleware(r)
+
+	for _, id := range []string{"a", "b", "c"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cart/"+id, nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+
+	body := scrape(t)
+	assert.Contains(t, body, `cart_api_http_requests_total{method="GET",route="/cart/{cart_id}",status="404"} 3`)
+	assert.NotContains(t, body, `route="/cart/a"`)
+}
+
+func TestMiddleware_LabelsUnmatchedRequests(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/wishlist/{list_id}", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+	h := metrics.Middleware(r)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/wishlist/a", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	body := scrape(t)
+	assert.Contains(t, body, `cart_api_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, body, `cart_api_http_requests_total{method="PATCH",route="unmatched",status="405"} 1`)
+	assert.NotContains(t, body, `route="/nowhere"`)
+}
+
+func TestMiddleware_LabelsNonStandardMethodsAsOther(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/items", func(w http.ResponseWriter, r *http.Request) {})
+	h := metrics.Middleware(r)
+
+	for _, method := range []string{"BREW", "PROPFIND", "get"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, "/items", nil))
+	}
+
+	body := scrape(t)
+	assert.Contains(t, body, `cart_api_http_requests_total{method="other",route="/items",status="200"} 3`)
+	assert.NotContains(t, body, `method="BREW"`)
+	assert.NotContains(t, body, `method="get"`)
+}
+
+func TestObserveCacheAndProvider(t *testing.T) {
+	metrics.ObserveCacheOperation("get", time.Now(), nil)
+	metrics.ObserveCacheOperation("get", time.Now(), fmt.Errorf("cache down"))
+	metrics.ObserveProviderRequest("get_item", 0, time.Now(), fmt.Errorf("timeout"))
+
+	body := scrape(t)
+	assert.Contains(t, body, `cart_api_cache_operation_duration_seconds_count{operation="get"} 2`)
+	assert.Contains(t, body, `cart_api_cache_operation_errors_total{operation="get"} 1`)
+	assert.Contains(t, body, `cart_api_provider_request_errors_total{operation="get_item"} 1`)
+}
+
+func TestBusinessCounters(t *testing.T) {
+	metrics.CartCreated()
+	metrics.ItemAdded()
+	metrics.CartDeleted()
+
+	body := scrape(t)
+	for _, name := range []string{
+		"cart_api_business_carts_created_total",
+		"cart_api_business_items_added_total",
+		"cart_api_business_carts_deleted_total",
+	} {
+		assert.True(t, strings.Contains(body, name+" "), "missing metric %s", name)
+	}
+}
+
+func scrape(t *testing.T) string {
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	b, err := io.ReadAll(rec.Result().Body)
+	assert.Nil(t, err)
+	return string(b)
+}
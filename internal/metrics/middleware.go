@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	unknownRoute = "unknown"
+	//unmatchedRoute labels the requests no route took, answered 404 or 405 by the router
+	unmatchedRoute = "unmatched"
+	//otherMethod labels the requests of methods outside standardMethods
+	otherMethod = "other"
+)
+
+//standardMethods are the methods requests are labelled with, any other one a client sends is labelled as
+//otherMethod so clients can't create series at will
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+//Router serves the requests of the routes it matches, *mux.Router satisfies it
+type Router interface {
+	http.Handler
+	Match(req *http.Request, match *mux.RouteMatch) bool
+}
+
+//Middleware records rate, errors and duration of every request served by router, labelled with the mux
+//route template so that path parameters such as cart IDs do not explode the metric cardinality.
+//It wraps the router itself, so the requests no route matches are recorded as well
+func Middleware(router Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		router.ServeHTTP(sr, r)
+
+		ObserveHTTPRequest(routeTemplate(router, r), methodLabel(r.Method), sr.status, start)
+	})
+}
+
+func methodLabel(method string) string {
+	if standardMethods[method] {
+		return method
+	}
+	return otherMethod
+}
+
+func routeTemplate(router Router, r *http.Request) string {
+	match := mux.RouteMatch{}
+	if !router.Match(r, &match) || match.MatchErr != nil || match.Route == nil {
+		return unmatchedRoute
+	}
+	tpl, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return unknownRoute
+	}
+	return tpl
+}
+
+//statusRecorder keeps the status code written by the handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.status = status
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(b)
+}
+
+//Flush lets streaming handlers keep working through the recorder
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+//Hijack lets connection upgrades keep working through the recorder
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
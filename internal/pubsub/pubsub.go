@@ -0,0 +1,199 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/go-redis/redis/v8"
+)
+
+//Broker fans the messages published on a topic out to every subscriber of it
+type Broker interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+	//Subscribe gives a subscription buffering up to buffer messages. A subscriber falling further
+	//behind is dropped, closing its channel, so a slow reader never blocks the others
+	Subscribe(topic string, buffer int) *Subscription
+	//Subscribers tells how many subscribers of topic this instance has
+	Subscribers(topic string) int
+}
+
+//Subscription receives the messages of a single topic until closed
+type Subscription struct {
+	//C delivers the messages, it is closed when the subscription is closed or dropped
+	C <-chan []byte
+
+	c     chan []byte
+	topic string
+	hub   *hub
+	once  sync.Once
+}
+
+//Close stops the subscription, it is safe to call more than once
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+}
+
+//hub keeps the local subscribers of every topic
+type hub struct {
+	mu   sync.Mutex
+	subs map[string]map[*Subscription]struct{}
+}
+
+func newHub() *hub {
+	return &hub{
+		subs: map[string]map[*Subscription]struct{}{},
+	}
+}
+
+func (h *hub) subscribe(topic string, buffer int) *Subscription {
+	c := make(chan []byte, buffer)
+	s := &Subscription{
+		C:     c,
+		c:     c,
+		topic: topic,
+		hub:   h,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[topic] == nil {
+		h.subs[topic] = map[*Subscription]struct{}{}
+	}
+	h.subs[topic][s] = struct{}{}
+	return s
+}
+
+func (h *hub) remove(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(s)
+}
+
+func (h *hub) removeLocked(s *Subscription) {
+	s.once.Do(func() {
+		delete(h.subs[s.topic], s)
+		if len(h.subs[s.topic]) == 0 {
+			delete(h.subs, s.topic)
+		}
+		close(s.c)
+	})
+}
+
+func (h *hub) count(topic string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[topic])
+}
+
+func (h *hub) deliver(topic string, payload []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs[topic] {
+		select {
+		case s.c <- payload:
+		default:
+			h.removeLocked(s)
+		}
+	}
+}
+
+type memoryBroker struct {
+	hub *hub
+}
+
+//NewMemoryBroker gives a Broker living in process memory, meant for tests and single instance runs
+func NewMemoryBroker() Broker {
+	return &memoryBroker{
+		hub: newHub(),
+	}
+}
+
+func (m *memoryBroker) Publish(ctx context.Context, topic string, payload []byte) error {
+	m.hub.deliver(topic, payload)
+	return nil
+}
+
+func (m *memoryBroker) Subscribe(topic string, buffer int) *Subscription {
+	return m.hub.subscribe(topic, buffer)
+}
+
+func (m *memoryBroker) Subscribers(topic string) int {
+	return m.hub.count(topic)
+}
+
+//RedisBroker shares the messages between every instance through Redis pub/sub.
+//Each instance keeps a single Redis subscription, fanned out locally to its subscribers
+type RedisBroker struct {
+	client redis.UniversalClient
+	prefix string
+	logger logger.Logger
+	hub    *hub
+
+	mu sync.Mutex
+	//ps is the subscription of Run, nil while it is not relaying
+	ps *redis.PubSub
+}
+
+//NewRedisBroker gives a RedisBroker publishing every topic on the channel prefix+topic.
+//Run must be called for messages to reach the subscribers
+func NewRedisBroker(logger logger.Logger, client redis.UniversalClient, prefix string) *RedisBroker {
+	return &RedisBroker{
+		client: client,
+		prefix: prefix,
+		logger: logger,
+		hub:    newHub(),
+	}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, topic string, payload []byte) error {
+	return b.client.Publish(ctx, b.prefix+topic, payload).Err()
+}
+
+func (b *RedisBroker) Subscribe(topic string, buffer int) *Subscription {
+	return b.hub.subscribe(topic, buffer)
+}
+
+func (b *RedisBroker) Subscribers(topic string) int {
+	return b.hub.count(topic)
+}
+
+//Run relays the messages received from Redis to the local subscribers until ctx is done
+func (b *RedisBroker) Run(ctx context.Context) {
+	ps := b.client.PSubscribe(ctx, b.prefix+"*")
+	defer ps.Close()
+
+	ch := ps.Channel()
+	b.setSubscription(ps)
+	defer b.setSubscription(nil)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				b.logger.Warn(ctx, "Redis subscription closed")
+				return
+			}
+			b.hub.deliver(msg.Channel[len(b.prefix):], []byte(msg.Payload))
+		}
+	}
+}
+
+//Health fails when Run is not relaying the messages of Redis, or its subscription can't reach Redis
+func (b *RedisBroker) Health(ctx context.Context) error {
+	b.mu.Lock()
+	ps := b.ps
+	b.mu.Unlock()
+	if ps == nil {
+		return errors.New("pubsub: not subscribed to redis")
+	}
+	return ps.Ping(ctx)
+}
+
+func (b *RedisBroker) setSubscription(ps *redis.PubSub) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ps = ps
+}
@@ -0,0 +1,92 @@
+package pubsub_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/pubsub"
+	"github.com/go-redis/redismock/v8"
+)
+
+func TestMemoryBroker_FanOut(t *testing.T) {
+	b := pubsub.NewMemoryBroker()
+	first := b.Subscribe("topic", 1)
+	second := b.Subscribe("topic", 1)
+	other := b.Subscribe("other", 1)
+	defer first.Close()
+	defer second.Close()
+	defer other.Close()
+
+	if err := b.Publish(context.TODO(), "topic", []byte("hello")); err != nil {
+		t.Fatalf("Error was not expected: %s", err)
+	}
+
+	for _, s := range []*pubsub.Subscription{first, second} {
+		if msg := <-s.C; string(msg) != "hello" {
+			t.Fatalf("Unexpected message %s", msg)
+		}
+	}
+	select {
+	case msg := <-other.C:
+		t.Fatalf("Message of another topic received: %s", msg)
+	default:
+	}
+}
+
+func TestMemoryBroker_DropsSlowSubscriber(t *testing.T) {
+	b := pubsub.NewMemoryBroker()
+	s := b.Subscribe("topic", 1)
+
+	b.Publish(context.TODO(), "topic", []byte("first"))
+	b.Publish(context.TODO(), "topic", []byte("second"))
+
+	if msg := <-s.C; string(msg) != "first" {
+		t.Fatalf("Buffered message was expected, got %s", msg)
+	}
+	if _, ok := <-s.C; ok {
+		t.Fatalf("Channel of a slow subscriber must be closed")
+	}
+	//closing a dropped subscription must not panic
+	s.Close()
+}
+
+func TestMemoryBroker_Close(t *testing.T) {
+	b := pubsub.NewMemoryBroker()
+	s := b.Subscribe("topic", 1)
+	if b.Subscribers("topic") != 1 {
+		t.Fatalf("One subscriber was expected")
+	}
+	s.Close()
+	s.Close()
+	if b.Subscribers("topic") != 0 {
+		t.Fatalf("No subscriber was expected after Close")
+	}
+
+	b.Publish(context.TODO(), "topic", []byte("hello"))
+	if _, ok := <-s.C; ok {
+		t.Fatalf("No message was expected after Close")
+	}
+}
+
+func TestRedisBroker_Publish(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	mock.ExpectPublish("prefix:topic", []byte("hello")).SetVal(1)
+	b := pubsub.NewRedisBroker(logger.NewLogger("pubsub unit test", false), db, "prefix:")
+
+	if err := b.Publish(context.TODO(), "topic", []byte("hello")); err != nil {
+		t.Fatalf("Error was not expected: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Expectations not met: %s", err)
+	}
+}
+
+func TestRedisBroker_HealthWithoutRun(t *testing.T) {
+	db, _ := redismock.NewClientMock()
+	b := pubsub.NewRedisBroker(logger.NewLogger("pubsub unit test", false), db, "prefix:")
+
+	if err := b.Health(context.TODO()); err == nil {
+		t.Fatalf("Error was expected before Run subscribes")
+	}
+}
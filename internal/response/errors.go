@@ -3,31 +3,63 @@ package response
 import "fmt"
 
 const (
-	ErrCodeInternalServerError        = "err_internal"
-	ErrDescriptionInternalServerError = "Internal Server Error"
+	ErrCodeInternalServerError = "err_internal"
+	ErrCodeBadRequest          = "err_bad_request"
 
-	ErrCodeBadRequest            = "err_bad_request"
-	ErrDescriptionBadRequestURL  = "The URL In Request contains errors"
-	ErrDescriptionBadRequestBody = "The provided body contains errors"
-
-	ErrDescriptionCartNotFound = "The Cart ID was not found"
-
-	ErrDescriptionItemAlreadyInCart = "The item already exists in the cart"
-	ErrDescriptionItemNotFound      = "The item does not exists in the cart"
-
-	ErrDescriptionItemNotFoundProvider = "The item was not found on the provider"
+	//ErrMessageBadRequestURL keys the description of a bad request whose URL has errors, rather than its body
+	ErrMessageBadRequestURL = "err_bad_request_url"
 )
 
+//ErrorDocsBaseURL is where each error code is documented, codes are appended as anchors
+var ErrorDocsBaseURL = "https://github.com/eduardohoraciosanto/bootcamp-feature-driven/blob/main/docs/errors.md"
+
 var (
-	StandardInternalServerError = Error{ErrCodeInternalServerError, ErrDescriptionInternalServerError}
-	StandardBadBodyRequest      = Error{ErrCodeBadRequest, ErrDescriptionBadRequestBody}
+	StandardInternalServerError = NewError(ErrCodeInternalServerError, ErrCodeInternalServerError)
+	StandardBadBodyRequest      = NewError(ErrCodeBadRequest, ErrCodeBadRequest)
+	StandardBadURLRequest       = NewError(ErrCodeBadRequest, ErrMessageBadRequestURL)
 )
 
-type Error struct {
-	Code        string `json:"code"`
+type FieldViolation struct {
+	Field       string `json:"field"`
 	Description string `json:"description"`
 }
 
+type Error struct {
+	Code             string                 `json:"code"`
+	Description      string                 `json:"description"`
+	Details          map[string]interface{} `json:"details,omitempty"`
+	Fields           []FieldViolation       `json:"fields,omitempty"`
+	DocumentationURL string                 `json:"documentation_url,omitempty"`
+	//MessageKey keys the description within the locale catalogs, the code when empty
+	MessageKey string `json:"-"`
+}
+
+//NewError gives the error of code described by the message keyed by key, translated when answered
+func NewError(code, key string) Error {
+	msg, _ := defaultCatalog.Message(DefaultLocale, key)
+	return Error{
+		Code:        code,
+		Description: msg,
+		MessageKey:  key,
+	}
+}
+
 func (e Error) Error() string {
 	return fmt.Sprintf("%s:%s", e.Code, e.Description)
 }
+
+//WithFieldViolation returns a copy of the error with a new field violation appended
+func (e Error) WithFieldViolation(field, description string) Error {
+	fields := make([]FieldViolation, 0, len(e.Fields)+1)
+	fields = append(fields, e.Fields...)
+	e.Fields = append(fields, FieldViolation{
+		Field:       field,
+		Description: description,
+	})
+	return e
+}
+
+//DocumentationURL gives the link where the given error code is explained
+func DocumentationURL(code string) string {
+	return ErrorDocsBaseURL + "#" + code
+}
@@ -0,0 +1,163 @@
+package response
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+//DefaultLocale is used whenever none of the locales requested by the client is available
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+var defaultCatalog = mustLoadCatalog(localeFiles, "locales")
+
+//Catalog holds the error descriptions of every shipped locale, keyed by locale and error code
+type Catalog struct {
+	messages map[string]map[string]string
+}
+
+//LoadCatalog reads every <locale>.json file inside dir
+func LoadCatalog(fsys fs.FS, dir string) (*Catalog, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	c := &Catalog{
+		messages: map[string]map[string]string{},
+	}
+	for _, file := range files {
+		b, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		msgs := map[string]string{}
+		if err := json.Unmarshal(b, &msgs); err != nil {
+			return nil, fmt.Errorf("unable to parse locale file %s: %w", file, err)
+		}
+		locale := strings.ToLower(strings.TrimSuffix(path.Base(file), ".json"))
+		c.messages[locale] = msgs
+	}
+	if _, ok := c.messages[DefaultLocale]; !ok {
+		return nil, fmt.Errorf("default locale %s not found in %s", DefaultLocale, dir)
+	}
+	return c, nil
+}
+
+func mustLoadCatalog(fsys fs.FS, dir string) *Catalog {
+	c, err := LoadCatalog(fsys, dir)
+	if err != nil {
+		panic("unable to load error catalog: " + err.Error())
+	}
+	return c
+}
+
+//DefaultCatalog gives the catalog built from the embedded locale files
+func DefaultCatalog() *Catalog {
+	return defaultCatalog
+}
+
+//Locales lists the locales available in the catalog
+func (c *Catalog) Locales() []string {
+	locales := make([]string, 0, len(c.messages))
+	for l := range c.messages {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+//Message gives the description for code in exactly the given locale
+func (c *Catalog) Message(locale, code string) (string, bool) {
+	msg, ok := c.messages[strings.ToLower(locale)][code]
+	return msg, ok
+}
+
+//Localize picks the best description for code following the Accept-Language header.
+//Each requested tag is tried in preference order, first as-is (es-ar) and then by its
+//base language (es), finally falling back to DefaultLocale. The chosen locale is returned as well.
+func (c *Catalog) Localize(acceptLanguage, code string) (msg, locale string, ok bool) {
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if msg, ok := c.Message(tag, code); ok {
+			return msg, tag, true
+		}
+		if base := strings.SplitN(tag, "-", 2)[0]; base != tag {
+			if msg, ok := c.Message(base, code); ok {
+				return msg, base, true
+			}
+		}
+	}
+	msg, ok = c.Message(DefaultLocale, code)
+	return msg, DefaultLocale, ok
+}
+
+//parseAcceptLanguage gives the language tags of the header ordered by their quality value
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	tags := []weighted{}
+	for _, part := range strings.Split(header, ",") {
+		tag, q := parseMediaRange(part)
+		if tag == "" || tag == "*" || q <= 0 {
+			continue
+		}
+		tags = append(tags, weighted{tag: strings.ReplaceAll(tag, "_", "-"), q: q})
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	res := make([]string, 0, len(tags))
+	for _, t := range tags {
+		res = append(res, t.tag)
+	}
+	return res
+}
+
+//localize translates the description of the error view model to the language requested.
+//Descriptions set explicitly by the caller, that differ from the default one, are kept as-is.
+func localize(w http.ResponseWriter, r *http.Request, vm Error) Error {
+	acceptLanguage := ""
+	if r != nil {
+		acceptLanguage = r.Header.Get("Accept-Language")
+	}
+	vm, locale := localizeError(acceptLanguage, vm)
+	if locale != "" {
+		w.Header().Set("Content-Language", locale)
+	}
+	return vm
+}
+
+//localizeError translates the description of vm, keyed by its MessageKey or else its code, giving the locale
+//used when it was translated. Descriptions not taken from the catalog are kept untouched
+func localizeError(acceptLanguage string, vm Error) (Error, string) {
+	key := vm.MessageKey
+	if key == "" {
+		key = vm.Code
+	}
+	if def, ok := defaultCatalog.Message(DefaultLocale, key); ok && vm.Description != "" && vm.Description != def {
+		return vm, ""
+	}
+	msg, locale, ok := defaultCatalog.Localize(acceptLanguage, key)
+	if !ok {
+		return vm, ""
+	}
+	vm.Description = msg
+	return vm, locale
+}
+
+//ErrorFromError gives the error as RespondWithError would answer it, translated following acceptLanguage.
+//Meant for transports writing errors outside of an HTTP response
+func ErrorFromError(acceptLanguage string, err error) Error {
+	vm, _ := localizeError(acceptLanguage, viewModelFromError(err))
+	return vm
+}
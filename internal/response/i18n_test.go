@@ -0,0 +1,144 @@
+package response_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	serviceErrors "github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/response"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalog_EveryCodeInEveryLocale(t *testing.T) {
+	catalog := response.DefaultCatalog()
+
+	codes := append([]string{
+		response.ErrCodeInternalServerError,
+		response.ErrCodeBadRequest,
+		response.ErrMessageBadRequestURL,
+	}, serviceErrors.Codes...)
+
+	assert.NotEmpty(t, catalog.Locales())
+	for _, locale := range catalog.Locales() {
+		for _, code := range codes {
+			msg, ok := catalog.Message(locale, code)
+			assert.Truef(t, ok, "code %s has no message in locale %s", code, locale)
+			assert.NotEmptyf(t, msg, "code %s has an empty message in locale %s", code, locale)
+		}
+	}
+}
+
+func TestCatalog_Localize(t *testing.T) {
+	catalog := response.DefaultCatalog()
+	es, _ := catalog.Message("es", serviceErrors.CartNotFoundCode)
+	pt, _ := catalog.Message("pt", serviceErrors.CartNotFoundCode)
+	en, _ := catalog.Message("en", serviceErrors.CartNotFoundCode)
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		expected       string
+		expectedLocale string
+	}{
+		{"exact match", "es", es, "es"},
+		{"region falls back to base", "es-AR", es, "es"},
+		{"quality order is honoured", "es;q=0.5, pt-BR;q=0.8", pt, "pt"},
+		{"unknown locale falls back to default", "fr-FR, de", en, "en"},
+		{"missing header falls back to default", "", en, "en"},
+		{"zero quality is ignored", "es;q=0, fr", en, "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, locale, ok := catalog.Localize(tt.acceptLanguage, serviceErrors.CartNotFoundCode)
+			assert.True(t, ok)
+			assert.Equal(t, tt.expected, msg)
+			assert.Equal(t, tt.expectedLocale, locale)
+		})
+	}
+}
+
+func TestLoadCatalog_MissingDefaultLocale(t *testing.T) {
+	fsys := fstest.MapFS{
+		"locales/es.json": &fstest.MapFile{Data: []byte(`{"err_internal":"Error interno"}`)},
+	}
+
+	_, err := response.LoadCatalog(fsys, "locales")
+	assert.NotNil(t, err)
+}
+
+func TestRespondWithError_Localized(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/cart/someCart", nil)
+	req.Header.Set("Accept-Language", "es-AR,es;q=0.9,en;q=0.8")
+
+	err := response.RespondWithError(rec, req, serviceErrors.ServiceError{Code: serviceErrors.CartNotFoundCode})
+	assert.Nil(t, err)
+
+	res := rec.Result()
+	defer res.Body.Close()
+
+	body := struct {
+		Error response.Error `json:"error"`
+	}{}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&body))
+
+	expected, _ := response.DefaultCatalog().Message("es", serviceErrors.CartNotFoundCode)
+	assert.Equal(t, expected, body.Error.Description)
+	assert.Equal(t, "es", res.Header.Get("Content-Language"))
+}
+
+func TestRespondWithError_KeyedDescriptionTranslated(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "es")
+
+	err := response.RespondWithError(rec, req, response.StandardBadURLRequest)
+	assert.Nil(t, err)
+
+	res := rec.Result()
+	defer res.Body.Close()
+
+	body := struct {
+		Error response.Error `json:"error"`
+	}{}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&body))
+
+	expected, _ := response.DefaultCatalog().Message("es", response.ErrMessageBadRequestURL)
+	assert.Equal(t, response.ErrCodeBadRequest, body.Error.Code)
+	assert.Equal(t, expected, body.Error.Description)
+	assert.Equal(t, "es", res.Header.Get("Content-Language"))
+}
+
+func TestRespondWithError_CustomDescriptionNotTranslated(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "es")
+
+	err := response.RespondWithError(rec, req, response.Error{
+		Code:        response.ErrCodeBadRequest,
+		Description: "The cart_id is too long",
+	})
+	assert.Nil(t, err)
+
+	res := rec.Result()
+	defer res.Body.Close()
+
+	body := struct {
+		Error response.Error `json:"error"`
+	}{}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&body))
+
+	assert.Equal(t, "The cart_id is too long", body.Error.Description)
+}
+
+func TestErrorFromError_Localized(t *testing.T) {
+	vm := response.ErrorFromError("es", serviceErrors.ServiceError{Code: serviceErrors.CartNotFoundCode})
+
+	assert.Equal(t, serviceErrors.CartNotFoundCode, vm.Code)
+	assert.Equal(t, "No se encontró el carrito", vm.Description)
+	assert.NotEmpty(t, vm.DocumentationURL)
+}
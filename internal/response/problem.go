@@ -0,0 +1,83 @@
+package response
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	contentTypeJSON    = "application/json"
+	contentTypeProblem = "application/problem+json"
+)
+
+//Problem is the RFC 7807 representation of an error
+type Problem struct {
+	Type          string                 `json:"type"`
+	Title         string                 `json:"title"`
+	Status        int                    `json:"status"`
+	Detail        string                 `json:"detail,omitempty"`
+	Instance      string                 `json:"instance,omitempty"`
+	Code          string                 `json:"code"`
+	Details       map[string]interface{} `json:"details,omitempty"`
+	InvalidParams []FieldViolation       `json:"invalid_params,omitempty"`
+}
+
+func problemFromError(r *http.Request, status int, vm Error) Problem {
+	p := Problem{
+		Type:          vm.DocumentationURL,
+		Title:         http.StatusText(status),
+		Status:        status,
+		Detail:        vm.Description,
+		Code:          vm.Code,
+		Details:       vm.Details,
+		InvalidParams: vm.Fields,
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if r != nil && r.URL != nil {
+		p.Instance = r.URL.Path
+	}
+	return p
+}
+
+//wantsProblem tells whether the client prefers application/problem+json over application/json
+func wantsProblem(r *http.Request) bool {
+	if r == nil {
+		return false
+	}
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return false
+	}
+
+	problemQ, jsonQ := -1.0, -1.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, q := parseMediaRange(part)
+		switch mediaType {
+		case contentTypeProblem:
+			problemQ = q
+		case contentTypeJSON:
+			jsonQ = q
+		}
+	}
+	return problemQ > 0 && problemQ >= jsonQ
+}
+
+//parseMediaRange splits an Accept header element into its media type and quality value
+func parseMediaRange(part string) (string, float64) {
+	params := strings.Split(part, ";")
+	mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+	q := 1.0
+	for _, param := range params[1:] {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) != 2 || strings.ToLower(kv[0]) != "q" {
+			continue
+		}
+		if v, err := strconv.ParseFloat(kv[1], 64); err == nil {
+			q = v
+		}
+	}
+	return mediaType, q
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/config"
 	serviceErrors "github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
@@ -11,6 +12,26 @@ import (
 
 type Meta struct {
 	Version string `json:"version"`
+	//ExchangeRate is set when the amounts of the data were converted to another currency
+	ExchangeRate *ExchangeRate `json:"exchange_rate,omitempty"`
+}
+
+//ExchangeRate is the rate the amounts of a response were converted with
+type ExchangeRate struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	Rate float64   `json:"rate"`
+	AsOf time.Time `json:"as_of"`
+}
+
+//MetaOption adds to the meta of a response
+type MetaOption func(*Meta)
+
+//WithExchangeRate tells the amounts of the response were converted with the rate
+func WithExchangeRate(rate ExchangeRate) MetaOption {
+	return func(m *Meta) {
+		m.ExchangeRate = &rate
+	}
 }
 
 type BaseResponse struct {
@@ -19,13 +40,17 @@ type BaseResponse struct {
 	Error interface{} `json:"error,omitempty"`
 }
 
-func newBaseResponseWithData(data interface{}) BaseResponse {
-	return BaseResponse{
+func newBaseResponseWithData(data interface{}, opts ...MetaOption) BaseResponse {
+	res := BaseResponse{
 		Meta: Meta{
 			Version: config.GetVersion(),
 		},
 		Data: data,
 	}
+	for _, opt := range opts {
+		opt(&res.Meta)
+	}
+	return res
 }
 
 func newBaseResponseWithError(err interface{}) BaseResponse {
@@ -37,26 +62,45 @@ func newBaseResponseWithError(err interface{}) BaseResponse {
 	}
 }
 
-func RespondWithData(w http.ResponseWriter, statusCode int, data interface{}) error {
-	w.Header().Set("Content-Type", "application/json")
+func RespondWithData(w http.ResponseWriter, statusCode int, data interface{}, opts ...MetaOption) error {
+	w.Header().Set("Content-Type", contentTypeJSON)
 	w.WriteHeader(statusCode)
-	return json.NewEncoder(w).Encode(newBaseResponseWithData(data))
+	return json.NewEncoder(w).Encode(newBaseResponseWithData(data, opts...))
 }
 
-func RespondWithError(w http.ResponseWriter, err error) error {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCodeFromError(err))
-	return json.NewEncoder(w).Encode(newBaseResponseWithError(viewModelFromError(err)))
+//RespondWithError writes the error using the BaseResponse envelope, or as an RFC 7807
+//problem when the request Accept header prefers application/problem+json.
+//The description is translated following the request Accept-Language header
+func RespondWithError(w http.ResponseWriter, r *http.Request, err error) error {
+	status := statusCodeFromError(err)
+	vm := localize(w, r, viewModelFromError(err))
+
+	if wantsProblem(r) {
+		w.Header().Set("Content-Type", contentTypeProblem)
+		w.WriteHeader(status)
+		return json.NewEncoder(w).Encode(problemFromError(r, status, vm))
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(newBaseResponseWithError(vm))
 }
 
 func statusCodeFromError(err error) int {
 	mErr := &serviceErrors.ServiceError{}
 	if errors.As(err, mErr) {
 		switch mErr.Code {
-		case serviceErrors.CartNotFoundCode, serviceErrors.ItemNotFoundCode, serviceErrors.ItemNotFoundOnProviderCode:
+		case serviceErrors.CartNotFoundCode, serviceErrors.ItemNotFoundCode, serviceErrors.ItemNotFoundOnProviderCode, serviceErrors.WebhookNotFoundCode,
+			serviceErrors.ListNotFoundCode, serviceErrors.ListItemNotFoundCode, serviceErrors.TenantNotFoundCode:
 			return http.StatusNotFound
-		case serviceErrors.ItemAlreadyInCartCode:
+		case serviceErrors.ItemAlreadyInCartCode, serviceErrors.ItemOperationsFailedCode, serviceErrors.InsufficientStockCode:
 			return http.StatusUnprocessableEntity
+		case serviceErrors.PriceChangedCode:
+			return http.StatusConflict
+		case serviceErrors.ValidationErrorCode:
+			return http.StatusBadRequest
+		case serviceErrors.UnauthorizedCode:
+			return http.StatusUnauthorized
 		default:
 			return http.StatusInternalServerError
 		}
@@ -74,32 +118,36 @@ func statusCodeFromError(err error) int {
 	return http.StatusInternalServerError
 }
 
+//descriptionFromError gives the default locale description for the error code
 func descriptionFromError(mErr *serviceErrors.ServiceError) string {
-
-	switch mErr.Code {
-	case serviceErrors.ItemNotFoundOnProviderCode:
-		return ErrDescriptionItemNotFoundProvider
-	case serviceErrors.CartNotFoundCode:
-		return ErrDescriptionCartNotFound
-	case serviceErrors.ItemAlreadyInCartCode:
-		return ErrDescriptionItemAlreadyInCart
-	case serviceErrors.ItemNotFoundCode:
-		return ErrDescriptionItemNotFound
+	if msg, ok := defaultCatalog.Message(DefaultLocale, mErr.Code); ok {
+		return msg
 	}
-	return ErrDescriptionInternalServerError
+	return StandardInternalServerError.Description
 }
 
 func viewModelFromError(err error) Error {
 	sErr := &serviceErrors.ServiceError{}
 	if errors.As(err, sErr) {
-		return Error{
-			Code:        err.Error(),
-			Description: descriptionFromError(sErr),
+		vm := Error{
+			Code:             sErr.Code,
+			Description:      descriptionFromError(sErr),
+			Details:          sErr.Details,
+			DocumentationURL: DocumentationURL(sErr.Code),
+		}
+		for _, f := range sErr.Fields {
+			vm = vm.WithFieldViolation(f.Field, f.Description)
 		}
+		return vm
 	}
 	vErr := Error{}
 	if errors.As(err, &vErr) {
+		if vErr.DocumentationURL == "" {
+			vErr.DocumentationURL = DocumentationURL(vErr.Code)
+		}
 		return vErr
 	}
-	return StandardInternalServerError
+	vm := StandardInternalServerError
+	vm.DocumentationURL = DocumentationURL(vm.Code)
+	return vm
 }
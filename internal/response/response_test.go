@@ -1,6 +1,7 @@
 package response_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -29,10 +30,22 @@ func TestRespondWithData(t *testing.T) {
 
 }
 
+func TestRespondWithData_ExchangeRate(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	rate := response.ExchangeRate{From: "USD", To: "EUR", Rate: 0.92}
+	err := response.RespondWithData(rec, http.StatusOK, "TestData", response.WithExchangeRate(rate))
+	assert.Nil(t, err)
+
+	vm := response.BaseResponse{}
+	assert.Nil(t, json.NewDecoder(rec.Result().Body).Decode(&vm))
+	assert.Equal(t, &rate, vm.Meta.ExchangeRate)
+}
+
 func TestRespondWithError_InternalServer(t *testing.T) {
 	rec := httptest.NewRecorder()
 
-	err := response.RespondWithError(rec, fmt.Errorf("some Error"))
+	err := response.RespondWithError(rec, httptest.NewRequest(http.MethodGet, "/", nil), fmt.Errorf("some Error"))
 	assert.Nil(t, err)
 
 	res := rec.Result()
@@ -48,7 +61,7 @@ func TestRespondWithError_IsServiceError_InternalError(t *testing.T) {
 		Code: "someCode",
 	}
 
-	err := response.RespondWithError(rec, serviceError)
+	err := response.RespondWithError(rec, httptest.NewRequest(http.MethodGet, "/", nil), serviceError)
 	assert.Nil(t, err)
 
 	res := rec.Result()
@@ -64,7 +77,7 @@ func TestRespondWithError_IsServiceError_NotFound(t *testing.T) {
 		Code: serviceErrors.CartNotFoundCode,
 	}
 
-	err := response.RespondWithError(rec, serviceError)
+	err := response.RespondWithError(rec, httptest.NewRequest(http.MethodGet, "/", nil), serviceError)
 	assert.Nil(t, err)
 
 	res := rec.Result()
@@ -80,7 +93,7 @@ func TestRespondWithError_IsServiceError_ItemNotFound(t *testing.T) {
 		Code: serviceErrors.ItemNotFoundCode,
 	}
 
-	err := response.RespondWithError(rec, serviceError)
+	err := response.RespondWithError(rec, httptest.NewRequest(http.MethodGet, "/", nil), serviceError)
 	assert.Nil(t, err)
 
 	res := rec.Result()
@@ -96,7 +109,7 @@ func TestRespondWithError_IsServiceError_ItemNotFoundOnProvider(t *testing.T) {
 		Code: serviceErrors.ItemNotFoundOnProviderCode,
 	}
 
-	err := response.RespondWithError(rec, serviceError)
+	err := response.RespondWithError(rec, httptest.NewRequest(http.MethodGet, "/", nil), serviceError)
 	assert.Nil(t, err)
 
 	res := rec.Result()
@@ -112,7 +125,7 @@ func TestRespondWithError_IsServiceError_Unprocessable(t *testing.T) {
 		Code: serviceErrors.ItemAlreadyInCartCode,
 	}
 
-	err := response.RespondWithError(rec, serviceError)
+	err := response.RespondWithError(rec, httptest.NewRequest(http.MethodGet, "/", nil), serviceError)
 	assert.Nil(t, err)
 
 	res := rec.Result()
@@ -129,7 +142,7 @@ func TestRespondWithError_IsError_InternalError(t *testing.T) {
 		Description: "someDescription",
 	}
 
-	err := response.RespondWithError(rec, resError)
+	err := response.RespondWithError(rec, httptest.NewRequest(http.MethodGet, "/", nil), resError)
 	assert.Nil(t, err)
 
 	res := rec.Result()
@@ -141,12 +154,9 @@ func TestRespondWithError_IsError_InternalError(t *testing.T) {
 func TestRespondWithError_IsError_BadRequest(t *testing.T) {
 	rec := httptest.NewRecorder()
 
-	resError := response.Error{
-		Code:        response.ErrCodeBadRequest,
-		Description: response.ErrDescriptionBadRequestBody,
-	}
+	resError := response.StandardBadBodyRequest
 
-	err := response.RespondWithError(rec, resError)
+	err := response.RespondWithError(rec, httptest.NewRequest(http.MethodGet, "/", nil), resError)
 	assert.Nil(t, err)
 
 	res := rec.Result()
@@ -154,3 +164,68 @@ func TestRespondWithError_IsError_BadRequest(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
 }
+
+func TestRespondWithError_DefaultEnvelopeWithDetails(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/cart/someCart", nil)
+
+	serviceError := serviceErrors.ServiceError{
+		Code: serviceErrors.CartNotFoundCode,
+	}.WithDetail("cart_id", "someCart").WithCause(fmt.Errorf("redis: nil"))
+
+	err := response.RespondWithError(rec, req, serviceError)
+	assert.Nil(t, err)
+
+	res := rec.Result()
+	defer res.Body.Close()
+
+	body := struct {
+		Error response.Error `json:"error"`
+	}{}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&body))
+
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	assert.Equal(t, serviceErrors.CartNotFoundCode, body.Error.Code)
+	assert.Equal(t, "someCart", body.Error.Details["cart_id"])
+	assert.Equal(t, response.DocumentationURL(serviceErrors.CartNotFoundCode), body.Error.DocumentationURL)
+}
+
+func TestRespondWithError_ProblemJSON(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/cart/someCart/item", nil)
+	req.Header.Set("Accept", "application/json;q=0.5, application/problem+json")
+
+	serviceError := serviceErrors.ServiceError{
+		Code: serviceErrors.ValidationErrorCode,
+	}.WithFieldViolation("quantity", "must be greater than zero")
+
+	err := response.RespondWithError(rec, req, serviceError)
+	assert.Nil(t, err)
+
+	res := rec.Result()
+	defer res.Body.Close()
+
+	problem := response.Problem{}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&problem))
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assert.Equal(t, "application/problem+json", res.Header.Get("Content-Type"))
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
+	assert.Equal(t, "/cart/someCart/item", problem.Instance)
+	assert.Equal(t, response.DocumentationURL(serviceErrors.ValidationErrorCode), problem.Type)
+	assert.Equal(t, []response.FieldViolation{{Field: "quantity", Description: "must be greater than zero"}}, problem.InvalidParams)
+}
+
+func TestRespondWithError_ProblemJSONNotPreferred(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/problem+json;q=0.2, application/json")
+
+	err := response.RespondWithError(rec, req, fmt.Errorf("some Error"))
+	assert.Nil(t, err)
+
+	res := rec.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+}
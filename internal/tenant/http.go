@@ -0,0 +1,25 @@
+package tenant
+
+import (
+	"net/http"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/response"
+)
+
+//Middleware keeps the tenant of the request in its context, answering requests telling an unknown tenant
+//with an error. Requests telling no tenant go on without one
+func Middleware(reg *Registry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t, ok, err := reg.Resolve(r.Header.Get(APIKeyHeader), r.Header.Get(Header), r.Host)
+			if err != nil {
+				response.RespondWithError(w, r, err)
+				return
+			}
+			if ok {
+				r = r.WithContext(NewContext(r.Context(), t))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+)
+
+const (
+	//Header names the tenant of an HTTP request or gRPC call
+	Header = "X-Tenant-Id"
+	//APIKeyHeader carries the API key of the tenant making the request, telling the tenant on its own
+	APIKeyHeader = "X-Api-Key"
+
+	keyPrefix = "tenant:"
+)
+
+//validID keeps tenant IDs safe to use within keys and key patterns
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+//Tenant is a store sharing the service with others, with its data kept apart from theirs
+type Tenant struct {
+	ID string `json:"id"`
+	//Hosts are the hostnames serving the tenant, requests sent to them belong to it without naming it
+	Hosts []string `json:"hosts"`
+	//APIKeys identify the requests of the tenant
+	APIKeys []string `json:"api_keys"`
+	//CatalogURL is where the items of the tenant are fetched from, the default catalog when empty
+	CatalogURL string `json:"catalog_url"`
+	//Currency is the one amounts are given in when the request asks for none, the catalog currency when empty
+	Currency string `json:"currency"`
+	Limits   Limits `json:"limits"`
+}
+
+//Limits bound the carts of a tenant, zero meaning no limit
+type Limits struct {
+	//MaxLines is how many lines a cart may have
+	MaxLines int `json:"max_lines"`
+	//MaxQuantity is the most units a line may have
+	MaxQuantity int `json:"max_quantity"`
+}
+
+type contextKey struct{}
+
+//NewContext gives a context carrying the tenant, the services down the call work within it
+func NewContext(ctx context.Context, t Tenant) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+//FromContext gives the tenant carried by the context, ok is false for requests of no tenant
+func FromContext(ctx context.Context) (Tenant, bool) {
+	t, ok := ctx.Value(contextKey{}).(Tenant)
+	return t, ok
+}
+
+//Namespace gives the prefix of the keys of the tenant carried by the context, empty when there is none
+func Namespace(ctx context.Context) string {
+	if t, ok := FromContext(ctx); ok {
+		return keyPrefix + t.ID + ":"
+	}
+	return ""
+}
+
+//Registry holds the tenants sharing the service. A nil Registry has no tenants
+type Registry struct {
+	tenants  []Tenant
+	byID     map[string]Tenant
+	byHost   map[string]Tenant
+	byAPIKey map[string]Tenant
+}
+
+type registryFile struct {
+	Tenants []Tenant `json:"tenants"`
+}
+
+//Load reads the registry from a JSON file listing the tenants
+func Load(path string) (*Registry, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := registryFile{}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("unable to parse tenants %s: %w", path, err)
+	}
+	reg, err := NewRegistry(f.Tenants...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tenants %s: %w", path, err)
+	}
+	return reg, nil
+}
+
+//NewRegistry gives a registry of the tenants, checking their IDs are valid and that no ID,
+//host nor API key is used twice
+func NewRegistry(tenants ...Tenant) (*Registry, error) {
+	r := &Registry{
+		byID:     map[string]Tenant{},
+		byHost:   map[string]Tenant{},
+		byAPIKey: map[string]Tenant{},
+	}
+	for _, t := range tenants {
+		if !validID.MatchString(t.ID) {
+			return nil, fmt.Errorf("tenant id %q must be up to 63 lowercase letters, digits and dashes", t.ID)
+		}
+		if _, ok := r.byID[t.ID]; ok {
+			return nil, fmt.Errorf("tenant %s is repeated", t.ID)
+		}
+		if t.Limits.MaxLines < 0 || t.Limits.MaxQuantity < 0 {
+			return nil, fmt.Errorf("tenant %s: limits must not be negative", t.ID)
+		}
+		t.Currency = strings.ToUpper(t.Currency)
+		for _, h := range t.Hosts {
+			h = strings.ToLower(h)
+			if other, ok := r.byHost[h]; ok {
+				return nil, fmt.Errorf("tenant %s: host %s is already served to tenant %s", t.ID, h, other.ID)
+			}
+			r.byHost[h] = t
+		}
+		for _, k := range t.APIKeys {
+			if k == "" {
+				return nil, fmt.Errorf("tenant %s: api keys must not be empty", t.ID)
+			}
+			if other, ok := r.byAPIKey[k]; ok {
+				return nil, fmt.Errorf("tenant %s: an api key is already given to tenant %s", t.ID, other.ID)
+			}
+			r.byAPIKey[k] = t
+		}
+		r.byID[t.ID] = t
+		r.tenants = append(r.tenants, t)
+	}
+	return r, nil
+}
+
+//Tenants gives every tenant, in the order they were registered
+func (r *Registry) Tenants() []Tenant {
+	if r == nil {
+		return nil
+	}
+	return r.tenants
+}
+
+//Resolve tells the tenant of a request from its API key, the tenant it names and the host it was sent to,
+//in that order. ok is false when the request tells no tenant, leaving it out of every tenant.
+//Tenants with API keys are only told by one of them: naming them or being sent to their hosts without it
+//fails with err_unauthorized, as anyone may set those. Unknown API keys, and API keys of another tenant than
+//the one named, fail with err_unauthorized as well, and naming an unknown tenant fails with err_tenant_not_found.
+//Unknown hosts tell no tenant
+func (r *Registry) Resolve(apiKey, id, host string) (Tenant, bool, error) {
+	var byID, byAPIKey, byHost map[string]Tenant
+	if r != nil {
+		byID, byAPIKey, byHost = r.byID, r.byAPIKey, r.byHost
+	}
+	if apiKey != "" {
+		t, ok := byAPIKey[apiKey]
+		if !ok || (id != "" && id != t.ID) {
+			return Tenant{}, false, errors.ServiceError{Code: errors.UnauthorizedCode}
+		}
+		return t, true, nil
+	}
+	if id != "" {
+		t, ok := byID[id]
+		if !ok {
+			return Tenant{}, false, errors.ServiceError{Code: errors.TenantNotFoundCode}.
+				WithDetail("tenant_id", id)
+		}
+		return keyless(t)
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	t, ok := byHost[strings.ToLower(host)]
+	if !ok {
+		return Tenant{}, false, nil
+	}
+	return keyless(t)
+}
+
+//keyless gives the tenant told without an API key, failing when it has any
+func keyless(t Tenant) (Tenant, bool, error) {
+	if len(t.APIKeys) > 0 {
+		return Tenant{}, false, errors.ServiceError{Code: errors.UnauthorizedCode}
+	}
+	return t, true, nil
+}
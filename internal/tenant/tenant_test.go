@@ -0,0 +1,142 @@
+package tenant_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"github.com/stretchr/testify/assert"
+)
+
+func fixtureRegistry(t *testing.T) *tenant.Registry {
+	reg, err := tenant.Load("testdata/tenants.json")
+	assert.Nil(t, err)
+	return reg
+}
+
+func TestLoad(t *testing.T) {
+	reg := fixtureRegistry(t)
+
+	tenants := reg.Tenants()
+	assert.Len(t, tenants, 3)
+	assert.Equal(t, "acme", tenants[0].ID)
+	assert.Equal(t, "https://catalog.acme.test", tenants[0].CatalogURL)
+	assert.Equal(t, "EUR", tenants[0].Currency)
+	assert.Equal(t, tenant.Limits{MaxLines: 2, MaxQuantity: 5}, tenants[0].Limits)
+}
+
+func TestNewRegistryValidation(t *testing.T) {
+	for name, tenants := range map[string][]tenant.Tenant{
+		"invalid id":      {{ID: "Acme Inc"}},
+		"empty id":        {{ID: ""}},
+		"repeated id":     {{ID: "acme"}, {ID: "acme"}},
+		"repeated host":   {{ID: "acme", Hosts: []string{"shop.test"}}, {ID: "globex", Hosts: []string{"SHOP.test"}}},
+		"repeated key":    {{ID: "acme", APIKeys: []string{"k"}}, {ID: "globex", APIKeys: []string{"k"}}},
+		"empty key":       {{ID: "acme", APIKeys: []string{""}}},
+		"negative limits": {{ID: "acme", Limits: tenant.Limits{MaxLines: -1}}},
+	} {
+		_, err := tenant.NewRegistry(tenants...)
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	reg := fixtureRegistry(t)
+
+	for name, tc := range map[string]struct {
+		apiKey, id, host string
+		want             string
+	}{
+		"by api key":        {apiKey: "acme-key", host: "globex.test", want: "acme"},
+		"by api key and id": {apiKey: "acme-key", id: "acme", want: "acme"},
+		"by id":             {id: "initrode", host: "shop.acme.test", want: "initrode"},
+		"by host":           {host: "shop.initrode.test", want: "initrode"},
+		"by host with port": {host: "Shop.Initrode.test:8080", want: "initrode"},
+		"unknown host":      {host: "localhost:8080"},
+		"nothing told":      {},
+	} {
+		got, ok, err := reg.Resolve(tc.apiKey, tc.id, tc.host)
+		assert.Nil(t, err, name)
+		assert.Equal(t, tc.want != "", ok, name)
+		assert.Equal(t, tc.want, got.ID, name)
+	}
+
+	//tenants with API keys are only told by them, anyone may name them or set the host
+	_, _, err := reg.Resolve("", "globex", "")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.UnauthorizedCode})
+	_, _, err = reg.Resolve("", "", "shop.acme.test")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.UnauthorizedCode})
+
+	_, _, err = reg.Resolve("unknown-key", "", "")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.UnauthorizedCode})
+	//the API key of a tenant does not open the data of another
+	_, _, err = reg.Resolve("acme-key", "globex", "")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.UnauthorizedCode})
+	_, _, err = reg.Resolve("", "umbrella", "")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.TenantNotFoundCode})
+
+	//without tenants, naming one fails as well
+	var none *tenant.Registry
+	_, _, err = none.Resolve("", "acme", "")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.TenantNotFoundCode})
+	_, ok, err := none.Resolve("", "", "shop.acme.test")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestNamespace(t *testing.T) {
+	assert.Equal(t, "", tenant.Namespace(context.Background()))
+	ctx := tenant.NewContext(context.Background(), tenant.Tenant{ID: "acme"})
+	assert.Equal(t, "tenant:acme:", tenant.Namespace(ctx))
+}
+
+func TestMiddleware(t *testing.T) {
+	var got string
+	handler := tenant.Middleware(fixtureRegistry(t))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tn, _ := tenant.FromContext(r.Context())
+		got = tn.ID
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "http://shop.initrode.test/cart/1", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "initrode", got)
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/cart/1", nil)
+	req.Header.Set(tenant.APIKeyHeader, "acme-key")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "acme", got)
+
+	//the client of a tenant can't reach the data of another by naming it
+	for _, headers := range []map[string]string{
+		{tenant.APIKeyHeader: "acme-key", tenant.Header: "globex"},
+		{tenant.Header: "globex"},
+	} {
+		req = httptest.NewRequest(http.MethodGet, "http://localhost/cart/1", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, headers)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/cart/1", nil)
+	req.Header.Set(tenant.Header, "umbrella")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), errors.TenantNotFoundCode)
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/cart/1", nil)
+	req.Header.Set(tenant.APIKeyHeader, "wrong")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
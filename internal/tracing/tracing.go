@@ -0,0 +1,151 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/eduardohoraciosanto/bootcamp-feature-driven"
+
+//installed is the exporter of the installed provider, nil until NewOpenTelemetryProvider is called
+var installed struct {
+	sync.Mutex
+	exp *recordingExporter
+}
+
+//recordingExporter keeps the outcome of the last export of the exporter it wraps
+type recordingExporter struct {
+	sdktrace.SpanExporter
+
+	mu  sync.Mutex
+	err error
+}
+
+func (e *recordingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.err = err
+	return err
+}
+
+//ExporterHealth fails when OpenTelemetry was not started, or the last spans exported could not be sent
+func ExporterHealth(ctx context.Context) error {
+	installed.Lock()
+	exp := installed.exp
+	installed.Unlock()
+	if exp == nil {
+		return errors.New("tracing: OpenTelemetry is not started")
+	}
+	exp.mu.Lock()
+	defer exp.mu.Unlock()
+	if exp.err != nil {
+		return fmt.Errorf("tracing: exporting spans: %w", exp.err)
+	}
+	return nil
+}
+
+//NewOpenTelemetryProvider builds a TracerProvider sending spans to the given exporter and installs it,
+//together with the W3C trace context propagator, as the global one.
+//Until it is called every span created by this package is a no-op.
+func NewOpenTelemetryProvider(ctx context.Context, service, exporter string) (*sdktrace.TracerProvider, error) {
+	var (
+		exp sdktrace.SpanExporter
+		err error
+	)
+	switch exporter {
+	case config.OTelExporterStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case config.OTelExporterOTLP:
+		exp, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown OpenTelemetry exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	recording := &recordingExporter{SpanExporter: exp}
+	installed.Lock()
+	installed.exp = recording
+	installed.Unlock()
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(recording),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(service),
+			semconv.ServiceVersionKey.String(config.GetVersion()),
+		)),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return tp, nil
+}
+
+//StartSpan starts a span as child of the one in ctx, if any
+func StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+//EndSpan records err, if any, on the span and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+//StartClientSpan starts a span for an outbound HTTP request, as child of the one in ctx,
+//and injects the W3C traceparent header into the request
+func StartClientSpan(ctx context.Context, req *http.Request, name string) trace.Span {
+	ctx, span := StartSpan(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return span
+}
+
+//EndClientSpan records the outcome of an outbound HTTP request and ends its span
+func EndClientSpan(span trace.Span, res *http.Response, err error) {
+	if res != nil {
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(res.StatusCode)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(res.StatusCode))
+	}
+	EndSpan(span, err)
+}
+
+//IDs gives the trace and span IDs of the span in ctx, ok is false when there is none
+func IDs(ctx context.Context) (traceID, spanID string, ok bool) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return "", "", false
+	}
+	return sc.TraceID().String(), sc.SpanID().String(), true
+}
+
+//Attr is a shorthand for a string attribute
+func Attr(key, value string) attribute.KeyValue {
+	return attribute.String(key, value)
+}
@@ -0,0 +1,100 @@
+package tracing_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/config"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tracing"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupRecorder() *tracetest.SpanRecorder {
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return sr
+}
+
+func TestMiddleware_SpanPerRoute(t *testing.T) {
+	sr := setupRecorder()
+
+	var traceID string
+	r := mux.NewRouter()
+	r.Use(tracing.Middleware)
+	r.HandleFunc("/cart/{cart_id}", func(w http.ResponseWriter, r *http.Request) {
+		traceID, _, _ = tracing.IDs(r.Context())
+		w.WriteHeader(http.StatusInternalServerError)
+	}).Methods(http.MethodGet)
+
+	req := httptest.NewRequest(http.MethodGet, "/cart/someCart", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "GET /cart/{cart_id}", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+}
+
+func TestClientSpan_PropagatesTraceparent(t *testing.T) {
+	sr := setupRecorder()
+
+	ctx, parent := tracing.StartSpan(context.Background(), "parent")
+
+	req, err := http.NewRequest(http.MethodGet, "http://provider/products/1", nil)
+	assert.Nil(t, err)
+
+	span := tracing.StartClientSpan(ctx, req, "provider.get_item")
+	tracing.EndClientSpan(span, &http.Response{StatusCode: http.StatusOK}, nil)
+	parent.End()
+
+	traceID, _, _ := tracing.IDs(ctx)
+	assert.Contains(t, req.Header.Get("traceparent"), traceID)
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "provider.get_item", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+}
+
+func TestEndSpan_RecordsError(t *testing.T) {
+	sr := setupRecorder()
+
+	_, span := tracing.StartSpan(context.Background(), "failing")
+	tracing.EndSpan(span, fmt.Errorf("boom"))
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Len(t, spans[0].Events(), 1)
+}
+
+func TestIDs_NoSpan(t *testing.T) {
+	_, _, ok := tracing.IDs(context.Background())
+	assert.False(t, ok)
+}
+
+func TestExporterHealth(t *testing.T) {
+	assert.NotNil(t, tracing.ExporterHealth(context.Background()))
+
+	tp, err := tracing.NewOpenTelemetryProvider(context.Background(), "tracing unit test", config.OTelExporterStdout)
+	assert.Nil(t, err)
+	defer tp.Shutdown(context.Background())
+	_, span := tracing.StartSpan(context.Background(), "exported")
+	span.End()
+	assert.Nil(t, tp.ForceFlush(context.Background()))
+
+	assert.Nil(t, tracing.ExporterHealth(context.Background()))
+}
@@ -2,18 +2,35 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/config"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/events"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/metrics"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/pubsub"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tracing"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/collab"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/currency"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/health"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/inventory"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/shipping"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/tax"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/webhook"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/wishlist"
+	grpctransport "github.com/eduardohoraciosanto/bootcamp-feature-driven/transport/grpc"
 	transport "github.com/eduardohoraciosanto/bootcamp-feature-driven/transport/http"
 	"github.com/go-redis/redis/v8"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
@@ -22,32 +39,47 @@ import (
 func main() {
 	conf := config.New()
 
-	if conf.TracingEnabled {
-		tracer.Start()
-		defer tracer.Stop()
-	}
+	stopTracing := startTracing(conf)
 
 	l := logger.NewLogger("shopping cart api", conf.TracingEnabled)
 
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     conf.RedisServer,
-		Password: conf.RedisPassword,
-	})
+	redisClient := newRedisClient(conf)
 
 	cacheClient := cache.NewRedisCache(
 		l.WithField("svc", "cache"),
 		0,
 		redisClient,
+		cacheOptions(conf)...,
 	)
+	if conf.TracingEnabled && conf.TracingProvider == config.TracingProviderOpenTelemetry {
+		cacheClient = cache.NewTracedCache(cacheClient)
+	}
+	if keys := cacheKeyring(conf, l); keys != nil {
+		//under the namespacing, so values are bound to the key of their tenant and list elements to the tenant
+		opts := []cache.EncryptionOption{cache.WithNamespace(tenant.Namespace)}
+		if conf.CacheRejectPlaintext {
+			opts = append(opts, cache.RejectingPlaintext())
+		}
+		cacheClient = cache.NewEncryptedCache(cacheClient, keys, opts...)
+	}
+	//every tenant has keys of its own, so none can read the data of another
+	cacheClient = cache.NewNamespacedCache(cacheClient, tenant.Namespace)
+	tenants := tenantRegistry(conf, l)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate-carts" {
+		code := migrateCarts(os.Args[2:], l, cacheClient, tenants)
+		stopTracing()
+		os.Exit(code)
+	}
 
 	isvc := item.NewExternalService(l.WithField("svc", "external service"), &http.Client{
 		Timeout: time.Second * 10,
 	})
 
-	hsvc := health.NewService(
+	invsvc := inventory.NewService(
+		l.WithField("svc", "inventory service"),
 		cacheClient,
-		isvc,
-		l.WithField("svc", "health service"),
+		conf.ReservationWindow,
 	)
 
 	csvc := cart.NewCartService(
@@ -55,9 +87,114 @@ func main() {
 		l.WithField("svc", "cart service"),
 		cacheClient,
 		isvc,
+		cart.WithShareSecret(shareSecret(conf, l)),
+		cart.WithShareTTL(conf.ShareTokenTTL),
+		cart.WithPriceLockTTL(conf.PriceLockTTL),
+		cart.WithInventory(invsvc),
+		cart.WithTaxes(taxCalculator(conf, l)),
+		cart.WithShipping(shippingProvider(conf, l)),
+	)
+
+	webhookOpts, webhookClient := []webhook.ServiceOption{}, webhook.NewHTTPClient(conf.WebhookTimeout)
+	if conf.WebhookAllowPrivateTargets {
+		l.Warn(context.Background(), "Webhooks may be delivered to private addresses, WEBHOOK_ALLOW_PRIVATE_TARGETS is only meant for local development")
+		webhookOpts = append(webhookOpts, webhook.AllowPrivateTargets())
+		webhookClient = &http.Client{
+			Timeout: conf.WebhookTimeout,
+		}
+	}
+	wsvc := webhook.NewService(
+		l.WithField("svc", "webhook service"),
+		cacheClient,
+		webhookOpts...,
 	)
 
-	httpTransportRouter := transport.NewHTTPRouter(hsvc, csvc, isvc)
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	broker := pubsub.NewRedisBroker(
+		l.WithField("svc", "cart stream broker"),
+		redisClient,
+		"cart-stream:",
+	)
+	go broker.Run(relayCtx)
+
+	stream := cart.NewStream(
+		l.WithField("svc", "cart stream"),
+		cacheClient,
+		broker,
+		cart.StreamOptions{
+			Heartbeat:   conf.StreamHeartbeat,
+			MaxDuration: conf.StreamMaxDuration,
+		},
+	)
+
+	ssvc := collab.NewService(
+		l.WithField("svc", "collab service"),
+		cacheClient,
+		broker,
+		sessionSecret(conf, l),
+		conf.SessionTokenTTL,
+	)
+
+	lsvc := wishlist.NewService(
+		l.WithField("svc", "wishlist service"),
+		cacheClient,
+		csvc,
+		isvc,
+	)
+
+	relay := events.NewRelay(
+		l.WithField("svc", "outbox relay"),
+		cacheClient,
+		conf.EventsRelayInterval,
+		conf.EventsLeaseTimeout,
+		append(eventSinks(conf, redisClient), wsvc, stream)...,
+	)
+
+	if conf.WebhookLeaseTimeout <= conf.WebhookTimeout {
+		//a delivery still being attempted would be taken by another instance
+		panic("WEBHOOK_LEASE_TIMEOUT must be longer than WEBHOOK_TIMEOUT")
+	}
+	webhookWorker := webhook.NewWorker(
+		l.WithField("svc", "webhook worker"),
+		cacheClient,
+		webhookClient,
+		webhook.WorkerOptions{
+			MaxAttempts:  conf.WebhookMaxAttempts,
+			Backoff:      conf.WebhookBackoff,
+			LeaseTimeout: conf.WebhookLeaseTimeout,
+		},
+	)
+
+	reaper := inventory.NewReaper(
+		l.WithField("svc", "reservation reaper"),
+		invsvc,
+		conf.ReservationReaperInterval,
+	)
+
+	hsvc := health.NewService(l.WithField("svc", "health service"))
+	hsvc.Register(health.NewChecker("cache", cache.Health(cacheClient)))
+	hsvc.Register(health.NewChecker("external", isvc.Health))
+	//live streams and collaboration miss updates without the broker, while carts keep working
+	hsvc.Register(health.NewChecker("broker", broker.Health), health.NonCritical())
+	hsvc.Register(health.NewChecker("webhooks", webhookWorker.Health), health.NonCritical())
+	if conf.TracingEnabled && conf.TracingProvider == config.TracingProviderOpenTelemetry {
+		hsvc.Register(health.NewChecker("otel_exporter", tracing.ExporterHealth), health.NonCritical())
+	}
+
+	//the outbox, the webhooks and the reservations are kept per tenant, so each one has its own workers
+	for _, ctx := range tenantContexts(relayCtx, tenants) {
+		go relay.Run(ctx)
+		go webhookWorker.Run(ctx)
+		go reaper.Run(ctx)
+	}
+
+	conv := currencyConverter(conf, l)
+	if conv != nil {
+		go conv.Run(relayCtx)
+	}
+	checkTenantCurrencies(tenants, conv)
+
+	httpTransportRouter := transport.NewHTTPRouter(hsvc, csvc, isvc, wsvc, stream, ssvc, lsvc, conv, tenants)
 
 	srv := &http.Server{
 		Addr: fmt.Sprintf("0.0.0.0:%s", conf.Port),
@@ -65,7 +202,8 @@ func main() {
 		WriteTimeout: time.Second * 15,
 		ReadTimeout:  time.Second * 15,
 		IdleTimeout:  time.Second * 60,
-		Handler:      httpTransportRouter,
+		//wrapping the router, so the requests no route matches are counted too
+		Handler: metrics.Middleware(httpTransportRouter),
 	}
 
 	l.WithField(
@@ -84,6 +222,27 @@ func main() {
 		}
 	}()
 
+	grpcSrv := grpctransport.NewGRPCServer(l.WithField("transport", "grpc"), tenants, hsvc, csvc, isvc)
+	grpcListener, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%s", conf.GRPCPort))
+	if err != nil {
+		panic("unable to listen for gRPC: " + err.Error())
+	}
+
+	l.WithField(
+		"transport", "grpc").
+		WithField(
+			"port", conf.GRPCPort).
+		Info(context.Background(), "Transport Start")
+
+	go func() {
+		if err := grpcSrv.Serve(grpcListener); err != nil {
+			l.WithField(
+				"transport", "grpc").
+				WithError(err).
+				Info(context.Background(), "Transport Stopped")
+		}
+	}()
+
 	c := make(chan os.Signal, 1)
 	// We'll accept graceful shutdowns when quit via SIGINT (Ctrl+C)
 	// SIGKILL, SIGQUIT or SIGTERM (Ctrl+/) will not be caught.
@@ -97,9 +256,267 @@ func main() {
 	// Doesn't block if no connections, but will otherwise wait
 	// until the timeout deadline.
 	srv.Shutdown(ctx)
+	grpcSrv.GracefulStop()
 	// Optionally, you could run srv.Shutdown in a goroutine and block on
 	// <-ctx.Done() if your application should wait for other services
 	// to finalize based on context cancellation.
+	stopRelay()
+	stopTracing()
 	l.Info(context.Background(), "Service gracefully shutted down")
 	os.Exit(0)
 }
+
+// migrateCarts runs the migrate-carts command, upgrading every stored cart to the current schema version,
+// the ones of no tenant first and then the ones of every tenant, and printing the progress. It gives the
+// exit code, failing when any cart could not be migrated.
+func migrateCarts(args []string, l logger.Logger, c cache.Cache, tenants *tenant.Registry) int {
+	flags := flag.NewFlagSet("migrate-carts", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only count the carts needing an upgrade")
+	reportEvery := flags.Int("report-every", cart.DefaultReportEvery, "carts scanned between progress reports")
+	flags.Parse(args)
+
+	migrator := cart.NewMigrator(l.WithField("svc", "cart migrator"), c, cart.MigratorOptions{
+		DryRun:      *dryRun,
+		ReportEvery: *reportEvery,
+	})
+	code := 0
+	for _, ctx := range tenantContexts(context.Background(), tenants) {
+		t, _ := tenant.FromContext(ctx)
+		progress, err := migrator.Run(ctx, func(p cart.MigrationProgress) {
+			fmt.Fprintf(os.Stderr, "tenant=%q scanned=%d upgraded=%d current=%d failed=%d\n", t.ID, p.Scanned, p.Upgraded, p.Current, p.Failed)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migration stopped: %v\n", err)
+			return 1
+		}
+		if progress.Failed > 0 {
+			code = 1
+		}
+	}
+	return code
+}
+
+// tenantRegistry gives the tenants of TENANTS_FILE, or nil when none is set, leaving every request out of any tenant.
+func tenantRegistry(conf config.Config, l logger.Logger) *tenant.Registry {
+	if conf.TenantsFile == "" {
+		l.Warn(context.Background(), "TENANTS_FILE is not set, requests will belong to no tenant")
+		return nil
+	}
+	reg, err := tenant.Load(conf.TenantsFile)
+	if err != nil {
+		panic("unable to load tenants: " + err.Error())
+	}
+	return reg
+}
+
+// newRedisClient connects to the Redis server, Sentinel nodes or Cluster told by the configuration.
+func newRedisClient(conf config.Config) redis.UniversalClient {
+	addrs := []string{}
+	for _, addr := range strings.Split(conf.RedisServer, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	client, err := cache.NewRedisClient(cache.RedisOptions{
+		Addrs:            addrs,
+		MasterName:       conf.RedisSentinelMaster,
+		Cluster:          conf.RedisCluster,
+		Username:         conf.RedisUsername,
+		Password:         conf.RedisPassword,
+		SentinelPassword: conf.RedisSentinelPassword,
+		DB:               conf.RedisDB,
+		TLS:              conf.RedisTLS,
+		TLSServerName:    conf.RedisTLSServerName,
+		PoolSize:         conf.RedisPoolSize,
+		DialTimeout:      conf.RedisDialTimeout,
+		ReadTimeout:      conf.RedisReadTimeout,
+		WriteTimeout:     conf.RedisWriteTimeout,
+	})
+	if err != nil {
+		panic("unable to configure redis: " + err.Error())
+	}
+	return client
+}
+
+// cacheOptions gives the codec and compression values are stored with in Redis.
+func cacheOptions(conf config.Config) []cache.Option {
+	opts := []cache.Option{}
+	switch conf.CacheCodec {
+	case config.CacheCodecJSON:
+		opts = append(opts, cache.WithCodec(cache.JSON))
+	case config.CacheCodecJSONMessagePack:
+		opts = append(opts, cache.WithCodec(cache.JSONMessagePack))
+	case config.CacheCodecJSONProtobufValue:
+		opts = append(opts, cache.WithCodec(cache.JSONProtobufValue))
+	default:
+		panic("unknown cache codec: " + conf.CacheCodec)
+	}
+	switch conf.CacheCompression {
+	case config.CacheCompressionNone:
+	case config.CacheCompressionGzip:
+		opts = append(opts, cache.WithCompression(cache.Gzip, conf.CacheCompressionThreshold))
+	case config.CacheCompressionZstd:
+		opts = append(opts, cache.WithCompression(cache.Zstd, conf.CacheCompressionThreshold))
+	default:
+		panic("unknown cache compression: " + conf.CacheCompression)
+	}
+	return opts
+}
+
+// cacheKeyring gives the keys values are encrypted with in Redis, or nil when none is set, storing them in plain.
+func cacheKeyring(conf config.Config, l logger.Logger) *cache.Keyring {
+	var keys *cache.Keyring
+	var err error
+	switch {
+	case conf.CacheEncryptionKeys != "" && conf.CacheEncryptionKeysFile != "":
+		panic("only one of CACHE_ENCRYPTION_KEYS and CACHE_ENCRYPTION_KEYS_FILE can be set")
+	case conf.CacheEncryptionKeysFile != "":
+		keys, err = cache.LoadKeyring(conf.CacheEncryptionKeysFile)
+	case conf.CacheEncryptionKeys != "":
+		keys, err = cache.ParseKeyring(conf.CacheEncryptionKeys)
+	default:
+		if conf.CacheRejectPlaintext {
+			panic("CACHE_REJECT_PLAINTEXT needs CACHE_ENCRYPTION_KEYS or CACHE_ENCRYPTION_KEYS_FILE")
+		}
+		l.Warn(context.Background(), "CACHE_ENCRYPTION_KEYS is not set, values will be stored in plain")
+		return nil
+	}
+	if err != nil {
+		panic("unable to load cache encryption keys: " + err.Error())
+	}
+	return keys
+}
+
+// tenantContexts gives a context for the requests of no tenant followed by one for every tenant,
+// for the work done on the data of each of them.
+func tenantContexts(ctx context.Context, tenants *tenant.Registry) []context.Context {
+	ctxs := []context.Context{ctx}
+	for _, t := range tenants.Tenants() {
+		ctxs = append(ctxs, tenant.NewContext(ctx, t))
+	}
+	return ctxs
+}
+
+// checkTenantCurrencies makes sure the currency of every tenant can be converted to.
+func checkTenantCurrencies(tenants *tenant.Registry, conv *currency.Converter) {
+	for _, t := range tenants.Tenants() {
+		if t.Currency == "" {
+			continue
+		}
+		if conv == nil {
+			panic("tenant " + t.ID + " has a currency but EXCHANGE_RATES_FILE is not set")
+		}
+		if _, err := conv.Rate(t.Currency); err != nil {
+			panic("tenant " + t.ID + " has a currency without exchange rate: " + err.Error())
+		}
+	}
+}
+
+// eventSinks builds the sinks the outbox relay publishes cart events to.
+func eventSinks(conf config.Config, redisClient redis.UniversalClient) []events.Sink {
+	sinks := []events.Sink{}
+	for _, name := range conf.EventsSinks {
+		switch name {
+		case config.EventsSinkRedisStream:
+			sinks = append(sinks, events.NewRedisStreamSink(redisClient, conf.EventsStream))
+		case config.EventsSinkStdout:
+			sinks = append(sinks, events.NewWriterSink(os.Stdout))
+		default:
+			panic("unknown events sink: " + name)
+		}
+	}
+	return sinks
+}
+
+// sessionSecret gives the key signing session tokens. Without one configured a random key is used,
+// only valid for this instance and until it restarts.
+func sessionSecret(conf config.Config, l logger.Logger) []byte {
+	if conf.SessionSecret != "" {
+		return []byte(conf.SessionSecret)
+	}
+	l.Warn(context.Background(), "SESSION_SECRET is not set, session tokens will only be valid on this instance")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic("unable to generate session secret: " + err.Error())
+	}
+	return secret
+}
+
+// shareSecret gives the key signing the links sharing carts. Without one configured the cart service
+// uses a random key, only valid for this instance and until it restarts.
+func shareSecret(conf config.Config, l logger.Logger) []byte {
+	if conf.ShareSecret == "" {
+		l.Warn(context.Background(), "SHARE_SECRET is not set, shared cart links will only work on this instance")
+		return nil
+	}
+	return []byte(conf.ShareSecret)
+}
+
+// taxCalculator gives the calculator following the rules of TAX_RULES_FILE, or nil when none is set,
+// leaving carts without taxes.
+func taxCalculator(conf config.Config, l logger.Logger) tax.Calculator {
+	if conf.TaxRulesFile == "" {
+		l.Warn(context.Background(), "TAX_RULES_FILE is not set, carts will have no taxes")
+		return nil
+	}
+	rules, err := tax.LoadRules(conf.TaxRulesFile)
+	if err != nil {
+		panic("unable to load tax rules: " + err.Error())
+	}
+	return tax.NewCalculator(rules)
+}
+
+// shippingProvider gives the provider quoting the rates of SHIPPING_RATES_FILE, or nil when none is set,
+// leaving carts without shipping options.
+func shippingProvider(conf config.Config, l logger.Logger) shipping.Provider {
+	if conf.ShippingRatesFile == "" {
+		l.Warn(context.Background(), "SHIPPING_RATES_FILE is not set, carts will have no shipping options")
+		return nil
+	}
+	table, err := shipping.LoadTable(conf.ShippingRatesFile)
+	if err != nil {
+		panic("unable to load shipping rates: " + err.Error())
+	}
+	return shipping.NewTableProvider(table)
+}
+
+// currencyConverter gives the converter following the rates of EXCHANGE_RATES_FILE, or nil when none is set,
+// leaving prices in the currency of the catalog.
+func currencyConverter(conf config.Config, l logger.Logger) *currency.Converter {
+	if conf.ExchangeRatesFile == "" {
+		l.Warn(context.Background(), "EXCHANGE_RATES_FILE is not set, prices will only be given in the catalog currency")
+		return nil
+	}
+	conv := currency.NewConverter(
+		l.WithField("svc", "currency converter"),
+		currency.NewFileProvider(conf.ExchangeRatesFile),
+		conf.ExchangeRatesRefreshInterval,
+	)
+	if err := conv.Refresh(context.Background()); err != nil {
+		panic("unable to load exchange rates: " + err.Error())
+	}
+	return conv
+}
+
+// startTracing starts the configured tracing provider, if enabled, and gives the function flushing it on shutdown.
+func startTracing(conf config.Config) func() {
+	if !conf.TracingEnabled {
+		return func() {}
+	}
+
+	switch conf.TracingProvider {
+	case config.TracingProviderOpenTelemetry:
+		tp, err := tracing.NewOpenTelemetryProvider(context.Background(), "shopping cart api", conf.OTelExporter)
+		if err != nil {
+			panic("unable to initialize OpenTelemetry: " + err.Error())
+		}
+		return func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+			tp.Shutdown(ctx)
+		}
+	default:
+		tracer.Start()
+		return tracer.Stop
+	}
+}
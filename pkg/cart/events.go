@@ -0,0 +1,114 @@
+package cart
+
+import "time"
+
+//Types of the domain events emitted when a cart changes
+const (
+	EventCartCreated         = "cart.created"
+	EventItemAdded           = "cart.item_added"
+	EventItemQuantityChanged = "cart.item_quantity_changed"
+	EventItemRemoved         = "cart.item_removed"
+	EventCartCleared         = "cart.cleared"
+	EventCartDeleted         = "cart.deleted"
+	EventPricesLocked        = "cart.prices_locked"
+	EventCartCheckedOut      = "cart.checked_out"
+	EventShippingAddressSet  = "cart.shipping_address_changed"
+	EventShippingOptionSet   = "cart.shipping_option_selected"
+)
+
+//DomainEvent is a change that happened to a cart
+type DomainEvent interface {
+	EventType() string
+	AggregateID() string
+}
+
+type CartCreated struct {
+	CartID string `json:"cart_id"`
+	//ClonedFrom is the cart copied, for clones. Their items follow as ItemAdded events
+	ClonedFrom string `json:"cloned_from,omitempty"`
+}
+
+type ItemAdded struct {
+	CartID   string            `json:"cart_id"`
+	ItemID   string            `json:"item_id"`
+	LineID   string            `json:"line_id"`
+	Options  map[string]string `json:"options,omitempty"`
+	Note     string            `json:"note,omitempty"`
+	Quantity int               `json:"quantity"`
+}
+
+type ItemQuantityChanged struct {
+	CartID      string `json:"cart_id"`
+	ItemID      string `json:"item_id"`
+	LineID      string `json:"line_id"`
+	OldQuantity int    `json:"old_quantity"`
+	NewQuantity int    `json:"new_quantity"`
+}
+
+type ItemRemoved struct {
+	CartID   string `json:"cart_id"`
+	ItemID   string `json:"item_id"`
+	LineID   string `json:"line_id"`
+	Quantity int    `json:"quantity"`
+}
+
+type CartCleared struct {
+	CartID       string `json:"cart_id"`
+	RemovedItems int    `json:"removed_items"`
+}
+
+type CartDeleted struct {
+	CartID string `json:"cart_id"`
+}
+
+type PricesLocked struct {
+	CartID    string    `json:"cart_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type ShippingAddressChanged struct {
+	CartID     string `json:"cart_id"`
+	Country    string `json:"country"`
+	Region     string `json:"region,omitempty"`
+	PostalCode string `json:"postal_code,omitempty"`
+}
+
+type ShippingOptionSelected struct {
+	CartID   string  `json:"cart_id"`
+	OptionID string  `json:"option_id"`
+	Carrier  string  `json:"carrier"`
+	Service  string  `json:"service"`
+	Cost     float32 `json:"cost"`
+}
+
+//CartCheckedOut carries the items at the price they are sold, for the order to be placed
+type CartCheckedOut struct {
+	CartID           string         `json:"cart_id"`
+	Items            []CheckoutItem `json:"items"`
+	Tax              float32        `json:"tax"`
+	ShippingOptionID string         `json:"shipping_option_id,omitempty"`
+	Shipping         float32        `json:"shipping"`
+	Total            float32        `json:"total"`
+}
+
+func (CartCreated) EventType() string            { return EventCartCreated }
+func (ItemAdded) EventType() string              { return EventItemAdded }
+func (ItemQuantityChanged) EventType() string    { return EventItemQuantityChanged }
+func (ItemRemoved) EventType() string            { return EventItemRemoved }
+func (CartCleared) EventType() string            { return EventCartCleared }
+func (CartDeleted) EventType() string            { return EventCartDeleted }
+func (PricesLocked) EventType() string           { return EventPricesLocked }
+func (CartCheckedOut) EventType() string         { return EventCartCheckedOut }
+func (ShippingAddressChanged) EventType() string { return EventShippingAddressSet }
+func (ShippingOptionSelected) EventType() string { return EventShippingOptionSet }
+
+func (e CartCreated) AggregateID() string            { return e.CartID }
+func (e ItemAdded) AggregateID() string              { return e.CartID }
+func (e ItemQuantityChanged) AggregateID() string    { return e.CartID }
+func (e ItemRemoved) AggregateID() string            { return e.CartID }
+func (e CartCleared) AggregateID() string            { return e.CartID }
+func (e CartDeleted) AggregateID() string            { return e.CartID }
+func (e PricesLocked) AggregateID() string           { return e.CartID }
+func (e CartCheckedOut) AggregateID() string         { return e.CartID }
+func (e ShippingAddressChanged) AggregateID() string { return e.CartID }
+func (e ShippingOptionSelected) AggregateID() string { return e.CartID }
@@ -0,0 +1,67 @@
+package cart_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/events"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCartEventsAreWrittenToOutbox(t *testing.T) {
+	c := cache.NewMemoryCache()
+	svc := cart.NewCartService("unit-testing",
+		logger.NewLogger("cart service unit testing", false),
+		c,
+		&externalMock{})
+
+	ctx := context.TODO()
+	created, err := svc.CreateCart(ctx)
+	assert.Nil(t, err)
+	_, err = svc.AddItemToCart(ctx, created.ID, "someItem", 1)
+	assert.Nil(t, err)
+	_, err = svc.ModifyItemInCart(ctx, created.ID, "someItem", 3)
+	assert.Nil(t, err)
+	_, err = svc.DeleteItemInCart(ctx, created.ID, "someItem")
+	assert.Nil(t, err)
+	_, err = svc.DeleteAllItemsInCart(ctx, created.ID)
+	assert.Nil(t, err)
+	assert.Nil(t, svc.DeleteCart(ctx, created.ID))
+
+	sink := events.NewMemorySink()
+	_, err = events.NewRelay(logger.NewLogger("relay unit testing", false), c, 0, 0, sink).Drain(ctx)
+	assert.Nil(t, err)
+
+	published := sink.Events()
+	types := []string{}
+	for _, e := range published {
+		types = append(types, e.Type)
+		assert.Equal(t, created.ID, e.AggregateID)
+	}
+	assert.Equal(t, []string{
+		cart.EventCartCreated,
+		cart.EventItemAdded,
+		cart.EventItemQuantityChanged,
+		cart.EventItemRemoved,
+		cart.EventCartCleared,
+		cart.EventCartDeleted,
+	}, types)
+	assert.JSONEq(t, `{"cart_id":"`+created.ID+`","item_id":"someItem","line_id":"`+cart.LineID("someItem", nil, "")+`","old_quantity":1,"new_quantity":3}`, string(published[2].Payload))
+}
+
+func TestDeleteCartNotFoundEmitsNothing(t *testing.T) {
+	c := cache.NewMemoryCache()
+	svc := cart.NewCartService("unit-testing",
+		logger.NewLogger("cart service unit testing", false),
+		c,
+		&externalMock{})
+
+	assert.NotNil(t, svc.DeleteCart(context.TODO(), "missingCart"))
+
+	pending, err := c.ListRange(context.TODO(), events.OutboxKey(cache.Partition("missingCart")), 0, -1)
+	assert.Nil(t, err)
+	assert.Empty(t, pending)
+}
@@ -2,15 +2,23 @@ package cart
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/events"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/response"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/currency"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/shipping"
 	"github.com/gorilla/mux"
 )
 
 type Handler struct {
 	Service Service
+	//Stream follows the changes of the carts, needed by StreamEvents only
+	Stream *Stream
 }
 
 //CreateCart creates a cart on the DB
@@ -18,14 +26,15 @@ func (c *Handler) CreateCart(w http.ResponseWriter, r *http.Request) {
 
 	cart, err := c.Service.CreateCart(r.Context())
 	if err != nil {
-		response.RespondWithError(w, err)
+		response.RespondWithError(w, r, err)
 		return
 	}
 
+	rate := currency.RateFromContext(r.Context())
 	res := CartResponse{
-		Cart: CartModelToTransportModel(cart),
+		Cart: CartModelToTransportModelIn(cart, rate),
 	}
-	response.RespondWithData(w, http.StatusOK, res)
+	response.RespondWithData(w, http.StatusOK, res, rate.Meta())
 }
 
 //GetCart creates a cart on the DB
@@ -34,13 +43,14 @@ func (c *Handler) GetCart(w http.ResponseWriter, r *http.Request) {
 	cartID := vars["cart_id"]
 	cart, err := c.Service.GetCart(r.Context(), cartID)
 	if err != nil {
-		response.RespondWithError(w, err)
+		response.RespondWithError(w, r, err)
 		return
 	}
+	rate := currency.RateFromContext(r.Context())
 	res := CartResponse{
-		Cart: CartModelToTransportModel(cart),
+		Cart: CartModelToTransportModelIn(cart, rate),
 	}
-	response.RespondWithData(w, http.StatusOK, res)
+	response.RespondWithData(w, http.StatusOK, res, rate.Meta())
 }
 
 //DeleteCart removes all items from the cart
@@ -50,13 +60,13 @@ func (c *Handler) DeleteCart(w http.ResponseWriter, r *http.Request) {
 
 	err := c.Service.DeleteCart(r.Context(), cartID)
 	if err != nil {
-		response.RespondWithError(w, err)
+		response.RespondWithError(w, r, err)
 		return
 	}
 	response.RespondWithData(w, http.StatusAccepted, nil)
 }
 
-//AddItem Adds an item to a cart
+//AddItem Adds an item to a cart, in a line of its own when its options or note are not in the cart yet
 func (c *Handler) AddItem(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	cartID := vars["cart_id"]
@@ -66,26 +76,28 @@ func (c *Handler) AddItem(w http.ResponseWriter, r *http.Request) {
 	err := decoder.Decode(&vm)
 	if err != nil {
 		log.Printf("Error decoding body: %v", err)
-		response.RespondWithError(w, response.StandardBadBodyRequest)
+		response.RespondWithError(w, r, response.StandardBadBodyRequest)
 		return
 	}
-	cart, err := c.Service.AddItemToCart(r.Context(), cartID, vm.ID, vm.Quantity)
+	line := Line{ItemID: vm.ID, Options: vm.Options, Note: vm.Note}
+	cart, err := c.Service.AddLineToCart(r.Context(), cartID, line, vm.Quantity)
 	if err != nil {
-		response.RespondWithError(w, err)
+		response.RespondWithError(w, r, err)
 		return
 	}
 
+	rate := currency.RateFromContext(r.Context())
 	res := CartResponse{
-		Cart: CartModelToTransportModel(cart),
+		Cart: CartModelToTransportModelIn(cart, rate),
 	}
-	response.RespondWithData(w, http.StatusOK, res)
+	response.RespondWithData(w, http.StatusOK, res, rate.Meta())
 }
 
-//UpdateQuantity changes the amount of a single item in the cart
+//UpdateQuantity changes the amount of a single line in the cart
 func (c *Handler) UpdateQuantity(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	cartID := vars["cart_id"]
-	itemID := vars["item_id"]
+	lineID := vars["line_id"]
 
 	vm := ModifyItemQuantityRequest{}
 	decoder := json.NewDecoder(r.Body)
@@ -93,39 +105,40 @@ func (c *Handler) UpdateQuantity(w http.ResponseWriter, r *http.Request) {
 	err := decoder.Decode(&vm)
 	if err != nil {
 		log.Printf("Error decoding body: %v", err)
-		response.RespondWithError(w, response.StandardBadBodyRequest)
+		response.RespondWithError(w, r, response.StandardBadBodyRequest)
 		return
 	}
 
-	cart, err := c.Service.ModifyItemInCart(r.Context(), cartID, itemID, vm.Quantity)
+	cart, err := c.Service.ModifyItemInCart(r.Context(), cartID, lineID, vm.Quantity)
 	if err != nil {
-		response.RespondWithError(w, err)
+		response.RespondWithError(w, r, err)
 		return
 	}
 
+	rate := currency.RateFromContext(r.Context())
 	res := CartResponse{
-		Cart: CartModelToTransportModel(cart),
+		Cart: CartModelToTransportModelIn(cart, rate),
 	}
-	response.RespondWithData(w, http.StatusOK, res)
+	response.RespondWithData(w, http.StatusOK, res, rate.Meta())
 }
 
-//RemoveItem removes an item from the cart
+//RemoveItem removes a line from the cart
 func (c *Handler) RemoveItem(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	cartID := vars["cart_id"]
-	itemID := vars["item_id"]
+	lineID := vars["line_id"]
 
-	cart, err := c.Service.DeleteItemInCart(r.Context(), cartID, itemID)
+	cart, err := c.Service.DeleteItemInCart(r.Context(), cartID, lineID)
 	if err != nil {
-		response.RespondWithError(w, err)
+		response.RespondWithError(w, r, err)
 		return
 	}
 
+	rate := currency.RateFromContext(r.Context())
 	res := CartResponse{
-		Cart: CartModelToTransportModel(cart),
+		Cart: CartModelToTransportModelIn(cart, rate),
 	}
-
-	response.RespondWithData(w, http.StatusOK, res)
+	response.RespondWithData(w, http.StatusOK, res, rate.Meta())
 }
 
 //RemoveAllItems removes all items from the cart
@@ -135,12 +148,378 @@ func (c *Handler) RemoveAllItems(w http.ResponseWriter, r *http.Request) {
 
 	cart, err := c.Service.DeleteAllItemsInCart(r.Context(), cartID)
 	if err != nil {
-		response.RespondWithError(w, err)
+		response.RespondWithError(w, r, err)
 		return
 	}
 
+	rate := currency.RateFromContext(r.Context())
 	res := CartResponse{
-		Cart: CartModelToTransportModel(cart),
+		Cart: CartModelToTransportModelIn(cart, rate),
+	}
+	response.RespondWithData(w, http.StatusOK, res, rate.Meta())
+}
+
+//ApplyItemOperations changes several items of the cart at once, applying all the operations or none
+func (c *Handler) ApplyItemOperations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cartID := vars["cart_id"]
+
+	vm := ItemOperationsRequest{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&vm)
+	if err != nil {
+		log.Printf("Error decoding body: %v", err)
+		response.RespondWithError(w, r, response.StandardBadBodyRequest)
+		return
+	}
+
+	ops := make([]ItemOperation, 0, len(vm.Operations))
+	for _, op := range vm.Operations {
+		ops = append(ops, ItemOperation{
+			Op:       op.Op,
+			ItemID:   op.ItemID,
+			LineID:   op.LineID,
+			Options:  op.Options,
+			Note:     op.Note,
+			Quantity: op.Quantity,
+		})
+	}
+	cart, err := c.Service.ApplyItemOperations(r.Context(), cartID, ops)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	rate := currency.RateFromContext(r.Context())
+	res := CartResponse{
+		Cart: CartModelToTransportModelIn(cart, rate),
+	}
+	response.RespondWithData(w, http.StatusOK, res, rate.Meta())
+}
+
+//CloneCart copies the cart under a new ID
+func (c *Handler) CloneCart(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cartID := vars["cart_id"]
+
+	cart, err := c.Service.CloneCart(r.Context(), cartID)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	rate := currency.RateFromContext(r.Context())
+	res := CartResponse{
+		Cart: CartModelToTransportModelIn(cart, rate),
+	}
+	response.RespondWithData(w, http.StatusCreated, res, rate.Meta())
+}
+
+//ShareCart gives a link to see the cart without being able to change it
+func (c *Handler) ShareCart(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cartID := vars["cart_id"]
+
+	token, err := c.Service.ShareCart(r.Context(), cartID)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	res := ShareCartResponse{
+		Token:     token.Value,
+		URL:       "/shared-carts/" + token.Value,
+		ExpiresAt: token.ExpiresAt,
+	}
+	response.RespondWithData(w, http.StatusCreated, res)
+}
+
+//GetSharedCart gives the cart a share token was issued for
+func (c *Handler) GetSharedCart(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	token := vars["token"]
+
+	cart, err := c.Service.GetSharedCart(r.Context(), token)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	rate := currency.RateFromContext(r.Context())
+	res := CartResponse{
+		Cart: CartModelToTransportModelIn(cart, rate),
+	}
+	response.RespondWithData(w, http.StatusOK, res, rate.Meta())
+}
+
+//ImportSharedCart adds the items of a shared cart to the cart in the body
+func (c *Handler) ImportSharedCart(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	token := vars["token"]
+
+	vm := ImportSharedCartRequest{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&vm)
+	if err != nil {
+		log.Printf("Error decoding body: %v", err)
+		response.RespondWithError(w, r, response.StandardBadBodyRequest)
+		return
+	}
+
+	cart, err := c.Service.ImportSharedCart(r.Context(), token, vm.CartID)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	rate := currency.RateFromContext(r.Context())
+	res := CartResponse{
+		Cart: CartModelToTransportModelIn(cart, rate),
+	}
+	response.RespondWithData(w, http.StatusOK, res, rate.Meta())
+}
+
+//SetShippingAddress sets where the cart is shipped to, which decides its taxes
+func (c *Handler) SetShippingAddress(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cartID := vars["cart_id"]
+
+	vm := SetShippingAddressRequest{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&vm)
+	if err != nil {
+		log.Printf("Error decoding body: %v", err)
+		response.RespondWithError(w, r, response.StandardBadBodyRequest)
+		return
+	}
+
+	address := Address{
+		Country:    vm.Country,
+		Region:     vm.Region,
+		PostalCode: vm.PostalCode,
+	}
+	cart, err := c.Service.SetShippingAddress(r.Context(), cartID, address)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	rate := currency.RateFromContext(r.Context())
+	res := CartResponse{
+		Cart: CartModelToTransportModelIn(cart, rate),
+	}
+	response.RespondWithData(w, http.StatusOK, res, rate.Meta())
+}
+
+//GetShippingOptions gives the options to ship the cart to its address, with their cost and delivery estimate
+func (c *Handler) GetShippingOptions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cartID := vars["cart_id"]
+
+	options, err := c.Service.GetShippingOptions(r.Context(), cartID)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	res := ShippingOptionsResponse{
+		Options: make([]shipping.TransportOption, 0, len(options)),
+	}
+	for _, o := range options {
+		res.Options = append(res.Options, shipping.OptionToTransportModel(o))
+	}
+	response.RespondWithData(w, http.StatusOK, res)
+}
+
+//SelectShippingOption chooses how the cart is shipped
+func (c *Handler) SelectShippingOption(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cartID := vars["cart_id"]
+
+	vm := SelectShippingOptionRequest{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&vm)
+	if err != nil {
+		log.Printf("Error decoding body: %v", err)
+		response.RespondWithError(w, r, response.StandardBadBodyRequest)
+		return
+	}
+
+	cart, err := c.Service.SelectShippingOption(r.Context(), cartID, vm.OptionID)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	rate := currency.RateFromContext(r.Context())
+	res := CartResponse{
+		Cart: CartModelToTransportModelIn(cart, rate),
+	}
+	response.RespondWithData(w, http.StatusOK, res, rate.Meta())
+}
+
+//LockPrices keeps the current prices of the cart for the checkout, for a limited time
+func (c *Handler) LockPrices(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cartID := vars["cart_id"]
+
+	cart, err := c.Service.LockPrices(r.Context(), cartID)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	rate := currency.RateFromContext(r.Context())
+	res := CartResponse{
+		Cart: CartModelToTransportModelIn(cart, rate),
+	}
+	response.RespondWithData(w, http.StatusOK, res, rate.Meta())
+}
+
+//Checkout prices the cart for the order. The body is optional
+func (c *Handler) Checkout(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cartID := vars["cart_id"]
+
+	vm := CheckoutRequest{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&vm)
+	if err != nil && err != io.EOF {
+		log.Printf("Error decoding body: %v", err)
+		response.RespondWithError(w, r, response.StandardBadBodyRequest)
+		return
+	}
+
+	checkout, err := c.Service.Checkout(r.Context(), cartID, vm.AcceptPriceChanges)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	res := CheckoutResponse{
+		Checkout: checkout,
 	}
 	response.RespondWithData(w, http.StatusOK, res)
 }
+
+//StreamEvents follows a cart over Server-Sent Events. The stream starts with a snapshot of the cart
+//and goes on with every event changing it. Clients sending Last-Event-ID resume right after that event
+//while it is still kept, getting a new snapshot otherwise
+func (c *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cartID := vars["cart_id"]
+	ctx := r.Context()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		response.RespondWithError(w, r, response.StandardInternalServerError)
+		return
+	}
+
+	//subscribing before reading the history leaves no gap, events found in both are only sent once
+	sub := c.Stream.Subscribe(ctx, cartID)
+	defer sub.Close()
+
+	history, err := c.Stream.History(ctx, cartID)
+	if err != nil {
+		log.Printf("Error reading cart history: %v", err)
+		response.RespondWithError(w, r, response.StandardInternalServerError)
+		return
+	}
+	seen := map[string]bool{}
+	for _, e := range history {
+		seen[e.ID] = true
+	}
+
+	replay, resumed := eventsAfter(history, r.Header.Get("Last-Event-ID"))
+	var snapshot *CartResponse
+	if !resumed {
+		cart, err := c.Service.GetCart(ctx, cartID)
+		if err != nil {
+			response.RespondWithError(w, r, err)
+			return
+		}
+		snapshot = &CartResponse{
+			Cart: CartModelToTransportModelIn(cart, currency.RateFromContext(ctx)),
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", time.Second.Milliseconds())
+
+	if snapshot != nil {
+		lastID := ""
+		if len(history) > 0 {
+			lastID = history[len(history)-1].ID
+		}
+		writeServerSentEvent(w, lastID, "snapshot", snapshot)
+	}
+	for _, e := range replay {
+		writeServerSentEvent(w, e.ID, e.Type, e)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(c.Stream.opts.Heartbeat)
+	defer heartbeat.Stop()
+	timeout := time.NewTimer(c.Stream.opts.MaxDuration)
+	defer timeout.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timeout.C:
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case msg, ok := <-sub.C:
+			if !ok {
+				//dropped for falling behind, the client reconnects and resumes
+				return
+			}
+			e := events.Event{}
+			if err := json.Unmarshal(msg, &e); err != nil || seen[e.ID] {
+				continue
+			}
+			writeServerSentEvent(w, e.ID, e.Type, e)
+			flusher.Flush()
+			if e.Type == EventCartDeleted {
+				return
+			}
+		}
+	}
+}
+
+//eventsAfter gives the events following lastEventID, found is false when it is not part of history
+func eventsAfter(history []events.Event, lastEventID string) (evs []events.Event, found bool) {
+	if lastEventID == "" {
+		return nil, false
+	}
+	for idx, e := range history {
+		if e.ID == lastEventID {
+			return history[idx+1:], true
+		}
+	}
+	return nil, false
+}
+
+func writeServerSentEvent(w http.ResponseWriter, id, event string, data interface{}) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error encoding event: %v", err)
+		return
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+}
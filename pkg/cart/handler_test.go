@@ -8,9 +8,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/shipping"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -146,6 +148,33 @@ func TestAddItemToCart_OK(t *testing.T) {
 	assert.Equal(t, http.StatusOK, res.StatusCode)
 }
 
+func TestAddItemToCart_WithOptions(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{},
+	}
+
+	body := `{"id":"1","quantity":2,"options":{"size":"M","color":"red"},"note":"gift wrap"}`
+	req, err := http.NewRequest("POST", "/", bytes.NewReader([]byte(body)))
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.AddItem(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	vm := struct {
+		Data cart.CartResponse `json:"data"`
+	}{}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&vm))
+	assert.Len(t, vm.Data.Cart.Items, 1)
+	line := vm.Data.Cart.Items[0]
+	assert.Equal(t, cart.LineID("1", map[string]string{"size": "M", "color": "red"}, "gift wrap"), line.LineID)
+	assert.Equal(t, map[string]string{"size": "M", "color": "red"}, line.Options)
+	assert.Equal(t, "gift wrap", line.Note)
+}
+
 func TestAddItemToCart_BadPayload(t *testing.T) {
 	h := cart.Handler{
 		Service: &mockedService{},
@@ -330,8 +359,355 @@ func TestRemoveAllItems_Error(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
 }
 
+func TestApplyItemOperations_OK(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{},
+	}
+
+	body := `{"operations":[{"op":"add","item_id":"1","quantity":2},{"op":"remove","item_id":"2"}]}`
+	req, err := http.NewRequest("PATCH", "/", bytes.NewReader([]byte(body)))
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.ApplyItemOperations(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestApplyItemOperations_BadBody(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{},
+	}
+
+	req, err := http.NewRequest("PATCH", "/", bytes.NewReader([]byte(`{"operations":[{"kind":"add"}]}`)))
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.ApplyItemOperations(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestApplyItemOperations_Error(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{
+			shouldFail: true,
+		},
+	}
+
+	req, err := http.NewRequest("PATCH", "/", bytes.NewReader([]byte(`{"operations":[]}`)))
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.ApplyItemOperations(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+}
+
+func TestCloneCart_OK(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{},
+	}
+
+	req, err := http.NewRequest("POST", "/", nil)
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.CloneCart(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+}
+
+func TestShareCart_OK(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{},
+	}
+
+	req, err := http.NewRequest("POST", "/", nil)
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.ShareCart(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	body := struct {
+		Data cart.ShareCartResponse `json:"data"`
+	}{}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Equal(t, "/shared-carts/someToken", body.Data.URL)
+}
+
+func TestGetSharedCart_Error(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{
+			shouldFail: true,
+		},
+	}
+
+	req, err := http.NewRequest("GET", "/", nil)
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.GetSharedCart(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+}
+
+func TestImportSharedCart_BadBody(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{},
+	}
+
+	req, err := http.NewRequest("POST", "/", bytes.NewReader([]byte(`{"cart":"someCartID"}`)))
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.ImportSharedCart(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
 // Mocks
 
+func TestSetShippingAddress_OK(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{},
+	}
+
+	body := `{"country":"US","region":"CA","postal_code":"94105"}`
+	req, err := http.NewRequest("PUT", "/", bytes.NewReader([]byte(body)))
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.SetShippingAddress(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	vm := struct {
+		Data cart.CartResponse `json:"data"`
+	}{}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&vm))
+	assert.Equal(t, &cart.TransportAddress{Country: "US", Region: "CA", PostalCode: "94105"}, vm.Data.Cart.ShippingAddress)
+}
+
+func TestSetShippingAddress_BadBody(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{},
+	}
+
+	req, err := http.NewRequest("PUT", "/", bytes.NewReader([]byte(`{"state":"CA"}`)))
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.SetShippingAddress(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestGetShippingOptions_OK(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{},
+	}
+
+	req, err := http.NewRequest("GET", "/", nil)
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.GetShippingOptions(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	vm := struct {
+		Data cart.ShippingOptionsResponse `json:"data"`
+	}{}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&vm))
+	assert.Len(t, vm.Data.Options, 1)
+	assert.Equal(t, "ground", vm.Data.Options[0].ID)
+}
+
+func TestGetShippingOptions_ServiceError(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{shouldFail: true},
+	}
+
+	req, err := http.NewRequest("GET", "/", nil)
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.GetShippingOptions(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+}
+
+func TestSelectShippingOption_OK(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{},
+	}
+
+	req, err := http.NewRequest("PUT", "/", bytes.NewReader([]byte(`{"option_id":"ground"}`)))
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.SelectShippingOption(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	vm := struct {
+		Data cart.CartResponse `json:"data"`
+	}{}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&vm))
+	assert.Equal(t, "ground", vm.Data.Cart.ShippingOption.ID)
+	assert.Equal(t, float32(5), *vm.Data.Cart.Totals.Shipping)
+}
+
+func TestSelectShippingOption_BadBody(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{},
+	}
+
+	req, err := http.NewRequest("PUT", "/", bytes.NewReader([]byte(`{"option":"ground"}`)))
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.SelectShippingOption(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestLockPrices_OK(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{},
+	}
+
+	req, err := http.NewRequest("POST", "/", nil)
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.LockPrices(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body := struct {
+		Data cart.CartResponse `json:"data"`
+	}{}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.NotNil(t, body.Data.Cart.PriceLockExpiresAt)
+	assert.Equal(t, float32(10), *body.Data.Cart.Items[0].LockedPrice)
+}
+
+func TestCheckout_OK(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{},
+	}
+
+	req, err := http.NewRequest("POST", "/", bytes.NewReader([]byte(`{"accept_price_changes":true}`)))
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.Checkout(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body := struct {
+		Data cart.CheckoutResponse `json:"data"`
+	}{}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Equal(t, float32(20), body.Data.Checkout.Total)
+}
+
+func TestCheckout_EmptyBody(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{},
+	}
+
+	req, err := http.NewRequest("POST", "/", bytes.NewReader(nil))
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.Checkout(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestCheckout_BadBody(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{},
+	}
+
+	req, err := http.NewRequest("POST", "/", bytes.NewReader([]byte(`{"accept":true}`)))
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.Checkout(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestCheckout_Error(t *testing.T) {
+	h := cart.Handler{
+		Service: &mockedService{
+			shouldFail: true,
+		},
+	}
+
+	req, err := http.NewRequest("POST", "/", bytes.NewReader(nil))
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.Checkout(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+}
+
 type mockedService struct {
 	shouldFail bool
 }
@@ -381,6 +757,21 @@ func (m *mockedService) AddItemToCart(ctx context.Context, cartID, itemID string
 		ID: cartID,
 	}, nil
 }
+func (m *mockedService) AddLineToCart(ctx context.Context, cartID string, line cart.Line, quantity int) (cart.Cart, error) {
+	if m.shouldFail {
+		return cart.Cart{}, fmt.Errorf("mock was asked to fail")
+	}
+	return cart.Cart{
+		ID: cartID,
+		Items: []item.Item{{
+			ID:       line.ItemID,
+			LineID:   line.ID(),
+			Options:  line.Options,
+			Note:     line.Note,
+			Quantity: quantity,
+		}},
+	}, nil
+}
 func (m *mockedService) ModifyItemInCart(ctx context.Context, cartID, itemID string, newQuantity int) (cart.Cart, error) {
 	if m.shouldFail {
 		return cart.Cart{}, fmt.Errorf("mock was asked to fail")
@@ -405,6 +796,100 @@ func (m *mockedService) DeleteAllItemsInCart(ctx context.Context, cartID string)
 		ID: cartID,
 	}, nil
 }
+func (m *mockedService) ApplyItemOperations(ctx context.Context, cartID string, ops []cart.ItemOperation) (cart.Cart, error) {
+	if m.shouldFail {
+		return cart.Cart{}, fmt.Errorf("mock was asked to fail")
+	}
+	return cart.Cart{
+		ID: cartID,
+	}, nil
+}
+func (m *mockedService) CloneCart(ctx context.Context, cartID string) (cart.Cart, error) {
+	if m.shouldFail {
+		return cart.Cart{}, fmt.Errorf("mock was asked to fail")
+	}
+	return cart.Cart{
+		ID: "clonedCartID",
+	}, nil
+}
+func (m *mockedService) ShareCart(ctx context.Context, cartID string) (cart.ShareToken, error) {
+	if m.shouldFail {
+		return cart.ShareToken{}, fmt.Errorf("mock was asked to fail")
+	}
+	return cart.ShareToken{
+		Value: "someToken",
+	}, nil
+}
+func (m *mockedService) GetSharedCart(ctx context.Context, token string) (cart.Cart, error) {
+	if m.shouldFail {
+		return cart.Cart{}, fmt.Errorf("mock was asked to fail")
+	}
+	return cart.Cart{
+		ID: "sharedCartID",
+	}, nil
+}
+func (m *mockedService) ImportSharedCart(ctx context.Context, token, cartID string) (cart.Cart, error) {
+	if m.shouldFail {
+		return cart.Cart{}, fmt.Errorf("mock was asked to fail")
+	}
+	return cart.Cart{
+		ID: cartID,
+	}, nil
+}
+func (m *mockedService) LockPrices(ctx context.Context, cartID string) (cart.Cart, error) {
+	if m.shouldFail {
+		return cart.Cart{}, fmt.Errorf("mock was asked to fail")
+	}
+	return cart.Cart{
+		ID: cartID,
+		Items: []item.Item{
+			{ID: "someItem", Quantity: 2, Price: 10},
+		},
+		PriceLock: &cart.PriceLock{
+			Prices:    map[string]float32{"someItem": 10},
+			ExpiresAt: time.Now().Add(time.Minute),
+		},
+	}, nil
+}
+func (m *mockedService) SetShippingAddress(ctx context.Context, cartID string, address cart.Address) (cart.Cart, error) {
+	if m.shouldFail {
+		return cart.Cart{}, fmt.Errorf("mock was asked to fail")
+	}
+	return cart.Cart{
+		ID:              cartID,
+		ShippingAddress: &address,
+	}, nil
+}
+func (m *mockedService) GetShippingOptions(ctx context.Context, cartID string) ([]shipping.Option, error) {
+	if m.shouldFail {
+		return nil, fmt.Errorf("mock was asked to fail")
+	}
+	return []shipping.Option{
+		{ID: "ground", Carrier: "UPS", Service: "Ground", Cost: 5, MinDays: 3, MaxDays: 5},
+	}, nil
+}
+func (m *mockedService) SelectShippingOption(ctx context.Context, cartID, optionID string) (cart.Cart, error) {
+	if m.shouldFail {
+		return cart.Cart{}, fmt.Errorf("mock was asked to fail")
+	}
+	return cart.Cart{
+		ID:               cartID,
+		ShippingOptionID: optionID,
+		Shipping:         &shipping.Option{ID: optionID, Cost: 5},
+	}, nil
+}
+func (m *mockedService) Checkout(ctx context.Context, cartID string, acceptPriceChanges bool) (cart.Checkout, error) {
+	if m.shouldFail {
+		return cart.Checkout{}, fmt.Errorf("mock was asked to fail")
+	}
+	return cart.Checkout{
+		CartID: cartID,
+		Items: []cart.CheckoutItem{
+			{ID: "someItem", Quantity: 2, UnitPrice: 10},
+		},
+		Total: 20,
+	}, nil
+}
 func (m *mockedService) DeleteCart(ctx context.Context, cartID string) error {
 	if m.shouldFail {
 		return fmt.Errorf("mock was asked to fail")
@@ -0,0 +1,146 @@
+package cart
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+)
+
+//Bounds of the options and note of a line
+const (
+	MaxLineOptions     = 10
+	MaxOptionKeyLength = 32
+	MaxOptionLength    = 64
+	MaxNoteLength      = 256
+)
+
+//Line is what tells the lines of a cart apart: the same item with other options, or with another note, is another line
+type Line struct {
+	ItemID  string
+	Options map[string]string
+	Note    string
+}
+
+//LineID gives the ID of the line of the item with the options and note, always the same for them
+func LineID(itemID string, options map[string]string, note string) string {
+	keys := make([]string, 0, len(options))
+	for k := range options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	h.Write([]byte(itemID))
+	for _, k := range keys {
+		h.Write([]byte{0})
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(options[k]))
+	}
+	h.Write([]byte{0, 0})
+	h.Write([]byte(note))
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+//ID gives the ID of the line
+func (l Line) ID() string {
+	return LineID(l.ItemID, l.Options, l.Note)
+}
+
+//Line gives the line the reference points to. References are line IDs, but clients knowing only item IDs may
+//use them for the line of the item without options nor note, or for items having a single line
+func (c Cart) Line(ref string) (item.Item, bool) {
+	idx := c.lineIndex(ref)
+	if idx < 0 {
+		return item.Item{}, false
+	}
+	return c.Items[idx], true
+}
+
+//lineIndex gives the index in Items of the line the reference points to, -1 when there is none
+func (c Cart) lineIndex(ref string) int {
+	plain := LineID(ref, nil, "")
+	byItem := -1
+	lines := 0
+	for idx, i := range c.Items {
+		if i.LineID == ref {
+			return idx
+		}
+		if i.ID == ref {
+			lines++
+			byItem = idx
+		}
+	}
+	for idx, i := range c.Items {
+		if i.LineID == plain {
+			return idx
+		}
+	}
+	if lines == 1 {
+		return byItem
+	}
+	return -1
+}
+
+//quantityOf gives the units of the item in the cart, adding up all of its lines
+func (c Cart) quantityOf(itemID string) int {
+	quantity := 0
+	for _, i := range c.Items {
+		if i.ID == itemID {
+			quantity += i.Quantity
+		}
+	}
+	return quantity
+}
+
+//limitsOf gives the limits the tenant of the context puts on its carts, none for requests of no tenant
+func limitsOf(ctx context.Context) tenant.Limits {
+	t, _ := tenant.FromContext(ctx)
+	return t.Limits
+}
+
+//validateLineCount checks a cart may have the lines
+func validateLineCount(lines int, limits tenant.Limits) error {
+	if limits.MaxLines > 0 && lines > limits.MaxLines {
+		return errors.ServiceError{Code: errors.ValidationErrorCode}.
+			WithFieldViolation("items", fmt.Sprintf("carts must have up to %d lines", limits.MaxLines))
+	}
+	return nil
+}
+
+//validateLineRequest checks the line fields coming from the client, reporting every invalid field at once
+func validateLineRequest(line Line, quantity int, limits tenant.Limits) error {
+	vErr := errors.ServiceError{Code: errors.ValidationErrorCode}
+	if line.ItemID == "" {
+		vErr = vErr.WithFieldViolation("id", "must not be empty")
+	}
+	if quantity <= 0 {
+		vErr = vErr.WithFieldViolation("quantity", "must be greater than zero")
+	}
+	if limits.MaxQuantity > 0 && quantity > limits.MaxQuantity {
+		vErr = vErr.WithFieldViolation("quantity", fmt.Sprintf("must be up to %d", limits.MaxQuantity))
+	}
+	if len(line.Options) > MaxLineOptions {
+		vErr = vErr.WithFieldViolation("options", "must have up to 10 options")
+	}
+	for k, v := range line.Options {
+		if k == "" || utf8.RuneCountInString(k) > MaxOptionKeyLength || utf8.RuneCountInString(v) > MaxOptionLength {
+			vErr = vErr.WithFieldViolation("options", "names must have from 1 to 32 characters and values up to 64")
+			break
+		}
+	}
+	if utf8.RuneCountInString(line.Note) > MaxNoteLength {
+		vErr = vErr.WithFieldViolation("note", "must be up to 256 characters")
+	}
+	if len(vErr.Fields) > 0 {
+		return vErr
+	}
+	return nil
+}
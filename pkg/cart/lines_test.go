@@ -0,0 +1,165 @@
+package cart_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineID(t *testing.T) {
+	a := cart.LineID("1", map[string]string{"size": "M", "color": "red"}, "gift")
+	b := cart.LineID("1", map[string]string{"color": "red", "size": "M"}, "gift")
+	assert.Equal(t, a, b)
+	assert.Len(t, a, 16)
+
+	assert.NotEqual(t, a, cart.LineID("1", map[string]string{"size": "M", "color": "red"}, ""))
+	assert.NotEqual(t, a, cart.LineID("1", map[string]string{"size": "L", "color": "red"}, "gift"))
+	assert.NotEqual(t, a, cart.LineID("2", map[string]string{"size": "M", "color": "red"}, "gift"))
+	assert.NotEqual(t, cart.LineID("1", map[string]string{"a": "b"}, ""), cart.LineID("1", map[string]string{"ab": ""}, ""))
+}
+
+func TestVariantsOfAnItemAreLinesOfTheirOwn(t *testing.T) {
+	ext := &externalMock{prices: map[string]float32{"1": 10}}
+	svc := newPricingTestService(cache.NewMemoryCache(), ext)
+	c, err := svc.CreateCart(context.TODO())
+	assert.Nil(t, err)
+
+	medium := cart.Line{ItemID: "1", Options: map[string]string{"size": "M"}}
+	large := cart.Line{ItemID: "1", Options: map[string]string{"size": "L"}, Note: "gift wrap"}
+	_, err = svc.AddLineToCart(context.TODO(), c.ID, medium, 1)
+	assert.Nil(t, err)
+	c, err = svc.AddLineToCart(context.TODO(), c.ID, large, 2)
+	assert.Nil(t, err)
+
+	assert.Len(t, c.Items, 2)
+	assert.Equal(t, medium.ID(), c.Items[0].LineID)
+	assert.Equal(t, map[string]string{"size": "M"}, c.Items[0].Options)
+	assert.Equal(t, large.ID(), c.Items[1].LineID)
+	assert.Equal(t, "gift wrap", c.Items[1].Note)
+	assert.Equal(t, float32(30), cart.CartModelToTransportModel(c).Totals.Total)
+
+	_, err = svc.AddLineToCart(context.TODO(), c.ID, cart.Line{ItemID: "1", Options: map[string]string{"size": "M"}}, 1)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ItemAlreadyInCartCode})
+
+	c, err = svc.ModifyItemInCart(context.TODO(), c.ID, large.ID(), 5)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, c.Items[0].Quantity)
+	assert.Equal(t, 5, c.Items[1].Quantity)
+
+	c, err = svc.DeleteItemInCart(context.TODO(), c.ID, medium.ID())
+	assert.Nil(t, err)
+	assert.Len(t, c.Items, 1)
+	assert.Equal(t, large.ID(), c.Items[0].LineID)
+}
+
+func TestLinesAreFoundByItemID(t *testing.T) {
+	svc := newPricingTestService(cache.NewMemoryCache(), &externalMock{})
+	c := cartWithItems(t, svc, map[string]int{"1": 1})
+
+	//the plain line of the item
+	c, err := svc.ModifyItemInCart(context.TODO(), c.ID, "1", 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, c.Items[0].Quantity)
+
+	//once the item has other lines, the plain one is still the one meant
+	variant := cart.Line{ItemID: "1", Note: "no onions"}
+	_, err = svc.AddLineToCart(context.TODO(), c.ID, variant, 1)
+	assert.Nil(t, err)
+	c, err = svc.DeleteItemInCart(context.TODO(), c.ID, "1")
+	assert.Nil(t, err)
+	assert.Len(t, c.Items, 1)
+	assert.Equal(t, variant.ID(), c.Items[0].LineID)
+
+	//an item with a single line, whatever its options
+	c, err = svc.ModifyItemInCart(context.TODO(), c.ID, "1", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, c.Items[0].Quantity)
+
+	//items with several lines and none of them plain must be given by line ID
+	_, err = svc.AddLineToCart(context.TODO(), c.ID, cart.Line{ItemID: "1", Note: "extra cheese"}, 1)
+	assert.Nil(t, err)
+	_, err = svc.DeleteItemInCart(context.TODO(), c.ID, "1")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ItemNotFoundCode})
+}
+
+func TestStockIsHeldForAllTheLinesOfAnItem(t *testing.T) {
+	stock := 3
+	ext := &externalMock{stocks: map[string]*int{"1": &stock}}
+	svc := newPricingTestService(cache.NewMemoryCache(), ext)
+	c, err := svc.CreateCart(context.TODO())
+	assert.Nil(t, err)
+
+	_, err = svc.AddLineToCart(context.TODO(), c.ID, cart.Line{ItemID: "1", Options: map[string]string{"size": "M"}}, 2)
+	assert.Nil(t, err)
+	_, err = svc.AddLineToCart(context.TODO(), c.ID, cart.Line{ItemID: "1", Options: map[string]string{"size": "L"}}, 2)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.InsufficientStockCode})
+
+	_, err = svc.ApplyItemOperations(context.TODO(), c.ID, []cart.ItemOperation{
+		{Op: cart.ItemOperationAdd, ItemID: "1", Options: map[string]string{"size": "L"}, Quantity: 1},
+	})
+	assert.Nil(t, err)
+	_, err = svc.ApplyItemOperations(context.TODO(), c.ID, []cart.ItemOperation{
+		{Op: cart.ItemOperationAdd, ItemID: "1", Options: map[string]string{"size": "S"}, Quantity: 1},
+	})
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.InsufficientStockCode})
+}
+
+func TestItemOperationsOnLines(t *testing.T) {
+	svc := newPricingTestService(cache.NewMemoryCache(), &externalMock{})
+	c, err := svc.CreateCart(context.TODO())
+	assert.Nil(t, err)
+	red := cart.Line{ItemID: "1", Options: map[string]string{"color": "red"}}
+
+	c, err = svc.ApplyItemOperations(context.TODO(), c.ID, []cart.ItemOperation{
+		{Op: cart.ItemOperationAdd, ItemID: "1", Quantity: 1},
+		{Op: cart.ItemOperationAdd, ItemID: "1", Options: red.Options, Quantity: 2},
+		{Op: cart.ItemOperationSet, LineID: red.ID(), Quantity: 4},
+		{Op: cart.ItemOperationRemove, ItemID: "1"},
+	})
+	assert.Nil(t, err)
+	assert.Len(t, c.Items, 1)
+	assert.Equal(t, red.ID(), c.Items[0].LineID)
+	assert.Equal(t, 4, c.Items[0].Quantity)
+
+	_, err = svc.ApplyItemOperations(context.TODO(), c.ID, []cart.ItemOperation{
+		{Op: cart.ItemOperationAdd, ItemID: "1", Options: red.Options, Quantity: 1},
+	})
+	sErr := errors.ServiceError{}
+	assert.ErrorAs(t, err, &sErr)
+	assert.Equal(t, errors.ItemOperationsFailedCode, sErr.Code)
+}
+
+func TestAddLineValidation(t *testing.T) {
+	svc := newPricingTestService(cache.NewMemoryCache(), &externalMock{})
+	c, err := svc.CreateCart(context.TODO())
+	assert.Nil(t, err)
+
+	options := map[string]string{}
+	for _, k := range strings.Split("a b c d e f g h i j k", " ") {
+		options[k] = "x"
+	}
+	_, err = svc.AddLineToCart(context.TODO(), c.ID, cart.Line{
+		ItemID:  "1",
+		Options: options,
+		Note:    strings.Repeat("n", cart.MaxNoteLength+1),
+	}, 1)
+	sErr := errors.ServiceError{}
+	assert.ErrorAs(t, err, &sErr)
+	assert.Equal(t, errors.ValidationErrorCode, sErr.Code)
+	fields := []string{}
+	for _, f := range sErr.Fields {
+		fields = append(fields, f.Field)
+	}
+	assert.ElementsMatch(t, []string{"options", "note"}, fields)
+
+	_, err = svc.AddLineToCart(context.TODO(), c.ID, cart.Line{
+		ItemID:  "1",
+		Options: map[string]string{"": "x"},
+	}, 1)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ValidationErrorCode})
+}
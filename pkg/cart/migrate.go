@@ -0,0 +1,153 @@
+package cart
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	keyPrefix = "cart:"
+	//legacyKeyPattern matches the bare IDs carts were stored under before their keys had a prefix
+	legacyKeyPattern = "????????-????-????-????-????????????"
+	//untaggedKeyPattern matches the keys carts were stored under before their keys had the tag of their partition
+	untaggedKeyPattern = keyPrefix + legacyKeyPattern
+	//KeyPattern matches the keys carts are stored under, their IDs being UUIDs
+	KeyPattern = keyPrefix + "{*}:" + legacyKeyPattern
+)
+
+//Key is where the cart is stored, tagged with the partition of the cart so its events are written along with it
+func Key(cartID string) string {
+	return keyPrefix + cache.PartitionTag(cache.Partition(cartID)) + ":" + cartID
+}
+
+//isLegacyKey tells whether the cart may be stored under its bare ID, only carts of UUIDs are looked for there,
+//so IDs naming keys of anything else are never read as carts
+func isLegacyKey(cartID string) bool {
+	_, err := uuid.Parse(cartID)
+	return err == nil && len(cartID) == len(legacyKeyPattern)
+}
+
+//legacyKeys gives where previous versions may have stored the cart, newest first: under its key before it had the
+//tag of the partition, and under its bare ID
+func legacyKeys(cartID string) []string {
+	keys := []string{keyPrefix + cartID}
+	if isLegacyKey(cartID) {
+		keys = append(keys, cartID)
+	}
+	return keys
+}
+
+//cartIDOf gives the ID of the cart stored under key, any of Key or legacyKeys
+func cartIDOf(key string) string {
+	return key[strings.LastIndexByte(key, ':')+1:]
+}
+
+//DefaultReportEvery is how many carts are scanned between progress reports unless MigratorOptions says otherwise
+const DefaultReportEvery = 1000
+
+//MigratorOptions tune a Migrator
+type MigratorOptions struct {
+	//DryRun only counts the carts needing an upgrade, leaving them as stored
+	DryRun bool
+	//ReportEvery is how many carts are scanned between progress reports
+	ReportEvery int
+}
+
+//MigrationProgress counts the carts a migration went through. Current carts were already stored with
+//SchemaVersion, or saved with it while being migrated, and Failed ones could not be read or written back
+type MigrationProgress struct {
+	Scanned  int
+	Upgraded int
+	Current  int
+	Failed   int
+}
+
+//Migrator upgrades every stored cart to SchemaVersion at once, instead of waiting for them to be read, and moves
+//the carts stored under any of their legacy keys to their Key. It works on the carts of the tenant of the context only.
+//It may run while the service does, carts saved between their read and their write back are left as saved
+type Migrator struct {
+	logger logger.Logger
+	cache  cache.Cache
+	opts   MigratorOptions
+}
+
+func NewMigrator(logger logger.Logger, c cache.Cache, opts MigratorOptions) *Migrator {
+	if opts.ReportEvery <= 0 {
+		opts.ReportEvery = DefaultReportEvery
+	}
+	return &Migrator{
+		logger: logger,
+		cache:  c,
+		opts:   opts,
+	}
+}
+
+//Run goes through every stored cart, calling report with the progress every ReportEvery carts and once done.
+//Carts failing are counted and logged without stopping the migration, only failing to scan the keys does
+func (m *Migrator) Run(ctx context.Context, report func(MigrationProgress)) (MigrationProgress, error) {
+	progress := MigrationProgress{}
+	m.logger.WithField("schema_version", SchemaVersion).WithField("dry_run", m.opts.DryRun).
+		Info(ctx, "Migrating stored Carts")
+	scan := func(key string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !strings.HasPrefix(key, keyPrefix) && !isLegacyKey(key) {
+			return nil
+		}
+		progress.Scanned++
+		switch upgraded, err := m.migrate(ctx, key); {
+		case err != nil:
+			m.logger.WithField("cart_id", key).WithError(err).Error(ctx, "Unable to migrate Cart")
+			progress.Failed++
+		case upgraded:
+			progress.Upgraded++
+		default:
+			progress.Current++
+		}
+		if progress.Scanned%m.opts.ReportEvery == 0 {
+			report(progress)
+		}
+		return nil
+	}
+	//the carts under legacy keys go last, so the ones moved to their key are not scanned twice
+	var err error
+	for _, pattern := range []string{KeyPattern, untaggedKeyPattern, legacyKeyPattern} {
+		if err = m.cache.Scan(ctx, pattern, scan); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		m.logger.WithError(err).Error(ctx, "Migration of stored Carts stopped")
+		return progress, err
+	}
+	if progress.Scanned == 0 || progress.Scanned%m.opts.ReportEvery != 0 {
+		report(progress)
+	}
+	return progress, nil
+}
+
+//migrate upgrades the cart stored under key, telling whether it needed it
+func (m *Migrator) migrate(ctx context.Context, key string) (bool, error) {
+	cartID := cartIDOf(key)
+	raw := json.RawMessage{}
+	if err := m.cache.Get(ctx, key, &raw); err != nil {
+		return false, err
+	}
+	cart, version, err := decodeCart(raw)
+	if err != nil {
+		return false, err
+	}
+	if version == SchemaVersion && key == Key(cartID) {
+		return false, nil
+	}
+	if m.opts.DryRun {
+		return true, nil
+	}
+	return writeBack(ctx, m.cache, key, cartID, raw, cart)
+}
@@ -1,15 +1,81 @@
 package cart
 
-import "github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+import (
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/currency"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/shipping"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/tax"
+)
 
 type Cart struct {
 	ID    string
 	Items []item.Item
+	//PriceLock, when set, holds the prices honoured at checkout until it expires
+	PriceLock *PriceLock `json:",omitempty"`
+	//ShippingAddress decides the taxes and the shipping options of the cart
+	ShippingAddress *Address `json:",omitempty"`
+	//ShippingOptionID is the shipping option chosen for the cart
+	ShippingOptionID string `json:",omitempty"`
+	//Taxes are calculated with the current prices every time the cart is read, they are not stored
+	Taxes *tax.Result `json:"-"`
+	//Shipping is the chosen option as currently quoted, nil when it is no longer offered for the cart
+	Shipping *shipping.Option `json:"-"`
+}
+
+//Address is where the cart is shipped to. Country is an ISO 3166-1 alpha-2 code and Region
+//the code of the state or province within it
+type Address struct {
+	Country    string
+	Region     string
+	PostalCode string
+}
+
+//PriceLock keeps the prices of the items in the cart when it was locked, by item ID
+type PriceLock struct {
+	Prices    map[string]float32
+	ExpiresAt time.Time
+}
+
+//LockedPrice gives the price locked for the item, if the lock covers it and has not expired at now
+func (c Cart) LockedPrice(itemID string, now time.Time) (float32, bool) {
+	if c.PriceLock == nil || !now.Before(c.PriceLock.ExpiresAt) {
+		return 0, false
+	}
+	price, ok := c.PriceLock.Prices[itemID]
+	return price, ok
 }
 
 type TransportCart struct {
 	ID    string               `json:"id"`
 	Items []item.TransportItem `json:"items"`
+	//Currency of the amounts, set when the currency of the catalog is known
+	Currency string `json:"currency,omitempty"`
+	//PriceLockExpiresAt is set while the prices of the cart are locked
+	PriceLockExpiresAt *time.Time        `json:"price_lock_expires_at,omitempty"`
+	ShippingAddress    *TransportAddress `json:"shipping_address,omitempty"`
+	//TaxRegion is the region whose tax rules apply, set when the cart has taxes
+	TaxRegion      string                    `json:"tax_region,omitempty"`
+	ShippingOption *shipping.TransportOption `json:"shipping_option,omitempty"`
+	Totals         TransportTotals           `json:"totals"`
+}
+
+type TransportAddress struct {
+	Country    string `json:"country"`
+	Region     string `json:"region,omitempty"`
+	PostalCode string `json:"postal_code,omitempty"`
+}
+
+//TransportTotals add up the items of the cart. Tax is only set when the cart has taxes,
+//it is part of Items already when TaxIncluded, and added on top of them otherwise.
+//Shipping is only set when the cart has a shipping option, it is not taxed
+type TransportTotals struct {
+	Items       float32  `json:"items"`
+	Tax         *float32 `json:"tax,omitempty"`
+	TaxIncluded bool     `json:"tax_included,omitempty"`
+	Shipping    *float32 `json:"shipping,omitempty"`
+	Total       float32  `json:"total"`
 }
 
 type CartResponse struct {
@@ -17,28 +83,218 @@ type CartResponse struct {
 }
 
 func CartModelToTransportModel(cart Cart) TransportCart {
+	return CartModelToTransportModelIn(cart, currency.Rate{})
+}
+
+//CartModelToTransportModelIn gives the cart with its amounts converted with the rate. Totals add up
+//the converted amounts, so they match the items shown
+func CartModelToTransportModelIn(cart Cart, rate currency.Rate) TransportCart {
+	now := time.Now()
 	vmItems := []item.TransportItem{}
+	itemsCents := int64(0)
 
 	for _, i := range cart.Items {
-		vmItems = append(vmItems, item.TransportItem{
-			ID:       i.ID,
-			Name:     i.Name,
-			Quantity: i.Quantity,
-			Price:    i.Price,
-		})
+		vmItem := item.TransportItem{
+			ID:          i.ID,
+			LineID:      i.LineID,
+			Options:     i.Options,
+			Note:        i.Note,
+			Name:        i.Name,
+			Quantity:    i.Quantity,
+			Price:       rate.Convert(i.Price),
+			PriceChange: item.ConvertPriceChange(item.PriceChangeOf(i), rate),
+			Weight:      i.Weight,
+		}
+		if price, ok := cart.LockedPrice(i.ID, now); ok {
+			price = rate.Convert(price)
+			vmItem.LockedPrice = &price
+		}
+		if cart.Taxes != nil {
+			if lt, ok := cart.Taxes.Line(i.LineID); ok {
+				vmItem.Tax = tax.LineTaxToTransportModel(lt)
+				vmItem.Tax.Net = rate.Convert(lt.Net)
+				vmItem.Tax.Tax = rate.Convert(lt.Tax)
+				vmItem.Tax.Gross = rate.Convert(lt.Gross)
+			}
+		}
+		vmItems = append(vmItems, vmItem)
+		itemsCents += tax.Cents(vmItem.Price) * int64(i.Quantity)
 	}
 
-	return TransportCart{
+	tc := TransportCart{
 		ID:    cart.ID,
 		Items: vmItems,
+		//the zero rate leaves the currency unknown
+		Currency: rate.To,
+		Totals: TransportTotals{
+			Items: tax.Amount(itemsCents),
+		},
+	}
+	totalCents := itemsCents
+	if a := cart.ShippingAddress; a != nil {
+		tc.ShippingAddress = &TransportAddress{
+			Country:    a.Country,
+			Region:     a.Region,
+			PostalCode: a.PostalCode,
+		}
+	}
+	if t := cart.Taxes; t != nil {
+		taxAmount := rate.Convert(t.Tax)
+		tc.TaxRegion = t.Region
+		tc.Totals.Tax = &taxAmount
+		tc.Totals.TaxIncluded = t.Mode == tax.ModeInclusive
+		if !tc.Totals.TaxIncluded {
+			totalCents += tax.Cents(taxAmount)
+		}
+	}
+	if o := cart.Shipping; o != nil {
+		option := shipping.OptionToTransportModel(*o)
+		option.Cost = rate.Convert(o.Cost)
+		cost := option.Cost
+		tc.ShippingOption = &option
+		tc.Totals.Shipping = &cost
+		totalCents += tax.Cents(cost)
 	}
+	tc.Totals.Total = tax.Amount(totalCents)
+	if cart.PriceLock != nil && now.Before(cart.PriceLock.ExpiresAt) {
+		expiresAt := cart.PriceLock.ExpiresAt
+		tc.PriceLockExpiresAt = &expiresAt
+	}
+	return tc
 }
 
 type AddItemToCartRequest struct {
 	ID       string `json:"id"`
 	Quantity int    `json:"quantity"`
+	//Options and Note make the item another line of the cart
+	Options map[string]string `json:"options"`
+	Note    string            `json:"note"`
 }
 
 type ModifyItemQuantityRequest struct {
 	Quantity int `json:"quantity"`
 }
+
+//Operations of a bulk change of the items of a cart
+const (
+	ItemOperationAdd    = "add"
+	ItemOperationSet    = "set"
+	ItemOperationRemove = "remove"
+)
+
+//Statuses of the operations of a bulk change
+const (
+	OperationStatusOK     = "ok"
+	OperationStatusFailed = "failed"
+)
+
+//ItemOperation is a single change of a bulk change of the items of a cart. Additions take the options and note
+//of the new line, the other operations point to the line with LineID, or with ItemID when it is not set
+type ItemOperation struct {
+	Op       string
+	ItemID   string
+	LineID   string
+	Options  map[string]string
+	Note     string
+	Quantity int
+}
+
+//lineRef gives the reference to the line the operation changes
+func (op ItemOperation) lineRef() string {
+	if op.LineID != "" {
+		return op.LineID
+	}
+	return op.ItemID
+}
+
+//ItemOperationResult tells how a single operation of a failed bulk change went
+type ItemOperationResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ItemID string `json:"item_id"`
+	LineID string `json:"line_id,omitempty"`
+	Status string `json:"status"`
+	//Code and Fields describe the error of the failed operations
+	Code   string            `json:"code,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+type ItemOperationRequest struct {
+	Op       string            `json:"op"`
+	ItemID   string            `json:"item_id"`
+	LineID   string            `json:"line_id,omitempty"`
+	Options  map[string]string `json:"options,omitempty"`
+	Note     string            `json:"note,omitempty"`
+	Quantity int               `json:"quantity,omitempty"`
+}
+
+type ItemOperationsRequest struct {
+	Operations []ItemOperationRequest `json:"operations"`
+}
+
+//ShareToken grants read-only access to a cart until it expires
+type ShareToken struct {
+	Value     string
+	ExpiresAt time.Time
+}
+
+type ShareCartResponse struct {
+	Token string `json:"token"`
+	//URL is the path resolving the token into the shared cart
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type ImportSharedCartRequest struct {
+	CartID string `json:"cart_id"`
+}
+
+//CheckoutItem is an item of a checked out cart, at the price it is sold
+type CheckoutItem struct {
+	ID        string            `json:"id"`
+	LineID    string            `json:"line_id"`
+	Name      string            `json:"name"`
+	Options   map[string]string `json:"options,omitempty"`
+	Note      string            `json:"note,omitempty"`
+	Quantity  int               `json:"quantity"`
+	UnitPrice float32           `json:"unit_price"`
+	//Locked tells the unit price comes from the price lock of the cart
+	Locked bool `json:"locked"`
+}
+
+//Checkout is the summary of a checked out cart. Tax is part of Subtotal already when TaxIncluded,
+//and added on top of it otherwise. Shipping is added on top of both
+type Checkout struct {
+	CartID         string                    `json:"cart_id"`
+	Items          []CheckoutItem            `json:"items"`
+	Subtotal       float32                   `json:"subtotal"`
+	Tax            float32                   `json:"tax"`
+	TaxIncluded    bool                      `json:"tax_included,omitempty"`
+	ShippingOption *shipping.TransportOption `json:"shipping_option,omitempty"`
+	Shipping       float32                   `json:"shipping"`
+	Total          float32                   `json:"total"`
+	CheckedOutAt   time.Time                 `json:"checked_out_at"`
+}
+
+type SetShippingAddressRequest struct {
+	Country    string `json:"country"`
+	Region     string `json:"region"`
+	PostalCode string `json:"postal_code"`
+}
+
+type SelectShippingOptionRequest struct {
+	OptionID string `json:"option_id"`
+}
+
+type ShippingOptionsResponse struct {
+	Options []shipping.TransportOption `json:"options"`
+}
+
+type CheckoutRequest struct {
+	//AcceptPriceChanges lets the checkout go on at the current prices of items whose price changed
+	AcceptPriceChanges bool `json:"accept_price_changes"`
+}
+
+type CheckoutResponse struct {
+	Checkout Checkout `json:"checkout"`
+}
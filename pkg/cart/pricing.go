@@ -0,0 +1,175 @@
+package cart
+
+import (
+	"context"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/shipping"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/tax"
+)
+
+//DefaultPriceLockTTL is how long prices stay locked unless WithPriceLockTTL is used
+const DefaultPriceLockTTL = 15 * time.Minute
+
+//WithPriceLockTTL sets how long prices stay locked
+func WithPriceLockTTL(ttl time.Duration) Option {
+	return func(s *service) {
+		s.priceLockTTL = ttl
+	}
+}
+
+//PriceChangeDetail describes an item whose price changed, in the details of err_price_changed
+type PriceChangeDetail struct {
+	ItemID    string  `json:"item_id"`
+	OldPrice  float32 `json:"old_price"`
+	NewPrice  float32 `json:"new_price"`
+	Direction string  `json:"direction"`
+}
+
+//unlockPrice drops the locked price of the item, so adding it again takes the price of that moment
+func (c *Cart) unlockPrice(itemID string) {
+	if c.PriceLock != nil {
+		delete(c.PriceLock.Prices, itemID)
+	}
+}
+
+func (s *service) LockPrices(ctx context.Context, cartID string) (Cart, error) {
+	log := s.logger.WithField("cart_id", cartID)
+
+	log.Info(ctx, "Locking Cart prices")
+	cart, err := s.GetCart(ctx, cartID)
+	if err != nil {
+		return Cart{}, err
+	}
+	if len(cart.Items) == 0 {
+		log.Error(ctx, "Cart has no items to lock")
+		return Cart{}, errors.ServiceError{Code: errors.ValidationErrorCode}.
+			WithFieldViolation("items", "the cart must have items")
+	}
+
+	lock := &PriceLock{
+		Prices:    make(map[string]float32, len(cart.Items)),
+		ExpiresAt: time.Now().Add(s.priceLockTTL).UTC(),
+	}
+	for _, i := range cart.Items {
+		lock.Prices[i.ID] = i.Price
+	}
+	cart.PriceLock = lock
+
+	log.Info(ctx, "Saving Cart in DB")
+	if err := s.save(ctx, cart, PricesLocked{CartID: cartID, ExpiresAt: lock.ExpiresAt}); err != nil {
+		log.WithError(err).Error(ctx, "Unable to save Cart to DB")
+		return Cart{}, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	return cart, nil
+}
+
+func (s *service) Checkout(ctx context.Context, cartID string, acceptPriceChanges bool) (Checkout, error) {
+	log := s.logger.WithField("cart_id", cartID)
+
+	log.Info(ctx, "Checking out Cart")
+	cart, err := s.GetCart(ctx, cartID)
+	if err != nil {
+		return Checkout{}, err
+	}
+	if len(cart.Items) == 0 {
+		log.Error(ctx, "Cart has no items to check out")
+		return Checkout{}, errors.ServiceError{Code: errors.ValidationErrorCode}.
+			WithFieldViolation("items", "the cart must have items")
+	}
+
+	now := time.Now()
+	res := Checkout{
+		CartID:       cartID,
+		Items:        make([]CheckoutItem, 0, len(cart.Items)),
+		CheckedOutAt: now.UTC(),
+	}
+	changes := []PriceChangeDetail{}
+	//an item whose price changed is reported once, whatever the lines it has
+	changed := map[string]bool{}
+	lines := make([]tax.Line, 0, len(cart.Items))
+	subtotal := int64(0)
+	for _, i := range cart.Items {
+		ci := CheckoutItem{
+			ID:        i.ID,
+			LineID:    i.LineID,
+			Name:      i.Name,
+			Options:   i.Options,
+			Note:      i.Note,
+			Quantity:  i.Quantity,
+			UnitPrice: i.Price,
+		}
+		if price, ok := cart.LockedPrice(i.ID, now); ok {
+			ci.UnitPrice = price
+			ci.Locked = true
+		} else if change := item.PriceChangeOf(i); change != nil && !acceptPriceChanges && !changed[i.ID] {
+			changed[i.ID] = true
+			changes = append(changes, PriceChangeDetail{
+				ItemID:    i.ID,
+				OldPrice:  change.OldPrice,
+				NewPrice:  change.NewPrice,
+				Direction: change.Direction,
+			})
+		}
+		res.Items = append(res.Items, ci)
+		lines = append(lines, tax.Line{
+			ID:        ci.LineID,
+			Category:  i.Category,
+			UnitPrice: ci.UnitPrice,
+			Quantity:  ci.Quantity,
+		})
+		subtotal += tax.Cents(ci.UnitPrice) * int64(ci.Quantity)
+	}
+	if len(changes) > 0 {
+		log.Error(ctx, "Prices changed since the items were added")
+		return Checkout{}, errors.ServiceError{Code: errors.PriceChangedCode}.
+			WithDetail("cart_id", cartID).
+			WithDetail("items", changes)
+	}
+
+	res.Subtotal = tax.Amount(subtotal)
+	total := subtotal
+	if taxes := s.calculateTaxes(cart, lines); taxes != nil {
+		res.Tax = taxes.Tax
+		res.TaxIncluded = taxes.Mode == tax.ModeInclusive
+		total = tax.Cents(taxes.Gross)
+	}
+	if cart.ShippingOptionID != "" {
+		//shipping is quoted again for the value sold, which may differ from the current one under a price lock
+		options, err := s.quoteShipping(ctx, cart, subtotal)
+		if err != nil {
+			log.WithError(err).Error(ctx, "Unable to quote shipping options")
+			return Checkout{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}.WithCause(err)
+		}
+		option, ok := findOption(options, cart.ShippingOptionID)
+		if !ok {
+			log.Error(ctx, "Shipping option is no longer offered for the Cart")
+			return Checkout{}, errors.ServiceError{Code: errors.ValidationErrorCode}.
+				WithFieldViolation("shipping_option", "is no longer offered for the cart, another one must be selected")
+		}
+		transportOption := shipping.OptionToTransportModel(option)
+		res.ShippingOption = &transportOption
+		res.Shipping = option.Cost
+		total += tax.Cents(option.Cost)
+	}
+	res.Total = tax.Amount(total)
+
+	//the lock is used up by the checkout
+	cart.PriceLock = nil
+	log.Info(ctx, "Saving Cart in DB")
+	event := CartCheckedOut{
+		CartID:           cartID,
+		Items:            res.Items,
+		Tax:              res.Tax,
+		ShippingOptionID: cart.ShippingOptionID,
+		Shipping:         res.Shipping,
+		Total:            res.Total,
+	}
+	if err := s.save(ctx, cart, event); err != nil {
+		log.WithError(err).Error(ctx, "Unable to save Cart to DB")
+		return Checkout{}, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	return res, nil
+}
@@ -0,0 +1,160 @@
+package cart_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/events"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPricingTestService(c cache.Cache, ext *externalMock, opts ...cart.Option) cart.Service {
+	return cart.NewCartService("unit-testing",
+		logger.NewLogger("cart service unit testing", false),
+		c,
+		ext,
+		opts...)
+}
+
+func TestAddedPriceIsKept(t *testing.T) {
+	ext := &externalMock{prices: map[string]float32{"1": 10, "2": 5}}
+	svc := newPricingTestService(cache.NewMemoryCache(), ext)
+	c := cartWithItems(t, svc, map[string]int{"1": 1})
+	_, err := svc.ApplyItemOperations(context.TODO(), c.ID, []cart.ItemOperation{
+		{Op: cart.ItemOperationAdd, ItemID: "2", Quantity: 1},
+	})
+	assert.Nil(t, err)
+
+	ext.prices = map[string]float32{"1": 12, "2": 4}
+	got, err := svc.GetCart(context.TODO(), c.ID)
+	assert.Nil(t, err)
+
+	tc := cart.CartModelToTransportModel(got)
+	assert.Equal(t, float32(12), tc.Items[0].Price)
+	assert.Equal(t, &item.PriceChange{OldPrice: 10, NewPrice: 12, Direction: item.PriceUp}, tc.Items[0].PriceChange)
+	assert.Equal(t, &item.PriceChange{OldPrice: 5, NewPrice: 4, Direction: item.PriceDown}, tc.Items[1].PriceChange)
+}
+
+func TestUnchangedPriceIsNotFlagged(t *testing.T) {
+	ext := &externalMock{prices: map[string]float32{"1": 10}}
+	svc := newPricingTestService(cache.NewMemoryCache(), ext)
+	c := cartWithItems(t, svc, map[string]int{"1": 1})
+
+	tc := cart.CartModelToTransportModel(c)
+	assert.Nil(t, tc.Items[0].PriceChange)
+	assert.Nil(t, tc.Items[0].LockedPrice)
+	assert.Nil(t, tc.PriceLockExpiresAt)
+}
+
+func TestCheckoutFailsOnPriceChange(t *testing.T) {
+	ext := &externalMock{prices: map[string]float32{"1": 10}}
+	svc := newPricingTestService(cache.NewMemoryCache(), ext)
+	c := cartWithItems(t, svc, map[string]int{"1": 2})
+	ext.prices["1"] = 11
+
+	_, err := svc.Checkout(context.TODO(), c.ID, false)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.PriceChangedCode})
+	sErr := errors.ServiceError{}
+	assert.ErrorAs(t, err, &sErr)
+	assert.Equal(t, []cart.PriceChangeDetail{
+		{ItemID: "1", OldPrice: 10, NewPrice: 11, Direction: item.PriceUp},
+	}, sErr.Details["items"])
+
+	checkout, err := svc.Checkout(context.TODO(), c.ID, true)
+	assert.Nil(t, err)
+	assert.Equal(t, float32(22), checkout.Total)
+	assert.False(t, checkout.Items[0].Locked)
+}
+
+func TestCheckoutHonoursPriceLock(t *testing.T) {
+	c := cache.NewMemoryCache()
+	ext := &externalMock{prices: map[string]float32{"1": 10, "2": 3}}
+	svc := newPricingTestService(c, ext)
+	created := cartWithItems(t, svc, map[string]int{"1": 2})
+
+	locked, err := svc.LockPrices(context.TODO(), created.ID)
+	assert.Nil(t, err)
+	tc := cart.CartModelToTransportModel(locked)
+	assert.NotNil(t, tc.PriceLockExpiresAt)
+	assert.Equal(t, float32(10), *tc.Items[0].LockedPrice)
+
+	//items added after locking are not covered by the lock
+	_, err = svc.AddItemToCart(context.TODO(), created.ID, "2", 1)
+	assert.Nil(t, err)
+	ext.prices = map[string]float32{"1": 15, "2": 3}
+
+	checkout, err := svc.Checkout(context.TODO(), created.ID, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []cart.CheckoutItem{
+		{ID: "1", LineID: cart.LineID("1", nil, ""), Quantity: 2, UnitPrice: 10, Locked: true},
+		{ID: "2", LineID: cart.LineID("2", nil, ""), Quantity: 1, UnitPrice: 3},
+	}, checkout.Items)
+	assert.Equal(t, float32(23), checkout.Total)
+
+	//the checkout uses up the lock
+	got, err := svc.GetCart(context.TODO(), created.ID)
+	assert.Nil(t, err)
+	assert.Nil(t, got.PriceLock)
+
+	sink := events.NewMemorySink()
+	_, err = events.NewRelay(logger.NewLogger("relay unit testing", false), c, 0, 0, sink).Drain(context.TODO())
+	assert.Nil(t, err)
+	published := sink.Events()
+	assert.Equal(t, cart.EventCartCheckedOut, published[len(published)-1].Type)
+}
+
+func TestExpiredPriceLockIsIgnored(t *testing.T) {
+	ext := &externalMock{prices: map[string]float32{"1": 10}}
+	svc := newPricingTestService(cache.NewMemoryCache(), ext, cart.WithPriceLockTTL(time.Millisecond))
+	c := cartWithItems(t, svc, map[string]int{"1": 1})
+
+	_, err := svc.LockPrices(context.TODO(), c.ID)
+	assert.Nil(t, err)
+	time.Sleep(5 * time.Millisecond)
+	ext.prices["1"] = 8
+
+	got, err := svc.GetCart(context.TODO(), c.ID)
+	assert.Nil(t, err)
+	tc := cart.CartModelToTransportModel(got)
+	assert.Nil(t, tc.PriceLockExpiresAt)
+	assert.Nil(t, tc.Items[0].LockedPrice)
+
+	_, err = svc.Checkout(context.TODO(), c.ID, false)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.PriceChangedCode})
+}
+
+func TestRemovedItemLosesLockedPrice(t *testing.T) {
+	ext := &externalMock{prices: map[string]float32{"1": 10}}
+	svc := newPricingTestService(cache.NewMemoryCache(), ext)
+	c := cartWithItems(t, svc, map[string]int{"1": 1})
+
+	_, err := svc.LockPrices(context.TODO(), c.ID)
+	assert.Nil(t, err)
+	_, err = svc.DeleteItemInCart(context.TODO(), c.ID, "1")
+	assert.Nil(t, err)
+	ext.prices["1"] = 12
+	_, err = svc.AddItemToCart(context.TODO(), c.ID, "1", 1)
+	assert.Nil(t, err)
+
+	checkout, err := svc.Checkout(context.TODO(), c.ID, false)
+	assert.Nil(t, err)
+	assert.Equal(t, float32(12), checkout.Items[0].UnitPrice)
+	assert.False(t, checkout.Items[0].Locked)
+}
+
+func TestCheckoutEmptyCart(t *testing.T) {
+	svc := newPricingTestService(cache.NewMemoryCache(), &externalMock{})
+	c, err := svc.CreateCart(context.TODO())
+	assert.Nil(t, err)
+
+	_, err = svc.Checkout(context.TODO(), c.ID, false)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ValidationErrorCode})
+	_, err = svc.LockPrices(context.TODO(), c.ID)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ValidationErrorCode})
+}
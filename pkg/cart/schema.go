@@ -0,0 +1,162 @@
+package cart
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+)
+
+//SchemaVersion is the version carts are stored with. Carts stored with an older one are upgraded when read
+//and written back upgraded. Changing how carts are stored means increasing it and registering the upgrade
+//from the previous version in upgrades
+const SchemaVersion = 2
+
+//legacySchemaVersion is the version of the carts stored as they were, before the envelope existed
+const legacySchemaVersion = 1
+
+//storedCart is the envelope carts are stored in
+type storedCart struct {
+	SchemaVersion int             `json:"schema_version"`
+	Cart          json.RawMessage `json:"cart"`
+}
+
+//Upgrade takes a stored cart of a version into the next one. It works on the JSON as stored, so it keeps
+//working once the Cart type changes again
+type Upgrade func(cart map[string]interface{}) error
+
+//upgrades holds the upgrade of every version into the next one, by the version upgraded
+var upgrades = map[int]Upgrade{
+	legacySchemaVersion: upgradeToLines,
+}
+
+//decodeCart reads a stored cart of any version, upgrading it to SchemaVersion. It gives the version it was stored with
+func decodeCart(b []byte) (Cart, int, error) {
+	stored := storedCart{}
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return Cart{}, 0, err
+	}
+	if stored.SchemaVersion == 0 {
+		stored = storedCart{SchemaVersion: legacySchemaVersion, Cart: b}
+	}
+	if stored.SchemaVersion > SchemaVersion {
+		return Cart{}, stored.SchemaVersion, fmt.Errorf("cart schema version %d is newer than %d, the one known", stored.SchemaVersion, SchemaVersion)
+	}
+
+	raw := stored.Cart
+	if stored.SchemaVersion < SchemaVersion {
+		data := map[string]interface{}{}
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return Cart{}, stored.SchemaVersion, err
+		}
+		for v := stored.SchemaVersion; v < SchemaVersion; v++ {
+			upgrade, ok := upgrades[v]
+			if !ok {
+				return Cart{}, stored.SchemaVersion, fmt.Errorf("no upgrade registered for cart schema version %d", v)
+			}
+			if err := upgrade(data); err != nil {
+				return Cart{}, stored.SchemaVersion, fmt.Errorf("upgrading cart schema version %d: %w", v, err)
+			}
+		}
+		var err error
+		if raw, err = json.Marshal(data); err != nil {
+			return Cart{}, stored.SchemaVersion, err
+		}
+	}
+
+	cart := Cart{}
+	if err := json.Unmarshal(raw, &cart); err != nil {
+		return Cart{}, stored.SchemaVersion, err
+	}
+	return cart, stored.SchemaVersion, nil
+}
+
+//encodeCart gives the cart as stored, in the envelope of SchemaVersion
+func encodeCart(cart Cart) (storedCart, error) {
+	b, err := json.Marshal(cart)
+	if err != nil {
+		return storedCart{}, err
+	}
+	return storedCart{SchemaVersion: SchemaVersion, Cart: b}, nil
+}
+
+//writeBack stores the cart read as raw from key under the key of cartID, upgraded to SchemaVersion, telling whether
+//it was stored. Nothing is stored when a change was saved since the read, that change being upgraded already.
+//Carts read under a legacy key are only moved while their key is missing, as saves only write there, and the
+//legacy key is removed either way
+func writeBack(ctx context.Context, c cache.Cache, key, cartID string, raw json.RawMessage, cart Cart) (bool, error) {
+	stored, err := encodeCart(cart)
+	if err != nil {
+		return false, err
+	}
+	if key == Key(cartID) {
+		return c.CompareAndSet(ctx, key, raw, stored)
+	}
+	moved, err := c.CompareAndSet(ctx, Key(cartID), nil, stored)
+	if err != nil {
+		return false, err
+	}
+	if err := c.Del(ctx, key); err != nil && !cache.IsNotFound(err) {
+		return moved, err
+	}
+	return moved, nil
+}
+
+//get reads the cart, upgrading it when stored with an older schema version or under a legacy key, as carts were
+//before their keys had a prefix and the tag of their partition. Upgraded carts are written back unless changed meanwhile, failing to do so is only
+//logged as the next read upgrades them again
+func (s *service) get(ctx context.Context, cartID string, cart *Cart) error {
+	key, raw := Key(cartID), json.RawMessage{}
+	err := s.cache.Get(ctx, key, &raw)
+	for _, legacy := range legacyKeys(cartID) {
+		if !cache.IsNotFound(err) {
+			break
+		}
+		key = legacy
+		err = s.cache.Get(ctx, key, &raw)
+	}
+	if err != nil {
+		return err
+	}
+	c, version, err := decodeCart(raw)
+	if err != nil {
+		return err
+	}
+	if version < SchemaVersion || key != Key(cartID) {
+		log := s.logger.WithField("cart_id", cartID).WithField("schema_version", version).WithField("key", key)
+		log.Info(ctx, "Writing back Cart upgraded to the current schema version and key")
+		if stored, err := writeBack(ctx, s.cache, key, cartID, raw, c); err != nil {
+			log.WithError(err).Warn(ctx, "Unable to write back upgraded Cart")
+		} else if !stored {
+			log.Info(ctx, "Upgraded Cart not written back, it was saved meanwhile")
+		}
+	}
+	*cart = c
+	return nil
+}
+
+//upgradeToLines gives their line ID to the items of carts stored before lines existed
+func upgradeToLines(cart map[string]interface{}) error {
+	items, _ := cart["Items"].([]interface{})
+	for _, i := range items {
+		line, ok := i.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("item is not an object")
+		}
+		if id, _ := line["LineID"].(string); id != "" {
+			continue
+		}
+		itemID, _ := line["ID"].(string)
+		note, _ := line["Note"].(string)
+		var options map[string]string
+		if opts, ok := line["Options"].(map[string]interface{}); ok {
+			options = make(map[string]string, len(opts))
+			for k, v := range opts {
+				options[k], _ = v.(string)
+			}
+		}
+		line["LineID"] = LineID(itemID, options, note)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package cart_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	legacyCartID = "6f1c2a8e-0000-4000-8000-000000000001"
+	otherCartID  = "6f1c2a8e-0000-4000-8000-000000000002"
+	brokenCartID = "6f1c2a8e-0000-4000-8000-000000000003"
+	//untaggedCartID is stored under its key as it was before keys had the tag of their partition
+	untaggedCartID = "6f1c2a8e-0000-4000-8000-000000000004"
+)
+
+//storedEnvelope is how carts are expected to be stored
+type storedEnvelope struct {
+	SchemaVersion int                    `json:"schema_version"`
+	Cart          map[string]interface{} `json:"cart"`
+}
+
+func storeRaw(t *testing.T, c cache.Cache, key, raw string) {
+	assert.Nil(t, c.Set(context.TODO(), key, json.RawMessage(raw)))
+}
+
+func TestCartsAreStoredWithSchemaVersion(t *testing.T) {
+	c := cache.NewMemoryCache()
+	svc := newPricingTestService(c, &externalMock{})
+	created := cartWithItems(t, svc, map[string]int{"1": 1})
+
+	stored := storedEnvelope{}
+	assert.Nil(t, c.Get(context.TODO(), cart.Key(created.ID), &stored))
+	assert.Equal(t, cart.SchemaVersion, stored.SchemaVersion)
+	assert.Equal(t, created.ID, stored.Cart["ID"])
+}
+
+func TestLegacyCartsAreUpgradedOnRead(t *testing.T) {
+	c := cache.NewMemoryCache()
+	svc := newPricingTestService(c, &externalMock{})
+	//a cart as stored before the schema was versioned, before lines existed and under its bare ID
+	storeRaw(t, c, legacyCartID, `{"ID":"`+legacyCartID+`","Items":[{"ID":"1","Quantity":2},{"ID":"2","Quantity":1}]}`)
+
+	got, err := svc.GetCart(context.TODO(), legacyCartID)
+	assert.Nil(t, err)
+	assert.Equal(t, cart.LineID("1", nil, ""), got.Items[0].LineID)
+	assert.Equal(t, cart.LineID("2", nil, ""), got.Items[1].LineID)
+
+	//the upgraded cart is written back under its key
+	stored := storedEnvelope{}
+	assert.Nil(t, c.Get(context.TODO(), cart.Key(legacyCartID), &stored))
+	assert.Equal(t, cart.SchemaVersion, stored.SchemaVersion)
+	assert.True(t, cache.IsNotFound(c.Get(context.TODO(), legacyCartID, &stored)))
+	items := stored.Cart["Items"].([]interface{})
+	assert.Equal(t, cart.LineID("1", nil, ""), items[0].(map[string]interface{})["LineID"])
+
+	//and changed by line ID like any other
+	_, err = svc.ModifyItemInCart(context.TODO(), legacyCartID, cart.LineID("1", nil, ""), 5)
+	assert.Nil(t, err)
+}
+
+func TestUntaggedCartsAreMovedOnRead(t *testing.T) {
+	c := cache.NewMemoryCache()
+	svc := newPricingTestService(c, &externalMock{})
+	storeRaw(t, c, "cart:"+untaggedCartID, `{"schema_version":2,"cart":{"ID":"`+untaggedCartID+`","Items":[]}}`)
+
+	got, err := svc.GetCart(context.TODO(), untaggedCartID)
+	assert.Nil(t, err)
+	assert.Equal(t, untaggedCartID, got.ID)
+
+	stored := storedEnvelope{}
+	assert.Nil(t, c.Get(context.TODO(), cart.Key(untaggedCartID), &stored))
+	assert.Equal(t, cart.SchemaVersion, stored.SchemaVersion)
+	assert.True(t, cache.IsNotFound(c.Get(context.TODO(), "cart:"+untaggedCartID, &stored)))
+}
+
+func TestCartsOfNewerSchemaVersionsAreNotRead(t *testing.T) {
+	c := cache.NewMemoryCache()
+	svc := newPricingTestService(c, &externalMock{})
+	raw := `{"schema_version":99,"cart":{"ID":"` + otherCartID + `"}}`
+	storeRaw(t, c, cart.Key(otherCartID), raw)
+
+	_, err := svc.GetCart(context.TODO(), otherCartID)
+	assert.NotNil(t, err)
+
+	//the cart is left as stored
+	stored := json.RawMessage{}
+	assert.Nil(t, c.Get(context.TODO(), cart.Key(otherCartID), &stored))
+	assert.JSONEq(t, raw, string(stored))
+}
+
+func TestOnlyUUIDsAreLookedForUnderTheirBareID(t *testing.T) {
+	c := cache.NewMemoryCache()
+	svc := newPricingTestService(c, &externalMock{})
+	storeRaw(t, c, "list:1", `{"ID":"1","Items":[]}`)
+
+	_, err := svc.GetCart(context.TODO(), "list:1")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.CartNotFoundCode})
+	stored := json.RawMessage{}
+	assert.Nil(t, c.Get(context.TODO(), "list:1", &stored))
+}
+
+func TestMigratorUpgradesStoredCarts(t *testing.T) {
+	c := cache.NewMemoryCache()
+	svc := newPricingTestService(c, &externalMock{})
+	current := cartWithItems(t, svc, map[string]int{"1": 1})
+	storeRaw(t, c, legacyCartID, `{"ID":"`+legacyCartID+`","Items":[{"ID":"1","Quantity":2}]}`)
+	//a current cart, but under its bare ID
+	storeRaw(t, c, otherCartID, `{"schema_version":2,"cart":{"ID":"`+otherCartID+`","Items":[]}}`)
+	storeRaw(t, c, brokenCartID, `"not a cart"`)
+	storeRaw(t, c, "cart:"+untaggedCartID, `{"schema_version":2,"cart":{"ID":"`+untaggedCartID+`","Items":[]}}`)
+	//keys of anything else than carts are left alone
+	storeRaw(t, c, "list:1", `{"ID":"1"}`)
+	log := logger.NewLogger("cart migrator unit testing", false)
+
+	reports := []cart.MigrationProgress{}
+	report := func(p cart.MigrationProgress) {
+		reports = append(reports, p)
+	}
+	progress, err := cart.NewMigrator(log, c, cart.MigratorOptions{DryRun: true}).Run(context.TODO(), report)
+	assert.Nil(t, err)
+	assert.Equal(t, cart.MigrationProgress{Scanned: 5, Upgraded: 3, Current: 1, Failed: 1}, progress)
+	stored := storedEnvelope{}
+	assert.Nil(t, c.Get(context.TODO(), legacyCartID, &stored))
+	assert.Equal(t, 0, stored.SchemaVersion)
+	assert.True(t, cache.IsNotFound(c.Get(context.TODO(), cart.Key(legacyCartID), &stored)))
+
+	progress, err = cart.NewMigrator(log, c, cart.MigratorOptions{ReportEvery: 2}).Run(context.TODO(), report)
+	assert.Nil(t, err)
+	assert.Equal(t, cart.MigrationProgress{Scanned: 5, Upgraded: 3, Current: 1, Failed: 1}, progress)
+	//the dry run reports once done, the real one every 2 carts and once done
+	assert.Len(t, reports, 4)
+	assert.Equal(t, 2, reports[1].Scanned)
+	for _, id := range []string{legacyCartID, otherCartID, untaggedCartID, current.ID} {
+		stored := storedEnvelope{}
+		assert.Nil(t, c.Get(context.TODO(), cart.Key(id), &stored))
+		assert.Equal(t, cart.SchemaVersion, stored.SchemaVersion)
+		assert.Equal(t, id, stored.Cart["ID"])
+	}
+	assert.True(t, cache.IsNotFound(c.Get(context.TODO(), otherCartID, &stored)))
+	assert.True(t, cache.IsNotFound(c.Get(context.TODO(), "cart:"+untaggedCartID, &stored)))
+
+	progress, err = cart.NewMigrator(log, c, cart.MigratorOptions{}).Run(context.TODO(), report)
+	assert.Nil(t, err)
+	assert.Equal(t, cart.MigrationProgress{Scanned: 5, Current: 4, Failed: 1}, progress)
+}
+
+//savingMeanwhile stores changed under key right after key is first read, as a save racing with that read would
+type savingMeanwhile struct {
+	cache.Cache
+	key     string
+	changed string
+	saved   bool
+}
+
+func (s *savingMeanwhile) Get(ctx context.Context, key string, here interface{}) error {
+	err := s.Cache.Get(ctx, key, here)
+	if key == s.key && !s.saved {
+		s.saved = true
+		s.Cache.Set(ctx, key, json.RawMessage(s.changed))
+	}
+	return err
+}
+
+func TestUpgradedCartsAreNotWrittenBackOverChangesSavedMeanwhile(t *testing.T) {
+	changed := `{"schema_version":2,"cart":{"ID":"` + legacyCartID + `","Items":[]}}`
+	c := &savingMeanwhile{Cache: cache.NewMemoryCache(), key: cart.Key(legacyCartID), changed: changed}
+	svc := newPricingTestService(c, &externalMock{})
+	storeRaw(t, c, cart.Key(legacyCartID), `{"ID":"`+legacyCartID+`","Items":[{"ID":"1","Quantity":2}]}`)
+
+	got, err := svc.GetCart(context.TODO(), legacyCartID)
+	assert.Nil(t, err)
+	assert.Len(t, got.Items, 1)
+
+	stored := json.RawMessage{}
+	assert.Nil(t, c.Get(context.TODO(), cart.Key(legacyCartID), &stored))
+	assert.JSONEq(t, changed, string(stored))
+}
+
+func TestMigratorKeepsChangesSavedMeanwhile(t *testing.T) {
+	changed := `{"schema_version":2,"cart":{"ID":"` + legacyCartID + `","Items":[]}}`
+	c := &savingMeanwhile{Cache: cache.NewMemoryCache(), key: cart.Key(legacyCartID), changed: changed}
+	storeRaw(t, c, cart.Key(legacyCartID), `{"ID":"`+legacyCartID+`","Items":[{"ID":"1","Quantity":2}]}`)
+	//moved to its key while being migrated
+	storeRaw(t, c, otherCartID, `{"ID":"`+otherCartID+`","Items":[{"ID":"1","Quantity":2}]}`)
+	storeRaw(t, c, cart.Key(otherCartID), `{"schema_version":2,"cart":{"ID":"`+otherCartID+`","Items":[]}}`)
+	log := logger.NewLogger("cart migrator unit testing", false)
+
+	progress, err := cart.NewMigrator(log, c, cart.MigratorOptions{}).Run(context.TODO(), func(cart.MigrationProgress) {})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, progress.Failed)
+
+	stored := json.RawMessage{}
+	assert.Nil(t, c.Get(context.TODO(), cart.Key(legacyCartID), &stored))
+	assert.JSONEq(t, changed, string(stored))
+	assert.Nil(t, c.Get(context.TODO(), cart.Key(otherCartID), &stored))
+	assert.JSONEq(t, `{"schema_version":2,"cart":{"ID":"`+otherCartID+`","Items":[]}}`, string(stored))
+	assert.True(t, cache.IsNotFound(c.Get(context.TODO(), otherCartID, &stored)))
+}
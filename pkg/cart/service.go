@@ -2,11 +2,19 @@ package cart
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/events"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/metrics"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/inventory"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/shipping"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/tax"
 	"github.com/google/uuid"
 )
 
@@ -15,28 +23,77 @@ type Service interface {
 	GetCart(ctx context.Context, cartID string) (Cart, error)
 	GetAvailableItems(ctx context.Context) ([]item.Item, error)
 	GetItem(ctx context.Context, id string) (item.Item, error)
+	//AddItemToCart adds the item without options nor note
 	AddItemToCart(ctx context.Context, cartID, itemID string, quantity int) (Cart, error)
-	ModifyItemInCart(ctx context.Context, cartID, itemID string, newQuantity int) (Cart, error)
-	DeleteItemInCart(ctx context.Context, cartID, itemID string) (Cart, error)
+	//AddLineToCart adds the item with its options and note, as a line of its own
+	AddLineToCart(ctx context.Context, cartID string, line Line, quantity int) (Cart, error)
+	//ModifyItemInCart and DeleteItemInCart change the line with the ID. An item ID stands for the line
+	//of the item without options nor note, or for the only line of the item
+	ModifyItemInCart(ctx context.Context, cartID, lineID string, newQuantity int) (Cart, error)
+	DeleteItemInCart(ctx context.Context, cartID, lineID string) (Cart, error)
 	DeleteAllItemsInCart(ctx context.Context, cartID string) (Cart, error)
+	//ApplyItemOperations applies every operation in order, or none of them when any fails
+	ApplyItemOperations(ctx context.Context, cartID string, ops []ItemOperation) (Cart, error)
 	DeleteCart(ctx context.Context, cartID string) error
+	//CloneCart copies the cart and its items under a new ID
+	CloneCart(ctx context.Context, cartID string) (Cart, error)
+	//ShareCart gives an expiring token granting read-only access to the cart
+	ShareCart(ctx context.Context, cartID string) (ShareToken, error)
+	//GetSharedCart gives the cart the share token was issued for
+	GetSharedCart(ctx context.Context, token string) (Cart, error)
+	//ImportSharedCart adds the items of the shared cart to the cart, all of them or none
+	ImportSharedCart(ctx context.Context, token, cartID string) (Cart, error)
+	//LockPrices keeps the current prices of the items in the cart, honoured at checkout until the lock expires
+	LockPrices(ctx context.Context, cartID string) (Cart, error)
+	//SetShippingAddress sets where the cart is shipped to, deciding its taxes and shipping options
+	SetShippingAddress(ctx context.Context, cartID string, address Address) (Cart, error)
+	//GetShippingOptions gives the options to ship the cart to its address, cheapest first
+	GetShippingOptions(ctx context.Context, cartID string) ([]shipping.Option, error)
+	//SelectShippingOption chooses how the cart is shipped among the options offered for it
+	SelectShippingOption(ctx context.Context, cartID, optionID string) (Cart, error)
+	//Checkout prices the cart for the order, failing when prices changed since the items were added
+	//unless acceptPriceChanges is set
+	Checkout(ctx context.Context, cartID string, acceptPriceChanges bool) (Checkout, error)
 }
 
+//MaxItemOperations bounds the operations of a single bulk change
+const MaxItemOperations = 100
+
 type service struct {
 	//dependencies of the service
 	logger          logger.Logger
 	version         string
 	cache           cache.Cache
 	externalService item.Service
+	//shareSecret signs the share tokens, valid for shareTTL
+	shareSecret []byte
+	shareTTL    time.Duration
+	//priceLockTTL is how long prices stay locked
+	priceLockTTL time.Duration
+	//inventory holds the stock of the items in the carts, when set
+	inventory inventory.Service
+	//taxes calculates the taxes of the carts, when set
+	taxes tax.Calculator
+	//shipping quotes the options to ship the carts, when set
+	shipping shipping.Provider
 }
 
-func NewCartService(version string, logger logger.Logger, cache cache.Cache, externalService item.Service) Service {
-	return &service{
+func NewCartService(version string, logger logger.Logger, cache cache.Cache, externalService item.Service, opts ...Option) Service {
+	s := &service{
 		logger:          logger,
 		version:         version,
 		cache:           cache,
 		externalService: externalService,
+		shareTTL:        DefaultShareTTL,
+		priceLockTTL:    DefaultPriceLockTTL,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if len(s.shareSecret) == 0 {
+		s.shareSecret = randomSecret()
 	}
+	return s
 }
 
 func (s *service) CreateCart(ctx context.Context) (Cart, error) {
@@ -48,12 +105,13 @@ func (s *service) CreateCart(ctx context.Context) (Cart, error) {
 		ID: cartID,
 	}
 	log.Info(ctx, "Creating new cart")
-	if err := s.cache.Set(ctx, cartID, cart); err != nil {
+	if err := s.save(ctx, cart, CartCreated{CartID: cartID}); err != nil {
 		log.WithError(err).Error(ctx, "Unable to save new cart in DB")
 		return Cart{}, errors.ServiceError{
 			Code: errors.CacheErrorCode,
-		}
+		}.WithCause(err)
 	}
+	metrics.CartCreated()
 
 	return cart, nil
 }
@@ -63,16 +121,18 @@ func (s *service) GetCart(ctx context.Context, cartID string) (Cart, error) {
 
 	cart := Cart{}
 	log.Info(ctx, "Getting cart from DB")
-	err := s.cache.Get(ctx, cartID, &cart)
+	err := s.get(ctx, cartID, &cart)
 	if err != nil {
 		log.WithError(err).Error(ctx, "Unable to save new cart in DB")
-		return Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}
+		return Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}.
+			WithDetail("cart_id", cartID).
+			WithCause(err)
 	}
 	log.WithField("cart_id", cartID).Info(ctx, "Populating items info from provider")
-	err = s.fetchItemsForCart(ctx, &cart)
+	_, err = s.fetchItemsForCart(ctx, &cart)
 	if err != nil {
 		log.WithError(err).Error(ctx, "Error fetching items for cart")
-		return Cart{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}
+		return Cart{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}.WithCause(err)
 	}
 
 	return cart, nil
@@ -103,124 +163,213 @@ func (s *service) GetItem(ctx context.Context, id string) (item.Item, error) {
 }
 
 func (s *service) AddItemToCart(ctx context.Context, cartID, itemID string, quantity int) (Cart, error) {
+	return s.AddLineToCart(ctx, cartID, Line{ItemID: itemID}, quantity)
+}
+func (s *service) AddLineToCart(ctx context.Context, cartID string, line Line, quantity int) (Cart, error) {
+	itemID := line.ItemID
+	lineID := line.ID()
 	log := s.logger.
 		WithField("cart_id", cartID).
 		WithField("item_id", itemID).
+		WithField("line_id", lineID).
 		WithField("quantity", quantity)
 
 	log.Info(ctx, "Adding Item to Cart")
+	limits := limitsOf(ctx)
+	if err := validateLineRequest(line, quantity, limits); err != nil {
+		log.WithError(err).Error(ctx, "Invalid item request")
+		return Cart{}, err
+	}
 	cart := Cart{}
 	log.Info(ctx, "Getting Cart from DB")
-	err := s.cache.Get(ctx, cartID, &cart)
+	err := s.get(ctx, cartID, &cart)
 	if err != nil {
 		log.WithError(err).Error(ctx, "Unable to get Cart from DB")
-		return Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}
+		return Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}.
+			WithDetail("cart_id", cartID).
+			WithCause(err)
 	}
 
 	log.Info(ctx, "Adding item to Cart")
 	for _, item := range cart.Items {
-		if item.ID == itemID {
+		if item.LineID == lineID {
 			log.WithError(err).Error(ctx, "Item Already in Cart")
-			return Cart{}, errors.ServiceError{Code: errors.ItemAlreadyInCartCode}
+			return Cart{}, errors.ServiceError{Code: errors.ItemAlreadyInCartCode}.
+				WithDetail("item_id", itemID).
+				WithDetail("line_id", lineID)
 		}
 	}
+	if err := validateLineCount(len(cart.Items)+1, limits); err != nil {
+		log.WithError(err).Error(ctx, "Cart has too many lines")
+		return Cart{}, err
+	}
+
+	log.Info(ctx, "Getting current price and stock of the Item from provider")
+	extItems, err := s.fetchItems(ctx, []string{itemID})
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to get data from the provider")
+		return Cart{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}.WithCause(err)
+	}
+	//stock is held for the item, whatever the line its units are in
+	held := cart.quantityOf(itemID)
+	if err := s.reserveStock(ctx, cartID, extItems[0], held+quantity); err != nil {
+		log.WithError(err).Error(ctx, "Unable to hold stock for the Item")
+		return Cart{}, err
+	}
 
 	cart.Items = append(cart.Items, item.Item{
-		ID:       itemID,
-		Quantity: quantity,
+		ID:         itemID,
+		LineID:     lineID,
+		Options:    line.Options,
+		Note:       line.Note,
+		Quantity:   quantity,
+		AddedPrice: extItems[0].Price,
 	})
 
 	log.Info(ctx, "Saving Cart to DB")
-	if err := s.cache.Set(ctx, cartID, cart); err != nil {
+	event := ItemAdded{
+		CartID:   cartID,
+		ItemID:   itemID,
+		LineID:   lineID,
+		Options:  line.Options,
+		Note:     line.Note,
+		Quantity: quantity,
+	}
+	if err := s.save(ctx, cart, event); err != nil {
 		log.WithError(err).Error(ctx, "Unable to save Cart in DB")
-		return Cart{}, err
+		s.restoreStock(ctx, cartID, extItems, map[string]int{itemID: held})
+		return Cart{}, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
 	}
+	metrics.ItemAdded()
 
 	log.Info(ctx, "Getting Cart Item details from provider")
-	err = s.fetchItemsForCart(ctx, &cart)
+	_, err = s.fetchItemsForCart(ctx, &cart)
 	if err != nil {
 		log.WithError(err).Error(ctx, "Unable to get data from the provider")
-		return Cart{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}
+		return Cart{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}.WithCause(err)
 	}
 
 	return cart, nil
 }
-func (s *service) ModifyItemInCart(ctx context.Context, cartID, itemID string, newQuantity int) (Cart, error) {
+func (s *service) ModifyItemInCart(ctx context.Context, cartID, lineID string, newQuantity int) (Cart, error) {
 	log := s.logger.
 		WithField("cart_id", cartID).
-		WithField("item_id", itemID).
+		WithField("line_id", lineID).
 		WithField("new_quantity", newQuantity)
 
 	log.Info(ctx, "Modifying item quantity in Cart")
+	if err := validateQuantity(newQuantity, limitsOf(ctx)); err != nil {
+		log.WithError(err).Error(ctx, "Invalid item request")
+		return Cart{}, err
+	}
 
 	cart := Cart{}
 
 	log.Info(ctx, "Getting Cart from DB")
-	err := s.cache.Get(ctx, cartID, &cart)
+	err := s.get(ctx, cartID, &cart)
 	if err != nil {
 		log.WithError(err).Error(ctx, "Unable to get Cart from DB")
-		return Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}
+		return Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}.
+			WithDetail("cart_id", cartID).
+			WithCause(err)
 	}
 
 	log.Info(ctx, "Looking for Item in Cart")
-	for idx, item := range cart.Items {
-		if item.ID == itemID {
-			log.Info(ctx, "Updating Item in Cart")
-			cart.Items[idx].Quantity = newQuantity
-			log.Info(ctx, "Saving Cart in DB")
-			if err := s.cache.Set(ctx, cartID, cart); err != nil {
-				log.WithError(err).Error(ctx, "Unable Saving Cart to DB")
-				return Cart{}, err
-			}
-			log.Info(ctx, "Getting Cart Item details from provider")
-			err = s.fetchItemsForCart(ctx, &cart)
-			if err != nil {
-				log.WithError(err).Error(ctx, "Unable to fetch item data from provider")
-				return Cart{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}
-			}
-			return cart, nil
-		}
+	idx := cart.lineIndex(lineID)
+	if idx < 0 {
+		log.Error(ctx, "Unable to find Item inside Cart")
+		return Cart{}, errors.ServiceError{Code: errors.ItemNotFoundCode}.
+			WithDetail("cart_id", cartID).
+			WithDetail("line_id", lineID)
+	}
+	line := cart.Items[idx]
+
+	log.Info(ctx, "Updating Item in Cart")
+	event := ItemQuantityChanged{
+		CartID:      cartID,
+		ItemID:      line.ID,
+		LineID:      line.LineID,
+		OldQuantity: line.Quantity,
+		NewQuantity: newQuantity,
+	}
+	log.Info(ctx, "Getting current stock of the Item from provider")
+	extItems, err := s.fetchItems(ctx, []string{line.ID})
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to fetch item data from provider")
+		return Cart{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}.WithCause(err)
 	}
-	log.Error(ctx, "Unable to find Item inside Cart")
-	return Cart{}, errors.ServiceError{Code: errors.ItemNotFoundCode}
+	held := cart.quantityOf(line.ID)
+	if err := s.reserveStock(ctx, cartID, extItems[0], held-line.Quantity+newQuantity); err != nil {
+		log.WithError(err).Error(ctx, "Unable to hold stock for the Item")
+		return Cart{}, err
+	}
+	cart.Items[idx].Quantity = newQuantity
+	log.Info(ctx, "Saving Cart in DB")
+	if err := s.save(ctx, cart, event); err != nil {
+		log.WithError(err).Error(ctx, "Unable Saving Cart to DB")
+		s.restoreStock(ctx, cartID, extItems, map[string]int{line.ID: held})
+		return Cart{}, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	log.Info(ctx, "Getting Cart Item details from provider")
+	_, err = s.fetchItemsForCart(ctx, &cart)
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to fetch item data from provider")
+		return Cart{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}.WithCause(err)
+	}
+	return cart, nil
 }
-func (s *service) DeleteItemInCart(ctx context.Context, cartID, itemID string) (Cart, error) {
+func (s *service) DeleteItemInCart(ctx context.Context, cartID, lineID string) (Cart, error) {
 	log := s.logger.
 		WithField("cart_id", cartID).
-		WithField("item_id", itemID)
+		WithField("line_id", lineID)
 
 	log.Info(ctx, "Deleting item from Cart")
 	cart := Cart{}
 	log.Info(ctx, "Getting Cart from DB")
-	err := s.cache.Get(ctx, cartID, &cart)
+	err := s.get(ctx, cartID, &cart)
 	if err != nil {
 		log.WithError(err).Error(ctx, "Unable to get Cart from DB")
-		return Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}
+		return Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}.
+			WithDetail("cart_id", cartID).
+			WithCause(err)
 	}
 
 	log.Info(ctx, "Removing item from Cart data")
-	for idx, item := range cart.Items {
-		if item.ID == itemID {
-			//we care about the order, so we perform to sub-slices
-
-			cart.Items = append(cart.Items[:idx], cart.Items[idx+1:]...)
-
-			log.Info(ctx, "Saving Cart in DB")
-			if err := s.cache.Set(ctx, cartID, cart); err != nil {
-				return Cart{}, err
-			}
-			log.Info(ctx, "Getting Cart Item details from provider")
-			err = s.fetchItemsForCart(ctx, &cart)
-			if err != nil {
-				log.WithError(err).Error(ctx, "Unable to fetch item data from provider")
-				return Cart{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}
-			}
+	idx := cart.lineIndex(lineID)
+	if idx < 0 {
+		log.Error(ctx, "Unable to find Item inside Cart")
+		return Cart{}, errors.ServiceError{Code: errors.ItemNotFoundCode}.
+			WithDetail("cart_id", cartID).
+			WithDetail("line_id", lineID)
+	}
+	line := cart.Items[idx]
+	//we care about the order, so we perform to sub-slices
+	cart.Items = append(cart.Items[:idx], cart.Items[idx+1:]...)
+	remaining := cart.quantityOf(line.ID)
+	if remaining == 0 {
+		cart.unlockPrice(line.ID)
+	}
 
-			return cart, nil
-		}
+	log.Info(ctx, "Saving Cart in DB")
+	event := ItemRemoved{CartID: cartID, ItemID: line.ID, LineID: line.LineID, Quantity: line.Quantity}
+	if err := s.save(ctx, cart, event); err != nil {
+		log.WithError(err).Error(ctx, "Unable Saving Cart to DB")
+		return Cart{}, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
 	}
-	log.WithError(err).Error(ctx, "Unable to find Item inside Cart")
-	return Cart{}, errors.ServiceError{Code: errors.ItemNotFoundCode}
+	if remaining == 0 {
+		s.releaseStock(ctx, cartID, line.ID)
+	} else if extItems, err := s.fetchItems(ctx, []string{line.ID}); err == nil {
+		//the other lines of the item keep their units held
+		s.restoreStock(ctx, cartID, extItems, map[string]int{line.ID: remaining})
+	}
+	log.Info(ctx, "Getting Cart Item details from provider")
+	_, err = s.fetchItemsForCart(ctx, &cart)
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to fetch item data from provider")
+		return Cart{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}.WithCause(err)
+	}
+
+	return cart, nil
 }
 func (s *service) DeleteAllItemsInCart(ctx context.Context, cartID string) (Cart, error) {
 	log := s.logger.WithField("cart_id", cartID)
@@ -228,46 +377,364 @@ func (s *service) DeleteAllItemsInCart(ctx context.Context, cartID string) (Cart
 
 	cart := Cart{}
 	log.Info(ctx, "Getting Cart from DB")
-	err := s.cache.Get(ctx, cartID, &cart)
+	err := s.get(ctx, cartID, &cart)
 	if err != nil {
 		log.WithError(err).Error(ctx, "Unable to get Cart from DB")
-		return Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}
+		return Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}.
+			WithDetail("cart_id", cartID).
+			WithCause(err)
 	}
 
 	log.Info(ctx, "Removing all items from Cart data")
+	event := CartCleared{CartID: cartID, RemovedItems: len(cart.Items)}
 	cart.Items = []item.Item{}
+	cart.PriceLock = nil
 	log.Info(ctx, "Saving Cart in DB")
-	if err := s.cache.Set(ctx, cartID, cart); err != nil {
+	if err := s.save(ctx, cart, event); err != nil {
 		log.WithError(err).Error(ctx, "Unable to save Cart to DB")
-		return Cart{}, err
+		return Cart{}, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	s.releaseAllStock(ctx, cartID)
+
+	return cart, nil
+}
+func (s *service) ApplyItemOperations(ctx context.Context, cartID string, ops []ItemOperation) (Cart, error) {
+	log := s.logger.
+		WithField("cart_id", cartID).
+		WithField("operations", len(ops))
+
+	log.Info(ctx, "Applying item operations to Cart")
+	if len(ops) == 0 || len(ops) > MaxItemOperations {
+		log.Error(ctx, "Invalid amount of item operations")
+		return Cart{}, errors.ServiceError{Code: errors.ValidationErrorCode}.
+			WithFieldViolation("operations", fmt.Sprintf("must have between 1 and %d operations", MaxItemOperations))
+	}
+
+	cart := Cart{}
+	log.Info(ctx, "Getting Cart from DB")
+	err := s.get(ctx, cartID, &cart)
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to get Cart from DB")
+		return Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}.
+			WithDetail("cart_id", cartID).
+			WithCause(err)
+	}
+
+	before := quantitiesOf(cart)
+
+	//every operation is tried, even after one fails, so the client learns about all the failures at once
+	results := make([]ItemOperationResult, 0, len(ops))
+	evs := make([]DomainEvent, 0, len(ops))
+	failed := false
+	limits := limitsOf(ctx)
+	for idx, op := range ops {
+		res := ItemOperationResult{
+			Index:  idx,
+			Op:     op.Op,
+			ItemID: op.ItemID,
+			LineID: op.LineID,
+			Status: OperationStatusOK,
+		}
+		ev, err := applyItemOperation(&cart, op, limits)
+		if err != nil {
+			sErr, _ := err.(errors.ServiceError)
+			failed = true
+			res.Status = OperationStatusFailed
+			res.Code = sErr.Code
+			for _, f := range sErr.Fields {
+				if res.Fields == nil {
+					res.Fields = map[string]string{}
+				}
+				res.Fields[f.Field] = f.Description
+			}
+		} else {
+			evs = append(evs, ev)
+		}
+		results = append(results, res)
+	}
+	if failed {
+		log.Error(ctx, "Some item operations failed, Cart left untouched")
+		return Cart{}, errors.ServiceError{Code: errors.ItemOperationsFailedCode}.
+			WithDetail("cart_id", cartID).
+			WithDetail("operations", results)
+	}
+
+	//the items are asked for once, their current price and stock validating the changes and filling the response
+	log.Info(ctx, "Getting Cart Item details from provider")
+	extItems, err := s.fetchItemsForCart(ctx, &cart)
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to fetch item data from provider")
+		return Cart{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}.WithCause(err)
+	}
+	observeAddedPrices(&cart, evs)
+	changed := itemsWithIDs(extItems, changedItems(cart, before))
+	quantities := quantitiesOf(cart)
+	for idx, extItem := range changed {
+		if err := s.reserveStock(ctx, cartID, extItem, quantities[extItem.ID]); err != nil {
+			log.WithError(err).Error(ctx, "Unable to hold stock for the Items")
+			s.restoreStock(ctx, cartID, changed[:idx], before)
+			return Cart{}, err
+		}
 	}
 
+	log.Info(ctx, "Saving Cart in DB")
+	if err := s.save(ctx, cart, evs...); err != nil {
+		log.WithError(err).Error(ctx, "Unable to save Cart to DB")
+		s.restoreStock(ctx, cartID, changed, before)
+		return Cart{}, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	for itemID := range before {
+		if _, ok := quantities[itemID]; !ok {
+			s.releaseStock(ctx, cartID, itemID)
+		}
+	}
+	for _, ev := range evs {
+		if _, ok := ev.(ItemAdded); ok {
+			metrics.ItemAdded()
+		}
+	}
 	return cart, nil
 }
+
+//applyItemOperation changes the cart in place, giving the event describing the change
+//Every error it gives is a ServiceError
+func applyItemOperation(cart *Cart, op ItemOperation, limits tenant.Limits) (DomainEvent, error) {
+	switch op.Op {
+	case ItemOperationAdd:
+		line := Line{ItemID: op.ItemID, Options: op.Options, Note: op.Note}
+		if err := validateLineRequest(line, op.Quantity, limits); err != nil {
+			return nil, err
+		}
+		lineID := line.ID()
+		for _, i := range cart.Items {
+			if i.LineID == lineID {
+				return nil, errors.ServiceError{Code: errors.ItemAlreadyInCartCode}
+			}
+		}
+		if err := validateLineCount(len(cart.Items)+1, limits); err != nil {
+			return nil, err
+		}
+		cart.Items = append(cart.Items, item.Item{
+			ID:       op.ItemID,
+			LineID:   lineID,
+			Options:  op.Options,
+			Note:     op.Note,
+			Quantity: op.Quantity,
+		})
+		return ItemAdded{
+			CartID:   cart.ID,
+			ItemID:   op.ItemID,
+			LineID:   lineID,
+			Options:  op.Options,
+			Note:     op.Note,
+			Quantity: op.Quantity,
+		}, nil
+	case ItemOperationSet:
+		if err := validateQuantity(op.Quantity, limits); err != nil {
+			return nil, err
+		}
+		idx := cart.lineIndex(op.lineRef())
+		if idx < 0 {
+			return nil, errors.ServiceError{Code: errors.ItemNotFoundCode}
+		}
+		event := ItemQuantityChanged{
+			CartID:      cart.ID,
+			ItemID:      cart.Items[idx].ID,
+			LineID:      cart.Items[idx].LineID,
+			OldQuantity: cart.Items[idx].Quantity,
+			NewQuantity: op.Quantity,
+		}
+		cart.Items[idx].Quantity = op.Quantity
+		return event, nil
+	case ItemOperationRemove:
+		idx := cart.lineIndex(op.lineRef())
+		if idx < 0 {
+			return nil, errors.ServiceError{Code: errors.ItemNotFoundCode}
+		}
+		line := cart.Items[idx]
+		event := ItemRemoved{CartID: cart.ID, ItemID: line.ID, LineID: line.LineID, Quantity: line.Quantity}
+		cart.Items = append(cart.Items[:idx], cart.Items[idx+1:]...)
+		if cart.quantityOf(line.ID) == 0 {
+			cart.unlockPrice(line.ID)
+		}
+		return event, nil
+	default:
+		return nil, errors.ServiceError{Code: errors.ValidationErrorCode}.
+			WithFieldViolation("op", "must be one of add, set or remove")
+	}
+}
+
 func (s *service) DeleteCart(ctx context.Context, cartID string) error {
 	log := s.logger.WithField("cart_id", cartID)
 
 	log.Info(ctx, "Deleting Cart entirely")
-	err := s.cache.Del(ctx, cartID)
+	cart := Cart{}
+	err := s.get(ctx, cartID, &cart)
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to get Cart from DB")
+		return errors.ServiceError{Code: errors.CartNotFoundCode}.
+			WithDetail("cart_id", cartID).
+			WithCause(err)
+	}
+	ops, err := outboxOps(CartDeleted{CartID: cartID})
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to build Cart events")
+		return errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	err = s.cache.Tx(ctx, append([]cache.Op{cache.DelOp(Key(cartID))}, ops...)...)
 	if err != nil {
 		log.WithError(err).Error(ctx, "Unable to delete Cart from DB")
-		return errors.ServiceError{Code: errors.CartNotFoundCode}
+		return errors.ServiceError{Code: errors.CacheErrorCode}.
+			WithDetail("cart_id", cartID).
+			WithCause(err)
 	}
+	metrics.CartDeleted()
+	s.releaseAllStock(ctx, cartID)
 	return nil
 }
-func (s *service) fetchItemsForCart(ctx context.Context, cart *Cart) error {
-	log := s.logger.WithField("cart_id", cart.ID)
 
-	log.Info(ctx, "Fetching Cart's items from provider")
-	//We fetch information from the external service to fill in Name and Price
-	for idx, item := range cart.Items {
-		extItem, err := s.externalService.GetItem(ctx, item.ID)
+// save stores the cart together with the events describing the change, in a single cache transaction
+func (s *service) save(ctx context.Context, cart Cart, evs ...DomainEvent) error {
+	stored, err := encodeCart(cart)
+	if err != nil {
+		return err
+	}
+	ops, err := outboxOps(evs...)
+	if err != nil {
+		return err
+	}
+	return s.cache.Tx(ctx, append([]cache.Op{cache.SetOp(Key(cart.ID), stored)}, ops...)...)
+}
+
+//outboxOps wraps the domain events in the envelope and gives the operations enqueueing them in the outbox
+func outboxOps(evs ...DomainEvent) ([]cache.Op, error) {
+	envelopes := make([]events.Event, 0, len(evs))
+	for _, de := range evs {
+		e, err := events.New(de.EventType(), de.AggregateID(), de)
 		if err != nil {
-			log.WithField("item_id", item.ID).Error(ctx, "Unable to get item from provider")
-			return err
+			return nil, err
 		}
+		envelopes = append(envelopes, e)
+	}
+	return events.OutboxOps(envelopes...), nil
+}
+
+//fetchItemsForCart fills the items of the cart with their details from the provider, along with its taxes and
+//shipping, giving the items of the provider in the order of the lines
+func (s *service) fetchItemsForCart(ctx context.Context, cart *Cart) ([]item.Item, error) {
+	log := s.logger.WithField("cart_id", cart.ID)
+
+	log.Info(ctx, "Fetching Cart's items from provider")
+	ids := make([]string, 0, len(cart.Items))
+	for _, i := range cart.Items {
+		ids = append(ids, i.ID)
+	}
+	extItems, err := s.fetchItems(ctx, ids)
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to get items from provider")
+		return nil, err
+	}
+	//We fill in Name, Price, Category and Weight, the price observed when the item was added is kept as-is
+	for idx, extItem := range extItems {
 		cart.Items[idx].Price = extItem.Price
 		cart.Items[idx].Name = extItem.Name
+		cart.Items[idx].Category = extItem.Category
+		cart.Items[idx].Weight = extItem.Weight
+	}
+	s.applyTaxes(cart)
+	s.applyShipping(ctx, cart)
+	return extItems, nil
+}
+
+//fetchItems gives the items of the provider with the ids, in the same order and with the ids asked for
+func (s *service) fetchItems(ctx context.Context, ids []string) ([]item.Item, error) {
+	res := make([]item.Item, 0, len(ids))
+	//A loader in the context, set by transports resolving many lookups per request, fetches them in one batch
+	if loader, ok := item.LoaderFromContext(ctx); ok {
+		extItems, err := loader.LoadMany(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, extItems...)
+	} else {
+		for _, id := range ids {
+			extItem, err := s.externalService.GetItem(ctx, id)
+			if err != nil {
+				s.logger.WithField("item_id", id).Error(ctx, "Unable to get item from provider")
+				return nil, err
+			}
+			res = append(res, extItem)
+		}
+	}
+	for idx := range res {
+		res[idx].ID = ids[idx]
+	}
+	return res, nil
+}
+
+//changedItems gives the items of the cart whose quantity is not the one they had before, new ones included
+func changedItems(cart Cart, before map[string]int) []string {
+	ids := []string{}
+	seen := map[string]bool{}
+	quantities := quantitiesOf(cart)
+	for _, i := range cart.Items {
+		//the item is only given once, whatever the lines it has
+		if q, ok := before[i.ID]; (!ok || q != quantities[i.ID]) && !seen[i.ID] {
+			ids = append(ids, i.ID)
+			seen[i.ID] = true
+		}
+	}
+	return ids
+}
+
+//itemsWithIDs gives the items of the provider with the ids, once each
+func itemsWithIDs(extItems []item.Item, ids []string) []item.Item {
+	byID := make(map[string]item.Item, len(extItems))
+	for _, extItem := range extItems {
+		byID[extItem.ID] = extItem
+	}
+	res := make([]item.Item, 0, len(ids))
+	for _, id := range ids {
+		res = append(res, byID[id])
+	}
+	return res
+}
+
+//quantitiesOf gives the units of every item in the cart, adding up all of its lines
+func quantitiesOf(cart Cart) map[string]int {
+	quantities := make(map[string]int, len(cart.Items))
+	for _, i := range cart.Items {
+		quantities[i.ID] += i.Quantity
+	}
+	return quantities
+}
+
+//observeAddedPrices keeps the current price of the lines added by the events as the price they were added at,
+//the items of the cart being filled with their current details. Lines removed and added again take it too, even
+//when their quantity is the one they had
+func observeAddedPrices(cart *Cart, evs []DomainEvent) {
+	added := map[string]bool{}
+	for _, ev := range evs {
+		if e, ok := ev.(ItemAdded); ok {
+			added[e.LineID] = true
+		}
+	}
+	for idx, i := range cart.Items {
+		if added[i.LineID] {
+			cart.Items[idx].AddedPrice = i.Price
+		}
+	}
+}
+
+//validateQuantity checks the quantity of a line coming from the client
+func validateQuantity(quantity int, limits tenant.Limits) error {
+	if quantity <= 0 {
+		return errors.ServiceError{Code: errors.ValidationErrorCode}.
+			WithFieldViolation("quantity", "must be greater than zero")
+	}
+	if limits.MaxQuantity > 0 && quantity > limits.MaxQuantity {
+		return errors.ServiceError{Code: errors.ValidationErrorCode}.
+			WithFieldViolation("quantity", fmt.Sprintf("must be up to %d", limits.MaxQuantity))
 	}
 	return nil
 }
@@ -2,9 +2,13 @@ package cart_test
 
 import (
 	"context"
+	"encoding/json"
+	stdErrors "errors"
 	"fmt"
 	"testing"
 
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
@@ -228,6 +232,25 @@ func TestAddItemToCartExternalFailure(t *testing.T) {
 	}
 }
 
+func TestAddItemToCartValidationFailure(t *testing.T) {
+	svc := cart.NewCartService("unit-testing",
+		logger.NewLogger("cart service unit testing", false),
+		&cacheMock{},
+		&externalMock{
+			shouldFail: false,
+		})
+
+	_, err := svc.AddItemToCart(context.TODO(), "someCart", "", 0)
+
+	sErr := errors.ServiceError{}
+	if !stdErrors.As(err, &sErr) || sErr.Code != errors.ValidationErrorCode {
+		t.Fatalf("Validation error was expected")
+	}
+	if len(sErr.Fields) != 2 {
+		t.Fatalf("Both fields were expected to be reported, got %d", len(sErr.Fields))
+	}
+}
+
 func TestModifyItemInCartOK(t *testing.T) {
 	svc := cart.NewCartService("unit-testing",
 		logger.NewLogger("cart service unit testing", false),
@@ -435,6 +458,160 @@ func TestDeleteAllItemsInCartCacheSetFailure(t *testing.T) {
 	}
 }
 
+func TestApplyItemOperationsOK(t *testing.T) {
+	svc := cart.NewCartService("unit-testing",
+		logger.NewLogger("cart service unit testing", false),
+		&cacheMock{},
+		&externalMock{
+			shouldFail: false,
+		})
+
+	c, err := svc.ApplyItemOperations(context.TODO(), "testCartID", []cart.ItemOperation{
+		{Op: cart.ItemOperationRemove, ItemID: "1-simple-Item"},
+		{Op: cart.ItemOperationSet, ItemID: "2-simple-Item", Quantity: 3},
+		{Op: cart.ItemOperationAdd, ItemID: "3-simple-Item", Quantity: 1},
+	})
+
+	if err != nil {
+		t.Fatalf("Service not Expected to fail")
+	}
+	if len(c.Items) != 2 || c.Items[0].Quantity != 3 || c.Items[1].ID != "3-simple-Item" {
+		t.Fatalf("Unexpected items in Cart: %+v", c.Items)
+	}
+}
+
+func TestApplyItemOperationsAllOrNothing(t *testing.T) {
+	svc := cart.NewCartService("unit-testing",
+		logger.NewLogger("cart service unit testing", false),
+		cache.NewMemoryCache(),
+		&externalMock{
+			shouldFail: false,
+		})
+	created, err := svc.CreateCart(context.TODO())
+	if err != nil {
+		t.Fatalf("Service not Expected to fail")
+	}
+	_, err = svc.AddItemToCart(context.TODO(), created.ID, "1", 1)
+	if err != nil {
+		t.Fatalf("Service not Expected to fail")
+	}
+
+	_, err = svc.ApplyItemOperations(context.TODO(), created.ID, []cart.ItemOperation{
+		{Op: cart.ItemOperationAdd, ItemID: "2", Quantity: 1},
+		{Op: cart.ItemOperationRemove, ItemID: "missing"},
+		{Op: cart.ItemOperationSet, ItemID: "1", Quantity: 0},
+		{Op: "bogus", ItemID: "1"},
+	})
+
+	sErr := errors.ServiceError{}
+	if !stdErrors.As(err, &sErr) || sErr.Code != errors.ItemOperationsFailedCode {
+		t.Fatalf("Expected item operations to fail, got %v", err)
+	}
+	results := sErr.Details["operations"].([]cart.ItemOperationResult)
+	statuses := []string{}
+	codes := []string{}
+	for _, r := range results {
+		statuses = append(statuses, r.Status)
+		codes = append(codes, r.Code)
+	}
+	if fmt.Sprint(statuses) != "[ok failed failed failed]" {
+		t.Fatalf("Unexpected statuses %v", statuses)
+	}
+	if fmt.Sprint(codes) != fmt.Sprint([]string{"", errors.ItemNotFoundCode, errors.ValidationErrorCode, errors.ValidationErrorCode}) {
+		t.Fatalf("Unexpected codes %v", codes)
+	}
+	if results[2].Fields["quantity"] == "" || results[3].Fields["op"] == "" {
+		t.Fatalf("Expected field violations, got %+v", results)
+	}
+
+	c, err := svc.GetCart(context.TODO(), created.ID)
+	if err != nil {
+		t.Fatalf("Service not Expected to fail")
+	}
+	if len(c.Items) != 1 || c.Items[0].Quantity != 1 {
+		t.Fatalf("Cart was not expected to change: %+v", c.Items)
+	}
+}
+
+func TestApplyItemOperationsAsksTheProviderOnce(t *testing.T) {
+	ext := &externalMock{prices: map[string]float32{"1": 10, "2": 20}}
+	svc := cart.NewCartService("unit-testing",
+		logger.NewLogger("cart service unit testing", false),
+		cache.NewMemoryCache(),
+		ext)
+	created, err := svc.CreateCart(context.TODO())
+	if err != nil {
+		t.Fatalf("Service not Expected to fail")
+	}
+	if _, err = svc.AddItemToCart(context.TODO(), created.ID, "1", 1); err != nil {
+		t.Fatalf("Service not Expected to fail")
+	}
+	ext.requested = nil
+
+	c, err := svc.ApplyItemOperations(context.TODO(), created.ID, []cart.ItemOperation{
+		{Op: cart.ItemOperationAdd, ItemID: "2", Quantity: 1},
+		{Op: cart.ItemOperationSet, ItemID: "1", Quantity: 2},
+	})
+
+	if err != nil {
+		t.Fatalf("Service not Expected to fail: %v", err)
+	}
+	if fmt.Sprint(ext.requested) != "[1 2]" {
+		t.Fatalf("Every item was expected to be asked for once, got %v", ext.requested)
+	}
+	if len(c.Items) != 2 || c.Items[0].Price != 10 || c.Items[1].Price != 20 || c.Items[1].AddedPrice != 20 {
+		t.Fatalf("Unexpected items in Cart: %+v", c.Items)
+	}
+}
+
+func TestApplyItemOperationsReAddedLinesTakeTheCurrentPrice(t *testing.T) {
+	ext := &externalMock{prices: map[string]float32{"1": 10}}
+	svc := cart.NewCartService("unit-testing",
+		logger.NewLogger("cart service unit testing", false),
+		cache.NewMemoryCache(),
+		ext)
+	created, err := svc.CreateCart(context.TODO())
+	if err != nil {
+		t.Fatalf("Service not Expected to fail")
+	}
+	if _, err = svc.AddItemToCart(context.TODO(), created.ID, "1", 2); err != nil {
+		t.Fatalf("Service not Expected to fail")
+	}
+	ext.prices["1"] = 12
+
+	//the quantity of the line is the same once done, it was still added again
+	c, err := svc.ApplyItemOperations(context.TODO(), created.ID, []cart.ItemOperation{
+		{Op: cart.ItemOperationRemove, ItemID: "1"},
+		{Op: cart.ItemOperationAdd, ItemID: "1", Quantity: 2},
+	})
+
+	if err != nil {
+		t.Fatalf("Service not Expected to fail: %v", err)
+	}
+	if len(c.Items) != 1 || c.Items[0].AddedPrice != 12 {
+		t.Fatalf("The price was expected to be observed again, got %+v", c.Items)
+	}
+	stored, err := svc.GetCart(context.TODO(), created.ID)
+	if err != nil || len(stored.Items) != 1 || stored.Items[0].AddedPrice != 12 {
+		t.Fatalf("The price was expected to be stored, got %+v, %v", stored.Items, err)
+	}
+}
+
+func TestApplyItemOperationsEmpty(t *testing.T) {
+	svc := cart.NewCartService("unit-testing",
+		logger.NewLogger("cart service unit testing", false),
+		&cacheMock{},
+		&externalMock{
+			shouldFail: false,
+		})
+
+	_, err := svc.ApplyItemOperations(context.TODO(), "testCartID", nil)
+
+	if !stdErrors.Is(err, errors.ServiceError{Code: errors.ValidationErrorCode}) {
+		t.Fatalf("Expected validation error, got %v", err)
+	}
+}
+
 func TestDeleteCartOK(t *testing.T) {
 	svc := cart.NewCartService("unit-testing",
 		logger.NewLogger("cart service unit testing", false),
@@ -488,17 +665,21 @@ func (c *cacheMock) Get(ctx context.Context, key string, here interface{}) error
 	if c.shouldGetFail {
 		return fmt.Errorf("Mock was asked to fail")
 	}
-	m := here.(*cart.Cart)
-
-	m.Items = []item.Item{
-		{
-			ID: "1-simple-Item",
-		},
-		{
-			ID: "2-simple-Item",
+	//carts are given as stored before the schema was versioned
+	b, err := json.Marshal(cart.Cart{
+		Items: []item.Item{
+			{
+				ID: "1-simple-Item",
+			},
+			{
+				ID: "2-simple-Item",
+			},
 		},
+	})
+	if err != nil {
+		return err
 	}
-	return nil
+	return json.Unmarshal(b, here)
 }
 func (c *cacheMock) Del(ctx context.Context, key string) error {
 	if c.shouldDelFail {
@@ -510,10 +691,44 @@ func (c *cacheMock) Del(ctx context.Context, key string) error {
 func (c *cacheMock) Alive(ctx context.Context) bool {
 	return !c.shouldAliveFail
 }
+func (c *cacheMock) CompareAndSet(ctx context.Context, key string, old json.RawMessage, value interface{}) (bool, error) {
+	if c.shouldSetFail {
+		return false, fmt.Errorf("Mock was asked to fail")
+	}
+	return true, nil
+}
+func (c *cacheMock) Tx(ctx context.Context, ops ...cache.Op) error {
+	if c.shouldSetFail || c.shouldDelFail {
+		return fmt.Errorf("Mock was asked to fail")
+	}
+	return nil
+}
+func (c *cacheMock) ListMove(ctx context.Context, src, dst string) (string, error) {
+	return "", cache.ErrListEmpty
+}
+func (c *cacheMock) ListRemove(ctx context.Context, list, value string) error {
+	return nil
+}
+func (c *cacheMock) ListRange(ctx context.Context, list string, start, stop int64) ([]string, error) {
+	return []string{}, nil
+}
+func (c *cacheMock) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	return delta, nil
+}
+func (c *cacheMock) Scan(ctx context.Context, match string, fn func(key string) error) error {
+	return nil
+}
 
 //External Service Mock
 type externalMock struct {
 	shouldFail bool
+	//prices, stocks, categories and weights give the current price, stock, category and weight of the items, by ID
+	prices     map[string]float32
+	stocks     map[string]*int
+	categories map[string]string
+	weights    map[string]int
+	//requested are the IDs of the items asked for, in order
+	requested []string
 }
 
 func (e *externalMock) Health(ctx context.Context) error {
@@ -527,7 +742,8 @@ func (e *externalMock) GetItem(ctx context.Context, id string) (item.Item, error
 	if e.shouldFail {
 		return item.Item{}, fmt.Errorf("External Mock was asked to Fail")
 	}
-	return item.Item{}, nil
+	e.requested = append(e.requested, id)
+	return item.Item{ID: id, Price: e.prices[id], Stock: e.stocks[id], Category: e.categories[id], Weight: e.weights[id]}, nil
 }
 func (e *externalMock) GetAllItems(ctx context.Context) ([]item.Item, error) {
 	if e.shouldFail {
@@ -0,0 +1,242 @@
+package cart
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/metrics"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	"github.com/google/uuid"
+)
+
+//DefaultShareTTL is how long share tokens are valid unless WithShareTTL is used
+const DefaultShareTTL = 7 * 24 * time.Hour
+
+//shareScope is the only access share tokens grant
+const shareScope = "read"
+
+//Option customizes the cart service
+type Option func(*service)
+
+//WithShareSecret sets the key signing share tokens, it must be the same on every instance.
+//Without it a random key is used, and tokens are only valid on the instance issuing them
+func WithShareSecret(secret []byte) Option {
+	return func(s *service) {
+		s.shareSecret = secret
+	}
+}
+
+//WithShareTTL sets how long share tokens are valid
+func WithShareTTL(ttl time.Duration) Option {
+	return func(s *service) {
+		s.shareTTL = ttl
+	}
+}
+
+//shareClaims is what a share token grants
+type shareClaims struct {
+	CartID    string `json:"cart_id"`
+	Scope     string `json:"scope"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+func randomSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic("unable to generate share secret: " + err.Error())
+	}
+	return secret
+}
+
+func (s *service) CloneCart(ctx context.Context, cartID string) (Cart, error) {
+	log := s.logger.WithField("cart_id", cartID)
+
+	log.Info(ctx, "Cloning Cart")
+	source := Cart{}
+	err := s.get(ctx, cartID, &source)
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to get Cart from DB")
+		return Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}.
+			WithDetail("cart_id", cartID).
+			WithCause(err)
+	}
+
+	clone := Cart{
+		ID: uuid.New().String(),
+	}
+	clone.Items = append(clone.Items, source.Items...)
+	evs := []DomainEvent{CartCreated{CartID: clone.ID, ClonedFrom: cartID}}
+	for _, i := range clone.Items {
+		evs = append(evs, ItemAdded{
+			CartID:   clone.ID,
+			ItemID:   i.ID,
+			LineID:   i.LineID,
+			Options:  i.Options,
+			Note:     i.Note,
+			Quantity: i.Quantity,
+		})
+	}
+
+	log = log.WithField("clone_id", clone.ID)
+	log.Info(ctx, "Holding stock for the cloned Cart")
+	extItems, err := s.fetchItems(ctx, changedItems(clone, map[string]int{}))
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to fetch item data from provider")
+		return Cart{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}.WithCause(err)
+	}
+	quantities := quantitiesOf(clone)
+	for _, extItem := range extItems {
+		if err := s.reserveStock(ctx, clone.ID, extItem, quantities[extItem.ID]); err != nil {
+			log.WithError(err).Error(ctx, "Unable to hold stock for the cloned Cart")
+			s.releaseAllStock(ctx, clone.ID)
+			return Cart{}, err
+		}
+	}
+
+	log.Info(ctx, "Saving cloned Cart in DB")
+	if err := s.save(ctx, clone, evs...); err != nil {
+		log.WithError(err).Error(ctx, "Unable to save cloned Cart in DB")
+		s.releaseAllStock(ctx, clone.ID)
+		return Cart{}, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	metrics.CartCreated()
+
+	byID := make(map[string]item.Item, len(extItems))
+	for _, extItem := range extItems {
+		byID[extItem.ID] = extItem
+	}
+	for idx, i := range clone.Items {
+		clone.Items[idx].Name = byID[i.ID].Name
+		clone.Items[idx].Price = byID[i.ID].Price
+	}
+	return clone, nil
+}
+
+func (s *service) ShareCart(ctx context.Context, cartID string) (ShareToken, error) {
+	log := s.logger.WithField("cart_id", cartID)
+
+	log.Info(ctx, "Sharing Cart")
+	cart := Cart{}
+	err := s.get(ctx, cartID, &cart)
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to get Cart from DB")
+		return ShareToken{}, errors.ServiceError{Code: errors.CartNotFoundCode}.
+			WithDetail("cart_id", cartID).
+			WithCause(err)
+	}
+
+	claims := shareClaims{
+		CartID:    cartID,
+		Scope:     shareScope,
+		ExpiresAt: time.Now().Add(s.shareTTL).Unix(),
+	}
+	b, err := json.Marshal(claims)
+	if err != nil {
+		return ShareToken{}, err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return ShareToken{
+		Value:     payload + "." + s.signShare(payload),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC(),
+	}, nil
+}
+
+func (s *service) GetSharedCart(ctx context.Context, token string) (Cart, error) {
+	claims, err := s.verifyShare(token)
+	if err != nil {
+		s.logger.WithError(err).Warn(ctx, "Invalid share token")
+		return Cart{}, err
+	}
+	return s.GetCart(ctx, claims.CartID)
+}
+
+func (s *service) ImportSharedCart(ctx context.Context, token, cartID string) (Cart, error) {
+	log := s.logger.WithField("cart_id", cartID)
+
+	claims, err := s.verifyShare(token)
+	if err != nil {
+		log.WithError(err).Warn(ctx, "Invalid share token")
+		return Cart{}, err
+	}
+	if claims.CartID == cartID {
+		return Cart{}, errors.ServiceError{Code: errors.ValidationErrorCode}.
+			WithFieldViolation("cart_id", "must not be the shared cart")
+	}
+	log = log.WithField("shared_cart_id", claims.CartID)
+
+	log.Info(ctx, "Importing shared Cart")
+	shared := Cart{}
+	if err := s.get(ctx, claims.CartID, &shared); err != nil {
+		log.WithError(err).Error(ctx, "Unable to get shared Cart from DB")
+		return Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}.
+			WithDetail("cart_id", claims.CartID).
+			WithCause(err)
+	}
+	target := Cart{}
+	if err := s.get(ctx, cartID, &target); err != nil {
+		log.WithError(err).Error(ctx, "Unable to get Cart from DB")
+		return Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}.
+			WithDetail("cart_id", cartID).
+			WithCause(err)
+	}
+	if len(shared.Items) == 0 {
+		log.Info(ctx, "Shared Cart is empty, nothing to import")
+		return s.GetCart(ctx, cartID)
+	}
+
+	//lines already in the cart get the shared quantity on top of theirs
+	quantities := map[string]int{}
+	for _, i := range target.Items {
+		quantities[i.LineID] = i.Quantity
+	}
+	ops := make([]ItemOperation, 0, len(shared.Items))
+	for _, i := range shared.Items {
+		if q, ok := quantities[i.LineID]; ok {
+			ops = append(ops, ItemOperation{Op: ItemOperationSet, ItemID: i.ID, LineID: i.LineID, Quantity: q + i.Quantity})
+			continue
+		}
+		ops = append(ops, ItemOperation{
+			Op:       ItemOperationAdd,
+			ItemID:   i.ID,
+			Options:  i.Options,
+			Note:     i.Note,
+			Quantity: i.Quantity,
+		})
+	}
+	return s.ApplyItemOperations(ctx, cartID, ops)
+}
+
+//verifyShare checks the token was signed by this service and has not expired
+func (s *service) verifyShare(token string) (shareClaims, error) {
+	unauthorized := errors.ServiceError{Code: errors.UnauthorizedCode}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(s.signShare(parts[0])), []byte(parts[1])) {
+		return shareClaims{}, unauthorized
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return shareClaims{}, unauthorized.WithCause(err)
+	}
+	claims := shareClaims{}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return shareClaims{}, unauthorized.WithCause(err)
+	}
+	if claims.Scope != shareScope || time.Now().Unix() >= claims.ExpiresAt {
+		return shareClaims{}, unauthorized
+	}
+	return claims, nil
+}
+
+func (s *service) signShare(payload string) string {
+	mac := hmac.New(sha256.New, s.shareSecret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,133 @@
+package cart_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/stretchr/testify/assert"
+)
+
+func newShareTestService(c cache.Cache, opts ...cart.Option) cart.Service {
+	return cart.NewCartService("unit-testing",
+		logger.NewLogger("cart service unit testing", false),
+		c,
+		&externalMock{},
+		opts...)
+}
+
+//cartWithItems creates a cart holding the given quantity of each item
+func cartWithItems(t *testing.T, svc cart.Service, quantities map[string]int) cart.Cart {
+	c, err := svc.CreateCart(context.TODO())
+	assert.Nil(t, err)
+	for id, q := range quantities {
+		c, err = svc.AddItemToCart(context.TODO(), c.ID, id, q)
+		assert.Nil(t, err)
+	}
+	return c
+}
+
+func quantities(c cart.Cart) map[string]int {
+	res := map[string]int{}
+	for _, i := range c.Items {
+		res[i.ID] = i.Quantity
+	}
+	return res
+}
+
+func TestCloneCart_DeepCopy(t *testing.T) {
+	svc := newShareTestService(cache.NewMemoryCache())
+	source := cartWithItems(t, svc, map[string]int{"1": 1, "2": 2})
+
+	clone, err := svc.CloneCart(context.TODO(), source.ID)
+	assert.Nil(t, err)
+	assert.NotEqual(t, source.ID, clone.ID)
+	assert.Equal(t, quantities(source), quantities(clone))
+
+	//changing the source leaves the clone untouched
+	_, err = svc.ModifyItemInCart(context.TODO(), source.ID, "1", 5)
+	assert.Nil(t, err)
+	clone, err = svc.GetCart(context.TODO(), clone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, quantities(clone)["1"])
+}
+
+func TestCloneCart_NotFound(t *testing.T) {
+	svc := newShareTestService(cache.NewMemoryCache())
+
+	_, err := svc.CloneCart(context.TODO(), "missing")
+
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.CartNotFoundCode})
+}
+
+func TestShareCart_GetSharedCart(t *testing.T) {
+	svc := newShareTestService(cache.NewMemoryCache(), cart.WithShareSecret([]byte("secret")))
+	source := cartWithItems(t, svc, map[string]int{"1": 3})
+
+	token, err := svc.ShareCart(context.TODO(), source.ID)
+	assert.Nil(t, err)
+	assert.True(t, token.ExpiresAt.After(time.Now()))
+
+	shared, err := svc.GetSharedCart(context.TODO(), token.Value)
+	assert.Nil(t, err)
+	assert.Equal(t, source.ID, shared.ID)
+	assert.Equal(t, map[string]int{"1": 3}, quantities(shared))
+}
+
+func TestGetSharedCart_InvalidTokens(t *testing.T) {
+	c := cache.NewMemoryCache()
+	svc := newShareTestService(c, cart.WithShareSecret([]byte("secret")))
+	source := cartWithItems(t, svc, nil)
+
+	expired, err := newShareTestService(c, cart.WithShareSecret([]byte("secret")), cart.WithShareTTL(-time.Minute)).
+		ShareCart(context.TODO(), source.ID)
+	assert.Nil(t, err)
+	foreign, err := newShareTestService(c, cart.WithShareSecret([]byte("another secret"))).
+		ShareCart(context.TODO(), source.ID)
+	assert.Nil(t, err)
+	valid, err := svc.ShareCart(context.TODO(), source.ID)
+	assert.Nil(t, err)
+
+	for name, token := range map[string]string{
+		"expired":  expired.Value,
+		"foreign":  foreign.Value,
+		"tampered": valid.Value + "x",
+		"garbage":  "not-a-token",
+	} {
+		_, err := svc.GetSharedCart(context.TODO(), token)
+		assert.ErrorIs(t, err, errors.ServiceError{Code: errors.UnauthorizedCode}, name)
+	}
+}
+
+func TestImportSharedCart_MergesQuantities(t *testing.T) {
+	svc := newShareTestService(cache.NewMemoryCache())
+	source := cartWithItems(t, svc, map[string]int{"1": 2, "2": 1})
+	mine := cartWithItems(t, svc, map[string]int{"1": 1, "3": 4})
+
+	token, err := svc.ShareCart(context.TODO(), source.ID)
+	assert.Nil(t, err)
+
+	res, err := svc.ImportSharedCart(context.TODO(), token.Value, mine.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"1": 3, "2": 1, "3": 4}, quantities(res))
+
+	//the shared cart is only read
+	shared, err := svc.GetCart(context.TODO(), source.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"1": 2, "2": 1}, quantities(shared))
+}
+
+func TestImportSharedCart_IntoItself(t *testing.T) {
+	svc := newShareTestService(cache.NewMemoryCache())
+	source := cartWithItems(t, svc, map[string]int{"1": 2})
+	token, err := svc.ShareCart(context.TODO(), source.ID)
+	assert.Nil(t, err)
+
+	_, err = svc.ImportSharedCart(context.TODO(), token.Value, source.ID)
+
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ValidationErrorCode})
+}
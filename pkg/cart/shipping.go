@@ -0,0 +1,139 @@
+package cart
+
+import (
+	"context"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/shipping"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/tax"
+)
+
+//WithShipping quotes the options to ship the carts with a shipping address. Without it carts have no shipping options
+func WithShipping(provider shipping.Provider) Option {
+	return func(s *service) {
+		s.shipping = provider
+	}
+}
+
+func (s *service) GetShippingOptions(ctx context.Context, cartID string) ([]shipping.Option, error) {
+	log := s.logger.WithField("cart_id", cartID)
+
+	log.Info(ctx, "Getting Cart shipping options")
+	cart, err := s.GetCart(ctx, cartID)
+	if err != nil {
+		return nil, err
+	}
+	if cart.ShippingAddress == nil {
+		log.Error(ctx, "Cart has no shipping address")
+		return nil, errors.ServiceError{Code: errors.ValidationErrorCode}.
+			WithFieldViolation("shipping_address", "the cart must have a shipping address")
+	}
+	options, err := s.quoteShipping(ctx, cart, cartValue(cart))
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to quote shipping options")
+		return nil, errors.ServiceError{Code: errors.ExternalApiErrorCode}.WithCause(err)
+	}
+	return options, nil
+}
+
+func (s *service) SelectShippingOption(ctx context.Context, cartID, optionID string) (Cart, error) {
+	log := s.logger.WithField("cart_id", cartID).WithField("option_id", optionID)
+
+	log.Info(ctx, "Selecting Cart shipping option")
+	options, err := s.GetShippingOptions(ctx, cartID)
+	if err != nil {
+		return Cart{}, err
+	}
+	option, ok := findOption(options, optionID)
+	if !ok {
+		log.Error(ctx, "Shipping option is not offered for the Cart")
+		return Cart{}, errors.ServiceError{Code: errors.ValidationErrorCode}.
+			WithFieldViolation("option_id", "is not offered for the cart")
+	}
+
+	cart := Cart{}
+	log.Info(ctx, "Getting Cart from DB")
+	err = s.get(ctx, cartID, &cart)
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to get Cart from DB")
+		return Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}.
+			WithDetail("cart_id", cartID).
+			WithCause(err)
+	}
+
+	cart.ShippingOptionID = option.ID
+	log.Info(ctx, "Saving Cart in DB")
+	event := ShippingOptionSelected{
+		CartID:   cartID,
+		OptionID: option.ID,
+		Carrier:  option.Carrier,
+		Service:  option.Service,
+		Cost:     option.Cost,
+	}
+	if err := s.save(ctx, cart, event); err != nil {
+		log.WithError(err).Error(ctx, "Unable to save Cart to DB")
+		return Cart{}, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+
+	log.Info(ctx, "Getting Cart Item details from provider")
+	_, err = s.fetchItemsForCart(ctx, &cart)
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to fetch item data from provider")
+		return Cart{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}.WithCause(err)
+	}
+	return cart, nil
+}
+
+//applyShipping quotes the chosen shipping option of the cart at the current prices and weights.
+//A failing provider leaves the cart without shipping rather than failing the read
+func (s *service) applyShipping(ctx context.Context, cart *Cart) {
+	cart.Shipping = nil
+	if cart.ShippingOptionID == "" || cart.ShippingAddress == nil {
+		return
+	}
+	options, err := s.quoteShipping(ctx, *cart, cartValue(*cart))
+	if err != nil {
+		s.logger.WithField("cart_id", cart.ID).WithError(err).Warn(ctx, "Unable to quote the shipping of the Cart")
+		return
+	}
+	if option, ok := findOption(options, cart.ShippingOptionID); ok {
+		cart.Shipping = &option
+	}
+}
+
+//quoteShipping gives the options to ship the items of the cart, worth value cents, to its address.
+//There are no options without a provider
+func (s *service) quoteShipping(ctx context.Context, cart Cart, value int64) ([]shipping.Option, error) {
+	if s.shipping == nil || cart.ShippingAddress == nil {
+		return []shipping.Option{}, nil
+	}
+	parcel := shipping.Parcel{Value: value}
+	for _, i := range cart.Items {
+		parcel.Weight += i.Weight * i.Quantity
+		parcel.Items += i.Quantity
+	}
+	dest := shipping.Destination{
+		Country:    cart.ShippingAddress.Country,
+		Region:     cart.ShippingAddress.Region,
+		PostalCode: cart.ShippingAddress.PostalCode,
+	}
+	return s.shipping.Quote(ctx, dest, parcel)
+}
+
+//cartValue gives what the items of the cart cost at their current price, in cents
+func cartValue(cart Cart) int64 {
+	value := int64(0)
+	for _, i := range cart.Items {
+		value += tax.Cents(i.Price) * int64(i.Quantity)
+	}
+	return value
+}
+
+func findOption(options []shipping.Option, id string) (shipping.Option, bool) {
+	for _, o := range options {
+		if o.ID == id {
+			return o, true
+		}
+	}
+	return shipping.Option{}, false
+}
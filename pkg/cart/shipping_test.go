@@ -0,0 +1,102 @@
+package cart_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/shipping"
+	"github.com/stretchr/testify/assert"
+)
+
+func newShippingTestService(t *testing.T, c cache.Cache, ext *externalMock) cart.Service {
+	table, err := shipping.LoadTable("../shipping/testdata/rates.json")
+	assert.Nil(t, err)
+	return newPricingTestService(c, ext, cart.WithShipping(shipping.NewTableProvider(table)))
+}
+
+func TestShippingOptionsNeedAnAddress(t *testing.T) {
+	svc := newShippingTestService(t, cache.NewMemoryCache(), &externalMock{})
+	c, err := svc.CreateCart(context.TODO())
+	assert.Nil(t, err)
+
+	_, err = svc.GetShippingOptions(context.TODO(), c.ID)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ValidationErrorCode})
+}
+
+func TestShippingOptionsFollowWeightAndValue(t *testing.T) {
+	ext := &externalMock{
+		prices:  map[string]float32{"1": 10},
+		weights: map[string]int{"1": 600},
+	}
+	svc := newShippingTestService(t, cache.NewMemoryCache(), ext)
+	c := cartWithItems(t, svc, map[string]int{"1": 2})
+	_, err := svc.SetShippingAddress(context.TODO(), c.ID, cart.Address{Country: "US"})
+	assert.Nil(t, err)
+
+	options, err := svc.GetShippingOptions(context.TODO(), c.ID)
+	assert.Nil(t, err)
+	assert.Len(t, options, 2)
+	assert.Equal(t, "ground", options[0].ID)
+	assert.Equal(t, float32(8), options[0].Cost)
+
+	//worth over 100, ground ships for free
+	_, err = svc.ModifyItemInCart(context.TODO(), c.ID, "1", 10)
+	assert.Nil(t, err)
+	options, err = svc.GetShippingOptions(context.TODO(), c.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, float32(0), options[0].Cost)
+}
+
+func TestSelectedShippingIsPartOfTheTotal(t *testing.T) {
+	ext := &externalMock{
+		prices:  map[string]float32{"1": 10},
+		weights: map[string]int{"1": 600},
+	}
+	svc := newShippingTestService(t, cache.NewMemoryCache(), ext)
+	c := cartWithItems(t, svc, map[string]int{"1": 2})
+	_, err := svc.SetShippingAddress(context.TODO(), c.ID, cart.Address{Country: "US"})
+	assert.Nil(t, err)
+
+	_, err = svc.SelectShippingOption(context.TODO(), c.ID, "overnight")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ValidationErrorCode})
+
+	selected, err := svc.SelectShippingOption(context.TODO(), c.ID, "express")
+	assert.Nil(t, err)
+	tc := cart.CartModelToTransportModel(selected)
+	assert.Equal(t, "express", tc.ShippingOption.ID)
+	assert.Equal(t, float32(21), *tc.Totals.Shipping)
+	assert.Equal(t, float32(41), tc.Totals.Total)
+
+	checkout, err := svc.Checkout(context.TODO(), c.ID, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "express", checkout.ShippingOption.ID)
+	assert.Equal(t, float32(21), checkout.Shipping)
+	assert.Equal(t, float32(41), checkout.Total)
+}
+
+func TestShippingOptionNoLongerOffered(t *testing.T) {
+	ext := &externalMock{
+		prices:  map[string]float32{"1": 10},
+		weights: map[string]int{"1": 600},
+	}
+	svc := newShippingTestService(t, cache.NewMemoryCache(), ext)
+	c := cartWithItems(t, svc, map[string]int{"1": 2})
+	_, err := svc.SetShippingAddress(context.TODO(), c.ID, cart.Address{Country: "US"})
+	assert.Nil(t, err)
+	_, err = svc.SelectShippingOption(context.TODO(), c.ID, "express")
+	assert.Nil(t, err)
+
+	//express does not take parcels over 10kg
+	_, err = svc.ModifyItemInCart(context.TODO(), c.ID, "1", 20)
+	assert.Nil(t, err)
+	got, err := svc.GetCart(context.TODO(), c.ID)
+	assert.Nil(t, err)
+	assert.Nil(t, got.Shipping)
+	assert.Nil(t, cart.CartModelToTransportModel(got).Totals.Shipping)
+
+	_, err = svc.Checkout(context.TODO(), c.ID, false)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ValidationErrorCode})
+}
@@ -0,0 +1,68 @@
+package cart
+
+import (
+	"context"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/inventory"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+)
+
+//WithInventory holds the stock of the items for the carts having them.
+//Without it quantities are only checked against the stock of the provider
+func WithInventory(inv inventory.Service) Option {
+	return func(s *service) {
+		s.inventory = inv
+	}
+}
+
+//reserveStock makes sure there is stock for quantity units of the item in the cart, holding it when
+//there is an inventory. Items whose stock is not tracked by the provider are always available
+func (s *service) reserveStock(ctx context.Context, cartID string, i item.Item, quantity int) error {
+	if i.Stock == nil {
+		return nil
+	}
+	if s.inventory == nil {
+		if quantity > *i.Stock {
+			return errors.ServiceError{Code: errors.InsufficientStockCode}.
+				WithDetail("item_id", i.ID).
+				WithDetail("requested", quantity).
+				WithDetail("available", *i.Stock)
+		}
+		return nil
+	}
+	return s.inventory.Reserve(ctx, cartID, i.ID, quantity, *i.Stock)
+}
+
+//releaseStock gives back the stock held for the item. Failures are only logged, the reservation expires anyway
+func (s *service) releaseStock(ctx context.Context, cartID, itemID string) {
+	if s.inventory == nil {
+		return
+	}
+	if err := s.inventory.Release(ctx, cartID, itemID); err != nil {
+		s.logger.WithField("cart_id", cartID).WithField("item_id", itemID).WithError(err).
+			Warn(ctx, "Unable to release stock, it is released when the reservation expires")
+	}
+}
+
+//releaseAllStock gives back the stock held for the cart. Failures are only logged, the reservation expires anyway
+func (s *service) releaseAllStock(ctx context.Context, cartID string) {
+	if s.inventory == nil {
+		return
+	}
+	if err := s.inventory.ReleaseAll(ctx, cartID); err != nil {
+		s.logger.WithField("cart_id", cartID).WithError(err).
+			Warn(ctx, "Unable to release stock, it is released when the reservation expires")
+	}
+}
+
+//restoreStock brings the stock held for the items back to the quantities the cart had, undoing
+//reservations made for a change that could not be saved
+func (s *service) restoreStock(ctx context.Context, cartID string, items []item.Item, quantities map[string]int) {
+	for _, i := range items {
+		if err := s.reserveStock(ctx, cartID, i, quantities[i.ID]); err != nil {
+			s.logger.WithField("cart_id", cartID).WithField("item_id", i.ID).WithError(err).
+				Warn(ctx, "Unable to restore held stock")
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package cart_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/inventory"
+	"github.com/stretchr/testify/assert"
+)
+
+func stock(n int) *int {
+	return &n
+}
+
+func newStockTestService(c cache.Cache, ext *externalMock) cart.Service {
+	inv := inventory.NewService(logger.NewLogger("inventory unit testing", false), c, time.Minute)
+	return newPricingTestService(c, ext, cart.WithInventory(inv))
+}
+
+func TestAddItemBeyondStock(t *testing.T) {
+	ext := &externalMock{stocks: map[string]*int{"1": stock(3)}}
+	svc := newStockTestService(cache.NewMemoryCache(), ext)
+	a := cartWithItems(t, svc, map[string]int{"1": 2})
+	b, err := svc.CreateCart(context.TODO())
+	assert.Nil(t, err)
+
+	_, err = svc.AddItemToCart(context.TODO(), b.ID, "1", 2)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.InsufficientStockCode})
+	got, err := svc.GetCart(context.TODO(), b.ID)
+	assert.Nil(t, err)
+	assert.Empty(t, got.Items)
+
+	_, err = svc.AddItemToCart(context.TODO(), b.ID, "1", 1)
+	assert.Nil(t, err)
+
+	//removing the item from a cart gives its stock back
+	_, err = svc.DeleteItemInCart(context.TODO(), a.ID, "1")
+	assert.Nil(t, err)
+	_, err = svc.ModifyItemInCart(context.TODO(), b.ID, "1", 3)
+	assert.Nil(t, err)
+}
+
+func TestModifyItemBeyondStock(t *testing.T) {
+	ext := &externalMock{stocks: map[string]*int{"1": stock(3)}}
+	svc := newStockTestService(cache.NewMemoryCache(), ext)
+	c := cartWithItems(t, svc, map[string]int{"1": 2})
+
+	_, err := svc.ModifyItemInCart(context.TODO(), c.ID, "1", 4)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.InsufficientStockCode})
+	got, err := svc.GetCart(context.TODO(), c.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, got.Items[0].Quantity)
+}
+
+func TestUntrackedStockIsUnlimited(t *testing.T) {
+	svc := newStockTestService(cache.NewMemoryCache(), &externalMock{})
+	cartWithItems(t, svc, map[string]int{"1": 500})
+}
+
+func TestStockIsCheckedWithoutInventory(t *testing.T) {
+	ext := &externalMock{stocks: map[string]*int{"1": stock(3)}}
+	svc := newPricingTestService(cache.NewMemoryCache(), ext)
+	c, err := svc.CreateCart(context.TODO())
+	assert.Nil(t, err)
+
+	_, err = svc.AddItemToCart(context.TODO(), c.ID, "1", 4)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.InsufficientStockCode})
+}
+
+func TestDeleteCartReleasesStock(t *testing.T) {
+	ext := &externalMock{stocks: map[string]*int{"1": stock(3)}}
+	svc := newStockTestService(cache.NewMemoryCache(), ext)
+	a := cartWithItems(t, svc, map[string]int{"1": 3})
+	b, err := svc.CreateCart(context.TODO())
+	assert.Nil(t, err)
+
+	_, err = svc.AddItemToCart(context.TODO(), b.ID, "1", 1)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.InsufficientStockCode})
+
+	assert.Nil(t, svc.DeleteCart(context.TODO(), a.ID))
+	_, err = svc.AddItemToCart(context.TODO(), b.ID, "1", 3)
+	assert.Nil(t, err)
+}
+
+func TestItemOperationsBeyondStockChangeNothing(t *testing.T) {
+	ext := &externalMock{stocks: map[string]*int{"1": stock(3), "2": stock(1)}}
+	svc := newStockTestService(cache.NewMemoryCache(), ext)
+	a := cartWithItems(t, svc, map[string]int{"1": 1})
+
+	_, err := svc.ApplyItemOperations(context.TODO(), a.ID, []cart.ItemOperation{
+		{Op: cart.ItemOperationSet, ItemID: "1", Quantity: 3},
+		{Op: cart.ItemOperationAdd, ItemID: "2", Quantity: 2},
+	})
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.InsufficientStockCode})
+
+	//the stock held by the first operation was given back
+	b, err := svc.CreateCart(context.TODO())
+	assert.Nil(t, err)
+	_, err = svc.AddItemToCart(context.TODO(), b.ID, "1", 2)
+	assert.Nil(t, err)
+}
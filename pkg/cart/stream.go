@@ -0,0 +1,124 @@
+package cart
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/events"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/pubsub"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+)
+
+const (
+	//HistorySize is how many events of a cart are kept for clients resuming a stream
+	HistorySize = 50
+
+	historyKeyPrefix = "cart-events:"
+	eventPrefix      = "cart."
+	subscriberBuffer = 32
+)
+
+func historyKey(cartID string) string {
+	return historyKeyPrefix + cartID
+}
+
+//streamTopic is where the events of the cart are broadcasted. The broker is shared by every tenant,
+//so it is named after the tenant as well as the cart
+func streamTopic(ctx context.Context, cartID string) string {
+	return tenant.Namespace(ctx) + cartID
+}
+
+//StreamOptions tunes the Server-Sent Events streams
+type StreamOptions struct {
+	//Heartbeat is how often a comment is sent to keep idle connections open
+	Heartbeat time.Duration
+	//MaxDuration ends streams before the server write timeout does, clients reconnect resuming from the last event
+	MaxDuration time.Duration
+}
+
+//DefaultStreamOptions fits the 15s write timeout of the HTTP server
+var DefaultStreamOptions = StreamOptions{
+	Heartbeat:   5 * time.Second,
+	MaxDuration: 12 * time.Second,
+}
+
+//Stream broadcasts the events of every cart to the clients following it. It is an events.Sink,
+//keeping a short history of each cart for resuming and publishing through the broker to every instance
+type Stream struct {
+	cache  cache.Cache
+	broker pubsub.Broker
+	logger logger.Logger
+	opts   StreamOptions
+}
+
+//NewStream gives a Stream, zero options are taken from DefaultStreamOptions
+func NewStream(logger logger.Logger, c cache.Cache, broker pubsub.Broker, opts StreamOptions) *Stream {
+	if opts.Heartbeat <= 0 {
+		opts.Heartbeat = DefaultStreamOptions.Heartbeat
+	}
+	if opts.MaxDuration <= 0 {
+		opts.MaxDuration = DefaultStreamOptions.MaxDuration
+	}
+	return &Stream{
+		cache:  c,
+		broker: broker,
+		logger: logger,
+		opts:   opts,
+	}
+}
+
+//Publish records the event in the history of its cart and broadcasts it. Deleting a cart forgets its history
+func (s *Stream) Publish(ctx context.Context, e events.Event) error {
+	if !strings.HasPrefix(e.Type, eventPrefix) {
+		return nil
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	key := historyKey(e.AggregateID)
+	if e.Type == EventCartDeleted {
+		err = s.cache.Tx(ctx, cache.DelOp(key))
+	} else {
+		err = s.cache.Tx(ctx,
+			cache.PushOp(key, string(b)),
+			cache.TrimOp(key, HistorySize),
+		)
+	}
+	if err != nil {
+		return err
+	}
+	return s.broker.Publish(ctx, streamTopic(ctx, e.AggregateID), b)
+}
+
+//Subscribe follows the events of a cart of the tenant of the context as they are published
+func (s *Stream) Subscribe(ctx context.Context, cartID string) *pubsub.Subscription {
+	return s.broker.Subscribe(streamTopic(ctx, cartID), subscriberBuffer)
+}
+
+//Followers tells how many clients of this instance follow the cart of the tenant of the context
+func (s *Stream) Followers(ctx context.Context, cartID string) int {
+	return s.broker.Subscribers(streamTopic(ctx, cartID))
+}
+
+//History gives the kept events of a cart, oldest first
+func (s *Stream) History(ctx context.Context, cartID string) ([]events.Event, error) {
+	entries, err := s.cache.ListRange(ctx, historyKey(cartID), 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	evs := make([]events.Event, 0, len(entries))
+	for idx := len(entries) - 1; idx >= 0; idx-- {
+		e := events.Event{}
+		if err := json.Unmarshal([]byte(entries[idx]), &e); err != nil {
+			return nil, err
+		}
+		evs = append(evs, e)
+	}
+	return evs, nil
+}
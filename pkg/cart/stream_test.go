@@ -0,0 +1,219 @@
+package cart_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/events"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/pubsub"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+//sseEvent is a single event read from a stream, comments are kept in comment
+type sseEvent struct {
+	id      string
+	event   string
+	data    string
+	comment string
+}
+
+func streamServer(t *testing.T, svc cart.Service, opts cart.StreamOptions) (*cart.Stream, *httptest.Server) {
+	stream := cart.NewStream(logger.NewLogger("stream unit test", false), cache.NewMemoryCache(), pubsub.NewMemoryBroker(), opts)
+	h := cart.Handler{
+		Service: svc,
+		Stream:  stream,
+	}
+	r := mux.NewRouter()
+	r.HandleFunc("/cart/{cart_id}/events", h.StreamEvents)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return stream, srv
+}
+
+//follow connects to the stream, giving the events as they are read
+func follow(t *testing.T, ctx context.Context, url, lastEventID string) (*http.Response, <-chan sseEvent) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	res, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+
+	out := make(chan sseEvent, 16)
+	go func() {
+		defer close(out)
+		defer res.Body.Close()
+		scanner := bufio.NewScanner(res.Body)
+		current := sseEvent{}
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if current != (sseEvent{}) {
+					out <- current
+				}
+				current = sseEvent{}
+			case strings.HasPrefix(line, ":"):
+				current.comment = strings.TrimSpace(line[1:])
+			case strings.HasPrefix(line, "id: "):
+				current.id = line[4:]
+			case strings.HasPrefix(line, "event: "):
+				current.event = line[7:]
+			case strings.HasPrefix(line, "data: "):
+				current.data = line[6:]
+			}
+		}
+	}()
+	return res, out
+}
+
+func next(t *testing.T, evs <-chan sseEvent) sseEvent {
+	for {
+		select {
+		case e, ok := <-evs:
+			if !ok {
+				t.Fatalf("Stream ended unexpectedly")
+			}
+			if e.event == "" && e.comment == "" {
+				//retry field only
+				continue
+			}
+			return e
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for an event")
+		}
+	}
+}
+
+func TestStreamEvents_SnapshotAndUpdates(t *testing.T) {
+	stream, srv := streamServer(t, &mockedService{}, cart.StreamOptions{Heartbeat: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	res, evs := follow(t, ctx, srv.URL+"/cart/someCart/events", "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	snapshot := next(t, evs)
+	assert.Equal(t, "snapshot", snapshot.event)
+	assert.Contains(t, snapshot.data, "someItemID")
+
+	e, _ := events.New(cart.EventItemAdded, "someCart", cart.ItemAdded{CartID: "someCart", ItemID: "1", Quantity: 1})
+	assert.Nil(t, stream.Publish(context.TODO(), e))
+	other, _ := events.New(cart.EventItemAdded, "otherCart", cart.ItemAdded{CartID: "otherCart", ItemID: "1", Quantity: 1})
+	assert.Nil(t, stream.Publish(context.TODO(), other))
+
+	update := next(t, evs)
+	assert.Equal(t, e.ID, update.id)
+	assert.Equal(t, cart.EventItemAdded, update.event)
+
+	deleted, _ := events.New(cart.EventCartDeleted, "someCart", cart.CartDeleted{CartID: "someCart"})
+	stream.Publish(context.TODO(), deleted)
+	assert.Equal(t, cart.EventCartDeleted, next(t, evs).event)
+	if _, ok := <-evs; ok {
+		t.Fatalf("Stream must end after the cart is deleted")
+	}
+}
+
+func TestStreamEvents_Resume(t *testing.T) {
+	stream, srv := streamServer(t, &mockedService{}, cart.StreamOptions{Heartbeat: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first, _ := events.New(cart.EventCartCreated, "someCart", cart.CartCreated{CartID: "someCart"})
+	second, _ := events.New(cart.EventItemAdded, "someCart", cart.ItemAdded{CartID: "someCart", ItemID: "1", Quantity: 1})
+	stream.Publish(context.TODO(), first)
+	stream.Publish(context.TODO(), second)
+
+	_, evs := follow(t, ctx, srv.URL+"/cart/someCart/events", first.ID)
+
+	replayed := next(t, evs)
+	assert.Equal(t, second.ID, replayed.id, "events after Last-Event-ID must be replayed without a snapshot")
+}
+
+func TestStreamEvents_UnknownLastEventID(t *testing.T) {
+	stream, srv := streamServer(t, &mockedService{}, cart.StreamOptions{Heartbeat: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e, _ := events.New(cart.EventCartCreated, "someCart", cart.CartCreated{CartID: "someCart"})
+	stream.Publish(context.TODO(), e)
+
+	_, evs := follow(t, ctx, srv.URL+"/cart/someCart/events", "forgottenID")
+
+	snapshot := next(t, evs)
+	assert.Equal(t, "snapshot", snapshot.event)
+	assert.Equal(t, e.ID, snapshot.id, "the snapshot must carry the latest event ID to resume from it")
+}
+
+func TestStreamEvents_HeartbeatAndMaxDuration(t *testing.T) {
+	_, srv := streamServer(t, &mockedService{}, cart.StreamOptions{
+		Heartbeat:   10 * time.Millisecond,
+		MaxDuration: 100 * time.Millisecond,
+	})
+
+	_, evs := follow(t, context.Background(), srv.URL+"/cart/someCart/events", "")
+	next(t, evs)
+	assert.Equal(t, "heartbeat", next(t, evs).comment)
+
+	for range evs {
+	}
+}
+
+func TestStreamEvents_CartNotFound(t *testing.T) {
+	_, srv := streamServer(t, &mockedService{shouldFail: true}, cart.StreamOptions{})
+
+	res, err := http.Get(srv.URL + "/cart/someCart/events")
+	assert.Nil(t, err)
+	defer res.Body.Close()
+	assert.NotEqual(t, http.StatusOK, res.StatusCode)
+}
+
+func TestStreamEvents_CleanupOnDisconnect(t *testing.T) {
+	stream, srv := streamServer(t, &mockedService{}, cart.StreamOptions{Heartbeat: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, evs := follow(t, ctx, srv.URL+"/cart/someCart/events", "")
+	next(t, evs)
+	assert.Equal(t, 1, stream.Followers(context.TODO(), "someCart"))
+	cancel()
+	for range evs {
+	}
+
+	assert.Eventually(t, func() bool {
+		return stream.Followers(context.TODO(), "someCart") == 0
+	}, time.Second, 10*time.Millisecond, "the subscription must be closed once the client is gone")
+}
+
+func TestStream_EventsStayWithinTheTenant(t *testing.T) {
+	c := cache.NewNamespacedCache(cache.NewMemoryCache(), tenant.Namespace)
+	stream := cart.NewStream(logger.NewLogger("stream unit test", false), c, pubsub.NewMemoryBroker(), cart.StreamOptions{})
+	acme := tenant.NewContext(context.TODO(), tenant.Tenant{ID: "acme"})
+	globex := tenant.NewContext(context.TODO(), tenant.Tenant{ID: "globex"})
+
+	acmeSub := stream.Subscribe(acme, "someCart")
+	defer acmeSub.Close()
+	globexSub := stream.Subscribe(globex, "someCart")
+	defer globexSub.Close()
+
+	assert.Nil(t, stream.Publish(globex, events.Event{ID: "1", Type: cart.EventItemAdded, AggregateID: "someCart"}))
+	assert.Contains(t, string(<-globexSub.C), `"id":"1"`)
+	select {
+	case b := <-acmeSub.C:
+		t.Fatalf("The event of another tenant was received: %s", b)
+	case <-time.After(50 * time.Millisecond):
+	}
+	history, err := stream.History(acme, "someCart")
+	assert.Nil(t, err)
+	assert.Empty(t, history)
+}
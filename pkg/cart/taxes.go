@@ -0,0 +1,111 @@
+package cart
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/tax"
+)
+
+var (
+	countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+	regionCode  = regexp.MustCompile(`^[A-Z0-9]{1,3}$`)
+)
+
+//WithTaxes calculates the taxes of the carts with a shipping address. Without it carts have no taxes
+func WithTaxes(calc tax.Calculator) Option {
+	return func(s *service) {
+		s.taxes = calc
+	}
+}
+
+func (s *service) SetShippingAddress(ctx context.Context, cartID string, address Address) (Cart, error) {
+	log := s.logger.WithField("cart_id", cartID)
+
+	log.Info(ctx, "Setting Cart shipping address")
+	address.Country = strings.ToUpper(strings.TrimSpace(address.Country))
+	address.Region = strings.ToUpper(strings.TrimSpace(address.Region))
+	address.PostalCode = strings.TrimSpace(address.PostalCode)
+	if err := validateAddress(address); err != nil {
+		log.WithError(err).Error(ctx, "Invalid shipping address")
+		return Cart{}, err
+	}
+
+	cart := Cart{}
+	log.Info(ctx, "Getting Cart from DB")
+	err := s.get(ctx, cartID, &cart)
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to get Cart from DB")
+		return Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}.
+			WithDetail("cart_id", cartID).
+			WithCause(err)
+	}
+
+	cart.ShippingAddress = &address
+	log.Info(ctx, "Saving Cart in DB")
+	event := ShippingAddressChanged{
+		CartID:     cartID,
+		Country:    address.Country,
+		Region:     address.Region,
+		PostalCode: address.PostalCode,
+	}
+	if err := s.save(ctx, cart, event); err != nil {
+		log.WithError(err).Error(ctx, "Unable to save Cart to DB")
+		return Cart{}, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+
+	log.Info(ctx, "Getting Cart Item details from provider")
+	_, err = s.fetchItemsForCart(ctx, &cart)
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to fetch item data from provider")
+		return Cart{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}.WithCause(err)
+	}
+	return cart, nil
+}
+
+//applyTaxes calculates the taxes of the items of the cart at their current price
+func (s *service) applyTaxes(cart *Cart) {
+	lines := make([]tax.Line, 0, len(cart.Items))
+	for _, i := range cart.Items {
+		lines = append(lines, tax.Line{
+			ID:        i.LineID,
+			Category:  i.Category,
+			UnitPrice: i.Price,
+			Quantity:  i.Quantity,
+		})
+	}
+	cart.Taxes = s.calculateTaxes(*cart, lines)
+}
+
+//calculateTaxes gives the taxes of the lines shipped to the address of the cart,
+//nil when the cart has no address or there are no rules for it
+func (s *service) calculateTaxes(cart Cart, lines []tax.Line) *tax.Result {
+	if s.taxes == nil || cart.ShippingAddress == nil {
+		return nil
+	}
+	res, ok := s.taxes.Calculate(cart.ShippingAddress.Country, cart.ShippingAddress.Region, lines)
+	if !ok {
+		return nil
+	}
+	return &res
+}
+
+//validateAddress checks the address fields coming from the client, reporting every invalid field at once
+func validateAddress(a Address) error {
+	vErr := errors.ServiceError{Code: errors.ValidationErrorCode}
+	if !countryCode.MatchString(a.Country) {
+		vErr = vErr.WithFieldViolation("country", "must be an ISO 3166-1 alpha-2 code")
+	}
+	if a.Region != "" && !regionCode.MatchString(a.Region) {
+		vErr = vErr.WithFieldViolation("region", "must be the code of the state or province, up to 3 letters or digits")
+	}
+	if len(a.PostalCode) > 16 {
+		vErr = vErr.WithFieldViolation("postal_code", "must be up to 16 characters")
+	}
+	if len(vErr.Fields) > 0 {
+		return vErr
+	}
+	return nil
+}
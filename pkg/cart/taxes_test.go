@@ -0,0 +1,124 @@
+package cart_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/currency"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/tax"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTaxTestService(t *testing.T, c cache.Cache, ext *externalMock) cart.Service {
+	rules, err := tax.LoadRules("../tax/testdata/rules.json")
+	assert.Nil(t, err)
+	return newPricingTestService(c, ext, cart.WithTaxes(tax.NewCalculator(rules)))
+}
+
+func TestCartWithoutAddressHasNoTaxes(t *testing.T) {
+	ext := &externalMock{prices: map[string]float32{"1": 10}}
+	svc := newTaxTestService(t, cache.NewMemoryCache(), ext)
+	c := cartWithItems(t, svc, map[string]int{"1": 2})
+
+	got, err := svc.GetCart(context.TODO(), c.ID)
+	assert.Nil(t, err)
+	tc := cart.CartModelToTransportModel(got)
+	assert.Nil(t, tc.Totals.Tax)
+	assert.Nil(t, tc.Items[0].Tax)
+	assert.Equal(t, float32(20), tc.Totals.Total)
+}
+
+func TestExclusiveTaxesAreAddedOnTop(t *testing.T) {
+	ext := &externalMock{
+		prices:     map[string]float32{"1": 10, "2": 4},
+		categories: map[string]string{"2": "groceries"},
+	}
+	svc := newTaxTestService(t, cache.NewMemoryCache(), ext)
+	c := cartWithItems(t, svc, map[string]int{"1": 2, "2": 1})
+
+	got, err := svc.SetShippingAddress(context.TODO(), c.ID, cart.Address{Country: "us", Region: "ca", PostalCode: "94105"})
+	assert.Nil(t, err)
+	assert.Equal(t, &cart.Address{Country: "US", Region: "CA", PostalCode: "94105"}, got.ShippingAddress)
+
+	tc := cart.CartModelToTransportModel(got)
+	assert.Equal(t, "US-CA", tc.TaxRegion)
+	assert.Equal(t, float32(24), tc.Totals.Items)
+	assert.Equal(t, float32(1.45), *tc.Totals.Tax)
+	assert.False(t, tc.Totals.TaxIncluded)
+	assert.Equal(t, float32(25.45), tc.Totals.Total)
+	for _, i := range tc.Items {
+		if i.ID == "2" {
+			assert.Equal(t, float64(0), i.Tax.Rate)
+		}
+	}
+
+	checkout, err := svc.Checkout(context.TODO(), c.ID, false)
+	assert.Nil(t, err)
+	assert.Equal(t, float32(24), checkout.Subtotal)
+	assert.Equal(t, float32(1.45), checkout.Tax)
+	assert.Equal(t, float32(25.45), checkout.Total)
+}
+
+func TestInclusiveTaxesArePartOfThePrice(t *testing.T) {
+	ext := &externalMock{prices: map[string]float32{"1": 11.9}}
+	svc := newTaxTestService(t, cache.NewMemoryCache(), ext)
+	c := cartWithItems(t, svc, map[string]int{"1": 1})
+
+	got, err := svc.SetShippingAddress(context.TODO(), c.ID, cart.Address{Country: "DE"})
+	assert.Nil(t, err)
+
+	tc := cart.CartModelToTransportModel(got)
+	assert.Equal(t, "DE", tc.TaxRegion)
+	assert.Equal(t, float32(1.9), *tc.Totals.Tax)
+	assert.True(t, tc.Totals.TaxIncluded)
+	assert.Equal(t, float32(11.9), tc.Totals.Total)
+}
+
+func TestRegionWithoutRulesHasNoTaxes(t *testing.T) {
+	ext := &externalMock{prices: map[string]float32{"1": 10}}
+	svc := newTaxTestService(t, cache.NewMemoryCache(), ext)
+	c := cartWithItems(t, svc, map[string]int{"1": 1})
+
+	got, err := svc.SetShippingAddress(context.TODO(), c.ID, cart.Address{Country: "FR"})
+	assert.Nil(t, err)
+	tc := cart.CartModelToTransportModel(got)
+	assert.Nil(t, tc.Totals.Tax)
+	assert.Equal(t, float32(10), tc.Totals.Total)
+}
+
+func TestSetShippingAddressValidation(t *testing.T) {
+	svc := newTaxTestService(t, cache.NewMemoryCache(), &externalMock{})
+	c, err := svc.CreateCart(context.TODO())
+	assert.Nil(t, err)
+
+	_, err = svc.SetShippingAddress(context.TODO(), c.ID, cart.Address{Country: "USA", Region: "CALIF"})
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ValidationErrorCode})
+	sErr := errors.ServiceError{}
+	assert.ErrorAs(t, err, &sErr)
+	assert.Len(t, sErr.Fields, 2)
+
+	_, err = svc.SetShippingAddress(context.TODO(), "missing", cart.Address{Country: "US"})
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.CartNotFoundCode})
+}
+
+func TestTotalsAddUpConvertedAmounts(t *testing.T) {
+	ext := &externalMock{prices: map[string]float32{"1": 3.33, "2": 1.01}}
+	svc := newTaxTestService(t, cache.NewMemoryCache(), ext)
+	c := cartWithItems(t, svc, map[string]int{"1": 3, "2": 1})
+	got, err := svc.SetShippingAddress(context.TODO(), c.ID, cart.Address{Country: "US", Region: "CA"})
+	assert.Nil(t, err)
+
+	tc := cart.CartModelToTransportModelIn(got, currency.Rate{From: "USD", To: "EUR", Value: 0.9})
+	assert.Equal(t, "EUR", tc.Currency)
+	items := float32(0)
+	for _, i := range tc.Items {
+		items += i.Price * float32(i.Quantity)
+	}
+	assert.InDelta(t, items, tc.Totals.Items, 0.001)
+	assert.Equal(t, float32(9.91), tc.Totals.Items)
+	assert.Equal(t, float32(0.71), *tc.Totals.Tax)
+	assert.Equal(t, float32(10.62), tc.Totals.Total)
+}
@@ -0,0 +1,109 @@
+package cart_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	acme   = tenant.NewContext(context.TODO(), tenant.Tenant{ID: "acme"})
+	globex = tenant.NewContext(context.TODO(), tenant.Tenant{ID: "globex"})
+)
+
+func TestTenantsCannotReadTheCartsOfOthers(t *testing.T) {
+	inner := cache.NewMemoryCache()
+	svc := newPricingTestService(cache.NewNamespacedCache(inner, tenant.Namespace), &externalMock{})
+	created, err := svc.CreateCart(acme)
+	assert.Nil(t, err)
+	_, err = svc.AddItemToCart(acme, created.ID, "1", 2)
+	assert.Nil(t, err)
+	notFound := errors.ServiceError{Code: errors.CartNotFoundCode}
+
+	//knowing the ID is not enough, for another tenant nor for requests of no tenant
+	for _, ctx := range []context.Context{globex, context.TODO()} {
+		_, err = svc.GetCart(ctx, created.ID)
+		assert.ErrorIs(t, err, notFound)
+		_, err = svc.AddItemToCart(ctx, created.ID, "2", 1)
+		assert.ErrorIs(t, err, notFound)
+		_, err = svc.ModifyItemInCart(ctx, created.ID, "1", 5)
+		assert.ErrorIs(t, err, notFound)
+		_, err = svc.DeleteItemInCart(ctx, created.ID, "1")
+		assert.ErrorIs(t, err, notFound)
+		_, err = svc.DeleteAllItemsInCart(ctx, created.ID)
+		assert.ErrorIs(t, err, notFound)
+		_, err = svc.ApplyItemOperations(ctx, created.ID, []cart.ItemOperation{{Op: cart.ItemOperationRemove, ItemID: "1"}})
+		assert.ErrorIs(t, err, notFound)
+		_, err = svc.CloneCart(ctx, created.ID)
+		assert.ErrorIs(t, err, notFound)
+		assert.ErrorIs(t, svc.DeleteCart(ctx, created.ID), notFound)
+	}
+
+	got, err := svc.GetCart(acme, created.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, got.Items[0].Quantity)
+
+	//the cart is kept under the keys of its tenant only
+	raw := json.RawMessage{}
+	assert.Nil(t, inner.Get(context.TODO(), "tenant:acme:"+cart.Key(created.ID), &raw))
+	assert.True(t, cache.IsNotFound(inner.Get(context.TODO(), cart.Key(created.ID), &raw)))
+	assert.True(t, cache.IsNotFound(inner.Get(context.TODO(), "tenant:globex:"+cart.Key(created.ID), &raw)))
+}
+
+func TestSharedCartsAreNotFoundByOtherTenants(t *testing.T) {
+	svc := newPricingTestService(cache.NewNamespacedCache(cache.NewMemoryCache(), tenant.Namespace), &externalMock{})
+	shared, err := svc.CreateCart(acme)
+	assert.Nil(t, err)
+	_, err = svc.AddItemToCart(acme, shared.ID, "1", 1)
+	assert.Nil(t, err)
+	token, err := svc.ShareCart(acme, shared.ID)
+	assert.Nil(t, err)
+
+	_, err = svc.GetSharedCart(globex, token.Value)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.CartNotFoundCode})
+	target, err := svc.CreateCart(globex)
+	assert.Nil(t, err)
+	_, err = svc.ImportSharedCart(globex, token.Value, target.ID)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.CartNotFoundCode})
+}
+
+func TestTenantLimits(t *testing.T) {
+	svc := newPricingTestService(cache.NewNamespacedCache(cache.NewMemoryCache(), tenant.Namespace), &externalMock{})
+	ctx := tenant.NewContext(context.TODO(), tenant.Tenant{ID: "acme", Limits: tenant.Limits{MaxLines: 2, MaxQuantity: 3}})
+	c, err := svc.CreateCart(ctx)
+	assert.Nil(t, err)
+	invalid := errors.ServiceError{Code: errors.ValidationErrorCode}
+
+	_, err = svc.AddItemToCart(ctx, c.ID, "1", 4)
+	assert.ErrorIs(t, err, invalid)
+	_, err = svc.AddItemToCart(ctx, c.ID, "1", 3)
+	assert.Nil(t, err)
+	_, err = svc.ModifyItemInCart(ctx, c.ID, "1", 4)
+	assert.ErrorIs(t, err, invalid)
+	_, err = svc.AddLineToCart(ctx, c.ID, cart.Line{ItemID: "1", Note: "gift"}, 1)
+	assert.Nil(t, err)
+
+	_, err = svc.AddItemToCart(ctx, c.ID, "2", 1)
+	sErr := errors.ServiceError{}
+	assert.ErrorAs(t, err, &sErr)
+	assert.Equal(t, errors.ValidationErrorCode, sErr.Code)
+	assert.Equal(t, "items", sErr.Fields[0].Field)
+	_, err = svc.ApplyItemOperations(ctx, c.ID, []cart.ItemOperation{
+		{Op: cart.ItemOperationRemove, ItemID: "1"},
+		{Op: cart.ItemOperationAdd, ItemID: "2", Quantity: 1},
+		{Op: cart.ItemOperationAdd, ItemID: "3", Quantity: 1},
+	})
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ItemOperationsFailedCode})
+
+	//other tenants have limits of their own
+	other, err := svc.CreateCart(globex)
+	assert.Nil(t, err)
+	_, err = svc.AddItemToCart(globex, other.ID, "1", 10)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,297 @@
+package collab
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/response"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	//writeWait bounds every write to a client
+	writeWait = 10 * time.Second
+	//pongWait is how long a client may stay silent, it must answer the pings sent every pingPeriod
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+	//maxCommandSize bounds the commands read from a client
+	maxCommandSize = 4096
+)
+
+//CloseTryAgainLater is sent to clients dropped for falling behind the broadcasts
+const CloseTryAgainLater = websocket.CloseTryAgainLater
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+type Handler struct {
+	Service Service
+	Carts   cart.Service
+}
+
+//IssueToken gives a token to join the session of a cart
+func (c *Handler) IssueToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cartID := vars["cart_id"]
+
+	vm := TokenRequest{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&vm)
+	if err != nil {
+		log.Printf("Error decoding body: %v", err)
+		response.RespondWithError(w, r, response.StandardBadBodyRequest)
+		return
+	}
+
+	if _, err := c.Carts.GetCart(r.Context(), cartID); err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+	token, err := c.Service.IssueToken(r.Context(), cartID, vm.Name)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	res := TokenResponse{
+		Token:     token.Value,
+		UserID:    token.UserID,
+		ExpiresAt: token.ExpiresAt,
+	}
+	response.RespondWithData(w, http.StatusCreated, res)
+}
+
+//Connect joins the session of a cart over a WebSocket. Browsers can't set headers on WebSockets,
+//so the token is taken from the token query parameter when there is no Authorization header
+func (c *Handler) Connect(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cartID := vars["cart_id"]
+
+	claims, err := c.Service.Authenticate(r.Context(), tokenFromRequest(r), cartID)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+	current, err := c.Carts.GetCart(r.Context(), cartID)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		//the upgrader already answered the client
+		log.Printf("Error upgrading connection: %v", err)
+		return
+	}
+
+	conn := &connection{
+		ws:     ws,
+		svc:    c.Service,
+		carts:  c.Carts,
+		cartID: cartID,
+		lang:   r.Header.Get("Accept-Language"),
+		participant: Participant{
+			ConnectionID: uuid.New().String(),
+			UserID:       claims.UserID,
+			Name:         claims.Name,
+		},
+		direct: make(chan Message, sendBuffer),
+	}
+	conn.run(detached{r.Context()}, current)
+}
+
+//detached keeps the values of the request, such as its tenant, correlation ID and span, for the session
+//outliving it. The session ends on its own when the connection is closed
+type detached struct {
+	parent context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detached) Done() <-chan struct{} {
+	return nil
+}
+
+func (detached) Err() error {
+	return nil
+}
+
+func (d detached) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+func tokenFromRequest(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimPrefix(h, "Bearer ")
+	}
+	return r.URL.Query().Get("token")
+}
+
+//connection is a single participant of a session. Only the write loop writes to the socket,
+//the read loop runs the commands and queues their results in direct
+type connection struct {
+	ws          *websocket.Conn
+	svc         Service
+	carts       cart.Service
+	cartID      string
+	lang        string
+	participant Participant
+	direct      chan Message
+}
+
+func (c *connection) run(base context.Context, current cart.Cart) {
+	ctx, cancel := context.WithCancel(base)
+	defer cancel()
+
+	sub := c.svc.Subscribe(ctx, c.cartID)
+	defer sub.Close()
+	defer c.ws.Close()
+
+	participants, err := c.svc.Join(ctx, c.cartID, c.participant)
+	if err != nil {
+		c.closeWith(websocket.CloseInternalServerErr, "unable to join the session")
+		return
+	}
+	//leaving once ctx is cancelled, but within the same tenant
+	defer c.svc.Leave(base, c.cartID, c.participant)
+
+	tc := cart.CartModelToTransportModel(current)
+	c.direct <- Message{
+		Type:         MessageWelcome,
+		Cart:         &tc,
+		You:          &c.participant,
+		Participants: participants,
+	}
+
+	go func() {
+		c.readLoop(ctx)
+		cancel()
+	}()
+	c.writeLoop(ctx, sub.C)
+}
+
+func (c *connection) readLoop(ctx context.Context) {
+	c.ws.SetReadLimit(maxCommandSize)
+	c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		cmd := Command{}
+		if err := c.ws.ReadJSON(&cmd); err != nil {
+			return
+		}
+		msg := c.execute(ctx, cmd)
+		select {
+		case c.direct <- msg:
+		default:
+			//the client sends commands without reading their results
+			return
+		}
+	}
+}
+
+func (c *connection) writeLoop(ctx context.Context, broadcasts <-chan []byte) {
+	ping := time.NewTicker(pingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.closeWith(websocket.CloseNormalClosure, "")
+			return
+		case msg := <-c.direct:
+			if !c.write(msg) {
+				return
+			}
+		case b, ok := <-broadcasts:
+			if !ok {
+				c.closeWith(CloseTryAgainLater, "falling behind, reconnect")
+				return
+			}
+			env := envelope{}
+			if err := json.Unmarshal(b, &env); err != nil || env.Origin == c.participant.ConnectionID {
+				continue
+			}
+			if !c.write(env.Message) {
+				return
+			}
+		case <-ping.C:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+			//the client answered the previous ping within pongWait, or the read loop would have ended
+			if err := c.svc.Heartbeat(ctx, c.cartID, c.participant); err != nil {
+				log.Printf("Error refreshing presence: %v", err)
+			}
+		}
+	}
+}
+
+func (c *connection) write(msg Message) bool {
+	c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.ws.WriteJSON(msg) == nil
+}
+
+func (c *connection) closeWith(code int, reason string) {
+	c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
+}
+
+//execute runs a command through the cart service, broadcasting the new cart to the others when it succeeds
+func (c *connection) execute(ctx context.Context, cmd Command) Message {
+	var (
+		updated cart.Cart
+		err     error
+	)
+	switch cmd.Type {
+	case CommandAddItem:
+		line := cart.Line{ItemID: cmd.ItemID, Options: cmd.Options, Note: cmd.Note}
+		updated, err = c.carts.AddLineToCart(ctx, c.cartID, line, cmd.Quantity)
+	case CommandUpdateItem:
+		updated, err = c.carts.ModifyItemInCart(ctx, c.cartID, cmd.LineID, cmd.Quantity)
+	case CommandRemoveItem:
+		updated, err = c.carts.DeleteItemInCart(ctx, c.cartID, cmd.LineID)
+	default:
+		err = errors.ServiceError{Code: errors.ValidationErrorCode}.
+			WithFieldViolation("type", "must be one of add_item, update_item or remove_item")
+	}
+	if err != nil {
+		vm := response.ErrorFromError(c.lang, err)
+		return Message{
+			Type:  MessageError,
+			ID:    cmd.ID,
+			Error: &vm,
+		}
+	}
+
+	tc := cart.CartModelToTransportModel(updated)
+	err = c.svc.Broadcast(ctx, c.cartID, c.participant.ConnectionID, Message{
+		Type: MessageCartUpdated,
+		Cart: &tc,
+		By:   &c.participant,
+	})
+	if err != nil {
+		log.Printf("Error broadcasting cart update: %v", err)
+	}
+	return Message{
+		Type: MessageResult,
+		ID:   cmd.ID,
+		Cart: &tc,
+	}
+}
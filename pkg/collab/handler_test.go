@@ -0,0 +1,218 @@
+package collab_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/collab"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func sessionServer(t *testing.T) (collab.Service, *httptest.Server) {
+	svc := newService(time.Minute)
+	h := collab.Handler{
+		Service: svc,
+		Carts:   &mockedCarts{},
+	}
+	r := mux.NewRouter()
+	r.HandleFunc("/cart/{cart_id}/session", h.IssueToken).Methods(http.MethodPost)
+	r.HandleFunc("/cart/{cart_id}/session", h.Connect).Methods(http.MethodGet)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return svc, srv
+}
+
+func dial(t *testing.T, srv *httptest.Server, svc collab.Service, cartID, name string) *websocket.Conn {
+	token, _ := svc.IssueToken(context.TODO(), cartID, name)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/cart/" + cartID + "/session?token=" + token.Value
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Error was not expected: %s", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+//read gives the next message of the given type, skipping the others
+func read(t *testing.T, ws *websocket.Conn, msgType string) collab.Message {
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		msg := collab.Message{}
+		if err := ws.ReadJSON(&msg); err != nil {
+			t.Fatalf("Error reading %s: %s", msgType, err)
+		}
+		if msg.Type == msgType {
+			return msg
+		}
+	}
+}
+
+func TestConnect_Collaboration(t *testing.T) {
+	svc, srv := sessionServer(t)
+
+	ana := dial(t, srv, svc, "someCart", "Ana")
+	welcome := read(t, ana, collab.MessageWelcome)
+	assert.Equal(t, "Ana", welcome.You.Name)
+	assert.Equal(t, "someCart", welcome.Cart.ID)
+
+	bob := dial(t, srv, svc, "someCart", "Bob")
+	assert.Len(t, read(t, bob, collab.MessageWelcome).Participants, 2)
+	joined := read(t, ana, collab.MessagePresence)
+	assert.Equal(t, "Bob", joined.Joined.Name)
+
+	assert.Nil(t, ana.WriteJSON(collab.Command{ID: "1", Type: collab.CommandAddItem, ItemID: "someItem", Quantity: 2}))
+	result := read(t, ana, collab.MessageResult)
+	assert.Equal(t, "1", result.ID)
+	assert.Len(t, result.Cart.Items, 1)
+
+	updated := read(t, bob, collab.MessageCartUpdated)
+	assert.Equal(t, "Ana", updated.By.Name)
+	assert.Len(t, updated.Cart.Items, 1)
+
+	bob.Close()
+	left := read(t, ana, collab.MessagePresence)
+	assert.Equal(t, "Bob", left.Left.Name)
+	assert.Len(t, left.Participants, 1)
+}
+
+func TestConnect_CommandError(t *testing.T) {
+	svc, srv := sessionServer(t)
+	ana := dial(t, srv, svc, "someCart", "Ana")
+
+	ana.WriteJSON(collab.Command{ID: "1", Type: "unknown"})
+	msg := read(t, ana, collab.MessageError)
+	assert.Equal(t, "1", msg.ID)
+	assert.Equal(t, errors.ValidationErrorCode, msg.Error.Code)
+
+	ana.WriteJSON(collab.Command{ID: "2", Type: collab.CommandRemoveItem, LineID: "missing"})
+	msg = read(t, ana, collab.MessageError)
+	assert.Equal(t, errors.ItemNotFoundCode, msg.Error.Code)
+}
+
+func TestConnect_CommandsAddressLines(t *testing.T) {
+	svc, srv := sessionServer(t)
+	ana := dial(t, srv, svc, "someCart", "Ana")
+	read(t, ana, collab.MessageWelcome)
+
+	for i, size := range []string{"S", "L"} {
+		ana.WriteJSON(collab.Command{ID: fmt.Sprint(i), Type: collab.CommandAddItem, ItemID: "someItem", Options: map[string]string{"size": size}, Quantity: 1})
+		read(t, ana, collab.MessageResult)
+	}
+	large := cart.LineID("someItem", map[string]string{"size": "L"}, "")
+
+	ana.WriteJSON(collab.Command{ID: "2", Type: collab.CommandUpdateItem, LineID: large, Quantity: 3})
+	result := read(t, ana, collab.MessageResult)
+	assert.Len(t, result.Cart.Items, 2)
+	assert.Equal(t, large, result.Cart.Items[1].LineID)
+	assert.Equal(t, "L", result.Cart.Items[1].Options["size"])
+	assert.Equal(t, 3, result.Cart.Items[1].Quantity)
+	assert.Equal(t, 1, result.Cart.Items[0].Quantity)
+
+	ana.WriteJSON(collab.Command{ID: "3", Type: collab.CommandRemoveItem, LineID: large})
+	result = read(t, ana, collab.MessageResult)
+	assert.Len(t, result.Cart.Items, 1)
+	assert.Equal(t, "S", result.Cart.Items[0].Options["size"])
+}
+
+func TestConnect_Unauthorized(t *testing.T) {
+	svc, srv := sessionServer(t)
+	token, _ := svc.IssueToken(context.TODO(), "otherCart", "Ana")
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/cart/someCart/session?token=" + token.Value
+	_, res, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
+
+func TestIssueToken(t *testing.T) {
+	_, srv := sessionServer(t)
+
+	res, err := http.Post(srv.URL+"/cart/someCart/session", "application/json", strings.NewReader(`{"name":"Ana"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+	res, err = http.Post(srv.URL+"/cart/missing/session", "application/json", strings.NewReader(`{"name":"Ana"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestConnect_CommandsRunWithinTheTenantOfTheRequest(t *testing.T) {
+	svc := newService(time.Minute)
+	carts := &mockedCarts{}
+	h := collab.Handler{Service: svc, Carts: carts}
+	reg, _ := tenant.NewRegistry(tenant.Tenant{ID: "acme"}, tenant.Tenant{ID: "globex"})
+	r := mux.NewRouter()
+	r.Use(tenant.Middleware(reg))
+	r.HandleFunc("/cart/{cart_id}/session", h.Connect).Methods(http.MethodGet)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	acme := tenant.NewContext(context.TODO(), tenant.Tenant{ID: "acme"})
+	token, _ := svc.IssueToken(acme, "someCart", "Ana")
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/cart/someCart/session?token=" + token.Value
+
+	_, res, err := websocket.DefaultDialer.Dial(url, http.Header{tenant.Header: {"globex"}})
+	assert.NotNil(t, err, "the token of a tenant must not join the session of another")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	ws, _, err := websocket.DefaultDialer.Dial(url, http.Header{tenant.Header: {"acme"}})
+	assert.Nil(t, err)
+	defer ws.Close()
+	read(t, ws, collab.MessageWelcome)
+	ws.WriteJSON(collab.Command{ID: "1", Type: collab.CommandAddItem, ItemID: "someItem", Quantity: 1})
+	read(t, ws, collab.MessageResult)
+	assert.Equal(t, []string{"acme"}, carts.tenants)
+}
+
+//mockedCarts keeps the items added to any cart, carts named missing don't exist
+type mockedCarts struct {
+	cart.Service
+	items []item.Item
+	//tenants are the ones items were added within
+	tenants []string
+}
+
+func (m *mockedCarts) GetCart(ctx context.Context, cartID string) (cart.Cart, error) {
+	if cartID == "missing" {
+		return cart.Cart{}, errors.ServiceError{Code: errors.CartNotFoundCode}
+	}
+	return cart.Cart{ID: cartID, Items: m.items}, nil
+}
+
+func (m *mockedCarts) AddLineToCart(ctx context.Context, cartID string, line cart.Line, quantity int) (cart.Cart, error) {
+	m.items = append(m.items, item.Item{ID: line.ItemID, Quantity: quantity, LineID: line.ID(), Options: line.Options, Note: line.Note})
+	t, _ := tenant.FromContext(ctx)
+	m.tenants = append(m.tenants, t.ID)
+	return m.GetCart(ctx, cartID)
+}
+
+func (m *mockedCarts) ModifyItemInCart(ctx context.Context, cartID, lineID string, quantity int) (cart.Cart, error) {
+	for idx := range m.items {
+		if m.items[idx].LineID == lineID {
+			m.items[idx].Quantity = quantity
+			return m.GetCart(ctx, cartID)
+		}
+	}
+	return cart.Cart{}, errors.ServiceError{Code: errors.ItemNotFoundCode}
+}
+
+func (m *mockedCarts) DeleteItemInCart(ctx context.Context, cartID, lineID string) (cart.Cart, error) {
+	for idx := range m.items {
+		if m.items[idx].LineID == lineID {
+			m.items = append(m.items[:idx], m.items[idx+1:]...)
+			return m.GetCart(ctx, cartID)
+		}
+	}
+	return cart.Cart{}, errors.ServiceError{Code: errors.ItemNotFoundCode}
+}
@@ -0,0 +1,96 @@
+package collab
+
+import (
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/response"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+)
+
+//Types of the commands sent by clients
+const (
+	CommandAddItem    = "add_item"
+	CommandUpdateItem = "update_item"
+	CommandRemoveItem = "remove_item"
+)
+
+//Types of the messages sent by the server
+const (
+	//MessageWelcome is the first message of a session, with the cart and who is in it
+	MessageWelcome = "welcome"
+	//MessageResult answers a command of the client with the resulting cart
+	MessageResult = "result"
+	//MessageError answers a command of the client that failed
+	MessageError = "error"
+	//MessageCartUpdated tells that another participant changed the cart
+	MessageCartUpdated = "cart_updated"
+	//MessagePresence tells that a participant joined or left
+	MessagePresence = "presence"
+)
+
+//Participant is a single connection to a session. The same user may be connected more than once
+type Participant struct {
+	ConnectionID string `json:"connection_id"`
+	UserID       string `json:"user_id"`
+	Name         string `json:"name"`
+}
+
+//Claims is what a session token grants
+type Claims struct {
+	//Tenant is the one the cart belongs to, empty for carts of no tenant
+	Tenant    string `json:"tenant,omitempty"`
+	CartID    string `json:"cart_id"`
+	UserID    string `json:"user_id"`
+	Name      string `json:"name"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type Token struct {
+	Value     string
+	UserID    string
+	ExpiresAt time.Time
+}
+
+//Command is a change of the cart requested by a client
+type Command struct {
+	//ID is chosen by the client to match the result answering the command
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	//ItemID, Options and Note tell the line add_item adds to
+	ItemID  string            `json:"item_id,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
+	Note    string            `json:"note,omitempty"`
+	//LineID is the line update_item and remove_item change, the ID of an item having a single line also works
+	LineID   string `json:"line_id,omitempty"`
+	Quantity int    `json:"quantity"`
+}
+
+//Message is sent by the server to the clients
+type Message struct {
+	Type string `json:"type"`
+	//ID is the one of the command answered, for result and error messages
+	ID           string              `json:"id,omitempty"`
+	Cart         *cart.TransportCart `json:"cart,omitempty"`
+	You          *Participant        `json:"you,omitempty"`
+	By           *Participant        `json:"by,omitempty"`
+	Joined       *Participant        `json:"joined,omitempty"`
+	Left         *Participant        `json:"left,omitempty"`
+	Participants []Participant       `json:"participants,omitempty"`
+	Error        *response.Error     `json:"error,omitempty"`
+}
+
+//envelope is what travels through the broker, Origin being the connection that caused the message
+type envelope struct {
+	Origin  string  `json:"origin"`
+	Message Message `json:"message"`
+}
+
+type TokenRequest struct {
+	Name string `json:"name"`
+}
+
+type TokenResponse struct {
+	Token     string    `json:"token"`
+	UserID    string    `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
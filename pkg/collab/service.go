@@ -0,0 +1,249 @@
+package collab
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/pubsub"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"github.com/google/uuid"
+)
+
+const (
+	presenceKeyPrefix = "cart-presence:"
+	topicPrefix       = "session:"
+	//sendBuffer is how many messages a connection may fall behind before being dropped
+	sendBuffer = 32
+	//PresenceTimeout is how long a participant stays in the session without a heartbeat, a few pings so a late
+	//one doesn't drop anyone
+	PresenceTimeout = 3 * pingPeriod
+)
+
+func presenceKey(cartID string) string {
+	return presenceKeyPrefix + cartID
+}
+
+//topic is where the messages of the session travel. The broker is shared by every tenant, so it is named
+//after the tenant as well as the cart
+func topic(ctx context.Context, cartID string) string {
+	return tenant.Namespace(ctx) + topicPrefix + cartID
+}
+
+//Service manages the collaborative sessions of the carts: who may join them, who is in them,
+//and the messages shared between their participants across every instance
+type Service interface {
+	//IssueToken gives a token granting name access to the session of the cart
+	IssueToken(ctx context.Context, cartID, name string) (Token, error)
+	//Authenticate checks the token was issued for the cart, within the tenant of the context, and has not expired
+	Authenticate(ctx context.Context, token, cartID string) (Claims, error)
+	//Join adds the participant to the session, giving everyone in it
+	Join(ctx context.Context, cartID string, p Participant) ([]Participant, error)
+	Leave(ctx context.Context, cartID string, p Participant) error
+	//Heartbeat tells the participant is still connected. Participants without one for PresenceTimeout are dropped
+	//from the session, as the instance serving them may have stopped without them leaving
+	Heartbeat(ctx context.Context, cartID string, p Participant) error
+	//Broadcast sends the message to every participant of the session, origin being the connection sending it
+	Broadcast(ctx context.Context, cartID, origin string, m Message) error
+	//Subscribe follows the messages broadcasted in the session
+	Subscribe(ctx context.Context, cartID string) *pubsub.Subscription
+}
+
+type service struct {
+	logger logger.Logger
+	cache  cache.Cache
+	broker pubsub.Broker
+	secret []byte
+	ttl    time.Duration
+}
+
+//NewService gives a Service signing its tokens with secret, valid for ttl
+func NewService(logger logger.Logger, c cache.Cache, broker pubsub.Broker, secret []byte, ttl time.Duration) Service {
+	return &service{
+		logger: logger,
+		cache:  c,
+		broker: broker,
+		secret: secret,
+		ttl:    ttl,
+	}
+}
+
+func (s *service) IssueToken(ctx context.Context, cartID, name string) (Token, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Token{}, errors.ServiceError{Code: errors.ValidationErrorCode}.
+			WithFieldViolation("name", "must not be empty")
+	}
+
+	t, _ := tenant.FromContext(ctx)
+	claims := Claims{
+		Tenant:    t.ID,
+		CartID:    cartID,
+		UserID:    uuid.New().String(),
+		Name:      name,
+		ExpiresAt: time.Now().Add(s.ttl).Unix(),
+	}
+	b, err := json.Marshal(claims)
+	if err != nil {
+		return Token{}, err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+
+	s.logger.WithField("cart_id", cartID).WithField("user_id", claims.UserID).Info(ctx, "Issuing session token")
+	return Token{
+		Value:     payload + "." + s.sign(payload),
+		UserID:    claims.UserID,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC(),
+	}, nil
+}
+
+func (s *service) Authenticate(ctx context.Context, token, cartID string) (Claims, error) {
+	unauthorized := errors.ServiceError{Code: errors.UnauthorizedCode}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(s.sign(parts[0])), []byte(parts[1])) {
+		return Claims{}, unauthorized
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return Claims{}, unauthorized.WithCause(err)
+	}
+	claims := Claims{}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return Claims{}, unauthorized.WithCause(err)
+	}
+	t, _ := tenant.FromContext(ctx)
+	if claims.Tenant != t.ID || claims.CartID != cartID || time.Now().Unix() >= claims.ExpiresAt {
+		return Claims{}, unauthorized
+	}
+	return claims, nil
+}
+
+func (s *service) Join(ctx context.Context, cartID string, p Participant) ([]Participant, error) {
+	participants, err := s.updatePresence(ctx, cartID, func(present []presence) []presence {
+		return append(without(present, p), presence{Participant: p, SeenAt: time.Now().UTC()})
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.Broadcast(ctx, cartID, p.ConnectionID, Message{
+		Type:         MessagePresence,
+		Joined:       &p,
+		Participants: participants,
+	})
+	if err != nil {
+		s.logger.WithField("cart_id", cartID).WithError(err).Warn(ctx, "Unable to announce participant")
+	}
+	return participants, nil
+}
+
+func (s *service) Leave(ctx context.Context, cartID string, p Participant) error {
+	participants, err := s.updatePresence(ctx, cartID, func(present []presence) []presence {
+		return without(present, p)
+	})
+	if err != nil {
+		return err
+	}
+	return s.Broadcast(ctx, cartID, p.ConnectionID, Message{
+		Type:         MessagePresence,
+		Left:         &p,
+		Participants: participants,
+	})
+}
+
+func (s *service) Heartbeat(ctx context.Context, cartID string, p Participant) error {
+	_, err := s.updatePresence(ctx, cartID, func(present []presence) []presence {
+		for idx := range present {
+			if present[idx].ConnectionID == p.ConnectionID {
+				present[idx].SeenAt = time.Now().UTC()
+				return present
+			}
+		}
+		//dropped while its heartbeats were late, it is still connected
+		return append(present, presence{Participant: p, SeenAt: time.Now().UTC()})
+	})
+	return err
+}
+
+func (s *service) Broadcast(ctx context.Context, cartID, origin string, m Message) error {
+	b, err := json.Marshal(envelope{
+		Origin:  origin,
+		Message: m,
+	})
+	if err != nil {
+		return err
+	}
+	return s.broker.Publish(ctx, topic(ctx, cartID), b)
+}
+
+func (s *service) Subscribe(ctx context.Context, cartID string) *pubsub.Subscription {
+	return s.broker.Subscribe(topic(ctx, cartID), sendBuffer)
+}
+
+//presence is a participant as stored in the session, with when it was last heard of
+type presence struct {
+	Participant
+	SeenAt time.Time `json:"seen_at"`
+}
+
+//without gives the participants in the session but p
+func without(present []presence, p Participant) []presence {
+	res := make([]presence, 0, len(present))
+	for _, e := range present {
+		if e.ConnectionID != p.ConnectionID {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+//updatePresence applies change to the participants in the session, once those without a heartbeat for
+//PresenceTimeout are pruned, giving everyone in it in the order they joined. The change is only stored when the
+//session is unchanged since read, and applied again otherwise, so concurrent joins and leaves are all kept
+func (s *service) updatePresence(ctx context.Context, cartID string, change func([]presence) []presence) ([]Participant, error) {
+	for ctx.Err() == nil {
+		raw, present := json.RawMessage{}, []presence{}
+		err := s.cache.Get(ctx, presenceKey(cartID), &raw)
+		if cache.IsNotFound(err) {
+			raw = nil
+		} else if err != nil {
+			return nil, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+		} else if err := json.Unmarshal(raw, &present); err != nil {
+			return nil, err
+		}
+
+		live := make([]presence, 0, len(present))
+		for _, e := range present {
+			if time.Since(e.SeenAt) < PresenceTimeout {
+				live = append(live, e)
+			}
+		}
+		live = change(live)
+		stored, err := s.cache.CompareAndSet(ctx, presenceKey(cartID), raw, live)
+		if err != nil {
+			return nil, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+		}
+		if !stored {
+			continue
+		}
+		participants := make([]Participant, len(live))
+		for idx, e := range live {
+			participants[idx] = e.Participant
+		}
+		return participants, nil
+	}
+	return nil, ctx.Err()
+}
+
+func (s *service) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,164 @@
+package collab_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/pubsub"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/collab"
+	"github.com/stretchr/testify/assert"
+)
+
+var testLogger = logger.NewLogger("collab unit test", false)
+
+func newService(ttl time.Duration) collab.Service {
+	return collab.NewService(testLogger, cache.NewMemoryCache(), pubsub.NewMemoryBroker(), []byte("secret"), ttl)
+}
+
+func TestToken_OK(t *testing.T) {
+	svc := newService(time.Minute)
+
+	token, err := svc.IssueToken(context.TODO(), "someCart", "Ana")
+	assert.Nil(t, err)
+
+	claims, err := svc.Authenticate(context.TODO(), token.Value, "someCart")
+	assert.Nil(t, err)
+	assert.Equal(t, "Ana", claims.Name)
+	assert.Equal(t, token.UserID, claims.UserID)
+}
+
+func TestToken_Rejected(t *testing.T) {
+	svc := newService(time.Minute)
+	token, _ := svc.IssueToken(context.TODO(), "someCart", "Ana")
+	unauthorized := errors.ServiceError{Code: errors.UnauthorizedCode}
+
+	_, err := svc.Authenticate(context.TODO(), token.Value, "otherCart")
+	assert.ErrorIs(t, err, unauthorized, "a token is only valid for its cart")
+
+	_, err = svc.Authenticate(context.TODO(), token.Value+"x", "someCart")
+	assert.ErrorIs(t, err, unauthorized, "a tampered token must be rejected")
+
+	other := collab.NewService(testLogger, cache.NewMemoryCache(), pubsub.NewMemoryBroker(), []byte("other"), time.Minute)
+	_, err = other.Authenticate(context.TODO(), token.Value, "someCart")
+	assert.ErrorIs(t, err, unauthorized, "a token signed with another secret must be rejected")
+
+	_, err = svc.Authenticate(context.TODO(), "", "someCart")
+	assert.ErrorIs(t, err, unauthorized)
+}
+
+func TestToken_Expired(t *testing.T) {
+	svc := newService(-time.Second)
+	token, _ := svc.IssueToken(context.TODO(), "someCart", "Ana")
+
+	_, err := svc.Authenticate(context.TODO(), token.Value, "someCart")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.UnauthorizedCode})
+}
+
+func TestToken_NameRequired(t *testing.T) {
+	svc := newService(time.Minute)
+
+	_, err := svc.IssueToken(context.TODO(), "someCart", "  ")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ValidationErrorCode})
+}
+
+func TestPresence(t *testing.T) {
+	svc := newService(time.Minute)
+	ana := collab.Participant{ConnectionID: "1", UserID: "a", Name: "Ana"}
+	bob := collab.Participant{ConnectionID: "2", UserID: "b", Name: "Bob"}
+
+	svc.Join(context.TODO(), "someCart", ana)
+	participants, err := svc.Join(context.TODO(), "someCart", bob)
+	assert.Nil(t, err)
+	assert.Equal(t, []collab.Participant{ana, bob}, participants)
+
+	sub := svc.Subscribe(context.TODO(), "someCart")
+	defer sub.Close()
+	assert.Nil(t, svc.Leave(context.TODO(), "someCart", ana))
+	assert.Contains(t, string(<-sub.C), `"left":{"connection_id":"1"`)
+
+	participants, _ = svc.Join(context.TODO(), "someCart", ana)
+	assert.Equal(t, []collab.Participant{bob, ana}, participants)
+}
+
+func TestPresence_ExpiresWithoutHeartbeats(t *testing.T) {
+	c := cache.NewMemoryCache()
+	svc := collab.NewService(testLogger, c, pubsub.NewMemoryBroker(), []byte("secret"), time.Minute)
+	ana := collab.Participant{ConnectionID: "1", UserID: "a", Name: "Ana"}
+	bob := collab.Participant{ConnectionID: "2", UserID: "b", Name: "Bob"}
+	ghost := collab.Participant{ConnectionID: "3", UserID: "g", Name: "Ghost"}
+	//connected to an instance that stopped, its heartbeats stopped long ago
+	c.Set(context.TODO(), "cart-presence:someCart", []map[string]interface{}{
+		{"connection_id": ghost.ConnectionID, "user_id": ghost.UserID, "name": ghost.Name, "seen_at": time.Now().Add(-collab.PresenceTimeout)},
+		{"connection_id": ana.ConnectionID, "user_id": ana.UserID, "name": ana.Name, "seen_at": time.Now()},
+	})
+
+	participants, err := svc.Join(context.TODO(), "someCart", bob)
+	assert.Nil(t, err)
+	assert.Equal(t, []collab.Participant{ana, bob}, participants)
+
+	//a heartbeat brings back a participant dropped while its heartbeats were late
+	assert.Nil(t, svc.Heartbeat(context.TODO(), "someCart", ghost))
+	participants, _ = svc.Join(context.TODO(), "someCart", ana)
+	assert.Equal(t, []collab.Participant{bob, ghost, ana}, participants)
+}
+
+func TestPresence_ConcurrentJoinsAreKept(t *testing.T) {
+	svc := newService(time.Minute)
+
+	wg := sync.WaitGroup{}
+	for idx := 0; idx < 20; idx++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			_, err := svc.Join(context.TODO(), "someCart", collab.Participant{ConnectionID: fmt.Sprint(idx)})
+			assert.Nil(t, err)
+		}(idx)
+	}
+	wg.Wait()
+
+	participants, _ := svc.Join(context.TODO(), "someCart", collab.Participant{ConnectionID: "last"})
+	assert.Len(t, participants, 21)
+}
+
+func TestToken_TenantOfTheCart(t *testing.T) {
+	svc := newService(time.Minute)
+	acme := tenant.NewContext(context.TODO(), tenant.Tenant{ID: "acme"})
+	globex := tenant.NewContext(context.TODO(), tenant.Tenant{ID: "globex"})
+	token, _ := svc.IssueToken(acme, "someCart", "Ana")
+	unauthorized := errors.ServiceError{Code: errors.UnauthorizedCode}
+
+	claims, err := svc.Authenticate(acme, token.Value, "someCart")
+	assert.Nil(t, err)
+	assert.Equal(t, "acme", claims.Tenant)
+
+	_, err = svc.Authenticate(globex, token.Value, "someCart")
+	assert.ErrorIs(t, err, unauthorized, "a token is only valid within its tenant")
+	_, err = svc.Authenticate(context.TODO(), token.Value, "someCart")
+	assert.ErrorIs(t, err, unauthorized, "a token is only valid within its tenant")
+}
+
+func TestBroadcastsStayWithinTheTenant(t *testing.T) {
+	svc := newService(time.Minute)
+	acme := tenant.NewContext(context.TODO(), tenant.Tenant{ID: "acme"})
+	globex := tenant.NewContext(context.TODO(), tenant.Tenant{ID: "globex"})
+
+	acmeSub := svc.Subscribe(acme, "someCart")
+	defer acmeSub.Close()
+	globexSub := svc.Subscribe(globex, "someCart")
+	defer globexSub.Close()
+
+	assert.Nil(t, svc.Broadcast(globex, "someCart", "1", collab.Message{Type: collab.MessageCartUpdated}))
+	assert.Contains(t, string(<-globexSub.C), collab.MessageCartUpdated)
+	select {
+	case b := <-acmeSub.C:
+		t.Fatalf("The broadcast of another tenant was received: %s", b)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,97 @@
+package currency
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+)
+
+//DefaultRefreshInterval is how often the rates are fetched again from the provider
+const DefaultRefreshInterval = time.Hour
+
+//Converter keeps the last rates of the provider, refreshed every interval, to convert from the base currency
+type Converter struct {
+	logger   logger.Logger
+	provider Provider
+	interval time.Duration
+
+	mu    sync.RWMutex
+	rates *Rates
+}
+
+func NewConverter(logger logger.Logger, provider Provider, interval time.Duration) *Converter {
+	return &Converter{
+		logger:   logger,
+		provider: provider,
+		interval: interval,
+	}
+}
+
+//Refresh fetches the rates from the provider, the previous ones are kept when it fails
+func (c *Converter) Refresh(ctx context.Context) error {
+	rates, err := c.provider.Rates(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.rates = &rates
+	c.mu.Unlock()
+	c.logger.WithField("base", rates.Base).WithField("updated_at", rates.UpdatedAt).Info(ctx, "Exchange rates refreshed")
+	return nil
+}
+
+//Run refreshes the rates every interval until ctx is done
+func (c *Converter) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := c.Refresh(ctx); err != nil {
+			c.logger.WithError(err).Warn(ctx, "Unable to refresh exchange rates, keeping the previous ones")
+		}
+	}
+}
+
+//Rate gives the rate from the base currency to the currency, which may be the base itself
+func (c *Converter) Rate(currency string) (Rate, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	c.mu.RLock()
+	rates := c.rates
+	c.mu.RUnlock()
+	if rates == nil {
+		return Rate{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}.
+			WithDetail("reason", "exchange rates are not loaded")
+	}
+	if currency == rates.Base {
+		return Rate{From: rates.Base, To: rates.Base, Value: 1, AsOf: rates.UpdatedAt}, nil
+	}
+	value, ok := rates.Rates[currency]
+	if !ok {
+		return Rate{}, unsupported(currency)
+	}
+	return Rate{From: rates.Base, To: currency, Value: value, AsOf: rates.UpdatedAt}, nil
+}
+
+//Base gives the currency the catalog is priced in, empty until the rates are loaded
+func (c *Converter) Base() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.rates == nil {
+		return ""
+	}
+	return c.rates.Base
+}
+
+func unsupported(currency string) error {
+	return errors.ServiceError{Code: errors.ValidationErrorCode}.
+		WithFieldViolation("currency", "is not supported").
+		WithDetail("currency", currency)
+}
@@ -0,0 +1,88 @@
+package currency_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/currency"
+	"github.com/stretchr/testify/assert"
+)
+
+func fixtureConverter(t *testing.T, path string) *currency.Converter {
+	c := currency.NewConverter(logger.NewLogger("currency unit testing", false), currency.NewFileProvider(path), currency.DefaultRefreshInterval)
+	assert.Nil(t, c.Refresh(context.TODO()))
+	return c
+}
+
+func TestRate(t *testing.T) {
+	c := fixtureConverter(t, "testdata/rates.json")
+	assert.Equal(t, "USD", c.Base())
+
+	rate, err := c.Rate("eur")
+	assert.Nil(t, err)
+	assert.Equal(t, "USD", rate.From)
+	assert.Equal(t, "EUR", rate.To)
+	assert.Equal(t, 0.9, rate.Value)
+	assert.Equal(t, 2026, rate.AsOf.Year())
+
+	base, err := c.Rate("USD")
+	assert.Nil(t, err)
+	assert.False(t, base.Converts())
+
+	_, err = c.Rate("XYZ")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ValidationErrorCode})
+}
+
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		name   string
+		rate   currency.Rate
+		amount float32
+		want   float32
+	}{
+		{"zero rate converts nothing", currency.Rate{}, 12.34, 12.34},
+		{"same currency converts nothing", currency.Rate{From: "USD", To: "USD", Value: 1}, 12.34, 12.34},
+		{"rounds to cents", currency.Rate{From: "USD", To: "EUR", Value: 0.9}, 10.99, 9.89},
+		{"rounds to whole units without minor units", currency.Rate{From: "USD", To: "JPY", Value: 150.25}, 10.99, 1651},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.rate.Convert(tc.amount))
+		})
+	}
+}
+
+func TestRefreshKeepsPreviousRatesOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rates.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"base":"USD","rates":{"EUR":0.9}}`), 0600))
+	c := fixtureConverter(t, path)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"base":"USD","rates":{"EUR":-1}}`), 0600))
+	assert.NotNil(t, c.Refresh(context.TODO()))
+	rate, err := c.Rate("EUR")
+	assert.Nil(t, err)
+	assert.Equal(t, 0.9, rate.Value)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"base":"USD","rates":{"EUR":0.95}}`), 0600))
+	assert.Nil(t, c.Refresh(context.TODO()))
+	rate, err = c.Rate("EUR")
+	assert.Nil(t, err)
+	assert.Equal(t, 0.95, rate.Value)
+}
+
+func TestRateBeforeLoading(t *testing.T) {
+	c := currency.NewConverter(logger.NewLogger("currency unit testing", false), currency.NewFileProvider("missing.json"), currency.DefaultRefreshInterval)
+	assert.NotNil(t, c.Refresh(context.TODO()))
+
+	_, err := c.Rate("EUR")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ExternalApiErrorCode})
+}
+
+func TestLoadSampleRates(t *testing.T) {
+	fixtureConverter(t, "../../config/exchange_rates.json")
+}
@@ -0,0 +1,87 @@
+package currency
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/response"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+)
+
+const (
+	//Header asks for the amounts of a response in a currency
+	Header = "Accept-Currency"
+	//QueryParam asks for the amounts of a response in a currency, winning over Header
+	QueryParam = "currency"
+)
+
+type rateContextKey struct{}
+
+//ContextWithRate gives a context carrying the rate the amounts of the response are converted with
+func ContextWithRate(ctx context.Context, rate Rate) context.Context {
+	return context.WithValue(ctx, rateContextKey{}, rate)
+}
+
+//RateFromContext gives the rate carried by the context, the zero Rate converting nothing when there is none
+func RateFromContext(ctx context.Context) Rate {
+	rate, _ := ctx.Value(rateContextKey{}).(Rate)
+	return rate
+}
+
+//FromRequest gives the currency the request asks for, empty when it asks for none
+func FromRequest(r *http.Request) string {
+	if c := r.URL.Query().Get(QueryParam); c != "" {
+		return strings.ToUpper(strings.TrimSpace(c))
+	}
+	return strings.ToUpper(strings.TrimSpace(r.Header.Get(Header)))
+}
+
+//Middleware keeps in the request context the rate to the currency the request asks for, answering
+//requests asking for unsupported currencies with a validation error. Requests asking for none get the
+//currency of their tenant, or the base one. Without a converter only the prices of the catalog are given,
+//so asking for any currency fails
+func Middleware(c *Converter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", Header)
+			requested := FromRequest(r)
+			if c == nil && requested != "" {
+				response.RespondWithError(w, r, unsupported(requested))
+				return
+			}
+			if requested == "" && c != nil {
+				//the amounts are given in the currency of the tenant or in the base one, telling it
+				requested = c.Base()
+				if t, ok := tenant.FromContext(r.Context()); ok && t.Currency != "" {
+					requested = t.Currency
+				}
+			}
+			if requested == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rate, err := c.Rate(requested)
+			if err != nil {
+				response.RespondWithError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ContextWithRate(r.Context(), rate)))
+		})
+	}
+}
+
+//Meta tells the response its amounts were converted with the rate, nothing when they were not
+func (r Rate) Meta() response.MetaOption {
+	return func(m *response.Meta) {
+		if !r.Converts() {
+			return
+		}
+		m.ExchangeRate = &response.ExchangeRate{
+			From: r.From,
+			To:   r.To,
+			Rate: r.Value,
+			AsOf: r.AsOf,
+		}
+	}
+}
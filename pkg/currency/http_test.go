@@ -0,0 +1,80 @@
+package currency_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/response"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/currency"
+	"github.com/stretchr/testify/assert"
+)
+
+//serveWithRate runs the middleware of the converter, giving the rate handed to the next handler
+func serveWithRate(c *currency.Converter, req *http.Request) (*httptest.ResponseRecorder, currency.Rate) {
+	var got currency.Rate
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = currency.RateFromContext(r.Context())
+		response.RespondWithData(w, http.StatusOK, "ok", got.Meta())
+	})
+	rr := httptest.NewRecorder()
+	currency.Middleware(c)(next).ServeHTTP(rr, req)
+	return rr, got
+}
+
+func TestMiddlewareQueryWinsOverHeader(t *testing.T) {
+	c := fixtureConverter(t, "testdata/rates.json")
+	req := httptest.NewRequest(http.MethodGet, "/items/available?currency=jpy", nil)
+	req.Header.Set(currency.Header, "EUR")
+
+	rr, rate := serveWithRate(c, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "JPY", rate.To)
+	assert.Equal(t, currency.Header, rr.Header().Get("Vary"))
+	assert.Contains(t, rr.Body.String(), `"exchange_rate":{"from":"USD","to":"JPY","rate":150.25`)
+}
+
+func TestMiddlewareDefaultsToBase(t *testing.T) {
+	c := fixtureConverter(t, "testdata/rates.json")
+
+	rr, rate := serveWithRate(c, httptest.NewRequest(http.MethodGet, "/items/available", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "USD", rate.To)
+	assert.NotContains(t, rr.Body.String(), "exchange_rate")
+}
+
+func TestMiddlewareDefaultsToTheCurrencyOfTheTenant(t *testing.T) {
+	c := fixtureConverter(t, "testdata/rates.json")
+	req := httptest.NewRequest(http.MethodGet, "/items/available", nil)
+	req = req.WithContext(tenant.NewContext(req.Context(), tenant.Tenant{ID: "acme", Currency: "EUR"}))
+
+	_, rate := serveWithRate(c, req)
+	assert.Equal(t, "EUR", rate.To)
+
+	//asking for a currency still wins
+	req.Header.Set(currency.Header, "JPY")
+	_, rate = serveWithRate(c, req)
+	assert.Equal(t, "JPY", rate.To)
+}
+
+func TestMiddlewareUnsupportedCurrency(t *testing.T) {
+	c := fixtureConverter(t, "testdata/rates.json")
+	req := httptest.NewRequest(http.MethodGet, "/items/available", nil)
+	req.Header.Set(currency.Header, "XYZ")
+
+	rr, _ := serveWithRate(c, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestMiddlewareWithoutConverter(t *testing.T) {
+	rr, rate := serveWithRate(nil, httptest.NewRequest(http.MethodGet, "/items/available", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.False(t, rate.Converts())
+
+	rr, _ = serveWithRate(nil, httptest.NewRequest(http.MethodGet, "/items/available?currency=EUR", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,48 @@
+package currency
+
+import (
+	"math"
+	"time"
+)
+
+//Rates are the exchange rates from the Base currency to every other one, as of UpdatedAt
+type Rates struct {
+	Base      string             `json:"base"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	Rates     map[string]float64 `json:"rates"`
+}
+
+//Rate converts amounts From a currency To another. The zero Rate converts nothing
+type Rate struct {
+	From  string
+	To    string
+	Value float64
+	AsOf  time.Time
+}
+
+//zeroDecimal are the currencies without minor units, their amounts are rounded to whole units
+var zeroDecimal = map[string]bool{
+	"CLP": true,
+	"ISK": true,
+	"JPY": true,
+	"KRW": true,
+	"PYG": true,
+	"VND": true,
+}
+
+//Converts tells the rate changes the currency of the amounts
+func (r Rate) Converts() bool {
+	return r.Value != 0 && r.From != r.To
+}
+
+//Convert gives the amount in the To currency, rounded to its minor units
+func (r Rate) Convert(amount float32) float32 {
+	if !r.Converts() {
+		return amount
+	}
+	scale := 100.0
+	if zeroDecimal[r.To] {
+		scale = 1
+	}
+	return float32(math.Round(float64(amount)*r.Value*scale) / scale)
+}
@@ -0,0 +1,59 @@
+package currency
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+)
+
+var code = regexp.MustCompile(`^[A-Z]{3}$`)
+
+//Provider gives the current exchange rates, from a file or a rates API
+type Provider interface {
+	Rates(ctx context.Context) (Rates, error)
+}
+
+type fileProvider struct {
+	path string
+}
+
+//NewFileProvider gives a Provider reading the rates of the JSON file at path every time they are asked for,
+//so changes to the file are seen on the next refresh
+func NewFileProvider(path string) Provider {
+	return &fileProvider{
+		path: path,
+	}
+}
+
+func (p *fileProvider) Rates(ctx context.Context) (Rates, error) {
+	b, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return Rates{}, err
+	}
+	rates := Rates{}
+	if err := json.Unmarshal(b, &rates); err != nil {
+		return Rates{}, fmt.Errorf("unable to parse exchange rates %s: %w", p.path, err)
+	}
+	if err := rates.Validate(); err != nil {
+		return Rates{}, fmt.Errorf("invalid exchange rates %s: %w", p.path, err)
+	}
+	return rates, nil
+}
+
+//Validate checks the currencies are ISO 4217 codes and every rate is positive
+func (r Rates) Validate() error {
+	if !code.MatchString(r.Base) {
+		return fmt.Errorf("base %q is not an ISO 4217 code", r.Base)
+	}
+	for currency, rate := range r.Rates {
+		if !code.MatchString(currency) {
+			return fmt.Errorf("%q is not an ISO 4217 code", currency)
+		}
+		if rate <= 0 {
+			return fmt.Errorf("rate of %s must be positive", currency)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//DefaultCheckTimeout bounds a check registered without an explicit timeout
+const DefaultCheckTimeout = 2 * time.Second
+
+//Checker verifies that a single dependency is working
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type checkerFunc struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+//NewChecker gives a Checker out of a plain function
+func NewChecker(name string, fn func(ctx context.Context) error) Checker {
+	return &checkerFunc{
+		name: name,
+		fn:   fn,
+	}
+}
+
+func (c *checkerFunc) Name() string {
+	return c.name
+}
+
+func (c *checkerFunc) Check(ctx context.Context) error {
+	return c.fn(ctx)
+}
+
+//CheckOption customizes how a registered check is run
+type CheckOption func(*registeredCheck)
+
+//WithTimeout sets how long the check may take before being considered failed
+func WithTimeout(d time.Duration) CheckOption {
+	return func(r *registeredCheck) {
+		r.timeout = d
+	}
+}
+
+//NonCritical makes the check informative only, a failure does not turn the service not ready
+func NonCritical() CheckOption {
+	return func(r *registeredCheck) {
+		r.critical = false
+	}
+}
+
+type registeredCheck struct {
+	checker  Checker
+	timeout  time.Duration
+	critical bool
+
+	mu          sync.Mutex
+	lastError   string
+	lastErrorAt time.Time
+	lastSuccess time.Time
+}
+
+//run executes the check bounded by its timeout and keeps track of its last outcome
+func (r *registeredCheck) run(ctx context.Context) Health {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- r.checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out after %s", r.timeout)
+	}
+	latency := time.Since(start)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.lastError = err.Error()
+		r.lastErrorAt = time.Now()
+	} else {
+		r.lastSuccess = time.Now()
+	}
+
+	return Health{
+		Name:        r.checker.Name(),
+		Alive:       err == nil,
+		Critical:    r.critical,
+		Latency:     latency,
+		LastError:   r.lastError,
+		LastErrorAt: r.lastErrorAt,
+		LastSuccess: r.lastSuccess,
+	}
+}
+
+//Registry holds the checks of every dependency of the service
+type Registry struct {
+	mu     sync.RWMutex
+	checks []*registeredCheck
+}
+
+//Register adds a check to the registry, checks are critical and bounded by DefaultCheckTimeout unless told otherwise
+func (r *Registry) Register(c Checker, opts ...CheckOption) {
+	rc := &registeredCheck{
+		checker:  c,
+		timeout:  DefaultCheckTimeout,
+		critical: true,
+	}
+	for _, opt := range opts {
+		opt(rc)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, rc)
+}
+
+//Run executes every registered check concurrently. The report is alive when every critical check passed
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make([]*registeredCheck, len(r.checks))
+	copy(checks, r.checks)
+	r.mu.RUnlock()
+
+	results := make([]Health, len(checks))
+	wg := sync.WaitGroup{}
+	for idx, c := range checks {
+		wg.Add(1)
+		go func(idx int, c *registeredCheck) {
+			defer wg.Done()
+			results[idx] = c.run(ctx)
+		}(idx, c)
+	}
+	wg.Wait()
+
+	report := Report{
+		Alive:    true,
+		Services: results,
+	}
+	for _, h := range results {
+		if h.Critical && !h.Alive {
+			report.Alive = false
+		}
+	}
+	return report
+}
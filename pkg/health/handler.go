@@ -10,29 +10,25 @@ type Handler struct {
 	Service Service
 }
 
-//Health is the handler for the health endpoint
+//Health is the handler for the health endpoint, it reports every dependency like Readiness does
 func (c *Handler) Health(w http.ResponseWriter, r *http.Request) {
-	//using lower level pkg to do the logic
-	service, external, db, err := c.Service.HealthCheck(r.Context())
-	if err != nil {
-		response.RespondWithError(w, response.StandardInternalServerError)
-		return
-	}
-	hr := HealthResponse{
-		Services: []TransportHealth{
-			{
-				Name:  "service",
-				Alive: service,
-			},
-			{
-				Name:  "external",
-				Alive: external,
-			},
-			{
-				Name:  "cache",
-				Alive: db,
-			},
-		},
+	c.Readiness(w, r)
+}
+
+//Liveness answers 200 as long as the process is able to serve requests
+func (c *Handler) Liveness(w http.ResponseWriter, r *http.Request) {
+	respondWithReport(w, c.Service.Liveness(r.Context()))
+}
+
+//Readiness answers 503 when any critical dependency is failing
+func (c *Handler) Readiness(w http.ResponseWriter, r *http.Request) {
+	respondWithReport(w, c.Service.Readiness(r.Context()))
+}
+
+func respondWithReport(w http.ResponseWriter, report Report) {
+	status := http.StatusOK
+	if !report.Alive {
+		status = http.StatusServiceUnavailable
 	}
-	response.RespondWithData(w, http.StatusOK, hr)
+	response.RespondWithData(w, status, ReportToTransportModel(report))
 }
@@ -2,7 +2,6 @@ package health_test
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -44,21 +43,68 @@ func TestHealth_ServiceError(t *testing.T) {
 
 	res := rr.Result()
 
-	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+}
+
+func TestLiveness_OKWhenDependenciesFail(t *testing.T) {
+	h := health.Handler{
+		Service: &serviceMock{
+			shouldFail: true,
+		},
+	}
+
+	req, err := http.NewRequest("GET", "/livez", nil)
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.Liveness(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestReadiness_ServiceUnavailable(t *testing.T) {
+	h := health.Handler{
+		Service: &serviceMock{
+			shouldFail: true,
+		},
+	}
+
+	req, err := http.NewRequest("GET", "/readyz", nil)
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+
+	h.Readiness(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
 }
 
 // mocks
 
 type serviceMock struct {
-	shouldFail     bool
-	serviceStatus  bool
-	externalStatus bool
-	cacheStatus    bool
+	shouldFail bool
 }
 
-func (s *serviceMock) HealthCheck(ctx context.Context) (service, external, cache bool, err error) {
-	if s.shouldFail {
-		return s.serviceStatus, s.externalStatus, s.cacheStatus, fmt.Errorf("service asked to fail")
+func (s *serviceMock) Register(c health.Checker, opts ...health.CheckOption) {}
+
+func (s *serviceMock) Liveness(ctx context.Context) health.Report {
+	return health.Report{
+		Alive:    true,
+		Services: []health.Health{{Name: "service", Alive: true}},
+	}
+}
+
+func (s *serviceMock) Readiness(ctx context.Context) health.Report {
+	return health.Report{
+		Alive: !s.shouldFail,
+		Services: []health.Health{
+			{Name: "service", Alive: true},
+			{Name: "cache", Alive: !s.shouldFail, LastError: "cache down"},
+		},
 	}
-	return s.serviceStatus, s.externalStatus, s.cacheStatus, nil
 }
@@ -1,14 +1,60 @@
 package health
 
+import "time"
+
 type Health struct {
 	Name  string
 	Alive bool
+	//Critical checks make the service not ready when failing
+	Critical    bool
+	Latency     time.Duration
+	LastError   string
+	LastErrorAt time.Time
+	LastSuccess time.Time
+}
+
+//Report is the outcome of running a set of checks
+type Report struct {
+	Alive    bool
+	Services []Health
 }
 
 type TransportHealth struct {
-	Name  string `json:"name"`
-	Alive bool   `json:"alive"`
+	Name          string     `json:"name"`
+	Alive         bool       `json:"alive"`
+	Critical      bool       `json:"critical"`
+	LatencyMs     float64    `json:"latency_ms"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
 }
 type HealthResponse struct {
+	Alive    bool              `json:"alive"`
 	Services []TransportHealth `json:"services"`
 }
+
+func ReportToTransportModel(r Report) HealthResponse {
+	res := HealthResponse{
+		Alive:    r.Alive,
+		Services: []TransportHealth{},
+	}
+	for _, h := range r.Services {
+		th := TransportHealth{
+			Name:      h.Name,
+			Alive:     h.Alive,
+			Critical:  h.Critical,
+			LatencyMs: float64(h.Latency.Microseconds()) / 1000,
+			LastError: h.LastError,
+		}
+		if !h.LastErrorAt.IsZero() {
+			t := h.LastErrorAt
+			th.LastErrorAt = &t
+		}
+		if !h.LastSuccess.IsZero() {
+			t := h.LastSuccess
+			th.LastSuccessAt = &t
+		}
+		res.Services = append(res.Services, th)
+	}
+	return res
+}
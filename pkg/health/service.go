@@ -3,38 +3,58 @@ package health
 import (
 	"context"
 
-	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
-	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
 )
 
 //Service is the interface for the health
 type Service interface {
-	HealthCheck(ctx context.Context) (service, external, cache bool, err error)
+	//Register adds the check of a new dependency
+	Register(c Checker, opts ...CheckOption)
+	//Liveness tells whether the process itself is working, without looking at dependencies
+	Liveness(ctx context.Context) Report
+	//Readiness runs every registered check to tell whether the service can take traffic
+	Readiness(ctx context.Context) Report
 }
 
 type svc struct {
-	log   logger.Logger
-	cache cache.Cache
-	item  item.Service
+	log      logger.Logger
+	registry *Registry
 }
 
-//NewService gives a new Service
-func NewService(c cache.Cache, item item.Service, log logger.Logger) Service {
+//NewService gives a new Service without checks, every dependency is added with Register
+func NewService(log logger.Logger) Service {
 	return &svc{
-		log:   log,
-		cache: c,
-		item:  item,
+		log:      log,
+		registry: &Registry{},
 	}
 }
 
-//HealthCheck returns the status of the API and it's components
-func (s *svc) HealthCheck(ctx context.Context) (service, external, cache bool, err error) {
+func (s *svc) Register(c Checker, opts ...CheckOption) {
+	s.registry.Register(c, opts...)
+}
+
+//Liveness returns the status of the API process
+func (s *svc) Liveness(ctx context.Context) Report {
+	return Report{
+		Alive: true,
+		Services: []Health{
+			{
+				Name:     "service",
+				Alive:    true,
+				Critical: true,
+			},
+		},
+	}
+}
+
+//Readiness returns the status of the API and it's components
+func (s *svc) Readiness(ctx context.Context) Report {
 	s.log.Info(ctx, "Performing Health Check")
 
-	external = true
-	if err := s.item.Health(ctx); err != nil {
-		external = false
+	report := s.registry.Run(ctx)
+	report.Services = append(s.Liveness(ctx).Services, report.Services...)
+	if !report.Alive {
+		s.log.Warn(ctx, "Health Check failed")
 	}
-	return true, external, s.cache.Alive(ctx), nil
+	return report
 }
@@ -2,49 +2,129 @@ package health
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
 )
 
+//newService gives a Service checking the cache and the external item provider, as main does
+func newService(c cache.Cache, item item.Service) Service {
+	s := NewService(logger.NewLogger("health svc unit test", false))
+	s.Register(NewChecker("cache", cache.Health(c)))
+	s.Register(NewChecker("external", item.Health))
+	return s
+}
+
 func TestHealthCheck(t *testing.T) {
-	service := NewService(
-		&cacheMocked{cacheShouldFail: false},
-		&externalAPIMocked{externalAPIShouldFail: false},
-		logger.NewLogger("health svc unit test", false),
-	)
-	s, e, d, err := service.HealthCheck(context.TODO())
-	if s != true || e != true || d != true || err != nil {
-		t.Errorf("Unexpected values from method: service %t, db %t, error %s", s, d, err)
+	service := newService(&cacheMocked{cacheShouldFail: false}, &externalAPIMocked{externalAPIShouldFail: false})
+	r := service.Readiness(context.TODO())
+	s, e, d := statusOf(r, "service"), statusOf(r, "external"), statusOf(r, "cache")
+	if s != true || e != true || d != true || r.Alive != true {
+		t.Errorf("Unexpected values from method: service %t, external %t, db %t, alive %t", s, e, d, r.Alive)
 	}
 }
 
 func TestHealthCheck_CacheFail(t *testing.T) {
-	service := NewService(
-		&cacheMocked{cacheShouldFail: true},
-		&externalAPIMocked{externalAPIShouldFail: false},
-		logger.NewLogger("health svc unit test", false),
-	)
+	service := newService(&cacheMocked{cacheShouldFail: true}, &externalAPIMocked{externalAPIShouldFail: false})
 
-	s, e, d, err := service.HealthCheck(context.TODO())
-	if s != true || e != true || d != false || err != nil {
-		t.Errorf("Unexpected values from method: service %t, db %t, error %s", s, d, err)
+	r := service.Readiness(context.TODO())
+	s, e, d := statusOf(r, "service"), statusOf(r, "external"), statusOf(r, "cache")
+	if s != true || e != true || d != false || r.Alive != false {
+		t.Errorf("Unexpected values from method: service %t, external %t, db %t, alive %t", s, e, d, r.Alive)
 	}
 }
 
 func TestHealthCheck_ExternalFail(t *testing.T) {
-	service := NewService(
-		&cacheMocked{cacheShouldFail: false},
-		&externalAPIMocked{externalAPIShouldFail: true},
-		logger.NewLogger("health svc unit test", false),
-	)
+	service := newService(&cacheMocked{cacheShouldFail: false}, &externalAPIMocked{externalAPIShouldFail: true})
+
+	r := service.Readiness(context.TODO())
+	s, e, d := statusOf(r, "service"), statusOf(r, "external"), statusOf(r, "cache")
+	if s != true || e != false || d != true || r.Alive != false {
+		t.Errorf("Unexpected values from method: service %t, external %t, db %t, alive %t", s, e, d, r.Alive)
+	}
+}
+
+func TestLiveness_IgnoresDependencies(t *testing.T) {
+	service := newService(&cacheMocked{cacheShouldFail: true}, &externalAPIMocked{externalAPIShouldFail: true})
+
+	r := service.Liveness(context.TODO())
+	if r.Alive != true || len(r.Services) != 1 {
+		t.Errorf("Liveness was expected to only report the service itself")
+	}
+}
+
+func TestRegistry_NonCriticalFailureKeepsReady(t *testing.T) {
+	service := newService(&cacheMocked{cacheShouldFail: false}, &externalAPIMocked{externalAPIShouldFail: false})
+	service.Register(NewChecker("optional", func(ctx context.Context) error {
+		return fmt.Errorf("optional dependency down")
+	}), NonCritical())
+
+	r := service.Readiness(context.TODO())
+	if r.Alive != true || statusOf(r, "optional") != false {
+		t.Errorf("Non critical check failure was not expected to affect readiness")
+	}
+}
+
+func TestRegistry_TimeoutAndConcurrency(t *testing.T) {
+	registry := &Registry{}
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	registry.Register(NewChecker("slow1", slow), WithTimeout(50*time.Millisecond))
+	registry.Register(NewChecker("slow2", slow), WithTimeout(50*time.Millisecond))
+
+	start := time.Now()
+	r := registry.Run(context.TODO())
+	elapsed := time.Since(start)
+
+	if r.Alive != false {
+		t.Errorf("Timed out checks were expected to fail")
+	}
+	if elapsed >= 100*time.Millisecond {
+		t.Errorf("Checks were expected to run concurrently, took %s", elapsed)
+	}
+	for _, h := range r.Services {
+		if h.LastError == "" || h.LastErrorAt.IsZero() || h.Latency < 50*time.Millisecond {
+			t.Errorf("Unexpected result for %s: %+v", h.Name, h)
+		}
+	}
+}
 
-	s, e, d, err := service.HealthCheck(context.TODO())
-	if s != true || e != false || d != true || err != nil {
-		t.Errorf("Unexpected values from method: service %t, db %t, error %s", s, d, err)
+func TestRegistry_KeepsLastSuccessAndLastError(t *testing.T) {
+	registry := &Registry{}
+	fail := false
+	registry.Register(NewChecker("flaky", func(ctx context.Context) error {
+		if fail {
+			return fmt.Errorf("flaky is down")
+		}
+		return nil
+	}))
+
+	first := registry.Run(context.TODO()).Services[0]
+	fail = true
+	second := registry.Run(context.TODO()).Services[0]
+
+	if first.LastSuccess.IsZero() || first.LastError != "" {
+		t.Errorf("Unexpected first result: %+v", first)
+	}
+	if second.Alive || second.LastError != "flaky is down" || !second.LastSuccess.Equal(first.LastSuccess) {
+		t.Errorf("Unexpected second result: %+v", second)
+	}
+}
+
+func statusOf(r Report, name string) bool {
+	for _, h := range r.Services {
+		if h.Name == name {
+			return h.Alive
+		}
 	}
+	return false
 }
 
 //Cache Mocked
@@ -77,6 +157,33 @@ func (c *cacheMocked) Alive(ctx context.Context) bool {
 	}
 	return true
 }
+func (c *cacheMocked) CompareAndSet(ctx context.Context, key string, old json.RawMessage, value interface{}) (bool, error) {
+	if c.cacheShouldFail {
+		return false, fmt.Errorf("Mock Cache Asked to Fail")
+	}
+	return true, nil
+}
+func (c *cacheMocked) Tx(ctx context.Context, ops ...cache.Op) error {
+	if c.cacheShouldFail {
+		return fmt.Errorf("Mock Cache Asked to Fail")
+	}
+	return nil
+}
+func (c *cacheMocked) ListMove(ctx context.Context, src, dst string) (string, error) {
+	return "", cache.ErrListEmpty
+}
+func (c *cacheMocked) ListRemove(ctx context.Context, list, value string) error {
+	return nil
+}
+func (c *cacheMocked) ListRange(ctx context.Context, list string, start, stop int64) ([]string, error) {
+	return []string{}, nil
+}
+func (c *cacheMocked) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	return delta, nil
+}
+func (c *cacheMocked) Scan(ctx context.Context, match string, fn func(key string) error) error {
+	return nil
+}
 
 type externalAPIMocked struct {
 	externalAPIShouldFail bool
@@ -0,0 +1,10 @@
+package inventory
+
+import "time"
+
+//Reservation is the stock a cart holds, by item ID, until it expires
+type Reservation struct {
+	CartID    string
+	Items     map[string]int
+	ExpiresAt time.Time
+}
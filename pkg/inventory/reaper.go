@@ -0,0 +1,39 @@
+package inventory
+
+import (
+	"context"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+)
+
+//Reaper releases the expired reservations periodically
+type Reaper struct {
+	logger   logger.Logger
+	svc      Service
+	interval time.Duration
+}
+
+func NewReaper(logger logger.Logger, svc Service, interval time.Duration) *Reaper {
+	return &Reaper{
+		logger:   logger,
+		svc:      svc,
+		interval: interval,
+	}
+}
+
+//Run releases the expired reservations every interval until ctx is done
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.svc.ReleaseExpired(ctx); err != nil {
+			r.logger.WithError(err).Warn(ctx, "Unable to release expired reservations, retrying later")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
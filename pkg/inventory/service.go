@@ -0,0 +1,215 @@
+package inventory
+
+import (
+	"context"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+)
+
+const (
+	reservationKeyPrefix = "reservation:"
+	reservedKeyPrefix    = "stock-reserved:"
+)
+
+//DefaultWindow is how long a cart holds stock since its last change unless told otherwise
+const DefaultWindow = 30 * time.Minute
+
+//ReservationsKey lists the carts of the partition holding stock, so expired reservations can be found. Reservations
+//are in the partition of their cart, so they are listed along with being saved
+func ReservationsKey(partition int) string {
+	return "reservations:" + cache.PartitionTag(partition)
+}
+
+func reservationKey(cartID string) string {
+	return reservationKeyPrefix + cache.PartitionTag(cache.Partition(cartID)) + ":" + cartID
+}
+
+//reservedKey holds the amount of the item held by every cart together
+func reservedKey(itemID string) string {
+	return reservedKeyPrefix + itemID
+}
+
+//Service holds the stock of the items in the carts for a window of time, so no more than the
+//stock of an item ends up in carts. Every change of a reservation renews its window
+type Service interface {
+	//Reserve holds quantity units of the item for the cart, replacing what the cart held of it.
+	//It fails with err_insufficient_stock when stock, minus what the other carts hold, is not enough
+	Reserve(ctx context.Context, cartID, itemID string, quantity, stock int) error
+	//Release gives back what the cart holds of the item
+	Release(ctx context.Context, cartID, itemID string) error
+	//ReleaseAll gives back everything the cart holds
+	ReleaseAll(ctx context.Context, cartID string) error
+	//ReleaseExpired gives back the reservations past their window, giving how many were released
+	ReleaseExpired(ctx context.Context) (int, error)
+}
+
+type service struct {
+	logger logger.Logger
+	cache  cache.Cache
+	window time.Duration
+}
+
+//NewService gives a Service holding stock for window since the last change of a reservation
+func NewService(logger logger.Logger, c cache.Cache, window time.Duration) Service {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	return &service{
+		logger: logger,
+		cache:  c,
+		window: window,
+	}
+}
+
+func (s *service) Reserve(ctx context.Context, cartID, itemID string, quantity, stock int) error {
+	log := s.logger.
+		WithField("cart_id", cartID).
+		WithField("item_id", itemID).
+		WithField("quantity", quantity)
+
+	r, found, err := s.reservation(ctx, cartID)
+	if err != nil {
+		return err
+	}
+	held := r.Items[itemID]
+
+	//the counter is changed first, so two carts racing for the last units can't both get them
+	delta := int64(quantity - held)
+	if delta != 0 {
+		reserved, err := s.cache.IncrBy(ctx, reservedKey(itemID), delta)
+		if err != nil {
+			log.WithError(err).Error(ctx, "Unable to change reserved stock")
+			return errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+		}
+		if delta > 0 && reserved > int64(stock) {
+			if _, err := s.cache.IncrBy(ctx, reservedKey(itemID), -delta); err != nil {
+				log.WithError(err).Error(ctx, "Unable to undo reserved stock")
+			}
+			available := stock - int(reserved-delta) + held
+			if available < 0 {
+				available = 0
+			}
+			log.Info(ctx, "Not enough stock for the Cart")
+			return errors.ServiceError{Code: errors.InsufficientStockCode}.
+				WithDetail("item_id", itemID).
+				WithDetail("requested", quantity).
+				WithDetail("available", available)
+		}
+	}
+
+	if quantity == 0 {
+		delete(r.Items, itemID)
+	} else {
+		r.Items[itemID] = quantity
+	}
+	r.ExpiresAt = time.Now().Add(s.window).UTC()
+	return s.store(ctx, r, found)
+}
+
+func (s *service) Release(ctx context.Context, cartID, itemID string) error {
+	r, found, err := s.reservation(ctx, cartID)
+	if err != nil {
+		return err
+	}
+	if !found || r.Items[itemID] == 0 {
+		return nil
+	}
+	return s.Reserve(ctx, cartID, itemID, 0, 0)
+}
+
+func (s *service) ReleaseAll(ctx context.Context, cartID string) error {
+	r, found, err := s.reservation(ctx, cartID)
+	if err != nil || !found {
+		return err
+	}
+	return s.drop(ctx, r)
+}
+
+func (s *service) ReleaseExpired(ctx context.Context) (int, error) {
+	released := 0
+	for partition := 0; partition < cache.Partitions; partition++ {
+		n, err := s.releaseExpired(ctx, partition)
+		released += n
+		if err != nil {
+			return released, err
+		}
+	}
+	return released, nil
+}
+
+//releaseExpired gives back the stock held by the expired reservations of the partition
+func (s *service) releaseExpired(ctx context.Context, partition int) (int, error) {
+	cartIDs, err := s.cache.ListRange(ctx, ReservationsKey(partition), 0, -1)
+	if err != nil {
+		return 0, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	released := 0
+	now := time.Now()
+	for _, cartID := range cartIDs {
+		r, found, err := s.reservation(ctx, cartID)
+		if err != nil {
+			return released, err
+		}
+		if found && now.Before(r.ExpiresAt) {
+			continue
+		}
+		if err := s.drop(ctx, r); err != nil {
+			return released, err
+		}
+		if found {
+			s.logger.WithField("cart_id", cartID).Info(ctx, "Reservation expired, stock released")
+			released++
+		}
+	}
+	return released, nil
+}
+
+//reservation gives the reservation of the cart, an empty one when it holds nothing
+func (s *service) reservation(ctx context.Context, cartID string) (Reservation, bool, error) {
+	r := Reservation{}
+	err := s.cache.Get(ctx, reservationKey(cartID), &r)
+	if cache.IsNotFound(err) {
+		return Reservation{CartID: cartID, Items: map[string]int{}}, false, nil
+	}
+	if err != nil {
+		s.logger.WithField("cart_id", cartID).WithError(err).Error(ctx, "Unable to get reservation")
+		return Reservation{}, false, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	if r.Items == nil {
+		r.Items = map[string]int{}
+	}
+	return r, true, nil
+}
+
+//store saves the reservation, adding new ones to the reservations looked at for expiry
+func (s *service) store(ctx context.Context, r Reservation, found bool) error {
+	ops := []cache.Op{cache.SetOp(reservationKey(r.CartID), r)}
+	if !found {
+		ops = append(ops, cache.PushOp(ReservationsKey(cache.Partition(r.CartID)), r.CartID))
+	}
+	if err := s.cache.Tx(ctx, ops...); err != nil {
+		s.logger.WithField("cart_id", r.CartID).WithError(err).Error(ctx, "Unable to save reservation")
+		return errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	return nil
+}
+
+//drop gives back everything held by the reservation and forgets it
+func (s *service) drop(ctx context.Context, r Reservation) error {
+	for itemID, quantity := range r.Items {
+		if _, err := s.cache.IncrBy(ctx, reservedKey(itemID), -int64(quantity)); err != nil {
+			return errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+		}
+	}
+	err := s.cache.Del(ctx, reservationKey(r.CartID))
+	if err != nil && !cache.IsNotFound(err) {
+		return errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	if err := s.cache.ListRemove(ctx, ReservationsKey(cache.Partition(r.CartID)), r.CartID); err != nil {
+		return errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	return nil
+}
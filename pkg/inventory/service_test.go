@@ -0,0 +1,115 @@
+package inventory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/inventory"
+	"github.com/stretchr/testify/assert"
+)
+
+func newService(c cache.Cache, window time.Duration) inventory.Service {
+	return inventory.NewService(logger.NewLogger("inventory unit testing", false), c, window)
+}
+
+func reserved(t *testing.T, c cache.Cache, itemID string) int {
+	n := 0
+	err := c.Get(context.TODO(), "stock-reserved:"+itemID, &n)
+	if !cache.IsNotFound(err) {
+		assert.Nil(t, err)
+	}
+	return n
+}
+
+func TestReserveWithinStock(t *testing.T) {
+	c := cache.NewMemoryCache()
+	svc := newService(c, time.Minute)
+
+	assert.Nil(t, svc.Reserve(context.TODO(), "cartA", "1", 2, 3))
+	assert.Nil(t, svc.Reserve(context.TODO(), "cartB", "1", 1, 3))
+	assert.Equal(t, 3, reserved(t, c, "1"))
+
+	//changing the quantity only holds the difference
+	assert.Nil(t, svc.Reserve(context.TODO(), "cartA", "1", 1, 3))
+	assert.Equal(t, 2, reserved(t, c, "1"))
+}
+
+func TestReserveBeyondStock(t *testing.T) {
+	c := cache.NewMemoryCache()
+	svc := newService(c, time.Minute)
+
+	assert.Nil(t, svc.Reserve(context.TODO(), "cartA", "1", 2, 3))
+	err := svc.Reserve(context.TODO(), "cartB", "1", 2, 3)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.InsufficientStockCode})
+	sErr := errors.ServiceError{}
+	assert.ErrorAs(t, err, &sErr)
+	assert.Equal(t, 1, sErr.Details["available"])
+	assert.Equal(t, 2, reserved(t, c, "1"))
+
+	//what a cart holds counts as available to itself
+	err = svc.Reserve(context.TODO(), "cartA", "1", 4, 3)
+	assert.ErrorAs(t, err, &sErr)
+	assert.Equal(t, 3, sErr.Details["available"])
+}
+
+func TestRelease(t *testing.T) {
+	c := cache.NewMemoryCache()
+	svc := newService(c, time.Minute)
+
+	assert.Nil(t, svc.Reserve(context.TODO(), "cartA", "1", 2, 3))
+	assert.Nil(t, svc.Reserve(context.TODO(), "cartA", "2", 1, 3))
+	assert.Nil(t, svc.Release(context.TODO(), "cartA", "1"))
+	assert.Equal(t, 0, reserved(t, c, "1"))
+	assert.Equal(t, 1, reserved(t, c, "2"))
+
+	assert.Nil(t, svc.ReleaseAll(context.TODO(), "cartA"))
+	assert.Equal(t, 0, reserved(t, c, "2"))
+	//releasing what is not held does nothing
+	assert.Nil(t, svc.Release(context.TODO(), "cartA", "1"))
+	assert.Nil(t, svc.ReleaseAll(context.TODO(), "cartA"))
+}
+
+func TestReleaseExpired(t *testing.T) {
+	c := cache.NewMemoryCache()
+	short := newService(c, time.Millisecond)
+	long := newService(c, time.Hour)
+
+	assert.Nil(t, short.Reserve(context.TODO(), "cartA", "1", 2, 3))
+	assert.Nil(t, long.Reserve(context.TODO(), "cartB", "1", 1, 3))
+	time.Sleep(5 * time.Millisecond)
+
+	released, err := long.ReleaseExpired(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, released)
+	assert.Equal(t, 1, reserved(t, c, "1"))
+
+	//the stock released can be held by others
+	assert.Nil(t, long.Reserve(context.TODO(), "cartC", "1", 2, 3))
+
+	released, err = long.ReleaseExpired(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, released)
+}
+
+func TestReaperReleasesExpired(t *testing.T) {
+	c := cache.NewMemoryCache()
+	svc := newService(c, time.Millisecond)
+	assert.Nil(t, svc.Reserve(context.TODO(), "cartA", "1", 2, 3))
+	time.Sleep(5 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		inventory.NewReaper(logger.NewLogger("reaper unit testing", false), svc, time.Millisecond).Run(ctx)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		return reserved(t, c, "1") == 0
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
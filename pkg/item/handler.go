@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/response"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/currency"
 	"github.com/gorilla/mux"
 )
 
@@ -16,20 +17,25 @@ func (c *Handler) GetAllItems(w http.ResponseWriter, r *http.Request) {
 
 	items, err := c.Service.GetAllItems(r.Context())
 	if err != nil {
-		response.RespondWithError(w, err)
+		response.RespondWithError(w, r, err)
 		return
 	}
+	rate := currency.RateFromContext(r.Context())
 	vmItems := []TransportItem{}
 	for _, item := range items {
 		vmItem := TransportItem{
-			ID:    item.ID,
-			Name:  item.Name,
-			Price: item.Price,
+			ID:       item.ID,
+			Name:     item.Name,
+			Price:    rate.Convert(item.Price),
+			Currency: rate.To,
+			Stock:    item.Stock,
+			Category: item.Category,
+			Weight:   item.Weight,
 		}
 		vmItems = append(vmItems, vmItem)
 	}
 
-	response.RespondWithData(w, http.StatusOK, vmItems)
+	response.RespondWithData(w, http.StatusOK, vmItems, rate.Meta())
 }
 
 //GetItem returns a particular item from the external API
@@ -38,14 +44,19 @@ func (c *Handler) GetItem(w http.ResponseWriter, r *http.Request) {
 	itemID := vars["item_id"]
 	item, err := c.Service.GetItem(r.Context(), itemID)
 	if err != nil {
-		response.RespondWithError(w, err)
+		response.RespondWithError(w, r, err)
 		return
 	}
+	rate := currency.RateFromContext(r.Context())
 	vmItem := TransportItem{
-		ID:    item.ID,
-		Name:  item.Name,
-		Price: item.Price,
+		ID:       item.ID,
+		Name:     item.Name,
+		Price:    rate.Convert(item.Price),
+		Currency: rate.To,
+		Stock:    item.Stock,
+		Category: item.Category,
+		Weight:   item.Weight,
 	}
 
-	response.RespondWithData(w, http.StatusOK, vmItem)
+	response.RespondWithData(w, http.StatusOK, vmItem, rate.Meta())
 }
@@ -2,11 +2,14 @@ package item_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/response"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/currency"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
 	"github.com/stretchr/testify/assert"
 )
@@ -64,6 +67,33 @@ func TestGetItem_OK(t *testing.T) {
 	assert.Equal(t, http.StatusOK, res.StatusCode)
 }
 
+func TestGetItem_InCurrency(t *testing.T) {
+	h := item.Handler{
+		Service: &mockedService{},
+	}
+
+	req, err := http.NewRequest("GET", "/", nil)
+	assert.Nil(t, err)
+	rate := currency.Rate{From: "USD", To: "EUR", Value: 0.5}
+	req = req.WithContext(currency.ContextWithRate(req.Context(), rate))
+
+	rr := httptest.NewRecorder()
+
+	h.GetItem(rr, req)
+
+	res := rr.Result()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	vm := struct {
+		Meta response.Meta      `json:"meta"`
+		Data item.TransportItem `json:"data"`
+	}{}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&vm))
+	assert.Equal(t, float32(6.17), vm.Data.Price)
+	assert.Equal(t, "EUR", vm.Data.Currency)
+	assert.Equal(t, 0.5, vm.Meta.ExchangeRate.Rate)
+}
+
 func TestGetItem_Error(t *testing.T) {
 	h := item.Handler{
 		Service: &mockedService{
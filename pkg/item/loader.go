@@ -0,0 +1,164 @@
+package item
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+)
+
+type loaderContextKey struct{}
+
+//Loader batches the item lookups of a single request, the DataLoader way.
+//Lookups arriving within the wait of the first one are fetched together, with a single call listing
+//the catalog when there is more than one item, and every item is fetched once for the life of the Loader
+type Loader struct {
+	svc  Service
+	wait time.Duration
+
+	mu      sync.Mutex
+	results map[string]*loaderResult
+	batch   []string
+}
+
+type loaderResult struct {
+	done chan struct{}
+	item Item
+	err  error
+}
+
+//NewLoader gives a Loader fetching from svc. It caches every item, so it must only live as long as a request
+func NewLoader(svc Service, wait time.Duration) *Loader {
+	return &Loader{
+		svc:     svc,
+		wait:    wait,
+		results: map[string]*loaderResult{},
+	}
+}
+
+//ContextWithLoader gives a context carrying the loader, letting the services down the call use it
+func ContextWithLoader(ctx context.Context, l *Loader) context.Context {
+	return context.WithValue(ctx, loaderContextKey{}, l)
+}
+
+//LoaderFromContext gives the loader carried by the context, if any
+func LoaderFromContext(ctx context.Context) (*Loader, bool) {
+	l, ok := ctx.Value(loaderContextKey{}).(*Loader)
+	return l, ok
+}
+
+//Load gives the item with the id, waiting for the batch it belongs to
+func (l *Loader) Load(ctx context.Context, id string) (Item, error) {
+	return l.await(ctx, l.enqueue(ctx, id)[0])
+}
+
+//LoadMany gives the items with the ids in the same order, all of them fetched in the same batch
+func (l *Loader) LoadMany(ctx context.Context, ids []string) ([]Item, error) {
+	pending := l.enqueue(ctx, ids...)
+	res := make([]Item, 0, len(ids))
+	for _, p := range pending {
+		i, err := l.await(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, i)
+	}
+	return res, nil
+}
+
+//Prime keeps items already known, so loading them does not call the provider
+func (l *Loader) Prime(items []Item) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, i := range items {
+		if _, ok := l.results[i.ID]; ok {
+			continue
+		}
+		res := &loaderResult{
+			done: make(chan struct{}),
+			item: i,
+		}
+		close(res.done)
+		l.results[i.ID] = res
+	}
+}
+
+//enqueue adds the ids not seen yet to the current batch, scheduling it when it is a new one
+func (l *Loader) enqueue(ctx context.Context, ids ...string) []*loaderResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	pending := make([]*loaderResult, 0, len(ids))
+	for _, id := range ids {
+		res, ok := l.results[id]
+		if !ok {
+			res = &loaderResult{
+				done: make(chan struct{}),
+			}
+			l.results[id] = res
+			l.batch = append(l.batch, id)
+			if len(l.batch) == 1 {
+				time.AfterFunc(l.wait, func() {
+					l.dispatch(ctx)
+				})
+			}
+		}
+		pending = append(pending, res)
+	}
+	return pending
+}
+
+func (l *Loader) await(ctx context.Context, res *loaderResult) (Item, error) {
+	select {
+	case <-res.done:
+		return res.item, res.err
+	case <-ctx.Done():
+		return Item{}, ctx.Err()
+	}
+}
+
+func (l *Loader) dispatch(ctx context.Context) {
+	l.mu.Lock()
+	ids := l.batch
+	l.batch = nil
+	pending := make([]*loaderResult, 0, len(ids))
+	for _, id := range ids {
+		pending = append(pending, l.results[id])
+	}
+	l.mu.Unlock()
+
+	found, err := l.fetch(ctx, ids)
+	for idx, id := range ids {
+		res := pending[idx]
+		i, ok := found[id]
+		switch {
+		case err != nil:
+			res.err = err
+		case !ok:
+			res.err = errors.ServiceError{Code: errors.ItemNotFoundOnProviderCode}.WithDetail("item_id", id)
+		default:
+			res.item = i
+		}
+		close(res.done)
+	}
+}
+
+func (l *Loader) fetch(ctx context.Context, ids []string) (map[string]Item, error) {
+	if len(ids) == 1 {
+		i, err := l.svc.GetItem(ctx, ids[0])
+		if err != nil {
+			return nil, err
+		}
+		return map[string]Item{ids[0]: i}, nil
+	}
+	all, err := l.svc.GetAllItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]Item, len(all))
+	for _, i := range all {
+		res[i.ID] = i
+	}
+	return res, nil
+}
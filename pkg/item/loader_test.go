@@ -0,0 +1,106 @@
+package item_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoader_BatchesConcurrentLoads(t *testing.T) {
+	svc := &countingService{}
+	l := item.NewLoader(svc, 10*time.Millisecond)
+
+	wg := sync.WaitGroup{}
+	for _, id := range []string{"1", "2", "3", "2"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			i, err := l.Load(context.Background(), id)
+			assert.Nil(t, err)
+			assert.Equal(t, id, i.ID)
+		}(id)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, svc.allCalls)
+	assert.Equal(t, 0, svc.getCalls)
+
+	//already loaded items are not fetched again
+	_, err := l.Load(context.Background(), "3")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, svc.allCalls)
+}
+
+func TestLoader_SingleItem(t *testing.T) {
+	svc := &countingService{}
+	l := item.NewLoader(svc, 0)
+
+	i, err := l.Load(context.Background(), "2")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Item 2", i.Name)
+	assert.Equal(t, 1, svc.getCalls)
+	assert.Equal(t, 0, svc.allCalls)
+}
+
+func TestLoader_LoadManyNotFound(t *testing.T) {
+	svc := &countingService{}
+	l := item.NewLoader(svc, 0)
+
+	_, err := l.LoadMany(context.Background(), []string{"1", "99"})
+
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ItemNotFoundOnProviderCode})
+	assert.Equal(t, 1, svc.allCalls)
+}
+
+func TestLoader_Prime(t *testing.T) {
+	svc := &countingService{}
+	l := item.NewLoader(svc, 0)
+	l.Prime([]item.Item{{ID: "1", Name: "Primed"}})
+
+	items, err := l.LoadMany(context.Background(), []string{"1"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Primed", items[0].Name)
+	assert.Equal(t, 0, svc.getCalls+svc.allCalls)
+}
+
+type countingService struct {
+	mu       sync.Mutex
+	getCalls int
+	allCalls int
+}
+
+var loaderCatalog = []item.Item{
+	{ID: "1", Name: "Item 1", Price: 10},
+	{ID: "2", Name: "Item 2", Price: 20},
+	{ID: "3", Name: "Item 3", Price: 30},
+}
+
+func (c *countingService) Health(ctx context.Context) error {
+	return nil
+}
+
+func (c *countingService) GetItem(ctx context.Context, id string) (item.Item, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.getCalls++
+	for _, i := range loaderCatalog {
+		if i.ID == id {
+			return i, nil
+		}
+	}
+	return item.Item{}, errors.ServiceError{Code: errors.ItemNotFoundOnProviderCode}
+}
+
+func (c *countingService) GetAllItems(ctx context.Context) ([]item.Item, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.allCalls++
+	return loaderCatalog, nil
+}
@@ -1,10 +1,68 @@
 package item
 
+import (
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/currency"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/tax"
+)
+
 type Item struct {
 	ID       string
 	Name     string
 	Quantity int
 	Price    float32
+	//Category decides the tax rate of the item, it may be empty
+	Category string `json:",omitempty"`
+	//AddedPrice is the price observed when the item was added to a cart, zero when unknown
+	AddedPrice float32
+	//Stock is the amount available on the provider, nil when the provider does not track it
+	Stock *int `json:",omitempty"`
+	//Weight is in grams, zero when unknown
+	Weight int `json:",omitempty"`
+	//LineID identifies the line of a cart holding the item, the same item may be in several lines with
+	//other Options, which choose its variant, or another Note from the customer. They are empty outside carts
+	LineID  string            `json:",omitempty"`
+	Options map[string]string `json:",omitempty"`
+	Note    string            `json:",omitempty"`
+}
+
+//Directions of a price change
+const (
+	PriceUp   = "up"
+	PriceDown = "down"
+)
+
+//PriceChange tells the price of the provider differs from the one observed when the item was added
+type PriceChange struct {
+	OldPrice  float32 `json:"old_price"`
+	NewPrice  float32 `json:"new_price"`
+	Direction string  `json:"direction"`
+}
+
+//PriceChangeOf gives the change of price of the item since it was added, nil when it did not change
+func PriceChangeOf(i Item) *PriceChange {
+	if i.AddedPrice == 0 || i.AddedPrice == i.Price {
+		return nil
+	}
+	direction := PriceUp
+	if i.Price < i.AddedPrice {
+		direction = PriceDown
+	}
+	return &PriceChange{
+		OldPrice:  i.AddedPrice,
+		NewPrice:  i.Price,
+		Direction: direction,
+	}
+}
+
+//ConvertPriceChange gives the change with its prices converted with the rate
+func ConvertPriceChange(c *PriceChange, rate currency.Rate) *PriceChange {
+	if c == nil {
+		return nil
+	}
+	converted := *c
+	converted.OldPrice = rate.Convert(c.OldPrice)
+	converted.NewPrice = rate.Convert(c.NewPrice)
+	return &converted
 }
 
 type TransportItem struct {
@@ -12,12 +70,33 @@ type TransportItem struct {
 	Name     string  `json:"name"`
 	Quantity int     `json:"quantity,omitempty"`
 	Price    float32 `json:"price"`
+	//Currency of the price, only set for the items of the catalog when the currency is known
+	Currency string `json:"currency,omitempty"`
+	//LineID, Options and Note are only set for the items of a cart
+	LineID  string            `json:"line_id,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
+	Note    string            `json:"note,omitempty"`
+	//PriceChange and LockedPrice are only set for the items of a cart
+	PriceChange *PriceChange `json:"price_change,omitempty"`
+	LockedPrice *float32     `json:"locked_price,omitempty"`
+	//Stock is only set for the items of the catalog tracking it
+	Stock    *int   `json:"stock,omitempty"`
+	Category string `json:"category,omitempty"`
+	//Weight is in grams
+	Weight int `json:"weight,omitempty"`
+	//Tax is only set for the items of a cart with taxes
+	Tax *tax.TransportLineTax `json:"tax,omitempty"`
 }
 
 type ExternalItem struct {
-	ID    string `json:"id,omitempty"`
-	Name  string `json:"name,omitempty"`
-	Price string `json:"price,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Price    string `json:"price,omitempty"`
+	Category string `json:"category,omitempty"`
+	//Weight is in grams, missing when unknown
+	Weight int `json:"weight,omitempty"`
+	//Stock is missing for items whose stock is not tracked
+	Stock *int `json:"stock,omitempty"`
 }
 
 type ExternalHealth struct {
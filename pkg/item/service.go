@@ -6,16 +6,28 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/correlation"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/metrics"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tracing"
 )
 
 const (
-	healthEndpoint   = "https://bootcamp-products.getsandbox.com/health"
-	articlesEndpoint = "https://bootcamp-products.getsandbox.com/products"
+	//DefaultCatalogURL is where items are fetched from, unless the tenant of the request has a catalog of its own
+	DefaultCatalogURL = "https://bootcamp-products.getsandbox.com"
+	healthPath        = "/health"
+	articlesPath      = "/products"
 
 	healthStatusOK = "OK"
+
+	operationHealth      = "health"
+	operationGetItem     = "get_item"
+	operationGetAllItems = "get_all_items"
 )
 
 type Service interface {
@@ -44,13 +56,16 @@ func NewExternalService(logger logger.Logger, client ItemClient) Service {
 
 func (e *externalService) Health(ctx context.Context) error {
 	e.logger.Info(ctx, "Calling External API Health")
-	outCtx := context.WithValue(context.Background(), "X-Correlation-ID", ctx.Value("correlation_id"))
-	req, err := http.NewRequestWithContext(outCtx, http.MethodGet, healthEndpoint, nil)
+	req, err := newRequest(ctx, catalogURL(ctx)+healthPath)
 	if err != nil {
 		e.logger.WithError(err).Error(ctx, "Error creating request to external provider")
 		return err
 	}
+	span := tracing.StartClientSpan(ctx, req, "provider."+operationHealth)
+	start := time.Now()
 	res, err := e.client.Do(req)
+	observeProviderRequest(operationHealth, start, res, err)
+	tracing.EndClientSpan(span, res, err)
 	if err != nil {
 		e.logger.WithError(err).Error(ctx, "Error Calling External API Health")
 		return err
@@ -73,14 +88,16 @@ func (e *externalService) GetItem(ctx context.Context, id string) (Item, error)
 	log := e.logger.WithField("item_id", id)
 
 	log.Info(ctx, "Getting single item from provider")
-	outCtx := context.WithValue(context.Background(), "X-Correlation-ID", ctx.Value("correlation_id"))
-
-	req, err := http.NewRequestWithContext(outCtx, http.MethodGet, articlesEndpoint+"/"+id, nil)
+	req, err := newRequest(ctx, catalogURL(ctx)+articlesPath+"/"+id)
 	if err != nil {
 		log.WithError(err).Error(ctx, "Error creating request to external provider")
 		return Item{}, err
 	}
+	span := tracing.StartClientSpan(ctx, req, "provider."+operationGetItem)
+	start := time.Now()
 	res, err := e.client.Do(req)
+	observeProviderRequest(operationGetItem, start, res, err)
+	tracing.EndClientSpan(span, res, err)
 	if err != nil {
 		log.WithError(err).Error(ctx, "Error getting item from external provider")
 		return Item{}, err
@@ -103,22 +120,28 @@ func (e *externalService) GetItem(ctx context.Context, id string) (Item, error)
 	}
 	log.Info(ctx, "Item fetched successfully")
 	mItem := Item{
-		ID:    eItem.Data.ID,
-		Name:  eItem.Data.Name,
-		Price: float32(price),
+		ID:       eItem.Data.ID,
+		Name:     eItem.Data.Name,
+		Price:    float32(price),
+		Stock:    eItem.Data.Stock,
+		Category: eItem.Data.Category,
+		Weight:   eItem.Data.Weight,
 	}
 
 	return mItem, nil
 }
 func (e *externalService) GetAllItems(ctx context.Context) ([]Item, error) {
 	e.logger.Info(ctx, "Getting all items from provider")
-	outCtx := context.WithValue(context.Background(), "X-Correlation-ID", ctx.Value("correlation_id"))
-	req, err := http.NewRequestWithContext(outCtx, http.MethodGet, articlesEndpoint, nil)
+	req, err := newRequest(ctx, catalogURL(ctx)+articlesPath)
 	if err != nil {
 		e.logger.WithError(err).Error(ctx, "Error creating request to external provider")
 		return []Item{}, err
 	}
+	span := tracing.StartClientSpan(ctx, req, "provider."+operationGetAllItems)
+	start := time.Now()
 	res, err := e.client.Do(req)
+	observeProviderRequest(operationGetAllItems, start, res, err)
+	tracing.EndClientSpan(span, res, err)
 	if err != nil {
 		e.logger.WithError(err).Error(ctx, "Error getting all items from external provider")
 		return []Item{}, err
@@ -139,11 +162,46 @@ func (e *externalService) GetAllItems(ctx context.Context) ([]Item, error) {
 			return []Item{}, err
 		}
 		mItems = append(mItems, Item{
-			ID:    eItem.ID,
-			Name:  eItem.Name,
-			Price: float32(price),
+			ID:       eItem.ID,
+			Name:     eItem.Name,
+			Price:    float32(price),
+			Stock:    eItem.Stock,
+			Category: eItem.Category,
+			Weight:   eItem.Weight,
 		})
 	}
 
 	return mItems, nil
 }
+
+//newRequest gives a GET of url to the provider, cancelled along with ctx and carrying its correlation ID
+func newRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if id := correlation.FromContext(ctx); id != "" {
+		req.Header.Set(correlation.Header, id)
+	}
+	return req, nil
+}
+
+//catalogURL gives the catalog of the tenant of the context, the default one when it has none
+func catalogURL(ctx context.Context) string {
+	if t, ok := tenant.FromContext(ctx); ok && t.CatalogURL != "" {
+		return strings.TrimSuffix(t.CatalogURL, "/")
+	}
+	return DefaultCatalogURL
+}
+
+//observeProviderRequest records the call in the provider metrics, server errors count as failures
+func observeProviderRequest(operation string, start time.Time, res *http.Response, err error) {
+	status := 0
+	if res != nil {
+		status = res.StatusCode
+	}
+	if err == nil && status >= http.StatusInternalServerError {
+		err = fmt.Errorf("provider answered with status %d", status)
+	}
+	metrics.ObserveProviderRequest(operation, status, start, err)
+}
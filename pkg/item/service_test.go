@@ -9,7 +9,9 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/correlation"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
 )
 
@@ -113,6 +115,30 @@ func TestGetItem(t *testing.T) {
 		t.Fatalf("Error was not expected")
 	}
 }
+func TestGetItemWithStock(t *testing.T) {
+	stock := 3
+	svc := item.NewExternalService(
+		logger.NewLogger("item unit test", false),
+		&itemClientMock{
+			response: item.ExternalGetItemResponse{
+				Data: item.ExternalItem{
+					ID:    "someItemID",
+					Name:  "Some Item ID",
+					Price: "12.34",
+					Stock: &stock,
+				},
+			},
+		},
+	)
+
+	i, err := svc.GetItem(context.TODO(), "someItemID")
+	if err != nil {
+		t.Fatalf("Error was not expected")
+	}
+	if i.Stock == nil || *i.Stock != 3 {
+		t.Fatalf("Stock of 3 was expected, got %v", i.Stock)
+	}
+}
 func TestGetItemNotFound(t *testing.T) {
 
 	svc := item.NewExternalService(
@@ -269,10 +295,54 @@ func TestGetAllItemsFloatParseError(t *testing.T) {
 
 //*****ItemClientMock
 
+func TestItemsAreFetchedFromTheCatalogOfTheTenant(t *testing.T) {
+	client := &itemClientMock{
+		response: item.ExternalGetItemResponse{
+			Data: item.ExternalItem{ID: "1", Price: "1.5"},
+		},
+	}
+	svc := item.NewExternalService(logger.NewLogger("item unit test", false), client)
+
+	if _, err := svc.GetItem(context.TODO(), "1"); err != nil || client.requestedURL != item.DefaultCatalogURL+"/products/1" {
+		t.Fatalf("The default catalog was expected, got %s, %v", client.requestedURL, err)
+	}
+
+	ctx := tenant.NewContext(context.TODO(), tenant.Tenant{ID: "acme", CatalogURL: "https://catalog.acme.test/v2/"})
+	if _, err := svc.GetItem(ctx, "1"); err != nil || client.requestedURL != "https://catalog.acme.test/v2/products/1" {
+		t.Fatalf("The catalog of the tenant was expected, got %s, %v", client.requestedURL, err)
+	}
+}
+
+func TestRequestsCarryTheContextAndCorrelationID(t *testing.T) {
+	client := &itemClientMock{
+		response: item.ExternalGetAllItemsResponse{},
+	}
+	svc := item.NewExternalService(logger.NewLogger("item unit test", false), client)
+	type key struct{}
+	ctx, id := correlation.WithID(context.WithValue(context.TODO(), key{}, "incoming"), "")
+
+	if _, err := svc.GetAllItems(ctx); err != nil {
+		t.Fatalf("Error was not expected: %v", err)
+	}
+	if got := client.request.Header.Get(correlation.Header); got != id {
+		t.Fatalf("The correlation ID %s was expected as header, got %q", id, got)
+	}
+	if client.request.Context().Value(key{}) != "incoming" {
+		t.Fatalf("The request was expected within the incoming context")
+	}
+
+	svc.GetItem(context.TODO(), "1")
+	if got := client.request.Header.Get(correlation.Header); got != "" {
+		t.Fatalf("No correlation ID was expected, got %q", got)
+	}
+}
+
 type itemClientMock struct {
 	response           interface{}
 	responseStatusCode int
 	shouldFail         bool
+	requestedURL       string
+	request            *http.Request
 }
 
 func (i *itemClientMock) Get(url string) (*http.Response, error) {
@@ -289,6 +359,8 @@ func (i *itemClientMock) Get(url string) (*http.Response, error) {
 }
 
 func (i *itemClientMock) Do(req *http.Request) (*http.Response, error) {
+	i.requestedURL = req.URL.String()
+	i.request = req
 	if i.shouldFail {
 		return nil, fmt.Errorf("Mock asked to fail")
 	}
@@ -0,0 +1,78 @@
+package shipping
+
+import "time"
+
+//Destination is where a parcel is shipped to. Country is an ISO 3166-1 alpha-2 code and Region
+//the code of the state or province within it
+type Destination struct {
+	Country    string
+	Region     string
+	PostalCode string
+}
+
+//Parcel describes what is shipped
+type Parcel struct {
+	//Weight is in grams
+	Weight int
+	//Value is what the items of the parcel cost, in cents
+	Value int64
+	Items int
+}
+
+//Option is a way of shipping a parcel, with its cost and delivery estimate
+type Option struct {
+	ID      string
+	Carrier string
+	Service string
+	Cost    float32
+	//MinDays and MaxDays bound the days the delivery takes, from DeliveryFrom to DeliveryTo
+	MinDays      int
+	MaxDays      int
+	DeliveryFrom time.Time
+	DeliveryTo   time.Time
+}
+
+//Table has the rates of every zone. Zones are a country such as US, a region within it such as US-CA,
+//or * for every destination without its own zone
+type Table struct {
+	Zones map[string][]Rate `json:"zones"`
+}
+
+//Rate is an option offered in a zone. It costs Base plus PerKg for every started kilogram,
+//nothing when the parcel is worth FreeOver or more, and it is not offered for parcels heavier than MaxWeight
+type Rate struct {
+	ID      string  `json:"id"`
+	Carrier string  `json:"carrier"`
+	Service string  `json:"service"`
+	Base    float32 `json:"base"`
+	PerKg   float32 `json:"per_kg"`
+	//FreeOver and MaxWeight, in grams, are not applied when zero
+	FreeOver  float32 `json:"free_over"`
+	MaxWeight int     `json:"max_weight"`
+	MinDays   int     `json:"min_days"`
+	MaxDays   int     `json:"max_days"`
+}
+
+type TransportOption struct {
+	ID           string    `json:"id"`
+	Carrier      string    `json:"carrier"`
+	Service      string    `json:"service"`
+	Cost         float32   `json:"cost"`
+	MinDays      int       `json:"min_days"`
+	MaxDays      int       `json:"max_days"`
+	DeliveryFrom time.Time `json:"estimated_delivery_from"`
+	DeliveryTo   time.Time `json:"estimated_delivery_to"`
+}
+
+func OptionToTransportModel(o Option) TransportOption {
+	return TransportOption{
+		ID:           o.ID,
+		Carrier:      o.Carrier,
+		Service:      o.Service,
+		Cost:         o.Cost,
+		MinDays:      o.MinDays,
+		MaxDays:      o.MaxDays,
+		DeliveryFrom: o.DeliveryFrom,
+		DeliveryTo:   o.DeliveryTo,
+	}
+}
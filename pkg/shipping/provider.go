@@ -0,0 +1,126 @@
+package shipping
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+//AnyZone has the rates of the destinations without a zone of their own
+const AnyZone = "*"
+
+//Provider gives the options to ship parcels, backed by a rate table or a carrier API
+type Provider interface {
+	//Quote gives the options to ship the parcel to the destination, cheapest first.
+	//It gives no options when the destination is not served
+	Quote(ctx context.Context, dest Destination, parcel Parcel) ([]Option, error)
+}
+
+type tableProvider struct {
+	table Table
+	now   func() time.Time
+}
+
+//NewTableProvider gives a Provider quoting the rates of the table, it must be valid
+func NewTableProvider(table Table) Provider {
+	return &tableProvider{
+		table: table,
+		now:   time.Now,
+	}
+}
+
+//LoadTable reads and validates the rate table of the JSON file at path
+func LoadTable(path string) (Table, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Table{}, err
+	}
+	table := Table{}
+	if err := json.Unmarshal(b, &table); err != nil {
+		return Table{}, fmt.Errorf("unable to parse shipping rates %s: %w", path, err)
+	}
+	if err := table.Validate(); err != nil {
+		return Table{}, fmt.Errorf("invalid shipping rates %s: %w", path, err)
+	}
+	return table, nil
+}
+
+//Validate checks every rate has a unique ID within its zone, no negative amounts and sensible delivery days
+func (t Table) Validate() error {
+	for zone, rates := range t.Zones {
+		ids := map[string]bool{}
+		for _, r := range rates {
+			if r.ID == "" {
+				return fmt.Errorf("zone %s: rates must have an id", zone)
+			}
+			if ids[r.ID] {
+				return fmt.Errorf("zone %s: rate %s is repeated", zone, r.ID)
+			}
+			ids[r.ID] = true
+			if r.Base < 0 || r.PerKg < 0 || r.FreeOver < 0 || r.MaxWeight < 0 {
+				return fmt.Errorf("zone %s: rate %s has negative amounts", zone, r.ID)
+			}
+			if r.MinDays < 0 || r.MaxDays < r.MinDays {
+				return fmt.Errorf("zone %s: rate %s must take from min_days to max_days", zone, r.ID)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *tableProvider) Quote(ctx context.Context, dest Destination, parcel Parcel) ([]Option, error) {
+	rates := p.lookup(dest)
+	today := p.now().UTC().Truncate(24 * time.Hour)
+	res := make([]Option, 0, len(rates))
+	for _, r := range rates {
+		if r.MaxWeight > 0 && parcel.Weight > r.MaxWeight {
+			continue
+		}
+		cost := cents(r.Base) + cents(r.PerKg)*int64(math.Ceil(float64(parcel.Weight)/1000))
+		if r.FreeOver > 0 && parcel.Value >= cents(r.FreeOver) {
+			cost = 0
+		}
+		res = append(res, Option{
+			ID:           r.ID,
+			Carrier:      r.Carrier,
+			Service:      r.Service,
+			Cost:         float32(cost) / 100,
+			MinDays:      r.MinDays,
+			MaxDays:      r.MaxDays,
+			DeliveryFrom: today.AddDate(0, 0, r.MinDays),
+			DeliveryTo:   today.AddDate(0, 0, r.MaxDays),
+		})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Cost != res[j].Cost {
+			return res[i].Cost < res[j].Cost
+		}
+		return res[i].MaxDays < res[j].MaxDays
+	})
+	return res, nil
+}
+
+//lookup gives the rates of the region within the country, falling back to the ones of the whole country
+//and then to the ones of any destination
+func (p *tableProvider) lookup(dest Destination) []Rate {
+	country := strings.ToUpper(dest.Country)
+	if dest.Region != "" {
+		if rates, ok := p.table.Zones[country+"-"+strings.ToUpper(dest.Region)]; ok {
+			return rates
+		}
+	}
+	if rates, ok := p.table.Zones[country]; ok {
+		return rates
+	}
+	return p.table.Zones[AnyZone]
+}
+
+//cents turns an amount into cents, rounding half away from zero
+func cents(amount float32) int64 {
+	return int64(math.Round(float64(amount) * 100))
+}
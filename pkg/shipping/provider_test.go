@@ -0,0 +1,97 @@
+package shipping_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/shipping"
+	"github.com/stretchr/testify/assert"
+)
+
+func fixtureProvider(t *testing.T) shipping.Provider {
+	table, err := shipping.LoadTable("testdata/rates.json")
+	assert.Nil(t, err)
+	return shipping.NewTableProvider(table)
+}
+
+func TestQuote(t *testing.T) {
+	p := fixtureProvider(t)
+
+	cases := []struct {
+		name    string
+		dest    shipping.Destination
+		parcel  shipping.Parcel
+		options map[string]float32
+	}{
+		{"base plus every started kilogram", shipping.Destination{Country: "US", Region: "CA"}, shipping.Parcel{Weight: 1200, Value: 2000}, map[string]float32{"ground": 8, "express": 21}},
+		{"weightless parcels pay the base", shipping.Destination{Country: "us"}, shipping.Parcel{Value: 2000}, map[string]float32{"ground": 5, "express": 15}},
+		{"free over the value", shipping.Destination{Country: "US"}, shipping.Parcel{Weight: 500, Value: 10000}, map[string]float32{"ground": 0, "express": 18}},
+		{"too heavy rates are left out", shipping.Destination{Country: "US"}, shipping.Parcel{Weight: 10001, Value: 100}, map[string]float32{"ground": 21.5}},
+		{"region zone wins over the country", shipping.Destination{Country: "US", Region: "HI"}, shipping.Parcel{Weight: 3000}, map[string]float32{"air": 20}},
+		{"any zone serves the rest", shipping.Destination{Country: "FR"}, shipping.Parcel{Weight: 2000}, map[string]float32{"international": 46}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			options, err := p.Quote(context.TODO(), tc.dest, tc.parcel)
+			assert.Nil(t, err)
+			got := map[string]float32{}
+			for _, o := range options {
+				got[o.ID] = o.Cost
+			}
+			assert.Equal(t, tc.options, got)
+		})
+	}
+}
+
+func TestQuoteCheapestFirstWithDeliveryEstimates(t *testing.T) {
+	p := fixtureProvider(t)
+
+	options, err := p.Quote(context.TODO(), shipping.Destination{Country: "US"}, shipping.Parcel{Weight: 100})
+	assert.Nil(t, err)
+	assert.Len(t, options, 2)
+	assert.Equal(t, "ground", options[0].ID)
+	assert.Equal(t, "UPS", options[0].Carrier)
+	assert.Equal(t, 3, options[0].MinDays)
+	assert.Equal(t, 2*24*time.Hour, options[0].DeliveryTo.Sub(options[0].DeliveryFrom))
+	assert.True(t, options[0].DeliveryFrom.After(time.Now()))
+}
+
+func TestQuoteUnservedDestination(t *testing.T) {
+	p := shipping.NewTableProvider(shipping.Table{Zones: map[string][]shipping.Rate{
+		"US": {{ID: "ground", Base: 5, MaxDays: 3}},
+	}})
+
+	options, err := p.Quote(context.TODO(), shipping.Destination{Country: "DE"}, shipping.Parcel{})
+	assert.Nil(t, err)
+	assert.Empty(t, options)
+}
+
+func TestLoadTableRejectsInvalidRates(t *testing.T) {
+	cases := map[string]string{
+		"not json":         `{`,
+		"missing id":       `{"zones":{"US":[{"base":1}]}}`,
+		"repeated id":      `{"zones":{"US":[{"id":"a"},{"id":"a"}]}}`,
+		"negative amount":  `{"zones":{"US":[{"id":"a","base":-1}]}}`,
+		"days out of line": `{"zones":{"US":[{"id":"a","min_days":5,"max_days":2}]}}`,
+	}
+	dir := t.TempDir()
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, "rates.json")
+			assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0600))
+			_, err := shipping.LoadTable(path)
+			assert.NotNil(t, err)
+		})
+	}
+	_, err := shipping.LoadTable(filepath.Join(dir, "missing.json"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLoadSampleTable(t *testing.T) {
+	_, err := shipping.LoadTable("../../config/shipping_rates.json")
+	assert.Nil(t, err)
+}
@@ -0,0 +1,163 @@
+package tax
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+//rateScale turns rates into integers, so taxes are computed exactly in cents
+const rateScale = 1000000
+
+//Calculator gives the taxes of the items sold to a region
+type Calculator interface {
+	//Calculate gives the taxes of the lines sold to the country and region,
+	//false when there are no rules for them
+	Calculate(country, region string, lines []Line) (Result, bool)
+}
+
+type calculator struct {
+	rules Rules
+}
+
+//NewCalculator gives a Calculator following the rules, they must be valid
+func NewCalculator(rules Rules) Calculator {
+	return &calculator{
+		rules: rules,
+	}
+}
+
+//LoadRules reads and validates the rules of the JSON file at path
+func LoadRules(path string) (Rules, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Rules{}, err
+	}
+	rules := Rules{}
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return Rules{}, fmt.Errorf("unable to parse tax rules %s: %w", path, err)
+	}
+	if err := rules.Validate(); err != nil {
+		return Rules{}, fmt.Errorf("invalid tax rules %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+//Validate checks every region has a known mode and rounding, and rates between 0 and 1
+func (r Rules) Validate() error {
+	for name, region := range r.Regions {
+		if region.Mode != ModeExclusive && region.Mode != ModeInclusive {
+			return fmt.Errorf("region %s: unknown mode %q", name, region.Mode)
+		}
+		switch region.Rounding {
+		case RoundHalfUp, RoundHalfEven, RoundUp, RoundDown:
+		default:
+			return fmt.Errorf("region %s: unknown rounding %q", name, region.Rounding)
+		}
+		if region.Rate < 0 || region.Rate >= 1 {
+			return fmt.Errorf("region %s: rate must be between 0 and 1", name)
+		}
+		for category, rate := range region.Categories {
+			if rate < 0 || rate >= 1 {
+				return fmt.Errorf("region %s: rate of %s must be between 0 and 1", name, category)
+			}
+		}
+	}
+	return nil
+}
+
+//lookup gives the rules of the region within the country, falling back to the ones of the whole country
+func (c *calculator) lookup(country, region string) (string, RegionRules, bool) {
+	country = strings.ToUpper(country)
+	if region != "" {
+		key := country + "-" + strings.ToUpper(region)
+		if rules, ok := c.rules.Regions[key]; ok {
+			return key, rules, true
+		}
+	}
+	rules, ok := c.rules.Regions[country]
+	return country, rules, ok
+}
+
+func (c *calculator) Calculate(country, region string, lines []Line) (Result, bool) {
+	key, rules, ok := c.lookup(country, region)
+	if !ok {
+		return Result{}, false
+	}
+
+	res := Result{
+		Region: key,
+		Mode:   rules.Mode,
+		Lines:  make([]LineTax, 0, len(lines)),
+	}
+	var totalNet, totalTax, totalGross int64
+	for _, l := range lines {
+		rate := rules.Rate
+		if categoryRate, ok := rules.Categories[l.Category]; ok {
+			rate = categoryRate
+		}
+		scaledRate := int64(math.Round(rate * rateScale))
+		amount := Cents(l.UnitPrice) * int64(l.Quantity)
+
+		var net, tax, gross int64
+		if rules.Mode == ModeInclusive {
+			gross = amount
+			tax = divide(amount*scaledRate, rateScale+scaledRate, rules.Rounding)
+			net = gross - tax
+		} else {
+			net = amount
+			tax = divide(amount*scaledRate, rateScale, rules.Rounding)
+			gross = net + tax
+		}
+		totalNet += net
+		totalTax += tax
+		totalGross += gross
+		res.Lines = append(res.Lines, LineTax{
+			ID:    l.ID,
+			Rate:  rate,
+			Net:   Amount(net),
+			Tax:   Amount(tax),
+			Gross: Amount(gross),
+		})
+	}
+	res.Net = Amount(totalNet)
+	res.Tax = Amount(totalTax)
+	res.Gross = Amount(totalGross)
+	return res, true
+}
+
+//Cents gives the price in cents, so amounts can be added up without floating point errors
+func Cents(price float32) int64 {
+	return int64(math.Round(float64(price) * 100))
+}
+
+//Amount gives the price of the cents
+func Amount(cents int64) float32 {
+	return float32(cents) / 100
+}
+
+//divide gives num/den rounded following the rounding policy, both being positive
+func divide(num, den int64, rounding string) int64 {
+	q, r := num/den, num%den
+	if r == 0 {
+		return q
+	}
+	switch rounding {
+	case RoundUp:
+		return q + 1
+	case RoundDown:
+		return q
+	case RoundHalfEven:
+		if 2*r > den || (2*r == den && q%2 == 1) {
+			return q + 1
+		}
+		return q
+	default:
+		if 2*r >= den {
+			return q + 1
+		}
+		return q
+	}
+}
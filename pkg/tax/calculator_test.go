@@ -0,0 +1,108 @@
+package tax_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/tax"
+	"github.com/stretchr/testify/assert"
+)
+
+func fixtureCalculator(t *testing.T) tax.Calculator {
+	rules, err := tax.LoadRules("testdata/rules.json")
+	assert.Nil(t, err)
+	return tax.NewCalculator(rules)
+}
+
+func TestCalculateLines(t *testing.T) {
+	calc := fixtureCalculator(t)
+
+	cases := []struct {
+		name      string
+		country   string
+		region    string
+		category  string
+		unitPrice float32
+		quantity  int
+		rate      float64
+		net       float32
+		tax       float32
+		gross     float32
+	}{
+		{"exclusive rounds half up", "US", "CA", "", 10, 1, 0.0725, 10, 0.73, 10.73},
+		{"category rate", "US", "CA", "groceries", 3.99, 2, 0, 7.98, 0, 7.98},
+		{"exclusive rounds up", "US", "NY", "", 1, 1, 0.08875, 1, 0.09, 1.09},
+		{"inclusive takes the tax out", "DE", "", "", 10, 1, 0.19, 8.40, 1.60, 10},
+		{"inclusive category rate", "DE", "", "books", 10.70, 1, 0.07, 10, 0.70, 10.70},
+		{"half even rounds ties down to even", "CH", "", "", 0.10, 1, 0.05, 0.10, 0, 0.10},
+		{"half even rounds ties up to even", "CH", "", "", 0.30, 1, 0.05, 0.30, 0.02, 0.32},
+		{"rounds down", "JP", "", "", 0.99, 1, 0.1, 0.99, 0.09, 1.08},
+		{"region falls back to country", "us", "tx", "", 5, 3, 0, 15, 0, 15},
+		{"lowercase region", "us", "ca", "", 10, 1, 0.0725, 10, 0.73, 10.73},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, ok := calc.Calculate(c.country, c.region, []tax.Line{
+				{ID: "1", Category: c.category, UnitPrice: c.unitPrice, Quantity: c.quantity},
+			})
+			assert.True(t, ok)
+			assert.Equal(t, []tax.LineTax{
+				{ID: "1", Rate: c.rate, Net: c.net, Tax: c.tax, Gross: c.gross},
+			}, res.Lines)
+			assert.Equal(t, c.net, res.Net)
+			assert.Equal(t, c.tax, res.Tax)
+			assert.Equal(t, c.gross, res.Gross)
+		})
+	}
+}
+
+func TestCalculateTotalsAddUpRoundedLines(t *testing.T) {
+	calc := fixtureCalculator(t)
+
+	//every line is rounded on its own, 0.725 twice and not 1.45 once
+	res, ok := calc.Calculate("US", "CA", []tax.Line{
+		{ID: "1", UnitPrice: 10, Quantity: 1},
+		{ID: "2", UnitPrice: 10, Quantity: 1},
+		{ID: "3", Category: "groceries", UnitPrice: 2.5, Quantity: 4},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, "US-CA", res.Region)
+	assert.Equal(t, tax.ModeExclusive, res.Mode)
+	assert.Equal(t, float32(30), res.Net)
+	assert.Equal(t, float32(1.46), res.Tax)
+	assert.Equal(t, float32(31.46), res.Gross)
+
+	line, ok := res.Line("3")
+	assert.True(t, ok)
+	assert.Equal(t, float32(0), line.Tax)
+}
+
+func TestCalculateUnknownRegion(t *testing.T) {
+	calc := fixtureCalculator(t)
+
+	_, ok := calc.Calculate("FR", "", []tax.Line{{ID: "1", UnitPrice: 10, Quantity: 1}})
+	assert.False(t, ok)
+}
+
+func TestLoadRulesInvalid(t *testing.T) {
+	cases := map[string]string{
+		"unknown mode":     `{"regions":{"US":{"mode":"gross","rounding":"half_up","rate":0.1}}}`,
+		"unknown rounding": `{"regions":{"US":{"mode":"exclusive","rounding":"bankers","rate":0.1}}}`,
+		"rate over one":    `{"regions":{"US":{"mode":"exclusive","rounding":"half_up","rate":7}}}`,
+		"category rate":    `{"regions":{"US":{"mode":"exclusive","rounding":"half_up","rate":0.1,"categories":{"food":-1}}}}`,
+		"not json":         `regions: US`,
+	}
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.json")
+			assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0600))
+			_, err := tax.LoadRules(path)
+			assert.NotNil(t, err)
+		})
+	}
+
+	_, err := tax.LoadRules(filepath.Join(os.TempDir(), "missing-rules.json"))
+	assert.NotNil(t, err)
+}
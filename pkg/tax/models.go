@@ -0,0 +1,85 @@
+package tax
+
+//Pricing modes
+const (
+	//ModeExclusive adds the tax on top of the prices, as sales taxes do
+	ModeExclusive = "exclusive"
+	//ModeInclusive takes the tax out of the prices, as VAT does
+	ModeInclusive = "inclusive"
+)
+
+//Rounding policies, applied to the tax of every line in cents
+const (
+	RoundHalfUp   = "half_up"
+	RoundHalfEven = "half_even"
+	RoundUp       = "up"
+	RoundDown     = "down"
+)
+
+//Rules are the tax rules of every region, keyed by country ("DE") or country and region ("US-CA")
+type Rules struct {
+	Regions map[string]RegionRules `json:"regions"`
+}
+
+//RegionRules tell how the items sold to a region are taxed
+type RegionRules struct {
+	Mode     string `json:"mode"`
+	Rounding string `json:"rounding"`
+	//Rate applies to the items whose category has no rate of its own
+	Rate       float64            `json:"rate"`
+	Categories map[string]float64 `json:"categories,omitempty"`
+}
+
+//Line is an item to be taxed
+type Line struct {
+	ID        string
+	Category  string
+	UnitPrice float32
+	Quantity  int
+}
+
+//LineTax is the tax of a line. Gross is what the customer pays for it, Net is Gross without the tax
+type LineTax struct {
+	ID    string
+	Rate  float64
+	Net   float32
+	Tax   float32
+	Gross float32
+}
+
+//Result is the tax of every line and the totals of them all
+type Result struct {
+	Region string
+	Mode   string
+	Lines  []LineTax
+	Net    float32
+	Tax    float32
+	Gross  float32
+}
+
+//Line gives the tax of the line with the id
+func (r Result) Line(id string) (LineTax, bool) {
+	for _, l := range r.Lines {
+		if l.ID == id {
+			return l, true
+		}
+	}
+	return LineTax{}, false
+}
+
+type TransportLineTax struct {
+	Rate float64 `json:"rate"`
+	Net  float32 `json:"net"`
+	Tax  float32 `json:"tax"`
+	//Gross is what the customer pays for the line
+	Gross float32 `json:"gross"`
+}
+
+func LineTaxToTransportModel(l LineTax) *TransportLineTax {
+	return &TransportLineTax{
+		Rate:  l.Rate,
+		Net:   l.Net,
+		Tax:   l.Tax,
+		Gross: l.Gross,
+	}
+}
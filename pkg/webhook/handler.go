@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/response"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	Service Service
+}
+
+//CreateSubscription registers a new webhook, its secret is only answered here
+func (c *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	vm := CreateSubscriptionRequest{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&vm)
+	if err != nil {
+		log.Printf("Error decoding body: %v", err)
+		response.RespondWithError(w, r, response.StandardBadBodyRequest)
+		return
+	}
+
+	sub, err := c.Service.CreateSubscription(r.Context(), vm.URL, vm.EventTypes, vm.Secret)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	res := SubscriptionResponse{
+		Subscription: SubscriptionModelToTransportModel(sub, true),
+	}
+	response.RespondWithData(w, http.StatusCreated, res)
+}
+
+//GetSubscriptions lists every webhook
+func (c *Handler) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subs, err := c.Service.GetSubscriptions(r.Context())
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	res := SubscriptionsResponse{
+		Subscriptions: []TransportSubscription{},
+	}
+	for _, s := range subs {
+		res.Subscriptions = append(res.Subscriptions, SubscriptionModelToTransportModel(s, false))
+	}
+	response.RespondWithData(w, http.StatusOK, res)
+}
+
+//GetSubscription gives a single webhook
+func (c *Handler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sub, err := c.Service.GetSubscription(r.Context(), vars["webhook_id"])
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	res := SubscriptionResponse{
+		Subscription: SubscriptionModelToTransportModel(sub, false),
+	}
+	response.RespondWithData(w, http.StatusOK, res)
+}
+
+//DeleteSubscription removes a webhook, pending deliveries to it are dropped
+func (c *Handler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	err := c.Service.DeleteSubscription(r.Context(), vars["webhook_id"])
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+	response.RespondWithData(w, http.StatusAccepted, nil)
+}
+
+//GetDeliveryLog gives the latest delivery attempts of a webhook, newest first
+func (c *Handler) GetDeliveryLog(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	attempts, err := c.Service.GetDeliveryLog(r.Context(), vars["webhook_id"])
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+	response.RespondWithData(w, http.StatusOK, AttemptsResponse{Attempts: attempts})
+}
+
+//GetDeadLetters gives the deliveries of a webhook that ran out of attempts, newest first
+func (c *Handler) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	deliveries, err := c.Service.GetDeadLetters(r.Context(), vars["webhook_id"])
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	res := DeadLettersResponse{
+		Deliveries: []TransportDelivery{},
+	}
+	for _, d := range deliveries {
+		res.Deliveries = append(res.Deliveries, TransportDelivery{
+			ID:        d.ID,
+			Event:     d.Event,
+			CreatedAt: d.CreatedAt,
+		})
+	}
+	response.RespondWithData(w, http.StatusOK, res)
+}
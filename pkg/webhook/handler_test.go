@@ -0,0 +1,63 @@
+package webhook_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/webhook"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateSubscriptionHandler_OK(t *testing.T) {
+	h := webhook.Handler{
+		Service: webhook.NewService(testLogger, cache.NewMemoryCache(), testResolver),
+	}
+
+	body, _ := json.Marshal(webhook.CreateSubscriptionRequest{URL: "https://example.com/hook"})
+	req, err := http.NewRequest("POST", "/webhooks", bytes.NewReader(body))
+	assert.Nil(t, err)
+	rr := httptest.NewRecorder()
+
+	h.CreateSubscription(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	res := struct {
+		Data webhook.SubscriptionResponse `json:"data"`
+	}{}
+	json.NewDecoder(rr.Body).Decode(&res)
+	assert.NotEmpty(t, res.Data.Subscription.Secret, "the secret must be answered on creation")
+}
+
+func TestCreateSubscriptionHandler_BadBody(t *testing.T) {
+	h := webhook.Handler{
+		Service: webhook.NewService(testLogger, cache.NewMemoryCache(), testResolver),
+	}
+
+	req, err := http.NewRequest("POST", "/webhooks", bytes.NewReader([]byte(`{"unknown":1}`)))
+	assert.Nil(t, err)
+	rr := httptest.NewRecorder()
+
+	h.CreateSubscription(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestGetSubscriptionHandler_NotFound(t *testing.T) {
+	h := webhook.Handler{
+		Service: webhook.NewService(testLogger, cache.NewMemoryCache(), testResolver),
+	}
+
+	req, err := http.NewRequest("GET", "/webhooks/someID", nil)
+	assert.Nil(t, err)
+	req = mux.SetURLVars(req, map[string]string{"webhook_id": "someID"})
+	rr := httptest.NewRecorder()
+
+	h.GetSubscription(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
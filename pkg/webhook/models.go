@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/events"
+)
+
+//AllEvents subscribes to every event type
+const AllEvents = "*"
+
+type Subscription struct {
+	ID         string
+	URL        string
+	Secret     string
+	EventTypes []string
+	CreatedAt  time.Time
+}
+
+//Matches tells whether the subscription wants events of the given type
+func (s Subscription) Matches(eventType string) bool {
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == AllEvents || t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+//Delivery is a single event to be sent to a single subscription
+type Delivery struct {
+	ID             string
+	SubscriptionID string
+	Event          events.Event
+	CreatedAt      time.Time
+	//Attempts is how many attempts were made so far
+	Attempts int `json:",omitempty"`
+	//LeasedUntil is when the worker attempting the delivery gives it up, or when its next attempt is due.
+	//Once passed any worker may take it
+	LeasedUntil time.Time
+}
+
+//Attempt is an entry of the delivery log
+type Attempt struct {
+	DeliveryID string    `json:"delivery_id"`
+	EventID    string    `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Delivered  bool      `json:"delivered"`
+	At         time.Time `json:"at"`
+}
+
+type TransportSubscription struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type TransportDelivery struct {
+	ID        string       `json:"id"`
+	Event     events.Event `json:"event"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type SubscriptionResponse struct {
+	Subscription TransportSubscription `json:"subscription"`
+}
+
+type SubscriptionsResponse struct {
+	Subscriptions []TransportSubscription `json:"subscriptions"`
+}
+
+type AttemptsResponse struct {
+	Attempts []Attempt `json:"attempts"`
+}
+
+type DeadLettersResponse struct {
+	Deliveries []TransportDelivery `json:"deliveries"`
+}
+
+type CreateSubscriptionRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Secret     string   `json:"secret"`
+}
+
+//SubscriptionModelToTransportModel maps a subscription, the secret is only kept when withSecret
+//as it must only be shown once, when the subscription is created
+func SubscriptionModelToTransportModel(s Subscription, withSecret bool) TransportSubscription {
+	ts := TransportSubscription{
+		ID:         s.ID,
+		URL:        s.URL,
+		EventTypes: s.EventTypes,
+		CreatedAt:  s.CreatedAt,
+	}
+	if ts.EventTypes == nil {
+		ts.EventTypes = []string{}
+	}
+	if withSecret {
+		ts.Secret = s.Secret
+	}
+	return ts
+}
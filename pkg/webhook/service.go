@@ -0,0 +1,312 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/events"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	//SubscriptionsKey is the list of the IDs of every subscription. The subscriptions, their delivery logs and dead
+	//letters share its hash tag, so they are created and deleted together
+	SubscriptionsKey = keyPrefix + "{subscriptions}"
+
+	//MaxLogEntries bounds the delivery log and the dead letters kept per subscription
+	MaxLogEntries = 100
+
+	keyPrefix = "webhook:"
+)
+
+//PendingKey is the queue of the IDs of the deliveries of the partition waiting for the worker. Deliveries are in
+//the partition of the aggregate of their event
+func PendingKey(partition int) string {
+	return keyPrefix + "deliveries:" + cache.PartitionTag(partition) + ":pending"
+}
+
+//ProcessingKey is the list of the IDs of the deliveries of the partition taken by a worker and not yet finished
+func ProcessingKey(partition int) string {
+	return keyPrefix + "deliveries:" + cache.PartitionTag(partition) + ":processing"
+}
+
+func subscriptionKey(id string) string {
+	return SubscriptionsKey + ":" + id
+}
+
+func logKey(id string) string {
+	return subscriptionKey(id) + ":log"
+}
+
+func deadLetterKey(id string) string {
+	return subscriptionKey(id) + ":dead_letters"
+}
+
+func deliveryKey(partition int, id string) string {
+	return keyPrefix + "delivery:" + cache.PartitionTag(partition) + ":" + id
+}
+
+//Service manages the webhook subscriptions. It is also an events.Sink, queueing a delivery
+//for every subscription interested in a published event
+type Service interface {
+	events.Sink
+	CreateSubscription(ctx context.Context, url string, eventTypes []string, secret string) (Subscription, error)
+	GetSubscriptions(ctx context.Context) ([]Subscription, error)
+	GetSubscription(ctx context.Context, id string) (Subscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+	GetDeliveryLog(ctx context.Context, id string) ([]Attempt, error)
+	GetDeadLetters(ctx context.Context, id string) ([]Delivery, error)
+}
+
+type service struct {
+	logger   logger.Logger
+	cache    cache.Cache
+	resolver Resolver
+	//allowPrivate accepts receivers on any address
+	allowPrivate bool
+}
+
+//ServiceOption customizes the Service
+type ServiceOption func(*service)
+
+//WithResolver resolves the hosts of the subscribed URLs, net.DefaultResolver being used otherwise
+func WithResolver(r Resolver) ServiceOption {
+	return func(s *service) {
+		s.resolver = r
+	}
+}
+
+//AllowPrivateTargets accepts receivers on loopback, private and link-local addresses, meant for local
+//development and tests only
+func AllowPrivateTargets() ServiceOption {
+	return func(s *service) {
+		s.allowPrivate = true
+	}
+}
+
+func NewService(logger logger.Logger, cache cache.Cache, opts ...ServiceOption) Service {
+	s := &service{
+		logger:   logger,
+		cache:    cache,
+		resolver: net.DefaultResolver,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *service) CreateSubscription(ctx context.Context, target string, eventTypes []string, secret string) (Subscription, error) {
+	log := s.logger.WithField("url", target)
+
+	if err := s.validateSubscription(ctx, target, eventTypes); err != nil {
+		log.WithError(err).Error(ctx, "Invalid subscription request")
+		return Subscription{}, err
+	}
+	if secret == "" {
+		generated, err := newSecret()
+		if err != nil {
+			log.WithError(err).Error(ctx, "Unable to generate secret")
+			return Subscription{}, err
+		}
+		secret = generated
+	}
+
+	sub := Subscription{
+		ID:         uuid.New().String(),
+		URL:        target,
+		Secret:     secret,
+		EventTypes: eventTypes,
+		CreatedAt:  time.Now().UTC(),
+	}
+	log = log.WithField("webhook_id", sub.ID)
+	log.Info(ctx, "Creating webhook subscription")
+	err := s.cache.Tx(ctx,
+		cache.SetOp(subscriptionKey(sub.ID), sub),
+		cache.PushOp(SubscriptionsKey, sub.ID),
+	)
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to save subscription in DB")
+		return Subscription{}, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	return sub, nil
+}
+
+func (s *service) GetSubscriptions(ctx context.Context) ([]Subscription, error) {
+	ids, err := s.cache.ListRange(ctx, SubscriptionsKey, 0, -1)
+	if err != nil {
+		s.logger.WithError(err).Error(ctx, "Unable to list subscriptions")
+		return nil, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+
+	subs := []Subscription{}
+	//the list holds the newest first, subscriptions are answered oldest first
+	for idx := len(ids) - 1; idx >= 0; idx-- {
+		sub := Subscription{}
+		err := s.cache.Get(ctx, subscriptionKey(ids[idx]), &sub)
+		if cache.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			s.logger.WithField("webhook_id", ids[idx]).WithError(err).Error(ctx, "Unable to get subscription")
+			return nil, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+func (s *service) GetSubscription(ctx context.Context, id string) (Subscription, error) {
+	sub := Subscription{}
+	err := s.cache.Get(ctx, subscriptionKey(id), &sub)
+	if err != nil {
+		s.logger.WithField("webhook_id", id).WithError(err).Error(ctx, "Unable to get subscription")
+		return Subscription{}, notFoundOrCacheError(id, err)
+	}
+	return sub, nil
+}
+
+func (s *service) DeleteSubscription(ctx context.Context, id string) error {
+	log := s.logger.WithField("webhook_id", id)
+
+	if _, err := s.GetSubscription(ctx, id); err != nil {
+		return err
+	}
+	log.Info(ctx, "Deleting webhook subscription")
+	err := s.cache.Tx(ctx,
+		cache.DelOp(subscriptionKey(id)),
+		cache.DelOp(logKey(id)),
+		cache.DelOp(deadLetterKey(id)),
+	)
+	if err == nil {
+		err = s.cache.ListRemove(ctx, SubscriptionsKey, id)
+	}
+	if err != nil {
+		log.WithError(err).Error(ctx, "Unable to delete subscription")
+		return errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	return nil
+}
+
+func (s *service) GetDeliveryLog(ctx context.Context, id string) ([]Attempt, error) {
+	if _, err := s.GetSubscription(ctx, id); err != nil {
+		return nil, err
+	}
+	entries, err := s.cache.ListRange(ctx, logKey(id), 0, MaxLogEntries-1)
+	if err != nil {
+		s.logger.WithField("webhook_id", id).WithError(err).Error(ctx, "Unable to get delivery log")
+		return nil, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	attempts := make([]Attempt, 0, len(entries))
+	for _, e := range entries {
+		a := Attempt{}
+		if err := json.Unmarshal([]byte(e), &a); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, nil
+}
+
+func (s *service) GetDeadLetters(ctx context.Context, id string) ([]Delivery, error) {
+	if _, err := s.GetSubscription(ctx, id); err != nil {
+		return nil, err
+	}
+	entries, err := s.cache.ListRange(ctx, deadLetterKey(id), 0, MaxLogEntries-1)
+	if err != nil {
+		s.logger.WithField("webhook_id", id).WithError(err).Error(ctx, "Unable to get dead letters")
+		return nil, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	deliveries := make([]Delivery, 0, len(entries))
+	for _, e := range entries {
+		d := Delivery{}
+		if err := json.Unmarshal([]byte(e), &d); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+//Publish queues a delivery of the event for every subscription interested in it
+func (s *service) Publish(ctx context.Context, e events.Event) error {
+	subs, err := s.GetSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	partition := cache.Partition(e.AggregateID)
+	ops := []cache.Op{}
+	for _, sub := range subs {
+		if !sub.Matches(e.Type) {
+			continue
+		}
+		d := Delivery{
+			ID:             uuid.New().String(),
+			SubscriptionID: sub.ID,
+			Event:          e,
+			CreatedAt:      time.Now().UTC(),
+		}
+		ops = append(ops,
+			cache.SetOp(deliveryKey(partition, d.ID), d),
+			cache.PushOp(PendingKey(partition), d.ID),
+		)
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+	return s.cache.Tx(ctx, ops...)
+}
+
+func notFoundOrCacheError(id string, err error) error {
+	if cache.IsNotFound(err) {
+		return errors.ServiceError{Code: errors.WebhookNotFoundCode}.
+			WithDetail("webhook_id", id).
+			WithCause(err)
+	}
+	return errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+}
+
+//validateSubscription checks the subscription fields coming from the client, reporting every invalid field at once.
+//The host of the URL is resolved, so receivers are not on the network the service runs in
+func (s *service) validateSubscription(ctx context.Context, target string, eventTypes []string) error {
+	vErr := errors.ServiceError{Code: errors.ValidationErrorCode}
+	u, err := url.Parse(target)
+	switch {
+	case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+		vErr = vErr.WithFieldViolation("url", "must be an absolute http or https URL")
+	case s.allowPrivate:
+	default:
+		if err := checkHost(ctx, s.resolver, u.Hostname()); IsForbiddenTarget(err) {
+			vErr = vErr.WithFieldViolation("url", "must not be on a loopback, private, link-local or unspecified address")
+		} else if err != nil {
+			vErr = vErr.WithFieldViolation("url", "must have a host that resolves")
+		}
+	}
+	for _, t := range eventTypes {
+		if t == "" {
+			vErr = vErr.WithFieldViolation("event_types", "must not contain empty event types")
+			break
+		}
+	}
+	if len(vErr.Fields) > 0 {
+		return vErr
+	}
+	return nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
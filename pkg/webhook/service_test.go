@@ -0,0 +1,114 @@
+package webhook_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/events"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+var testLogger = logger.NewLogger("webhook unit test", false)
+
+//hostsResolver resolves the hosts it knows, as DNS would
+type hostsResolver map[string]string
+
+func (h hostsResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ip, ok := h[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return []net.IPAddr{{IP: net.ParseIP(ip)}}, nil
+}
+
+var testResolver = webhook.WithResolver(hostsResolver{
+	"example.com":          "93.184.216.34",
+	"internal.example.com": "10.0.0.7",
+})
+
+func TestCreateSubscription_OK(t *testing.T) {
+	svc := webhook.NewService(testLogger, cache.NewMemoryCache(), testResolver)
+
+	sub, err := svc.CreateSubscription(context.TODO(), "https://example.com/hook", []string{"cart.created"}, "")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, sub.ID)
+	assert.NotEmpty(t, sub.Secret, "a secret must be generated when none is given")
+
+	subs, err := svc.GetSubscriptions(context.TODO())
+	assert.Nil(t, err)
+	assert.Len(t, subs, 1)
+	assert.Equal(t, sub.ID, subs[0].ID)
+}
+
+func TestCreateSubscription_Invalid(t *testing.T) {
+	svc := webhook.NewService(testLogger, cache.NewMemoryCache(), testResolver)
+
+	_, err := svc.CreateSubscription(context.TODO(), "ftp://example.com", []string{""}, "")
+
+	sErr := errors.ServiceError{}
+	assert.ErrorAs(t, err, &sErr)
+	assert.Equal(t, errors.ValidationErrorCode, sErr.Code)
+	assert.Len(t, sErr.Fields, 2)
+}
+
+func TestCreateSubscription_ForbiddenTargets(t *testing.T) {
+	svc := webhook.NewService(testLogger, cache.NewMemoryCache(), testResolver)
+
+	for _, target := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+		"http://0.0.0.0/hook",
+		"http://192.168.1.10/hook",
+		"https://internal.example.com/hook",
+		"https://unknown.example.com/hook",
+	} {
+		_, err := svc.CreateSubscription(context.TODO(), target, nil, "")
+		sErr := errors.ServiceError{}
+		assert.ErrorAsf(t, err, &sErr, "%s was expected to be rejected", target)
+		assert.Equal(t, errors.ValidationErrorCode, sErr.Code)
+	}
+	subs, _ := svc.GetSubscriptions(context.TODO())
+	assert.Empty(t, subs)
+}
+
+func TestCreateSubscription_PrivateTargetsAllowed(t *testing.T) {
+	svc := webhook.NewService(testLogger, cache.NewMemoryCache(), webhook.AllowPrivateTargets())
+
+	_, err := svc.CreateSubscription(context.TODO(), "http://127.0.0.1:8080/hook", nil, "")
+	assert.Nil(t, err)
+}
+
+func TestDeleteSubscription(t *testing.T) {
+	svc := webhook.NewService(testLogger, cache.NewMemoryCache(), testResolver)
+	sub, _ := svc.CreateSubscription(context.TODO(), "https://example.com/hook", nil, "secret")
+
+	assert.Nil(t, svc.DeleteSubscription(context.TODO(), sub.ID))
+
+	_, err := svc.GetSubscription(context.TODO(), sub.ID)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.WebhookNotFoundCode})
+	err = svc.DeleteSubscription(context.TODO(), sub.ID)
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.WebhookNotFoundCode})
+	subs, _ := svc.GetSubscriptions(context.TODO())
+	assert.Empty(t, subs)
+}
+
+func TestPublish_OnlyMatchingSubscriptions(t *testing.T) {
+	c := cache.NewMemoryCache()
+	svc := webhook.NewService(testLogger, c, testResolver)
+	svc.CreateSubscription(context.TODO(), "https://example.com/all", nil, "secret")
+	svc.CreateSubscription(context.TODO(), "https://example.com/created", []string{"cart.created"}, "secret")
+	svc.CreateSubscription(context.TODO(), "https://example.com/deleted", []string{"cart.deleted"}, "secret")
+
+	e, _ := events.New("cart.created", "someCart", nil)
+	assert.Nil(t, svc.Publish(context.TODO(), e))
+
+	queued, _ := c.ListRange(context.TODO(), webhook.PendingKey(partition), 0, -1)
+	assert.Len(t, queued, 2)
+}
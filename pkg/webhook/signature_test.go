@@ -0,0 +1,22 @@
+package webhook_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Now()
+	ts := strconv.FormatInt(now.Unix(), 10)
+	body := []byte(`{"id":"someEvent"}`)
+	sig := webhook.Sign("secret", now, body)
+
+	assert.True(t, webhook.Verify("secret", ts, sig, body))
+	assert.False(t, webhook.Verify("other", ts, sig, body), "a different secret must not verify")
+	assert.False(t, webhook.Verify("secret", ts, sig, []byte(`{"id":"tampered"}`)), "a different body must not verify")
+	assert.False(t, webhook.Verify("secret", strconv.FormatInt(now.Unix()+1, 10), sig, body), "a different timestamp must not verify")
+}
@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+//ErrForbiddenTarget is given for receivers on loopback, private, link-local or unspecified addresses,
+//so subscriptions can't reach the network the service runs in
+var ErrForbiddenTarget = errors.New("webhook: receiver address is not allowed")
+
+//IsForbiddenTarget tells whether err means the receiver is on a forbidden address
+func IsForbiddenTarget(err error) bool {
+	return errors.Is(err, ErrForbiddenTarget)
+}
+
+//Resolver gives the addresses of a host, *net.Resolver satisfies it
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+//privateNetworks are the ranges kept for private use, IPv4 ones and the unique local IPv6 addresses
+var privateNetworks = mustParseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7")
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	res := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		res = append(res, n)
+	}
+	return res
+}
+
+//forbiddenIP tells whether the address is one receivers must not be on
+func forbiddenIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return true
+	}
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//checkHost fails when the host is, or resolves to, any forbidden address
+func checkHost(ctx context.Context, r Resolver, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if forbiddenIP(ip) {
+			return fmt.Errorf("%w: %s", ErrForbiddenTarget, ip)
+		}
+		return nil
+	}
+	addrs, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, a := range addrs {
+		if forbiddenIP(a.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrForbiddenTarget, host, a.IP)
+		}
+	}
+	return nil
+}
+
+//NewHTTPClient gives the client sending deliveries, refusing to connect to forbidden addresses. The address is
+//checked when connecting, so hosts resolving elsewhere since they were subscribed, and redirects, are refused too.
+//Proxies are not used, as the address connected to would be the one of the proxy
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || forbiddenIP(ip) {
+				return fmt.Errorf("%w: %s", ErrForbiddenTarget, host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: DefaultWorkerOptions.Concurrency,
+		},
+	}
+}
@@ -0,0 +1,385 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+)
+
+//HTTPClient sends the deliveries, *http.Client satisfies it
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+//WorkerOptions tunes how deliveries are sent and retried
+type WorkerOptions struct {
+	//MaxAttempts is how many times a delivery is tried before going to the dead letters
+	MaxAttempts int
+	//Backoff is the wait before the first retry, doubled on every following retry
+	Backoff time.Duration
+	//MaxBackoff caps the wait between retries
+	MaxBackoff time.Duration
+	//Concurrency is how many deliveries are sent at the same time
+	Concurrency int
+	//PollInterval is how often the queue is checked for new deliveries and retries due
+	PollInterval time.Duration
+	//LeaseTimeout is how long a delivery is kept by the worker attempting it before any worker may take it again,
+	//so it must be longer than the timeout of the HTTP client
+	LeaseTimeout time.Duration
+	//BatchSize bounds how many deliveries taken are looked at in every partition on every poll
+	BatchSize int
+}
+
+//DefaultWorkerOptions retries for about a minute before giving up on a delivery
+var DefaultWorkerOptions = WorkerOptions{
+	MaxAttempts:  6,
+	Backoff:      2 * time.Second,
+	MaxBackoff:   30 * time.Second,
+	Concurrency:  4,
+	PollInterval: time.Second,
+	LeaseTimeout: time.Minute,
+	BatchSize:    100,
+}
+
+//Worker sends the queued deliveries to the subscribers. A delivery succeeds when the receiver
+//answers any 2xx, otherwise it is retried with exponential backoff and, once MaxAttempts is reached,
+//kept in the dead letters of the subscription. Every attempt is recorded in the delivery log.
+//Workers on several instances share the queue: a delivery is leased by the worker attempting it, and taken
+//again by any of them once the lease expires, which is also how retries are scheduled
+type Worker struct {
+	cache  cache.Cache
+	client HTTPClient
+	logger logger.Logger
+	opts   WorkerOptions
+
+	mu sync.Mutex
+	//runs keeps the error of the last drain of every running Run, nil when it succeeded
+	runs map[*struct{}]error
+}
+
+//NewWorker gives a Worker, zero options are taken from DefaultWorkerOptions
+func NewWorker(logger logger.Logger, c cache.Cache, client HTTPClient, opts WorkerOptions) *Worker {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultWorkerOptions.MaxAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultWorkerOptions.Backoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultWorkerOptions.MaxBackoff
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultWorkerOptions.Concurrency
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultWorkerOptions.PollInterval
+	}
+	if opts.LeaseTimeout <= 0 {
+		opts.LeaseTimeout = DefaultWorkerOptions.LeaseTimeout
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultWorkerOptions.BatchSize
+	}
+	return &Worker{
+		cache:  c,
+		client: client,
+		logger: logger,
+		opts:   opts,
+		runs:   map[*struct{}]error{},
+	}
+}
+
+//Run sends deliveries until ctx is done
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.PollInterval)
+	defer ticker.Stop()
+	run := &struct{}{}
+	w.setRun(run, nil)
+	defer w.endRun(run)
+	for {
+		_, err := w.Drain(ctx)
+		if err != nil {
+			w.logger.WithError(err).Warn(ctx, "Webhook queue drain interrupted, retrying later")
+		}
+		w.setRun(run, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//Health fails when no Run is sending deliveries, or the last drain of any of them failed
+func (w *Worker) Health(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.runs) == 0 {
+		return errors.New("webhook: worker is not running")
+	}
+	for _, err := range w.runs {
+		if err != nil {
+			return fmt.Errorf("webhook: draining the queue: %w", err)
+		}
+	}
+	return nil
+}
+
+func (w *Worker) setRun(run *struct{}, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.runs[run] = err
+}
+
+func (w *Worker) endRun(run *struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.runs, run)
+}
+
+//Drain takes every queued delivery and makes an attempt of the deliveries whose lease expired, the ones
+//due for a retry and the ones left unfinished by a worker that stopped. Up to BatchSize deliveries taken are looked
+//at in every partition, oldest first, each one going to the back of the taken ones so the next drain looks at the
+//following ones. It returns once those attempts are done, giving how many were made, failed ones being scheduled
+//for later rather than waited for
+func (w *Worker) Drain(ctx context.Context) (int, error) {
+	attempted := int64(0)
+	sem := make(chan struct{}, w.opts.Concurrency)
+	wg := sync.WaitGroup{}
+	var err error
+	for partition := 0; partition < cache.Partitions && err == nil; partition++ {
+		var ids []string
+		ids, err = w.take(ctx, partition)
+		for _, id := range ids {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(partition int, id string) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				ok, err := w.deliver(ctx, partition, id)
+				if err != nil {
+					w.logger.WithField("delivery_id", id).WithError(err).Warn(ctx, "Delivery left unfinished, it is retried once its lease expires")
+				}
+				if ok {
+					atomic.AddInt64(&attempted, 1)
+				}
+			}(partition, id)
+		}
+	}
+	wg.Wait()
+	return int(attempted), err
+}
+
+//take moves the queued deliveries of the partition to its taken ones, giving up to BatchSize of those to look at
+func (w *Worker) take(ctx context.Context, partition int) ([]string, error) {
+	for ctx.Err() == nil {
+		_, err := w.cache.ListMove(ctx, PendingKey(partition), ProcessingKey(partition))
+		if err == cache.ErrListEmpty {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ids := []string{}
+	seen := map[string]bool{}
+	for len(ids) < w.opts.BatchSize {
+		if err := ctx.Err(); err != nil {
+			return ids, err
+		}
+		//rotating the list, so deliveries not due yet don't hold back the ones after them
+		id, err := w.cache.ListMove(ctx, ProcessingKey(partition), ProcessingKey(partition))
+		if err == cache.ErrListEmpty {
+			return ids, nil
+		}
+		if err != nil {
+			return ids, err
+		}
+		if seen[id] {
+			return ids, nil
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+//lease takes the delivery for an attempt when its lease expired, telling whether it was taken and giving it
+//as stored once leased. The lease is only stored when the delivery is unchanged since read, so a single worker
+//takes it
+func (w *Worker) lease(ctx context.Context, partition int, id string) (Delivery, json.RawMessage, bool, error) {
+	key := deliveryKey(partition, id)
+	d := Delivery{}
+	raw := json.RawMessage{}
+	if err := w.cache.Get(ctx, key, &raw); err != nil {
+		return d, nil, false, err
+	}
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return d, nil, false, err
+	}
+	if time.Now().Before(d.LeasedUntil) {
+		return d, nil, false, nil
+	}
+	d.LeasedUntil = time.Now().Add(w.opts.LeaseTimeout).UTC()
+	leased, err := w.cache.CompareAndSet(ctx, key, raw, d)
+	if err != nil || !leased {
+		return d, nil, false, err
+	}
+	//read as stored, for the lease to be compared with when scheduling the retry
+	if err := w.cache.Get(ctx, key, &raw); err != nil {
+		return d, nil, false, err
+	}
+	return d, raw, true, nil
+}
+
+//deliver makes an attempt of the delivery when it is not leased, telling whether it was made.
+//A failed attempt leases it until the retry is due, on error it stays leased until its lease expires
+func (w *Worker) deliver(ctx context.Context, partition int, id string) (bool, error) {
+	log := w.logger.WithField("delivery_id", id)
+
+	d, leased, ok, err := w.lease(ctx, partition, id)
+	if cache.IsNotFound(err) {
+		log.Warn(ctx, "Delivery not found, dropping it")
+		return false, w.cache.ListRemove(ctx, ProcessingKey(partition), id)
+	}
+	if err != nil || !ok {
+		return false, err
+	}
+
+	log = log.WithField("webhook_id", d.SubscriptionID).WithField("event_id", d.Event.ID)
+	sub := Subscription{}
+	err = w.cache.Get(ctx, subscriptionKey(d.SubscriptionID), &sub)
+	if cache.IsNotFound(err) {
+		log.Info(ctx, "Subscription was deleted, dropping delivery")
+		return false, w.finish(ctx, partition, id)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	body, err := json.Marshal(d.Event)
+	if err != nil {
+		return false, err
+	}
+
+	attempt := d.Attempts + 1
+	status, sendErr := w.send(ctx, sub, d, body)
+	if sendErr != nil && ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	a := Attempt{
+		DeliveryID: d.ID,
+		EventID:    d.Event.ID,
+		EventType:  d.Event.Type,
+		Attempt:    attempt,
+		StatusCode: status,
+		Delivered:  sendErr == nil,
+		At:         time.Now().UTC(),
+	}
+	if sendErr != nil {
+		a.Error = sendErr.Error()
+	}
+	if err := w.record(ctx, sub.ID, a); err != nil {
+		log.WithError(err).Warn(ctx, "Unable to record delivery attempt")
+	}
+
+	if sendErr == nil {
+		log.WithField("attempt", attempt).Info(ctx, "Webhook delivered")
+		return true, w.finish(ctx, partition, id)
+	}
+	log.WithField("attempt", attempt).WithError(sendErr).Warn(ctx, "Webhook delivery failed")
+
+	if attempt < w.opts.MaxAttempts {
+		d.Attempts = attempt
+		d.LeasedUntil = time.Now().Add(w.backoff(attempt)).UTC()
+		if _, err := w.cache.CompareAndSet(ctx, deliveryKey(partition, id), leased, d); err != nil {
+			return true, err
+		}
+		return true, nil
+	}
+
+	log.Error(ctx, "Webhook delivery exhausted its attempts, moving it to the dead letters")
+	b, err := json.Marshal(d)
+	if err != nil {
+		return true, err
+	}
+	err = w.cache.Tx(ctx,
+		cache.PushOp(deadLetterKey(sub.ID), string(b)),
+		cache.TrimOp(deadLetterKey(sub.ID), MaxLogEntries),
+	)
+	if err != nil {
+		return true, err
+	}
+	return true, w.finish(ctx, partition, id)
+}
+
+//send makes a single attempt, giving the status answered by the receiver if any
+func (w *Worker) send(ctx context.Context, sub Subscription, d Delivery, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDelivery, d.ID)
+	req.Header.Set(HeaderEvent, d.Event.Type)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, now, body))
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("receiver answered %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+func (w *Worker) record(ctx context.Context, subscriptionID string, a Attempt) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return w.cache.Tx(ctx,
+		cache.PushOp(logKey(subscriptionID), string(b)),
+		cache.TrimOp(logKey(subscriptionID), MaxLogEntries),
+	)
+}
+
+//finish forgets a delivery that needs no more attempts
+func (w *Worker) finish(ctx context.Context, partition int, id string) error {
+	if err := w.cache.Tx(ctx, cache.DelOp(deliveryKey(partition, id))); err != nil {
+		return err
+	}
+	return w.cache.ListRemove(ctx, ProcessingKey(partition), id)
+}
+
+//backoff gives the wait before the given retry, starting at 1
+func (w *Worker) backoff(retry int) time.Duration {
+	d := w.opts.Backoff
+	for i := 1; i < retry; i++ {
+		d *= 2
+		if d >= w.opts.MaxBackoff {
+			return w.opts.MaxBackoff
+		}
+	}
+	return d
+}
@@ -0,0 +1,331 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/events"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+//partition is the one of the deliveries of the events of someCart
+var partition = cache.Partition("someCart")
+
+var testOptions = webhook.WorkerOptions{
+	MaxAttempts:  3,
+	Backoff:      time.Millisecond,
+	MaxBackoff:   5 * time.Millisecond,
+	Concurrency:  2,
+	PollInterval: time.Millisecond,
+}
+
+//receiver is an httptest server failing the first failures requests and checking every signature
+type receiver struct {
+	mu       sync.Mutex
+	secret   string
+	failures int
+	calls    int
+	received []events.Event
+	badSigs  int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.calls++
+	if !webhook.Verify(rc.secret, r.Header.Get(webhook.HeaderTimestamp), r.Header.Get(webhook.HeaderSignature), body) {
+		rc.badSigs++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if rc.calls <= rc.failures {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	e := events.Event{}
+	json.Unmarshal(body, &e)
+	rc.received = append(rc.received, e)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func setup(t *testing.T, rc *receiver) (webhook.Service, *webhook.Worker, webhook.Subscription, cache.Cache) {
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+
+	c := cache.NewMemoryCache()
+	svc := webhook.NewService(testLogger, c, webhook.AllowPrivateTargets())
+	sub, err := svc.CreateSubscription(context.TODO(), srv.URL, nil, rc.secret)
+	assert.Nil(t, err)
+	return svc, webhook.NewWorker(testLogger, c, srv.Client(), testOptions), sub, c
+}
+
+//drainUntil drains the queue until done tells the deliveries are through, the retries being due later
+func drainUntil(t *testing.T, w *webhook.Worker, done func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Deliveries were not through in time")
+		}
+		_, err := w.Drain(context.TODO())
+		assert.Nil(t, err)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+//leaseOf changes the lease of the only delivery taken, as another worker would
+func leaseOf(t *testing.T, c cache.Cache, until time.Time) {
+	ids, _ := c.ListRange(context.TODO(), webhook.ProcessingKey(partition), 0, -1)
+	assert.Len(t, ids, 1)
+	key := "webhook:delivery:" + cache.PartitionTag(partition) + ":" + ids[0]
+	d := webhook.Delivery{}
+	assert.Nil(t, c.Get(context.TODO(), key, &d))
+	d.LeasedUntil = until
+	assert.Nil(t, c.Set(context.TODO(), key, d))
+}
+
+func TestWorker_DeliversSigned(t *testing.T) {
+	rc := &receiver{secret: "secret"}
+	svc, w, sub, c := setup(t, rc)
+
+	e, _ := events.New("cart.created", "someCart", nil)
+	assert.Nil(t, svc.Publish(context.TODO(), e))
+
+	n, err := w.Drain(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 0, rc.badSigs)
+	assert.Len(t, rc.received, 1)
+	assert.Equal(t, e.ID, rc.received[0].ID)
+
+	attempts, _ := svc.GetDeliveryLog(context.TODO(), sub.ID)
+	assert.Len(t, attempts, 1)
+	assert.True(t, attempts[0].Delivered)
+	assert.Equal(t, http.StatusNoContent, attempts[0].StatusCode)
+	processing, _ := c.ListRange(context.TODO(), webhook.ProcessingKey(partition), 0, -1)
+	assert.Empty(t, processing)
+}
+
+func TestWorker_RetriesWithBackoff(t *testing.T) {
+	rc := &receiver{secret: "secret", failures: 2}
+	svc, w, sub, _ := setup(t, rc)
+
+	e, _ := events.New("cart.created", "someCart", nil)
+	svc.Publish(context.TODO(), e)
+	drainUntil(t, w, func() bool { return len(rc.received) == 1 })
+
+	assert.Equal(t, 3, rc.calls)
+	assert.Len(t, rc.received, 1)
+	attempts, _ := svc.GetDeliveryLog(context.TODO(), sub.ID)
+	assert.Len(t, attempts, 3)
+	assert.True(t, attempts[0].Delivered, "the newest attempt must be the successful one")
+	assert.Equal(t, 3, attempts[0].Attempt)
+	dead, _ := svc.GetDeadLetters(context.TODO(), sub.ID)
+	assert.Empty(t, dead)
+}
+
+func TestWorker_DeadLetters(t *testing.T) {
+	rc := &receiver{secret: "secret", failures: 10}
+	svc, w, sub, c := setup(t, rc)
+
+	e, _ := events.New("cart.created", "someCart", nil)
+	svc.Publish(context.TODO(), e)
+	drainUntil(t, w, func() bool {
+		dead, _ := svc.GetDeadLetters(context.TODO(), sub.ID)
+		return len(dead) == 1
+	})
+
+	assert.Equal(t, testOptions.MaxAttempts, rc.calls)
+	dead, _ := svc.GetDeadLetters(context.TODO(), sub.ID)
+	assert.Len(t, dead, 1)
+	assert.Equal(t, e.ID, dead[0].Event.ID)
+	processing, _ := c.ListRange(context.TODO(), webhook.ProcessingKey(partition), 0, -1)
+	assert.Empty(t, processing)
+}
+
+func TestWorker_RetriesAreScheduled(t *testing.T) {
+	rc := &receiver{secret: "secret", failures: 1}
+	svc, _, _, c := setup(t, rc)
+	opts := testOptions
+	opts.Backoff, opts.MaxBackoff = time.Hour, time.Hour
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+	w := webhook.NewWorker(testLogger, c, srv.Client(), opts)
+
+	e, _ := events.New("cart.created", "someCart", nil)
+	svc.Publish(context.TODO(), e)
+	start := time.Now()
+	n, err := w.Drain(context.TODO())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Less(t, int64(time.Since(start)), int64(time.Minute), "the retry must not be waited for")
+	assert.Equal(t, 1, rc.calls)
+
+	//not due yet
+	n, _ = w.Drain(context.TODO())
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 1, rc.calls)
+
+	leaseOf(t, c, time.Now().Add(-time.Second))
+	n, _ = w.Drain(context.TODO())
+	assert.Equal(t, 1, n)
+	assert.Len(t, rc.received, 1)
+}
+
+func TestWorker_DrainsEveryPartition(t *testing.T) {
+	rc := &receiver{secret: "secret"}
+	svc, w, _, c := setup(t, rc)
+
+	partitions := map[int]bool{}
+	for idx := 0; len(partitions) < 3; idx++ {
+		aggregateID := fmt.Sprintf("cart-%d", idx)
+		if partitions[cache.Partition(aggregateID)] {
+			continue
+		}
+		partitions[cache.Partition(aggregateID)] = true
+		e, _ := events.New("cart.created", aggregateID, nil)
+		assert.Nil(t, svc.Publish(context.TODO(), e))
+	}
+
+	n, err := w.Drain(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	assert.Len(t, rc.received, 3)
+	for p := range partitions {
+		processing, _ := c.ListRange(context.TODO(), webhook.ProcessingKey(p), 0, -1)
+		assert.Empty(t, processing)
+	}
+}
+
+func TestWorker_LooksAtBatchesOfDeliveries(t *testing.T) {
+	rc := &receiver{secret: "secret", failures: 1}
+	svc, _, _, c := setup(t, rc)
+	opts := testOptions
+	opts.Backoff, opts.MaxBackoff, opts.BatchSize = time.Hour, time.Hour, 2
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+	w := webhook.NewWorker(testLogger, c, srv.Client(), opts)
+
+	for i := 0; i < 5; i++ {
+		e, _ := events.New("cart.created", "someCart", nil)
+		svc.Publish(context.TODO(), e)
+	}
+
+	//the first delivery fails, waiting an hour for its retry without holding back the others
+	attempts := []int{}
+	for i := 0; i < 4; i++ {
+		n, err := w.Drain(context.TODO())
+		assert.Nil(t, err)
+		attempts = append(attempts, n)
+	}
+	assert.Equal(t, []int{2, 2, 1, 0}, attempts)
+	assert.Len(t, rc.received, 4)
+	processing, _ := c.ListRange(context.TODO(), webhook.ProcessingKey(partition), 0, -1)
+	assert.Len(t, processing, 1)
+}
+
+func TestWorker_RetakesExpiredLeases(t *testing.T) {
+	rc := &receiver{secret: "secret"}
+	svc, w, _, c := setup(t, rc)
+
+	e, _ := events.New("cart.created", "someCart", nil)
+	svc.Publish(context.TODO(), e)
+	//another worker took the delivery and is still attempting it
+	c.ListMove(context.TODO(), webhook.PendingKey(partition), webhook.ProcessingKey(partition))
+	leaseOf(t, c, time.Now().Add(time.Hour))
+
+	n, err := w.Drain(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 0, rc.calls)
+
+	//and stopped before finishing it
+	leaseOf(t, c, time.Now().Add(-time.Second))
+	n, err = w.Drain(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Len(t, rc.received, 1)
+	processing, _ := c.ListRange(context.TODO(), webhook.ProcessingKey(partition), 0, -1)
+	assert.Empty(t, processing)
+}
+
+func TestWorker_TakesEveryDeliveryOnce(t *testing.T) {
+	rc := &receiver{secret: "secret"}
+	svc, w, _, c := setup(t, rc)
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+	other := webhook.NewWorker(testLogger, c, srv.Client(), testOptions)
+
+	for i := 0; i < 10; i++ {
+		e, _ := events.New("cart.created", "someCart", nil)
+		svc.Publish(context.TODO(), e)
+	}
+	wg := sync.WaitGroup{}
+	for _, worker := range []*webhook.Worker{w, other} {
+		wg.Add(1)
+		go func(worker *webhook.Worker) {
+			defer wg.Done()
+			worker.Drain(context.TODO())
+		}(worker)
+	}
+	wg.Wait()
+	drainUntil(t, w, func() bool {
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+		return len(rc.received) == 10
+	})
+
+	assert.Equal(t, 10, rc.calls)
+}
+
+func TestWorker_DropsDeletedSubscription(t *testing.T) {
+	rc := &receiver{secret: "secret"}
+	svc, w, sub, c := setup(t, rc)
+
+	e, _ := events.New("cart.created", "someCart", nil)
+	svc.Publish(context.TODO(), e)
+	svc.DeleteSubscription(context.TODO(), sub.ID)
+	w.Drain(context.TODO())
+
+	assert.Equal(t, 0, rc.calls)
+	processing, _ := c.ListRange(context.TODO(), webhook.ProcessingKey(partition), 0, -1)
+	assert.Empty(t, processing)
+}
+
+func TestWorker_HealthWhileRunning(t *testing.T) {
+	_, w, _, _ := setup(t, &receiver{secret: "secret"})
+	assert.NotNil(t, w.Health(context.TODO()))
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		return w.Health(context.TODO()) == nil
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+	assert.NotNil(t, w.Health(context.TODO()))
+}
+
+func TestNewHTTPClient_RefusesForbiddenAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, err := webhook.NewHTTPClient(time.Second).Get(srv.URL)
+	assert.True(t, webhook.IsForbiddenTarget(err), "got %v", err)
+}
@@ -0,0 +1,142 @@
+package wishlist
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/response"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/currency"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	Service Service
+}
+
+//CreateList creates a new empty list
+func (c *Handler) CreateList(w http.ResponseWriter, r *http.Request) {
+	vm := CreateListRequest{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&vm)
+	if err != nil {
+		log.Printf("Error decoding body: %v", err)
+		response.RespondWithError(w, r, response.StandardBadBodyRequest)
+		return
+	}
+
+	l, err := c.Service.CreateList(r.Context(), vm.Name)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+	respondWithList(w, http.StatusCreated, l)
+}
+
+//GetList gives the list with the current price of its items
+func (c *Handler) GetList(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	l, err := c.Service.GetList(r.Context(), vars["list_id"])
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+	respondWithList(w, http.StatusOK, l)
+}
+
+//DeleteList removes the list and its items
+func (c *Handler) DeleteList(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := c.Service.DeleteList(r.Context(), vars["list_id"]); err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+	response.RespondWithData(w, http.StatusAccepted, nil)
+}
+
+//AddItem puts an item in the list
+func (c *Handler) AddItem(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vm := AddItemRequest{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&vm)
+	if err != nil {
+		log.Printf("Error decoding body: %v", err)
+		response.RespondWithError(w, r, response.StandardBadBodyRequest)
+		return
+	}
+
+	l, err := c.Service.AddItem(r.Context(), vars["list_id"], vm.ID, vm.Quantity)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+	respondWithList(w, http.StatusOK, l)
+}
+
+//RemoveItem takes an item out of the list
+func (c *Handler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	l, err := c.Service.RemoveItem(r.Context(), vars["list_id"], vars["item_id"])
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+	respondWithList(w, http.StatusOK, l)
+}
+
+//MoveFromCart saves an item of a cart in the list, answering the list
+func (c *Handler) MoveFromCart(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vm := MoveItemRequest{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&vm)
+	if err != nil {
+		log.Printf("Error decoding body: %v", err)
+		response.RespondWithError(w, r, response.StandardBadBodyRequest)
+		return
+	}
+
+	l, err := c.Service.MoveFromCart(r.Context(), vars["list_id"], vm.CartID, vm.ItemID)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+	respondWithList(w, http.StatusOK, l)
+}
+
+//MoveToCart brings an item of the list back to a cart, answering the cart
+func (c *Handler) MoveToCart(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vm := MoveItemRequest{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&vm)
+	if err != nil {
+		log.Printf("Error decoding body: %v", err)
+		response.RespondWithError(w, r, response.StandardBadBodyRequest)
+		return
+	}
+
+	updated, err := c.Service.MoveToCart(r.Context(), vars["list_id"], vm.CartID, vm.ItemID)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+	rate := currency.RateFromContext(r.Context())
+	res := cart.CartResponse{
+		Cart: cart.CartModelToTransportModelIn(updated, rate),
+	}
+	response.RespondWithData(w, http.StatusOK, res, rate.Meta())
+}
+
+func respondWithList(w http.ResponseWriter, status int, l List) {
+	res := ListResponse{
+		List: ListModelToTransportModel(l),
+	}
+	response.RespondWithData(w, status, res)
+}
@@ -0,0 +1,107 @@
+package wishlist_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/wishlist"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateList_Handler(t *testing.T) {
+	h := wishlist.Handler{
+		Service: &mockedService{},
+	}
+
+	req, err := http.NewRequest("POST", "/lists", bytes.NewReader([]byte(`{"name":"Wishlist"}`)))
+	assert.Nil(t, err)
+	rr := httptest.NewRecorder()
+
+	h.CreateList(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+}
+
+func TestCreateList_BadBody(t *testing.T) {
+	h := wishlist.Handler{
+		Service: &mockedService{},
+	}
+
+	req, err := http.NewRequest("POST", "/lists", bytes.NewReader([]byte(`{"title":"Wishlist"}`)))
+	assert.Nil(t, err)
+	rr := httptest.NewRecorder()
+
+	h.CreateList(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestGetList_HandlerNotFound(t *testing.T) {
+	h := wishlist.Handler{
+		Service: &mockedService{shouldFail: true},
+	}
+
+	req, err := http.NewRequest("GET", "/lists/missing", nil)
+	assert.Nil(t, err)
+	rr := httptest.NewRecorder()
+
+	h.GetList(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestMoveToCart_Handler(t *testing.T) {
+	h := wishlist.Handler{
+		Service: &mockedService{},
+	}
+
+	req, err := http.NewRequest("POST", "/lists/someList/move-to-cart", bytes.NewReader([]byte(`{"cart_id":"someCart","item_id":"1"}`)))
+	assert.Nil(t, err)
+	rr := httptest.NewRecorder()
+
+	h.MoveToCart(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+type mockedService struct {
+	shouldFail bool
+}
+
+func (m *mockedService) result() (wishlist.List, error) {
+	if m.shouldFail {
+		return wishlist.List{}, errors.ServiceError{Code: errors.ListNotFoundCode}
+	}
+	return wishlist.List{ID: "someList"}, nil
+}
+
+func (m *mockedService) CreateList(ctx context.Context, name string) (wishlist.List, error) {
+	return m.result()
+}
+func (m *mockedService) GetList(ctx context.Context, listID string) (wishlist.List, error) {
+	return m.result()
+}
+func (m *mockedService) DeleteList(ctx context.Context, listID string) error {
+	_, err := m.result()
+	return err
+}
+func (m *mockedService) AddItem(ctx context.Context, listID, itemID string, quantity int) (wishlist.List, error) {
+	return m.result()
+}
+func (m *mockedService) RemoveItem(ctx context.Context, listID, itemID string) (wishlist.List, error) {
+	return m.result()
+}
+func (m *mockedService) MoveFromCart(ctx context.Context, listID, cartID, itemID string) (wishlist.List, error) {
+	return m.result()
+}
+func (m *mockedService) MoveToCart(ctx context.Context, listID, cartID, itemID string) (cart.Cart, error) {
+	if _, err := m.result(); err != nil {
+		return cart.Cart{}, err
+	}
+	return cart.Cart{ID: cartID}, nil
+}
@@ -0,0 +1,73 @@
+package wishlist
+
+import "time"
+
+//List keeps items out of the cart without losing them, such as a save-for-later list or a wishlist
+type List struct {
+	ID        string
+	Name      string
+	Items     []Item
+	CreatedAt time.Time
+}
+
+//Item is an item kept in a list. Name and Price are the current ones of the provider
+type Item struct {
+	ID       string
+	Name     string
+	Quantity int
+	Price    float32
+	AddedAt  time.Time
+}
+
+type TransportItem struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Quantity int       `json:"quantity"`
+	Price    float32   `json:"price"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+type TransportList struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Items     []TransportItem `json:"items"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type ListResponse struct {
+	List TransportList `json:"list"`
+}
+
+type CreateListRequest struct {
+	Name string `json:"name"`
+}
+
+type AddItemRequest struct {
+	ID       string `json:"id"`
+	Quantity int    `json:"quantity"`
+}
+
+//MoveItemRequest names the cart and item of a move between a list and a cart
+type MoveItemRequest struct {
+	CartID string `json:"cart_id"`
+	ItemID string `json:"item_id"`
+}
+
+func ListModelToTransportModel(l List) TransportList {
+	res := TransportList{
+		ID:        l.ID,
+		Name:      l.Name,
+		Items:     []TransportItem{},
+		CreatedAt: l.CreatedAt,
+	}
+	for _, i := range l.Items {
+		res.Items = append(res.Items, TransportItem{
+			ID:       i.ID,
+			Name:     i.Name,
+			Quantity: i.Quantity,
+			Price:    i.Price,
+			AddedAt:  i.AddedAt,
+		})
+	}
+	return res
+}
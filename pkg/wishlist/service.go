@@ -0,0 +1,320 @@
+package wishlist
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	"github.com/google/uuid"
+)
+
+const keyPrefix = "list:"
+
+func listKey(id string) string {
+	return keyPrefix + id
+}
+
+//Service manages the lists and moves items between them and the carts.
+//Carts are only changed through cart.Service, so moves emit the usual cart events
+type Service interface {
+	CreateList(ctx context.Context, name string) (List, error)
+	GetList(ctx context.Context, listID string) (List, error)
+	DeleteList(ctx context.Context, listID string) error
+	//AddItem puts the item in the list, adding to the quantity already there
+	AddItem(ctx context.Context, listID, itemID string, quantity int) (List, error)
+	RemoveItem(ctx context.Context, listID, itemID string) (List, error)
+	//MoveFromCart takes the item out of the cart into the list, keeping its quantity. The item may be given
+	//by the ID of its line in the cart
+	MoveFromCart(ctx context.Context, listID, cartID, itemID string) (List, error)
+	//MoveToCart takes the item out of the list into the cart, keeping its quantity
+	MoveToCart(ctx context.Context, listID, cartID, itemID string) (cart.Cart, error)
+}
+
+type service struct {
+	logger logger.Logger
+	cache  cache.Cache
+	carts  cart.Service
+	items  item.Service
+}
+
+func NewService(logger logger.Logger, c cache.Cache, carts cart.Service, items item.Service) Service {
+	return &service{
+		logger: logger,
+		cache:  c,
+		carts:  carts,
+		items:  items,
+	}
+}
+
+func (s *service) CreateList(ctx context.Context, name string) (List, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return List{}, errors.ServiceError{Code: errors.ValidationErrorCode}.
+			WithFieldViolation("name", "must not be empty")
+	}
+
+	l := List{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Items:     []Item{},
+		CreatedAt: time.Now().UTC(),
+	}
+	log := s.logger.WithField("list_id", l.ID)
+	log.Info(ctx, "Creating list")
+	if err := s.cache.Set(ctx, listKey(l.ID), l); err != nil {
+		log.WithError(err).Error(ctx, "Unable to save list in DB")
+		return List{}, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	return l, nil
+}
+
+func (s *service) GetList(ctx context.Context, listID string) (List, error) {
+	l, err := s.get(ctx, listID)
+	if err != nil {
+		return List{}, err
+	}
+	return s.withProviderData(ctx, l)
+}
+
+func (s *service) DeleteList(ctx context.Context, listID string) error {
+	if _, err := s.get(ctx, listID); err != nil {
+		return err
+	}
+	s.logger.WithField("list_id", listID).Info(ctx, "Deleting list")
+	if err := s.cache.Del(ctx, listKey(listID)); err != nil {
+		return errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	return nil
+}
+
+func (s *service) AddItem(ctx context.Context, listID, itemID string, quantity int) (List, error) {
+	if err := validateItem(itemID, quantity); err != nil {
+		return List{}, err
+	}
+	l, err := s.get(ctx, listID)
+	if err != nil {
+		return List{}, err
+	}
+	//unknown items would break every later read of the list, so they are refused upfront
+	if _, err := s.items.GetItem(ctx, itemID); err != nil {
+		return List{}, err
+	}
+
+	s.logger.WithField("list_id", listID).WithField("item_id", itemID).Info(ctx, "Adding item to list")
+	l = withItem(l, itemID, quantity)
+	if err := s.save(ctx, l); err != nil {
+		return List{}, err
+	}
+	return s.withProviderData(ctx, l)
+}
+
+func (s *service) RemoveItem(ctx context.Context, listID, itemID string) (List, error) {
+	l, err := s.get(ctx, listID)
+	if err != nil {
+		return List{}, err
+	}
+	l, _, ok := withoutItem(l, itemID)
+	if !ok {
+		return List{}, errors.ServiceError{Code: errors.ListItemNotFoundCode}.
+			WithDetail("list_id", listID).
+			WithDetail("item_id", itemID)
+	}
+
+	s.logger.WithField("list_id", listID).WithField("item_id", itemID).Info(ctx, "Removing item from list")
+	if err := s.save(ctx, l); err != nil {
+		return List{}, err
+	}
+	return s.withProviderData(ctx, l)
+}
+
+func (s *service) MoveFromCart(ctx context.Context, listID, cartID, itemID string) (List, error) {
+	log := s.logger.
+		WithField("list_id", listID).
+		WithField("cart_id", cartID).
+		WithField("item_id", itemID)
+
+	l, err := s.get(ctx, listID)
+	if err != nil {
+		return List{}, err
+	}
+	c, err := s.carts.GetCart(ctx, cartID)
+	if err != nil {
+		return List{}, err
+	}
+	//the item may be given by the ID of its line in the cart, lists keep no options nor notes
+	line, ok := c.Line(itemID)
+	if !ok {
+		return List{}, errors.ServiceError{Code: errors.ItemNotFoundCode}.
+			WithDetail("cart_id", cartID).
+			WithDetail("item_id", itemID)
+	}
+
+	log.Info(ctx, "Moving item from cart to list")
+	moved := withItem(l, line.ID, line.Quantity)
+	if err := s.save(ctx, moved); err != nil {
+		return List{}, err
+	}
+	if _, err := s.carts.DeleteItemInCart(ctx, cartID, line.LineID); err != nil {
+		//the item stays in the cart, so it is taken back out of the list
+		log.WithError(err).Error(ctx, "Unable to remove item from cart, restoring list")
+		if rErr := s.save(ctx, l); rErr != nil {
+			log.WithError(rErr).Error(ctx, "Unable to restore list")
+		}
+		return List{}, err
+	}
+	return s.withProviderData(ctx, moved)
+}
+
+func (s *service) MoveToCart(ctx context.Context, listID, cartID, itemID string) (cart.Cart, error) {
+	log := s.logger.
+		WithField("list_id", listID).
+		WithField("cart_id", cartID).
+		WithField("item_id", itemID)
+
+	l, err := s.get(ctx, listID)
+	if err != nil {
+		return cart.Cart{}, err
+	}
+	remaining, moved, ok := withoutItem(l, itemID)
+	if !ok {
+		return cart.Cart{}, errors.ServiceError{Code: errors.ListItemNotFoundCode}.
+			WithDetail("list_id", listID).
+			WithDetail("item_id", itemID)
+	}
+	c, err := s.carts.GetCart(ctx, cartID)
+	if err != nil {
+		return cart.Cart{}, err
+	}
+	//items of the list go to the line of the item without options nor note
+	lineID := cart.LineID(itemID, nil, "")
+	inCart := 0
+	if line, ok := c.Line(lineID); ok {
+		inCart = line.Quantity
+	}
+
+	log.Info(ctx, "Moving item from list to cart")
+	//an item already in the cart gets the quantity of the list on top of its own
+	if inCart > 0 {
+		c, err = s.carts.ModifyItemInCart(ctx, cartID, lineID, inCart+moved.Quantity)
+	} else {
+		c, err = s.carts.AddItemToCart(ctx, cartID, itemID, moved.Quantity)
+	}
+	if err != nil {
+		return cart.Cart{}, err
+	}
+	if err := s.save(ctx, remaining); err != nil {
+		//the item stays in the list, so the cart is taken back to how it was
+		log.WithError(err).Error(ctx, "Unable to remove item from list, restoring cart")
+		var rErr error
+		if inCart > 0 {
+			_, rErr = s.carts.ModifyItemInCart(ctx, cartID, lineID, inCart)
+		} else {
+			_, rErr = s.carts.DeleteItemInCart(ctx, cartID, lineID)
+		}
+		if rErr != nil {
+			log.WithError(rErr).Error(ctx, "Unable to restore cart")
+		}
+		return cart.Cart{}, err
+	}
+	return c, nil
+}
+
+func (s *service) get(ctx context.Context, listID string) (List, error) {
+	l := List{}
+	if err := s.cache.Get(ctx, listKey(listID), &l); err != nil {
+		s.logger.WithField("list_id", listID).WithError(err).Error(ctx, "Unable to get list from DB")
+		if cache.IsNotFound(err) {
+			return List{}, errors.ServiceError{Code: errors.ListNotFoundCode}.
+				WithDetail("list_id", listID).
+				WithCause(err)
+		}
+		return List{}, errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	return l, nil
+}
+
+func (s *service) save(ctx context.Context, l List) error {
+	if err := s.cache.Set(ctx, listKey(l.ID), l); err != nil {
+		s.logger.WithField("list_id", l.ID).WithError(err).Error(ctx, "Unable to save list in DB")
+		return errors.ServiceError{Code: errors.CacheErrorCode}.WithCause(err)
+	}
+	return nil
+}
+
+//withProviderData fills in the current name and price of every item, fetching them in a single batch
+func (s *service) withProviderData(ctx context.Context, l List) (List, error) {
+	ids := make([]string, 0, len(l.Items))
+	for _, i := range l.Items {
+		ids = append(ids, i.ID)
+	}
+	loader, ok := item.LoaderFromContext(ctx)
+	if !ok {
+		loader = item.NewLoader(s.items, 0)
+	}
+	found, err := loader.LoadMany(ctx, ids)
+	if err != nil {
+		s.logger.WithField("list_id", l.ID).WithError(err).Error(ctx, "Unable to get items from provider")
+		return List{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}.WithCause(err)
+	}
+	for idx, i := range found {
+		l.Items[idx].Name = i.Name
+		l.Items[idx].Price = i.Price
+	}
+	return l, nil
+}
+
+//withItem gives the list with the item added, or its quantity increased when already there
+func withItem(l List, itemID string, quantity int) List {
+	items := make([]Item, 0, len(l.Items)+1)
+	found := false
+	for _, i := range l.Items {
+		if i.ID == itemID {
+			i.Quantity += quantity
+			found = true
+		}
+		items = append(items, i)
+	}
+	if !found {
+		items = append(items, Item{
+			ID:       itemID,
+			Quantity: quantity,
+			AddedAt:  time.Now().UTC(),
+		})
+	}
+	l.Items = items
+	return l
+}
+
+//withoutItem gives the list without the item, and the item removed. ok is false when it was not in the list
+func withoutItem(l List, itemID string) (res List, removed Item, ok bool) {
+	items := make([]Item, 0, len(l.Items))
+	for _, i := range l.Items {
+		if i.ID == itemID {
+			removed = i
+			ok = true
+			continue
+		}
+		items = append(items, i)
+	}
+	l.Items = items
+	return l, removed, ok
+}
+
+func validateItem(itemID string, quantity int) error {
+	vErr := errors.ServiceError{Code: errors.ValidationErrorCode}
+	if itemID == "" {
+		vErr = vErr.WithFieldViolation("id", "must not be empty")
+	}
+	if quantity <= 0 {
+		vErr = vErr.WithFieldViolation("quantity", "must be greater than zero")
+	}
+	if len(vErr.Fields) > 0 {
+		return vErr
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package wishlist_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/wishlist"
+	"github.com/stretchr/testify/assert"
+)
+
+var testLogger = logger.NewLogger("wishlist unit test", false)
+
+func newServices(c cache.Cache, items item.Service) (wishlist.Service, cart.Service) {
+	carts := cart.NewCartService("unit-testing", testLogger, c, items)
+	return wishlist.NewService(testLogger, c, carts, items), carts
+}
+
+func quantities(items []item.Item) map[string]int {
+	res := map[string]int{}
+	for _, i := range items {
+		res[i.ID] = i.Quantity
+	}
+	return res
+}
+
+func TestCreateList(t *testing.T) {
+	svc, _ := newServices(cache.NewMemoryCache(), &providerMock{})
+
+	l, err := svc.CreateList(context.TODO(), " Save for later ")
+	assert.Nil(t, err)
+	assert.Equal(t, "Save for later", l.Name)
+
+	_, err = svc.CreateList(context.TODO(), " ")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ValidationErrorCode})
+}
+
+func TestGetList_NotFound(t *testing.T) {
+	svc, _ := newServices(cache.NewMemoryCache(), &providerMock{})
+
+	_, err := svc.GetList(context.TODO(), "missing")
+
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ListNotFoundCode})
+}
+
+func TestAddItem_CurrentPrices(t *testing.T) {
+	provider := &providerMock{}
+	svc, _ := newServices(cache.NewMemoryCache(), provider)
+	l, err := svc.CreateList(context.TODO(), "Wishlist")
+	assert.Nil(t, err)
+
+	_, err = svc.AddItem(context.TODO(), l.ID, "1", 1)
+	assert.Nil(t, err)
+	_, err = svc.AddItem(context.TODO(), l.ID, "2", 1)
+	assert.Nil(t, err)
+	_, err = svc.AddItem(context.TODO(), l.ID, "1", 2)
+	assert.Nil(t, err)
+
+	provider.setPrice(50)
+	provider.reset()
+	l, err = svc.GetList(context.TODO(), l.ID)
+	assert.Nil(t, err)
+	assert.Len(t, l.Items, 2)
+	assert.Equal(t, 3, l.Items[0].Quantity)
+	assert.Equal(t, "Item 1", l.Items[0].Name)
+	assert.Equal(t, float32(50), l.Items[0].Price)
+	assert.Equal(t, float32(50), l.Items[1].Price)
+	//both prices come from a single provider call
+	assert.Equal(t, 1, provider.calls())
+}
+
+func TestAddItem_UnknownOnProvider(t *testing.T) {
+	svc, _ := newServices(cache.NewMemoryCache(), &providerMock{})
+	l, err := svc.CreateList(context.TODO(), "Wishlist")
+	assert.Nil(t, err)
+
+	_, err = svc.AddItem(context.TODO(), l.ID, "99", 1)
+
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ItemNotFoundOnProviderCode})
+}
+
+func TestMoveFromCart(t *testing.T) {
+	svc, carts := newServices(cache.NewMemoryCache(), &providerMock{})
+	c, err := carts.CreateCart(context.TODO())
+	assert.Nil(t, err)
+	_, err = carts.AddItemToCart(context.TODO(), c.ID, "1", 4)
+	assert.Nil(t, err)
+	l, err := svc.CreateList(context.TODO(), "Save for later")
+	assert.Nil(t, err)
+
+	l, err = svc.MoveFromCart(context.TODO(), l.ID, c.ID, "1")
+	assert.Nil(t, err)
+	assert.Len(t, l.Items, 1)
+	assert.Equal(t, 4, l.Items[0].Quantity)
+
+	c, err = carts.GetCart(context.TODO(), c.ID)
+	assert.Nil(t, err)
+	assert.Empty(t, c.Items)
+
+	_, err = svc.MoveFromCart(context.TODO(), l.ID, c.ID, "1")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ItemNotFoundCode})
+}
+
+func TestMoveFromCart_ByLineID(t *testing.T) {
+	svc, carts := newServices(cache.NewMemoryCache(), &providerMock{})
+	c, err := carts.CreateCart(context.TODO())
+	assert.Nil(t, err)
+	_, err = carts.AddItemToCart(context.TODO(), c.ID, "1", 1)
+	assert.Nil(t, err)
+	line := cart.Line{ItemID: "1", Options: map[string]string{"size": "M"}}
+	_, err = carts.AddLineToCart(context.TODO(), c.ID, line, 2)
+	assert.Nil(t, err)
+	l, err := svc.CreateList(context.TODO(), "Save for later")
+	assert.Nil(t, err)
+
+	l, err = svc.MoveFromCart(context.TODO(), l.ID, c.ID, line.ID())
+	assert.Nil(t, err)
+	assert.Len(t, l.Items, 1)
+	assert.Equal(t, 2, l.Items[0].Quantity)
+
+	//the line without options is left in the cart
+	c, err = carts.GetCart(context.TODO(), c.ID)
+	assert.Nil(t, err)
+	assert.Len(t, c.Items, 1)
+	assert.Equal(t, cart.LineID("1", nil, ""), c.Items[0].LineID)
+}
+
+func TestMoveToCart(t *testing.T) {
+	svc, carts := newServices(cache.NewMemoryCache(), &providerMock{})
+	c, err := carts.CreateCart(context.TODO())
+	assert.Nil(t, err)
+	_, err = carts.AddItemToCart(context.TODO(), c.ID, "1", 1)
+	assert.Nil(t, err)
+	l, err := svc.CreateList(context.TODO(), "Save for later")
+	assert.Nil(t, err)
+	_, err = svc.AddItem(context.TODO(), l.ID, "1", 2)
+	assert.Nil(t, err)
+	_, err = svc.AddItem(context.TODO(), l.ID, "2", 3)
+	assert.Nil(t, err)
+
+	_, err = svc.MoveToCart(context.TODO(), l.ID, c.ID, "1")
+	assert.Nil(t, err)
+	c, err = svc.MoveToCart(context.TODO(), l.ID, c.ID, "2")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"1": 3, "2": 3}, quantities(c.Items))
+
+	l, err = svc.GetList(context.TODO(), l.ID)
+	assert.Nil(t, err)
+	assert.Empty(t, l.Items)
+
+	_, err = svc.MoveToCart(context.TODO(), l.ID, c.ID, "1")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.ListItemNotFoundCode})
+}
+
+func TestMoveToCart_RestoresCartWhenListFails(t *testing.T) {
+	c := &failingListCache{Cache: cache.NewMemoryCache()}
+	svc, carts := newServices(c, &providerMock{})
+	ct, err := carts.CreateCart(context.TODO())
+	assert.Nil(t, err)
+	l, err := svc.CreateList(context.TODO(), "Save for later")
+	assert.Nil(t, err)
+	_, err = svc.AddItem(context.TODO(), l.ID, "1", 2)
+	assert.Nil(t, err)
+
+	c.fail = true
+	_, err = svc.MoveToCart(context.TODO(), l.ID, ct.ID, "1")
+	assert.ErrorIs(t, err, errors.ServiceError{Code: errors.CacheErrorCode})
+
+	ct, err = carts.GetCart(context.TODO(), ct.ID)
+	assert.Nil(t, err)
+	assert.Empty(t, ct.Items)
+	l, err = svc.GetList(context.TODO(), l.ID)
+	assert.Nil(t, err)
+	assert.Len(t, l.Items, 1)
+}
+
+//failingListCache fails saving lists when asked to
+type failingListCache struct {
+	cache.Cache
+	fail bool
+}
+
+func (c *failingListCache) Set(ctx context.Context, key string, value interface{}) error {
+	if c.fail && strings.HasPrefix(key, "list:") {
+		return fmt.Errorf("cache was asked to fail")
+	}
+	return c.Cache.Set(ctx, key, value)
+}
+
+type providerMock struct {
+	mu       sync.Mutex
+	price    float32
+	getCalls int
+	allCalls int
+}
+
+var catalog = []string{"1", "2", "3"}
+
+func (p *providerMock) setPrice(price float32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.price = price
+}
+
+func (p *providerMock) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.getCalls, p.allCalls = 0, 0
+}
+
+func (p *providerMock) calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.getCalls + p.allCalls
+}
+
+func (p *providerMock) item(id string) item.Item {
+	price := p.price
+	if price == 0 {
+		price = 10
+	}
+	return item.Item{ID: id, Name: "Item " + id, Price: price}
+}
+
+func (p *providerMock) Health(ctx context.Context) error {
+	return nil
+}
+
+func (p *providerMock) GetItem(ctx context.Context, id string) (item.Item, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.getCalls++
+	for _, c := range catalog {
+		if c == id {
+			return p.item(id), nil
+		}
+	}
+	return item.Item{}, errors.ServiceError{Code: errors.ItemNotFoundOnProviderCode}
+}
+
+func (p *providerMock) GetAllItems(ctx context.Context) ([]item.Item, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.allCalls++
+	res := []item.Item{}
+	for _, id := range catalog {
+		res = append(res, p.item(id))
+	}
+	return res, nil
+}
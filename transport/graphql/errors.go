@@ -0,0 +1,38 @@
+package transport
+
+import (
+	"context"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/response"
+)
+
+//resolverError is a service error as a GraphQL error, its code, details and fields travel in the extensions
+type resolverError struct {
+	vm response.Error
+}
+
+func resolverErrorFromError(ctx context.Context, err error) error {
+	return resolverError{
+		vm: response.ErrorFromError(acceptLanguageFromContext(ctx), err),
+	}
+}
+
+func (e resolverError) Error() string {
+	return e.vm.Description
+}
+
+func (e resolverError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{
+		"code": e.vm.Code,
+	}
+	if len(e.vm.Details) > 0 {
+		ext["details"] = e.vm.Details
+	}
+	if len(e.vm.Fields) > 0 {
+		ext["fields"] = e.vm.Fields
+	}
+	if e.vm.DocumentationURL != "" {
+		ext["documentation_url"] = e.vm.DocumentationURL
+	}
+	return ext
+}
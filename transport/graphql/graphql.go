@@ -0,0 +1,89 @@
+package transport
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/response"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var schema string
+
+//maxDepth bounds how nested a query may be
+const maxDepth = 10
+
+//BatchWait is how long item lookups wait for more of them before calling the provider
+var BatchWait = 2 * time.Millisecond
+
+type contextKey string
+
+const acceptLanguageKey contextKey = "accept_language"
+
+//Request is a GraphQL query sent over HTTP
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	//Extensions are accepted, as many clients send them, but not used
+	Extensions map[string]interface{} `json:"extensions"`
+}
+
+//Handler serves the GraphQL schema
+type Handler struct {
+	schema *graphql.Schema
+	items  item.Service
+}
+
+//NewHandler gives the handler resolving the schema through the cart and item services
+func NewHandler(csvc cart.Service, isvc item.Service) *Handler {
+	return &Handler{
+		schema: graphql.MustParseSchema(schema, &rootResolver{
+			carts: csvc,
+			items: isvc,
+		}, graphql.MaxDepth(maxDepth)),
+		items: isvc,
+	}
+}
+
+//ServeHTTP runs the query sent in the body. The response follows the GraphQL spec, with data and errors,
+//instead of the envelope of the REST endpoints
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := Request{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		log.Printf("Error decoding body: %v", err)
+		response.RespondWithError(w, r, response.StandardBadBodyRequest)
+		return
+	}
+
+	//every lookup of the request, including the ones made by the cart service, shares the loader
+	ctx := item.ContextWithLoader(r.Context(), item.NewLoader(h.items, BatchWait))
+	ctx = context.WithValue(ctx, acceptLanguageKey, r.Header.Get("Accept-Language"))
+
+	res := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+//loaderFromContext gives the loader of the request, or one for this lookup alone when there is none
+func loaderFromContext(ctx context.Context, isvc item.Service) *item.Loader {
+	if l, ok := item.LoaderFromContext(ctx); ok {
+		return l
+	}
+	return item.NewLoader(isvc, 0)
+}
+
+func acceptLanguageFromContext(ctx context.Context) string {
+	lang, _ := ctx.Value(acceptLanguageKey).(string)
+	return lang
+}
@@ -0,0 +1,236 @@
+package transport_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	transport "github.com/eduardohoraciosanto/bootcamp-feature-driven/transport/graphql"
+	"github.com/stretchr/testify/assert"
+)
+
+type graphqlResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Path       []interface{}          `json:"path"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func newHandler(isvc item.Service) (*transport.Handler, cart.Service) {
+	csvc := cart.NewCartService("test", logger.NewLogger("graphql test", false), cache.NewMemoryCache(), isvc)
+	return transport.NewHandler(csvc, isvc), csvc
+}
+
+func execute(t *testing.T, h http.Handler, query string, variables map[string]interface{}) graphqlResponse {
+	b, err := json.Marshal(transport.Request{
+		Query:     query,
+		Variables: variables,
+	})
+	assert.Nil(t, err)
+	req, err := http.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(b))
+	assert.Nil(t, err)
+	req.Header.Set("Accept-Language", "es")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	res := graphqlResponse{}
+	assert.Nil(t, json.NewDecoder(rr.Body).Decode(&res))
+	return res
+}
+
+func TestCart_BatchesItemLookups(t *testing.T) {
+	isvc := &mockedItemService{}
+	h, csvc := newHandler(isvc)
+	c, err := csvc.CreateCart(context.Background())
+	assert.Nil(t, err)
+	for _, id := range []string{"1", "2", "3"} {
+		_, err := csvc.AddItemToCart(context.Background(), c.ID, id, 1)
+		assert.Nil(t, err)
+	}
+	isvc.reset()
+
+	res := execute(t, h, `query($id: ID!) {
+		cart(id: $id) { id items { id name quantity price } }
+		item(id: "2") { name }
+	}`, map[string]interface{}{"id": c.ID})
+
+	assert.Empty(t, res.Errors)
+	got := struct {
+		ID    string `json:"id"`
+		Items []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"items"`
+	}{}
+	assert.Nil(t, json.Unmarshal(res.Data["cart"], &got))
+	assert.Equal(t, c.ID, got.ID)
+	assert.Len(t, got.Items, 3)
+	assert.Equal(t, "Item 2", got.Items[1].Name)
+	assert.JSONEq(t, `{"name":"Item 2"}`, string(res.Data["item"]))
+	assert.Equal(t, 1, isvc.allCalls)
+	assert.Equal(t, 0, isvc.getCalls)
+}
+
+func TestItems_Filter(t *testing.T) {
+	isvc := &mockedItemService{}
+	h, _ := newHandler(isvc)
+
+	res := execute(t, h, `{
+		items(filter: {minPrice: 15}) { id }
+		item(id: "3") { name }
+	}`, nil)
+
+	assert.Empty(t, res.Errors)
+	assert.JSONEq(t, `[{"id":"2"},{"id":"3"}]`, string(res.Data["items"]))
+	assert.JSONEq(t, `{"name":"Item 3"}`, string(res.Data["item"]))
+}
+
+func TestCart_NotFound(t *testing.T) {
+	h, _ := newHandler(&mockedItemService{})
+
+	res := execute(t, h, `{ cart(id: "missing") { id } }`, nil)
+
+	assert.Equal(t, "null", string(res.Data["cart"]))
+	assert.Len(t, res.Errors, 1)
+	assert.Equal(t, errors.CartNotFoundCode, res.Errors[0].Extensions["code"])
+	assert.NotEmpty(t, res.Errors[0].Extensions["documentation_url"])
+	assert.Equal(t, []interface{}{"cart"}, res.Errors[0].Path)
+}
+
+func TestMutations(t *testing.T) {
+	h, csvc := newHandler(&mockedItemService{})
+	c, err := csvc.CreateCart(context.Background())
+	assert.Nil(t, err)
+	vars := map[string]interface{}{"cart": c.ID}
+
+	res := execute(t, h, `mutation($cart: ID!) { addItem(cartId: $cart, itemId: "1", quantity: 2) { items { id quantity } } }`, vars)
+	assert.Empty(t, res.Errors)
+	assert.JSONEq(t, `{"items":[{"id":"1","quantity":2}]}`, string(res.Data["addItem"]))
+
+	res = execute(t, h, `mutation($cart: ID!) { updateItem(cartId: $cart, lineId: "1", quantity: 5) { items { quantity } } }`, vars)
+	assert.Empty(t, res.Errors)
+	assert.JSONEq(t, `{"items":[{"quantity":5}]}`, string(res.Data["updateItem"]))
+
+	res = execute(t, h, `mutation($cart: ID!) { addItem(cartId: $cart, itemId: "1", quantity: 1) { id } }`, vars)
+	assert.Len(t, res.Errors, 1)
+	assert.Equal(t, errors.ItemAlreadyInCartCode, res.Errors[0].Extensions["code"])
+
+	res = execute(t, h, `mutation($cart: ID!) { removeItem(cartId: $cart, lineId: "1") { items { id } } }`, vars)
+	assert.Empty(t, res.Errors)
+	assert.JSONEq(t, `{"items":[]}`, string(res.Data["removeItem"]))
+
+	res = execute(t, h, `mutation($cart: ID!) { clearCart(cartId: $cart) { items { id } } }`, vars)
+	assert.Empty(t, res.Errors)
+	assert.JSONEq(t, `{"items":[]}`, string(res.Data["clearCart"]))
+}
+
+func TestMutations_Lines(t *testing.T) {
+	h, csvc := newHandler(&mockedItemService{})
+	c, err := csvc.CreateCart(context.Background())
+	assert.Nil(t, err)
+	vars := map[string]interface{}{"cart": c.ID}
+
+	for _, size := range []string{"S", "L"} {
+		res := execute(t, h, `mutation($cart: ID!, $size: String!) {
+			addItem(cartId: $cart, itemId: "1", quantity: 1, options: [{name: "size", value: $size}], note: "gift") { id }
+		}`, map[string]interface{}{"cart": c.ID, "size": size})
+		assert.Empty(t, res.Errors)
+	}
+
+	res := execute(t, h, `query($cart: ID!) { cart(id: $cart) { items { id lineId options { name value } note } } }`, vars)
+	assert.Empty(t, res.Errors)
+	got := struct {
+		Items []struct {
+			ID      string `json:"id"`
+			LineID  string `json:"lineId"`
+			Options []struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			} `json:"options"`
+			Note string `json:"note"`
+		} `json:"items"`
+	}{}
+	assert.Nil(t, json.Unmarshal(res.Data["cart"], &got))
+	assert.Len(t, got.Items, 2)
+	assert.Equal(t, "1", got.Items[0].ID)
+	assert.NotEqual(t, got.Items[0].LineID, got.Items[1].LineID)
+	assert.Equal(t, "gift", got.Items[0].Note)
+
+	large := got.Items[0]
+	if large.Options[0].Value != "L" {
+		large = got.Items[1]
+	}
+	vars["line"] = large.LineID
+	res = execute(t, h, `mutation($cart: ID!, $line: ID!) { updateItem(cartId: $cart, lineId: $line, quantity: 3) { items { lineId quantity } } }`, vars)
+	assert.Empty(t, res.Errors)
+	assert.Contains(t, string(res.Data["updateItem"]), `{"lineId":"`+large.LineID+`","quantity":3}`)
+
+	res = execute(t, h, `mutation($cart: ID!, $line: ID!) { removeItem(cartId: $cart, lineId: $line) { items { options { value } } } }`, vars)
+	assert.Empty(t, res.Errors)
+	assert.JSONEq(t, `{"items":[{"options":[{"value":"S"}]}]}`, string(res.Data["removeItem"]))
+}
+
+func TestBadBody(t *testing.T) {
+	h, _ := newHandler(&mockedItemService{})
+
+	req, err := http.NewRequest(http.MethodPost, "/graphql", bytes.NewReader([]byte(`{"query": 1}`)))
+	assert.Nil(t, err)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+var catalog = []item.Item{
+	{ID: "1", Name: "Item 1", Price: 10},
+	{ID: "2", Name: "Item 2", Price: 20},
+	{ID: "3", Name: "Item 3", Price: 30},
+}
+
+type mockedItemService struct {
+	mu       sync.Mutex
+	getCalls int
+	allCalls int
+}
+
+func (m *mockedItemService) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.getCalls, m.allCalls = 0, 0
+}
+
+func (m *mockedItemService) Health(ctx context.Context) error {
+	return nil
+}
+
+func (m *mockedItemService) GetItem(ctx context.Context, id string) (item.Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.getCalls++
+	for _, i := range catalog {
+		if i.ID == id {
+			return i, nil
+		}
+	}
+	return item.Item{}, errors.ServiceError{Code: errors.ItemNotFoundOnProviderCode}
+}
+
+func (m *mockedItemService) GetAllItems(ctx context.Context) ([]item.Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.allCalls++
+	return catalog, nil
+}
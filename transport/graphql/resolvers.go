@@ -0,0 +1,229 @@
+package transport
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+//rootResolver resolves the queries and mutations through the same services the REST endpoints use
+type rootResolver struct {
+	carts cart.Service
+	items item.Service
+}
+
+type itemFilter struct {
+	IDs      *[]graphql.ID
+	Name     *string
+	MinPrice *float64
+	MaxPrice *float64
+}
+
+func (f *itemFilter) matches(i item.Item) bool {
+	if f == nil {
+		return true
+	}
+	if f.IDs != nil {
+		found := false
+		for _, id := range *f.IDs {
+			if string(id) == i.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Name != nil && !strings.Contains(strings.ToLower(i.Name), strings.ToLower(*f.Name)) {
+		return false
+	}
+	if f.MinPrice != nil && float64(i.Price) < *f.MinPrice {
+		return false
+	}
+	if f.MaxPrice != nil && float64(i.Price) > *f.MaxPrice {
+		return false
+	}
+	return true
+}
+
+func (r *rootResolver) Cart(ctx context.Context, args struct{ ID graphql.ID }) (*cartResolver, error) {
+	c, err := r.carts.GetCart(ctx, string(args.ID))
+	return r.cartResult(ctx, c, err)
+}
+
+func (r *rootResolver) Items(ctx context.Context, args struct{ Filter *itemFilter }) ([]*itemResolver, error) {
+	all, err := r.items.GetAllItems(ctx)
+	if err != nil {
+		return nil, resolverErrorFromError(ctx, err)
+	}
+	//the whole catalog is known now, later lookups of this request don't need the provider
+	loaderFromContext(ctx, r.items).Prime(all)
+
+	res := []*itemResolver{}
+	for _, i := range all {
+		if args.Filter.matches(i) {
+			res = append(res, &itemResolver{item: i})
+		}
+	}
+	return res, nil
+}
+
+func (r *rootResolver) Item(ctx context.Context, args struct{ ID graphql.ID }) (*itemResolver, error) {
+	i, err := loaderFromContext(ctx, r.items).Load(ctx, string(args.ID))
+	if err != nil {
+		return nil, resolverErrorFromError(ctx, err)
+	}
+	return &itemResolver{item: i}, nil
+}
+
+type lineOption struct {
+	Name  string
+	Value string
+}
+
+type addItemArgs struct {
+	CartID   graphql.ID
+	ItemID   graphql.ID
+	Quantity int32
+	Options  *[]lineOption
+	Note     *string
+}
+
+type quantityArgs struct {
+	CartID   graphql.ID
+	LineID   graphql.ID
+	Quantity int32
+}
+
+type lineArgs struct {
+	CartID graphql.ID
+	LineID graphql.ID
+}
+
+func (r *rootResolver) AddItem(ctx context.Context, args addItemArgs) (*cartResolver, error) {
+	line := cart.Line{ItemID: string(args.ItemID)}
+	if args.Options != nil {
+		line.Options = make(map[string]string, len(*args.Options))
+		for _, o := range *args.Options {
+			line.Options[o.Name] = o.Value
+		}
+	}
+	if args.Note != nil {
+		line.Note = *args.Note
+	}
+	c, err := r.carts.AddLineToCart(ctx, string(args.CartID), line, int(args.Quantity))
+	return r.cartResult(ctx, c, err)
+}
+
+func (r *rootResolver) UpdateItem(ctx context.Context, args quantityArgs) (*cartResolver, error) {
+	c, err := r.carts.ModifyItemInCart(ctx, string(args.CartID), string(args.LineID), int(args.Quantity))
+	return r.cartResult(ctx, c, err)
+}
+
+func (r *rootResolver) RemoveItem(ctx context.Context, args lineArgs) (*cartResolver, error) {
+	c, err := r.carts.DeleteItemInCart(ctx, string(args.CartID), string(args.LineID))
+	return r.cartResult(ctx, c, err)
+}
+
+func (r *rootResolver) ClearCart(ctx context.Context, args struct{ CartID graphql.ID }) (*cartResolver, error) {
+	c, err := r.carts.DeleteAllItemsInCart(ctx, string(args.CartID))
+	return r.cartResult(ctx, c, err)
+}
+
+//cartResult turns the outcome of a cart service call into the result of a resolver
+func (r *rootResolver) cartResult(ctx context.Context, c cart.Cart, err error) (*cartResolver, error) {
+	if err != nil {
+		return nil, resolverErrorFromError(ctx, err)
+	}
+	return &cartResolver{cart: c}, nil
+}
+
+type cartResolver struct {
+	cart cart.Cart
+}
+
+func (r *cartResolver) ID() graphql.ID {
+	return graphql.ID(r.cart.ID)
+}
+
+func (r *cartResolver) Items() []*cartItemResolver {
+	res := make([]*cartItemResolver, 0, len(r.cart.Items))
+	for _, i := range r.cart.Items {
+		res = append(res, &cartItemResolver{item: i})
+	}
+	return res
+}
+
+type cartItemResolver struct {
+	item item.Item
+}
+
+func (r *cartItemResolver) ID() graphql.ID {
+	return graphql.ID(r.item.ID)
+}
+
+func (r *cartItemResolver) LineID() graphql.ID {
+	return graphql.ID(r.item.LineID)
+}
+
+func (r *cartItemResolver) Name() string {
+	return r.item.Name
+}
+
+func (r *cartItemResolver) Quantity() int32 {
+	return int32(r.item.Quantity)
+}
+
+func (r *cartItemResolver) Price() float64 {
+	return float64(r.item.Price)
+}
+
+func (r *cartItemResolver) Options() []*lineOptionResolver {
+	names := make([]string, 0, len(r.item.Options))
+	for name := range r.item.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	res := make([]*lineOptionResolver, 0, len(names))
+	for _, name := range names {
+		res = append(res, &lineOptionResolver{option: lineOption{Name: name, Value: r.item.Options[name]}})
+	}
+	return res
+}
+
+func (r *cartItemResolver) Note() string {
+	return r.item.Note
+}
+
+type lineOptionResolver struct {
+	option lineOption
+}
+
+func (r *lineOptionResolver) Name() string {
+	return r.option.Name
+}
+
+func (r *lineOptionResolver) Value() string {
+	return r.option.Value
+}
+
+type itemResolver struct {
+	item item.Item
+}
+
+func (r *itemResolver) ID() graphql.ID {
+	return graphql.ID(r.item.ID)
+}
+
+func (r *itemResolver) Name() string {
+	return r.item.Name
+}
+
+func (r *itemResolver) Price() float64 {
+	return float64(r.item.Price)
+}
@@ -0,0 +1,79 @@
+package transport
+
+import (
+	"context"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/transport/grpc/pb"
+)
+
+//cartServer exposes cart.Service over gRPC
+type cartServer struct {
+	pb.UnimplementedCartServiceServer
+	svc cart.Service
+}
+
+func (s *cartServer) CreateCart(ctx context.Context, req *pb.CreateCartRequest) (*pb.CartResponse, error) {
+	return cartResponse(s.svc.CreateCart(ctx))
+}
+
+func (s *cartServer) GetCart(ctx context.Context, req *pb.GetCartRequest) (*pb.CartResponse, error) {
+	return cartResponse(s.svc.GetCart(ctx, req.GetCartId()))
+}
+
+func (s *cartServer) DeleteCart(ctx context.Context, req *pb.DeleteCartRequest) (*pb.DeleteCartResponse, error) {
+	if err := s.svc.DeleteCart(ctx, req.GetCartId()); err != nil {
+		return nil, statusFromError(err)
+	}
+	return &pb.DeleteCartResponse{}, nil
+}
+
+func (s *cartServer) AddItem(ctx context.Context, req *pb.AddItemRequest) (*pb.CartResponse, error) {
+	line := cart.Line{ItemID: req.GetItemId(), Options: req.GetOptions(), Note: req.GetNote()}
+	return cartResponse(s.svc.AddLineToCart(ctx, req.GetCartId(), line, int(req.GetQuantity())))
+}
+
+func (s *cartServer) UpdateItemQuantity(ctx context.Context, req *pb.UpdateItemQuantityRequest) (*pb.CartResponse, error) {
+	return cartResponse(s.svc.ModifyItemInCart(ctx, req.GetCartId(), req.GetLineId(), int(req.GetQuantity())))
+}
+
+func (s *cartServer) RemoveItem(ctx context.Context, req *pb.RemoveItemRequest) (*pb.CartResponse, error) {
+	return cartResponse(s.svc.DeleteItemInCart(ctx, req.GetCartId(), req.GetLineId()))
+}
+
+func (s *cartServer) RemoveAllItems(ctx context.Context, req *pb.RemoveAllItemsRequest) (*pb.CartResponse, error) {
+	return cartResponse(s.svc.DeleteAllItemsInCart(ctx, req.GetCartId()))
+}
+
+func cartResponse(c cart.Cart, err error) (*pb.CartResponse, error) {
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return &pb.CartResponse{
+		Cart: cartToProto(c),
+	}, nil
+}
+
+func cartToProto(c cart.Cart) *pb.Cart {
+	res := &pb.Cart{
+		Id:    c.ID,
+		Items: []*pb.Item{},
+	}
+	for _, i := range c.Items {
+		res.Items = append(res.Items, itemToProto(i))
+	}
+	return res
+}
+
+func itemToProto(i item.Item) *pb.Item {
+	return &pb.Item{
+		Id:       i.ID,
+		Name:     i.Name,
+		Quantity: int32(i.Quantity),
+		Price:    i.Price,
+		LineId:   i.LineID,
+		Options:  i.Options,
+		Note:     i.Note,
+	}
+}
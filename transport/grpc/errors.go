@@ -0,0 +1,76 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+
+	serviceErrors "github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/response"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//ErrorDomain identifies the errors of this API in the ErrorInfo details
+const ErrorDomain = "cart-api"
+
+//codeFromError maps the ServiceError codes to gRPC codes, the same way the HTTP transport maps them to statuses
+func codeFromError(err error) codes.Code {
+	sErr := serviceErrors.ServiceError{}
+	if !errors.As(err, &sErr) {
+		return codes.Internal
+	}
+	switch sErr.Code {
+	case serviceErrors.CartNotFoundCode, serviceErrors.ItemNotFoundCode, serviceErrors.ItemNotFoundOnProviderCode, serviceErrors.WebhookNotFoundCode,
+		serviceErrors.ListNotFoundCode, serviceErrors.ListItemNotFoundCode, serviceErrors.TenantNotFoundCode:
+		return codes.NotFound
+	case serviceErrors.ItemAlreadyInCartCode:
+		return codes.AlreadyExists
+	case serviceErrors.ItemOperationsFailedCode, serviceErrors.PriceChangedCode, serviceErrors.InsufficientStockCode:
+		return codes.FailedPrecondition
+	case serviceErrors.ValidationErrorCode:
+		return codes.InvalidArgument
+	case serviceErrors.UnauthorizedCode:
+		return codes.Unauthenticated
+	case serviceErrors.ExternalApiErrorCode, serviceErrors.CacheErrorCode:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+//statusFromError gives the gRPC status answering err. The error code travels in an ErrorInfo detail
+//and invalid fields in a BadRequest detail
+func statusFromError(err error) error {
+	vm := response.ErrorFromError("", err)
+	st := status.New(codeFromError(err), vm.Description)
+
+	info := &errdetails.ErrorInfo{
+		Reason:   vm.Code,
+		Domain:   ErrorDomain,
+		Metadata: map[string]string{},
+	}
+	for k, v := range vm.Details {
+		info.Metadata[k] = fmt.Sprint(v)
+	}
+	if vm.DocumentationURL != "" {
+		info.Metadata["documentation_url"] = vm.DocumentationURL
+	}
+	withDetails, dErr := st.WithDetails(info)
+	if dErr != nil {
+		return st.Err()
+	}
+	if len(vm.Fields) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, f := range vm.Fields {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       f.Field,
+				Description: f.Description,
+			})
+		}
+		if withFields, fErr := withDetails.WithDetails(br); fErr == nil {
+			withDetails = withFields
+		}
+	}
+	return withDetails.Err()
+}
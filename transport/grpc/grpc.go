@@ -0,0 +1,28 @@
+package transport
+
+import (
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/health"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/transport/grpc/pb"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+//NewGRPCServer gives a gRPC server exposing the cart, item and standard health services, calls working within
+//the tenant of tenants they tell. Server reflection is enabled so tools like grpcurl can be used without the proto files
+func NewGRPCServer(log logger.Logger, tenants *tenant.Registry, hsvc health.Service, csvc cart.Service, isvc item.Service) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(unaryInterceptor(log, tenants)),
+		grpc.StreamInterceptor(streamInterceptor(log, tenants)),
+	)
+
+	pb.RegisterCartServiceServer(srv, &cartServer{svc: csvc})
+	pb.RegisterItemServiceServer(srv, &itemServer{svc: isvc})
+	healthpb.RegisterHealthServer(srv, &healthServer{svc: hsvc})
+	reflection.Register(srv)
+	return srv
+}
@@ -0,0 +1,244 @@
+package transport_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/cache"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/correlation"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/errors"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/health"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	transport "github.com/eduardohoraciosanto/bootcamp-feature-driven/transport/grpc"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/transport/grpc/pb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newClientConn(t *testing.T, isvc item.Service) *grpc.ClientConn {
+	l := logger.NewLogger("grpc test", false)
+	c := cache.NewNamespacedCache(cache.NewMemoryCache(), tenant.Namespace)
+	csvc := cart.NewCartService("test", l, c, isvc)
+	hsvc := health.NewService(l)
+	hsvc.Register(health.NewChecker("cache", cache.Health(c)))
+	hsvc.Register(health.NewChecker("external", isvc.Health))
+	tenants, err := tenant.NewRegistry(
+		tenant.Tenant{ID: "acme", APIKeys: []string{"acme-key"}},
+		tenant.Tenant{ID: "globex"},
+	)
+	assert.Nil(t, err)
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := transport.NewGRPCServer(l, tenants, hsvc, csvc, isvc)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithInsecure(),
+	)
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestCartService_AddItem(t *testing.T) {
+	client := pb.NewCartServiceClient(newClientConn(t, &mockedItemService{}))
+	ctx := context.Background()
+
+	created, err := client.CreateCart(ctx, &pb.CreateCartRequest{})
+	assert.Nil(t, err)
+
+	res, err := client.AddItem(ctx, &pb.AddItemRequest{
+		CartId:   created.GetCart().GetId(),
+		ItemId:   "1",
+		Quantity: 2,
+	})
+	assert.Nil(t, err)
+	assert.Len(t, res.GetCart().GetItems(), 1)
+	assert.Equal(t, "1", res.GetCart().GetItems()[0].GetId())
+	assert.Equal(t, int32(2), res.GetCart().GetItems()[0].GetQuantity())
+
+	_, err = client.AddItem(ctx, &pb.AddItemRequest{
+		CartId:   created.GetCart().GetId(),
+		ItemId:   "1",
+		Quantity: 1,
+	})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+}
+
+func TestCartService_NotFound(t *testing.T) {
+	client := pb.NewCartServiceClient(newClientConn(t, &mockedItemService{}))
+
+	_, err := client.GetCart(context.Background(), &pb.GetCartRequest{CartId: "missing"})
+
+	st := status.Convert(err)
+	assert.Equal(t, codes.NotFound, st.Code())
+	assert.NotEmpty(t, st.Message())
+	assert.Len(t, st.Details(), 1)
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	assert.True(t, ok)
+	assert.Equal(t, errors.CartNotFoundCode, info.GetReason())
+	assert.Equal(t, transport.ErrorDomain, info.GetDomain())
+	assert.NotEmpty(t, info.GetMetadata()["documentation_url"])
+}
+
+func TestItemService_Unavailable(t *testing.T) {
+	client := pb.NewItemServiceClient(newClientConn(t, &mockedItemService{shouldFail: true}))
+
+	_, err := client.ListItems(context.Background(), &pb.ListItemsRequest{})
+
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestHealth_Check(t *testing.T) {
+	client := healthpb.NewHealthClient(newClientConn(t, &mockedItemService{shouldFail: true}))
+	ctx := context.Background()
+
+	res, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "cache"})
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.GetStatus())
+
+	res, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "external"})
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, res.GetStatus())
+
+	res, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, res.GetStatus())
+
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestCorrelationID(t *testing.T) {
+	client := pb.NewCartServiceClient(newClientConn(t, &mockedItemService{}))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), correlation.Header, "my-correlation-id")
+	header := metadata.MD{}
+	_, err := client.CreateCart(ctx, &pb.CreateCartRequest{}, grpc.Header(&header))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"my-correlation-id"}, header.Get(correlation.Header))
+
+	header = metadata.MD{}
+	_, err = client.CreateCart(context.Background(), &pb.CreateCartRequest{}, grpc.Header(&header))
+	assert.Nil(t, err)
+	assert.Len(t, header.Get(correlation.Header), 1)
+	assert.NotEmpty(t, header.Get(correlation.Header)[0])
+}
+
+func TestTenantsAreApart(t *testing.T) {
+	client := pb.NewCartServiceClient(newClientConn(t, &mockedItemService{}))
+	acme := metadata.AppendToOutgoingContext(context.Background(), tenant.APIKeyHeader, "acme-key")
+	globex := metadata.AppendToOutgoingContext(context.Background(), tenant.Header, "globex")
+
+	created, err := client.CreateCart(acme, &pb.CreateCartRequest{})
+	assert.Nil(t, err)
+	_, err = client.GetCart(acme, &pb.GetCartRequest{CartId: created.GetCart().GetId()})
+	assert.Nil(t, err)
+
+	_, err = client.GetCart(globex, &pb.GetCartRequest{CartId: created.GetCart().GetId()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.GetCart(context.Background(), &pb.GetCartRequest{CartId: created.GetCart().GetId()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	unknown := metadata.AppendToOutgoingContext(context.Background(), tenant.Header, "initech")
+	_, err = client.CreateCart(unknown, &pb.CreateCartRequest{})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	wrongKey := metadata.AppendToOutgoingContext(globex, tenant.APIKeyHeader, "acme-key")
+	_, err = client.CreateCart(wrongKey, &pb.CreateCartRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	//tenants with API keys can't be named without one
+	named := metadata.AppendToOutgoingContext(context.Background(), tenant.Header, "acme")
+	_, err = client.GetCart(named, &pb.GetCartRequest{CartId: created.GetCart().GetId()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+type mockedItemService struct {
+	shouldFail bool
+}
+
+func (m *mockedItemService) Health(ctx context.Context) error {
+	if m.shouldFail {
+		return fmt.Errorf("provider down")
+	}
+	return nil
+}
+
+func (m *mockedItemService) GetItem(ctx context.Context, id string) (item.Item, error) {
+	if m.shouldFail {
+		return item.Item{}, errors.ServiceError{Code: errors.ExternalApiErrorCode}
+	}
+	return item.Item{
+		ID:    id,
+		Name:  "Some Item",
+		Price: 10,
+	}, nil
+}
+
+func (m *mockedItemService) GetAllItems(ctx context.Context) ([]item.Item, error) {
+	if m.shouldFail {
+		return nil, errors.ServiceError{Code: errors.ExternalApiErrorCode}
+	}
+	return []item.Item{
+		{ID: "1", Name: "Some Item", Price: 10},
+	}, nil
+}
+
+func TestCartService_Lines(t *testing.T) {
+	client := pb.NewCartServiceClient(newClientConn(t, &mockedItemService{}))
+	ctx := context.Background()
+
+	created, err := client.CreateCart(ctx, &pb.CreateCartRequest{})
+	assert.Nil(t, err)
+	cartID := created.GetCart().GetId()
+	for _, size := range []string{"S", "L"} {
+		_, err = client.AddItem(ctx, &pb.AddItemRequest{
+			CartId:   cartID,
+			ItemId:   "1",
+			Quantity: 1,
+			Options:  map[string]string{"size": size},
+			Note:     "gift",
+		})
+		assert.Nil(t, err)
+	}
+
+	res, err := client.GetCart(ctx, &pb.GetCartRequest{CartId: cartID})
+	assert.Nil(t, err)
+	lines := res.GetCart().GetItems()
+	assert.Len(t, lines, 2)
+	assert.NotEqual(t, lines[0].GetLineId(), lines[1].GetLineId())
+	assert.Equal(t, "gift", lines[0].GetNote())
+
+	large := lines[0]
+	if large.GetOptions()["size"] != "L" {
+		large = lines[1]
+	}
+	res, err = client.UpdateItemQuantity(ctx, &pb.UpdateItemQuantityRequest{CartId: cartID, LineId: large.GetLineId(), Quantity: 3})
+	assert.Nil(t, err)
+	for _, l := range res.GetCart().GetItems() {
+		if l.GetLineId() == large.GetLineId() {
+			assert.Equal(t, int32(3), l.GetQuantity())
+		} else {
+			assert.Equal(t, int32(1), l.GetQuantity())
+		}
+	}
+
+	res, err = client.RemoveItem(ctx, &pb.RemoveItemRequest{CartId: cartID, LineId: large.GetLineId()})
+	assert.Nil(t, err)
+	assert.Len(t, res.GetCart().GetItems(), 1)
+	assert.Equal(t, "S", res.GetCart().GetItems()[0].GetOptions()["size"])
+}
@@ -0,0 +1,77 @@
+package transport
+
+import (
+	"context"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/health"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/transport/grpc/pb"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+//WatchInterval is how often Watch runs the checks looking for a change
+var WatchInterval = 5 * time.Second
+
+//healthServer implements the standard gRPC health protocol on top of health.Service.
+//The empty service and the API services are serving when the readiness is, every dependency
+//check can also be asked for by its name, such as cache
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	svc health.Service
+}
+
+func (s *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	st, known := s.status(ctx, req.GetService())
+	if !known {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.GetService())
+	}
+	return &healthpb.HealthCheckResponse{Status: st}, nil
+}
+
+func (s *healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	for {
+		st, known := s.status(stream.Context(), req.GetService())
+		if !known {
+			st = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+		if st != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+			last = st
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+//status gives the serving status of service, known is false when there is no such service
+func (s *healthServer) status(ctx context.Context, service string) (st healthpb.HealthCheckResponse_ServingStatus, known bool) {
+	report := s.svc.Readiness(ctx)
+	switch service {
+	case "", pb.CartService_ServiceDesc.ServiceName, pb.ItemService_ServiceDesc.ServiceName:
+		return servingStatus(report.Alive), true
+	}
+	for _, h := range report.Services {
+		if h.Name == service {
+			return servingStatus(h.Alive), true
+		}
+	}
+	return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, false
+}
+
+func servingStatus(alive bool) healthpb.HealthCheckResponse_ServingStatus {
+	if alive {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
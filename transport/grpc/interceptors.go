@@ -0,0 +1,101 @@
+package transport
+
+import (
+	"context"
+	"time"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/correlation"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/logger"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+//withCorrelationID keeps the correlation ID received in the metadata, or a new one, in the context
+//and sends it back in the response header, as the HTTP transport does
+func withCorrelationID(ctx context.Context) context.Context {
+	id := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get(correlation.Header); len(vals) > 0 {
+			id = vals[0]
+		}
+	}
+	ctx, id = correlation.WithID(ctx, id)
+	grpc.SetHeader(ctx, metadata.Pairs(correlation.Header, id))
+	return ctx
+}
+
+//withTenant keeps the tenant told by the metadata in the context, as the HTTP transport does.
+//The host is the authority the call was sent to
+func withTenant(ctx context.Context, reg *tenant.Registry) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if vals := md.Get(key); len(vals) > 0 {
+			return vals[0]
+		}
+		return ""
+	}
+	t, ok, err := reg.Resolve(first(tenant.APIKeyHeader), first(tenant.Header), first(":authority"))
+	if err != nil {
+		return ctx, statusFromError(err)
+	}
+	if ok {
+		ctx = tenant.NewContext(ctx, t)
+	}
+	return ctx, nil
+}
+
+//unaryInterceptor sets the correlation ID and the tenant, and logs every call once done
+func unaryInterceptor(log logger.Logger, reg *tenant.Registry) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = withCorrelationID(ctx)
+		start := time.Now()
+		ctx, err := withTenant(ctx, reg)
+		if err != nil {
+			logCall(ctx, log, info.FullMethod, start, err)
+			return nil, err
+		}
+		res, err := handler(ctx, req)
+		logCall(ctx, log, info.FullMethod, start, err)
+		return res, err
+	}
+}
+
+//streamInterceptor sets the correlation ID and the tenant, and logs every stream once closed
+func streamInterceptor(log logger.Logger, reg *tenant.Registry) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := withCorrelationID(ss.Context())
+		start := time.Now()
+		ctx, err := withTenant(ctx, reg)
+		if err != nil {
+			logCall(ctx, log, info.FullMethod, start, err)
+			return err
+		}
+		err = handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, log, info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logCall(ctx context.Context, log logger.Logger, method string, start time.Time, err error) {
+	l := log.
+		WithField("method", method).
+		WithField("code", status.Code(err).String()).
+		WithField("duration_ms", time.Since(start).Milliseconds())
+	if err != nil {
+		l.WithError(err).Warn(ctx, "gRPC call failed")
+		return
+	}
+	l.Info(ctx, "gRPC call")
+}
+
+//contextStream replaces the context of a stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
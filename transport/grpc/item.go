@@ -0,0 +1,38 @@
+package transport
+
+import (
+	"context"
+
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/transport/grpc/pb"
+)
+
+//itemServer exposes item.Service over gRPC
+type itemServer struct {
+	pb.UnimplementedItemServiceServer
+	svc item.Service
+}
+
+func (s *itemServer) ListItems(ctx context.Context, req *pb.ListItemsRequest) (*pb.ListItemsResponse, error) {
+	items, err := s.svc.GetAllItems(ctx)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	res := &pb.ListItemsResponse{
+		Items: []*pb.Item{},
+	}
+	for _, i := range items {
+		res.Items = append(res.Items, itemToProto(i))
+	}
+	return res, nil
+}
+
+func (s *itemServer) GetItem(ctx context.Context, req *pb.GetItemRequest) (*pb.ItemResponse, error) {
+	i, err := s.svc.GetItem(ctx, req.GetItemId())
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return &pb.ItemResponse{
+		Item: itemToProto(i),
+	}, nil
+}
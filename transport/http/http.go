@@ -1,18 +1,25 @@
 package transport
 
 import (
-	"context"
 	"net/http"
 
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/correlation"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/metrics"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tenant"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/internal/tracing"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/cart"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/collab"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/currency"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/health"
 	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/item"
-	"github.com/google/uuid"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/webhook"
+	"github.com/eduardohoraciosanto/bootcamp-feature-driven/pkg/wishlist"
+	graphqltransport "github.com/eduardohoraciosanto/bootcamp-feature-driven/transport/graphql"
 
 	muxtrace "gopkg.in/DataDog/dd-trace-go.v1/contrib/gorilla/mux"
 )
 
-func NewHTTPRouter(hsvc health.Service, csvc cart.Service, isvc item.Service) *muxtrace.Router {
+func NewHTTPRouter(hsvc health.Service, csvc cart.Service, isvc item.Service, wsvc webhook.Service, stream *cart.Stream, ssvc collab.Service, lsvc wishlist.Service, conv *currency.Converter, tenants *tenant.Registry) *muxtrace.Router {
 
 	hc := health.Handler{
 		Service: hsvc,
@@ -20,50 +27,103 @@ func NewHTTPRouter(hsvc health.Service, csvc cart.Service, isvc item.Service) *m
 
 	cc := cart.Handler{
 		Service: csvc,
+		Stream:  stream,
 	}
 
 	ic := item.Handler{
 		Service: isvc,
 	}
 
+	wc := webhook.Handler{
+		Service: wsvc,
+	}
+
+	sc := collab.Handler{
+		Service: ssvc,
+		Carts:   csvc,
+	}
+
+	lc := wishlist.Handler{
+		Service: lsvc,
+	}
+
 	r := muxtrace.NewRouter()
+	r.Use(tracing.Middleware)
 	r.Use(correlationIDMiddleware)
+	//the currency of the tenant is the default one, so tenants are resolved first
+	r.Use(tenant.Middleware(tenants))
+	r.Use(currency.Middleware(conv))
 
 	r.HandleFunc("/health", hc.Health).Methods(http.MethodGet)
+	r.HandleFunc("/livez", hc.Liveness).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", hc.Readiness).Methods(http.MethodGet)
+	r.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
 
 	//Cart Endpoints
 	r.HandleFunc("/cart", cc.CreateCart).Methods(http.MethodPost)
 	r.HandleFunc("/cart/{cart_id}", cc.GetCart).Methods(http.MethodGet)
 	r.HandleFunc("/cart/{cart_id}", cc.DeleteCart).Methods(http.MethodDelete)
+	r.HandleFunc("/cart/{cart_id}/events", cc.StreamEvents).Methods(http.MethodGet)
+	r.HandleFunc("/cart/{cart_id}/clone", cc.CloneCart).Methods(http.MethodPost)
+	r.HandleFunc("/cart/{cart_id}/share", cc.ShareCart).Methods(http.MethodPost)
+	r.HandleFunc("/cart/{cart_id}/shipping-address", cc.SetShippingAddress).Methods(http.MethodPut)
+	r.HandleFunc("/cart/{cart_id}/shipping-options", cc.GetShippingOptions).Methods(http.MethodGet)
+	r.HandleFunc("/cart/{cart_id}/shipping-option", cc.SelectShippingOption).Methods(http.MethodPut)
+	r.HandleFunc("/cart/{cart_id}/price-lock", cc.LockPrices).Methods(http.MethodPost)
+	r.HandleFunc("/cart/{cart_id}/checkout", cc.Checkout).Methods(http.MethodPost)
+
+	//Shared Carts
+	r.HandleFunc("/shared-carts/{token}", cc.GetSharedCart).Methods(http.MethodGet)
+	r.HandleFunc("/shared-carts/{token}/import", cc.ImportSharedCart).Methods(http.MethodPost)
+
+	//Collaborative Sessions on Cart
+	r.HandleFunc("/cart/{cart_id}/session", sc.IssueToken).Methods(http.MethodPost)
+	r.HandleFunc("/cart/{cart_id}/session", sc.Connect).Methods(http.MethodGet)
 
 	//Item Operations on Cart
 	r.HandleFunc("/cart/{cart_id}/item", cc.AddItem).Methods(http.MethodPost)
-	r.HandleFunc("/cart/{cart_id}/item/{item_id:[0-9]+}", cc.UpdateQuantity).Methods(http.MethodPut)
+	//either a line ID or an item ID, standing for the line of the item without options nor note or its only line
+	r.HandleFunc("/cart/{cart_id}/item/{line_id}", cc.UpdateQuantity).Methods(http.MethodPut)
 	r.HandleFunc("/cart/{cart_id}/item/all", cc.RemoveAllItems).Methods(http.MethodDelete)
-	r.HandleFunc("/cart/{cart_id}/item/{item_id:[0-9]+}", cc.RemoveItem).Methods(http.MethodDelete)
+	r.HandleFunc("/cart/{cart_id}/item/{line_id}", cc.RemoveItem).Methods(http.MethodDelete)
+	r.HandleFunc("/cart/{cart_id}/items", cc.ApplyItemOperations).Methods(http.MethodPatch)
 
 	//Items Endpoints
 	r.HandleFunc("/items/available", ic.GetAllItems).Methods(http.MethodGet)
 	r.HandleFunc("/items/{item_id}", ic.GetItem).Methods(http.MethodGet)
 
+	//List Endpoints
+	r.HandleFunc("/lists", lc.CreateList).Methods(http.MethodPost)
+	r.HandleFunc("/lists/{list_id}", lc.GetList).Methods(http.MethodGet)
+	r.HandleFunc("/lists/{list_id}", lc.DeleteList).Methods(http.MethodDelete)
+	r.HandleFunc("/lists/{list_id}/items", lc.AddItem).Methods(http.MethodPost)
+	r.HandleFunc("/lists/{list_id}/items/{item_id}", lc.RemoveItem).Methods(http.MethodDelete)
+	r.HandleFunc("/lists/{list_id}/move-from-cart", lc.MoveFromCart).Methods(http.MethodPost)
+	r.HandleFunc("/lists/{list_id}/move-to-cart", lc.MoveToCart).Methods(http.MethodPost)
+
+	//Webhook Endpoints
+	r.HandleFunc("/webhooks", wc.CreateSubscription).Methods(http.MethodPost)
+	r.HandleFunc("/webhooks", wc.GetSubscriptions).Methods(http.MethodGet)
+	r.HandleFunc("/webhooks/{webhook_id}", wc.GetSubscription).Methods(http.MethodGet)
+	r.HandleFunc("/webhooks/{webhook_id}", wc.DeleteSubscription).Methods(http.MethodDelete)
+	r.HandleFunc("/webhooks/{webhook_id}/deliveries", wc.GetDeliveryLog).Methods(http.MethodGet)
+	r.HandleFunc("/webhooks/{webhook_id}/dead-letters", wc.GetDeadLetters).Methods(http.MethodGet)
+
+	//GraphQL Endpoint
+	r.Handle("/graphql", graphqltransport.NewHandler(csvc, isvc)).Methods(http.MethodPost)
+
 	r.PathPrefix("/swagger").Handler(http.StripPrefix("/swagger", http.FileServer(http.Dir("./swagger"))))
 	return r
 }
 
 func correlationIDMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-		id := r.Header.Get("X-Correlation-Id")
-		if id == "" {
-			// generate new version 4 uuid
-			id = uuid.New().String()
-		}
-		// set the id to the request context
-		ctx = context.WithValue(ctx, "correlation_id", id)
+		// set the id to the request context, generating one when the client sent none
+		ctx, id := correlation.WithID(r.Context(), r.Header.Get(correlation.Header))
 		r = r.WithContext(ctx)
 
 		// set the response header
-		w.Header().Set("X-Correlation-Id", id)
+		w.Header().Set(correlation.Header, id)
 		next.ServeHTTP(w, r)
 	})
 }